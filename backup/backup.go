@@ -0,0 +1,401 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package backup creates and restores archives of the SQLite database and the uploaded files while the blog is running
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	// FormatVersion is the version of the archive layout
+	FormatVersion = 1
+
+	manifestName  = "manifest.json"
+	databaseName  = "goblog.sqlite"
+	filesDir      = "files"
+	archivePrefix = "goblog-backup-"
+	archiveSuffix = ".tar.gz"
+	timeLayout    = "20060102-150405"
+
+	// pagesPerStep is the number of database pages copied at once; between the steps the database is writable
+	pagesPerStep = 256
+)
+
+// ErrChecksum is returned if the content of an archive does not match its checksums
+var ErrChecksum = errors.New("the checksum of the backup does not match")
+
+// Manifest describes the content of a backup archive; it is the last entry of the archive
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int       `json:"schema_version"`
+
+	// Checksums contains the hex encoded SHA-256 checksum of every entry by its name
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Service creates the backups of the SQLite database and the uploaded files in the directory;
+// only the newest Keep backups are kept, all are kept if Keep is 0
+type Service struct {
+	DB           *sql.DB
+	FileLocation string
+	Directory    string
+	Keep         int
+}
+
+// Create writes a new backup archive along with a file containing the SHA-256 checksum of the archive;
+// it returns the path of the archive
+func (s *Service) Create() (string, error) {
+	if err := os.MkdirAll(s.Directory, 0750); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	snapshot, err := os.CreateTemp(s.Directory, ".snapshot-*.sqlite")
+
+	if err != nil {
+		return "", err
+	}
+
+	snapshot.Close()
+
+	defer os.Remove(snapshot.Name())
+
+	if err := Snapshot(s.DB, snapshot.Name()); err != nil {
+		return "", err
+	}
+
+	schemaVersion, err := schemaVersion(snapshot.Name())
+
+	if err != nil {
+		return "", err
+	}
+
+	name := archivePrefix + now.Format(timeLayout) + archiveSuffix
+	archive := filepath.Join(s.Directory, name)
+
+	tmp, err := os.CreateTemp(s.Directory, ".archive-*.tmp")
+
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.New()
+
+	err = writeArchive(io.MultiWriter(tmp, sum), snapshot.Name(), s.FileLocation, s.Directory, Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     now,
+		SchemaVersion: schemaVersion,
+	})
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), archive); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(archive+".sha256", []byte(hex.EncodeToString(sum.Sum(nil))+"  "+name+"\n"), 0640); err != nil {
+		return "", err
+	}
+
+	if _, err := s.Prune(); err != nil {
+		logger.Log.Errorf("could not remove the old backups %v", err)
+	}
+
+	return archive, nil
+}
+
+// InitSchedule creates a backup on every tick
+func (s *Service) InitSchedule(ticker *time.Ticker) {
+	go func() {
+		for range ticker.C {
+			archive, err := s.Create()
+
+			if err != nil {
+				logger.Log.Errorf("error while creating the scheduled backup %v", err)
+				continue
+			}
+
+			logger.Log.Infof("the scheduled backup %s was created", archive)
+		}
+	}()
+}
+
+// Prune removes the oldest backups exceeding the number of backups to keep; the removed archives are returned
+func (s *Service) Prune() ([]string, error) {
+	if s.Keep <= 0 {
+		return nil, nil
+	}
+
+	archives, err := List(s.Directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archives) <= s.Keep {
+		return nil, nil
+	}
+
+	removed := archives[:len(archives)-s.Keep]
+
+	for _, a := range removed {
+		if err := os.Remove(a); err != nil {
+			return nil, err
+		}
+
+		if err := os.Remove(a + ".sha256"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+// List returns the backup archives in the directory, the oldest first
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []string
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), archivePrefix) || !strings.HasSuffix(e.Name(), archiveSuffix) {
+			continue
+		}
+
+		archives = append(archives, filepath.Join(dir, e.Name()))
+	}
+
+	// the names contain the time of the backup
+	sort.Strings(archives)
+
+	return archives, nil
+}
+
+// Snapshot copies the database to the file dest with the online backup API of SQLite; the database stays usable
+// while it is copied, changes made during the copy restart it
+func Snapshot(db *sql.DB, dest string) error {
+	ctx := context.Background()
+
+	src, err := db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	destDB, err := sql.Open(database.SQLite, dest)
+
+	if err != nil {
+		return err
+	}
+
+	defer destDB.Close()
+
+	dst, err := destDB.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer dst.Close()
+
+	return dst.Raw(func(dc interface{}) error {
+		return src.Raw(func(sc interface{}) error {
+			destConn, ok := dc.(*sqlite3.SQLiteConn)
+			srcConn, ok2 := sc.(*sqlite3.SQLiteConn)
+
+			if !ok || !ok2 {
+				return errors.New("the online backup is only supported for SQLite databases")
+			}
+
+			b, err := destConn.Backup("main", srcConn, "main")
+
+			if err != nil {
+				return err
+			}
+
+			for {
+				done, err := b.Step(pagesPerStep)
+
+				if err != nil {
+					b.Finish()
+					return err
+				}
+
+				if done {
+					break
+				}
+
+				// give writers a chance to access the database
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			return b.Finish()
+		})
+	})
+}
+
+func schemaVersion(file string) (int, error) {
+	db, err := sql.Open(database.SQLite, file)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer db.Close()
+
+	var version sql.NullInt64
+
+	if err := db.QueryRow("SELECT max(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// writeArchive writes the database and the files in the file location except the backup directory to the archive
+func writeArchive(w io.Writer, dbFile, fileLocation, backupDir string, m Manifest) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	m.Checksums = make(map[string]string)
+
+	if err := addFile(tw, dbFile, databaseName, m.Checksums); err != nil {
+		return err
+	}
+
+	if len(fileLocation) > 0 {
+		backupDir, err := filepath.Abs(backupDir)
+
+		if err != nil {
+			return err
+		}
+
+		err = filepath.WalkDir(fileLocation, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if abs, err := filepath.Abs(p); err == nil && abs == backupDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(fileLocation, p)
+
+			if err != nil {
+				return err
+			}
+
+			return addFile(tw, p, path.Join(filesDir, filepath.ToSlash(rel)), m.Checksums)
+		})
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0640,
+		Size:    int64(len(b)),
+		ModTime: m.CreatedAt,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func addFile(tw *tar.Writer, file, name string, checksums map[string]string) error {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0640,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}); err != nil {
+		return err
+	}
+
+	sum := sha256.New()
+
+	if _, err := io.CopyN(tw, io.TeeReader(f, sum), fi.Size()); err != nil {
+		return err
+	}
+
+	checksums[name] = hex.EncodeToString(sum.Sum(nil))
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+func setup(t *testing.T) (string, *sql.DB) {
+	logger.InitLogger(io.Discard, "Debug")
+
+	dir := t.TempDir()
+
+	db, err := database.SQLiteConfig{File: filepath.Join(dir, "goblog.sqlite")}.Open()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err := database.InitTables(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("INSERT INTO category (name, slug, last_modified, user_id) VALUES('go', 'go', datetime('now'), 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "files", "2018"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{"a.txt": "alice", "2018/b.txt": "bob"} {
+		if err := os.WriteFile(filepath.Join(dir, "files", name), []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, db
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir, db := setup(t)
+
+	s := &Service{
+		DB:           db,
+		FileLocation: filepath.Join(dir, "files"),
+		Directory:    filepath.Join(dir, "backups"),
+		Keep:         2,
+	}
+
+	archive, err := s.Create()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Verify(archive)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Checksums) != 3 || m.SchemaVersion < 1 {
+		t.Fatalf("unexpected manifest %v", m)
+	}
+
+	// changes after the backup are discarded by the restore
+	if _, err := db.Exec("INSERT INTO category (name, slug, last_modified, user_id) VALUES('rust', 'rust', datetime('now'), 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "files", "2018", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	result, err := Restore(archive, filepath.Join(dir, "goblog.sqlite"), s.FileLocation)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(result.PreviousDatabase) || !exists(result.PreviousFiles) {
+		t.Errorf("expected the replaced database and files to be kept, but got %v", result)
+	}
+
+	restored, err := database.SQLiteConfig{File: filepath.Join(dir, "goblog.sqlite")}.Open()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer restored.Close()
+
+	var count int
+
+	if err := restored.QueryRow("SELECT count(*) FROM category").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected the category of the backup only, but got %d categories", count)
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dir, "files", "2018", "b.txt")); err != nil || string(b) != "bob" {
+		t.Errorf("expected the removed file to be restored, but got %s %v", b, err)
+	}
+}
+
+func TestVerifyTamperedBackup(t *testing.T) {
+	dir, db := setup(t)
+
+	s := &Service{
+		DB:        db,
+		Directory: filepath.Join(dir, "backups"),
+	}
+
+	archive, err := s.Create()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(archive)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b[len(b)/2] ^= 0xff
+
+	if err := os.WriteFile(archive, b, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(archive); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected the error ErrChecksum, but got %v", err)
+	}
+
+	if _, err := Restore(archive, filepath.Join(dir, "goblog.sqlite"), ""); err == nil {
+		t.Error("expected the restore of a tampered backup to fail")
+	}
+
+	if exists(filepath.Join(dir, "goblog.sqlite.restore")) {
+		t.Error("the staged database was not removed")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"goblog-backup-20180101-000000.tar.gz", "goblog-backup-20180102-000000.tar.gz", "goblog-backup-20180103-000000.tar.gz"} {
+		for _, f := range []string{name, name + ".sha256"} {
+			if err := os.WriteFile(filepath.Join(dir, f), nil, 0640); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	s := &Service{
+		Directory: dir,
+		Keep:      2,
+	}
+
+	removed, err := s.Prune()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || filepath.Base(removed[0]) != "goblog-backup-20180101-000000.tar.gz" {
+		t.Fatalf("expected the oldest backup to be removed, but got %v", removed)
+	}
+
+	if exists(removed[0] + ".sha256") {
+		t.Error("the checksum file of the removed backup still exists")
+	}
+
+	archives, err := List(dir)
+
+	if err != nil || len(archives) != 2 {
+		t.Errorf("expected two remaining backups, but got %v %v", archives, err)
+	}
+}
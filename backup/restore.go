@@ -0,0 +1,340 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+)
+
+// RestoreResult contains the manifest of the restored archive and the locations the replaced database and files were moved to
+type RestoreResult struct {
+	Manifest         *Manifest
+	PreviousDatabase string
+	PreviousFiles    string
+}
+
+// Verify checks the archive against its checksum file, if present, and every entry against the checksums of the manifest
+func Verify(archive string) (*Manifest, error) {
+	if err := verifyChecksumFile(archive); err != nil {
+		return nil, err
+	}
+
+	return readArchive(archive, func(name string, r io.Reader) error {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+}
+
+// Restore replaces the SQLite database file and the uploaded files with the content of the archive. The archive is
+// verified and the restored database is checked before anything is replaced; the replaced database and files are kept
+// with the suffix .before-restore-<time>. The blog must not run during the restore.
+func Restore(archive, dbFile, fileLocation string) (*RestoreResult, error) {
+	m, err := Verify(archive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileLocation = strings.TrimRight(fileLocation, string(filepath.Separator))
+
+	stagedDB := dbFile + ".restore"
+	stagedFiles := fileLocation + ".restore"
+
+	if err := os.Remove(stagedDB); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(fileLocation) > 0 {
+		if err := os.RemoveAll(stagedFiles); err != nil {
+			return nil, err
+		}
+
+		if err := os.MkdirAll(stagedFiles, 0750); err != nil {
+			return nil, err
+		}
+	}
+
+	swapped := false
+
+	defer func() {
+		if !swapped {
+			os.Remove(stagedDB)
+
+			if len(fileLocation) > 0 {
+				os.RemoveAll(stagedFiles)
+			}
+		}
+	}()
+
+	// the checksums are verified again, in case the archive was changed after the verification
+	if _, err := readArchive(archive, func(name string, r io.Reader) error {
+		if name == databaseName {
+			return writeFile(stagedDB, r)
+		}
+
+		if len(fileLocation) == 0 {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		}
+
+		target := filepath.Join(stagedFiles, filepath.FromSlash(strings.TrimPrefix(name, filesDir+"/")))
+
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return err
+		}
+
+		return writeFile(target, r)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := checkDatabase(stagedDB); err != nil {
+		return nil, err
+	}
+
+	suffix := ".before-restore-" + time.Now().Format(timeLayout)
+
+	result := &RestoreResult{
+		Manifest: m,
+	}
+
+	if exists(dbFile) {
+		result.PreviousDatabase = dbFile + suffix
+
+		if err := os.Rename(dbFile, result.PreviousDatabase); err != nil {
+			return nil, err
+		}
+	}
+
+	// the journal of the replaced database must not be applied to the restored one
+	for _, ext := range []string{"-wal", "-shm", "-journal"} {
+		if exists(dbFile + ext) {
+			if err := os.Rename(dbFile+ext, dbFile+suffix+ext); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := os.Rename(stagedDB, dbFile); err != nil {
+		return nil, err
+	}
+
+	swapped = true
+
+	if len(fileLocation) == 0 {
+		return result, nil
+	}
+
+	if exists(fileLocation) {
+		result.PreviousFiles = fileLocation + suffix
+
+		if err := os.Rename(fileLocation, result.PreviousFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(stagedFiles, fileLocation); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// readArchive passes every entry except the manifest to fn and verifies the checksums of the entries against the manifest
+func readArchive(archive string, fn func(name string, r io.Reader) error) (*Manifest, error) {
+	f, err := os.Open(archive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("the backup %s is not a gzip compressed archive %v", filepath.Base(archive), err)
+	}
+
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	var m *Manifest
+
+	sums := make(map[string]string)
+
+	for {
+		hdr, err := tr.Next()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("the backup contains the unexpected entry %s", hdr.Name)
+		}
+
+		if name == manifestName {
+			m = new(Manifest)
+
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(m); err != nil {
+				return nil, fmt.Errorf("the manifest of the backup is invalid %v", err)
+			}
+
+			continue
+		}
+
+		if name != databaseName && !strings.HasPrefix(name, filesDir+"/") {
+			return nil, fmt.Errorf("the backup contains the unexpected entry %s", hdr.Name)
+		}
+
+		sum := sha256.New()
+		r := io.TeeReader(tr, sum)
+
+		if err := fn(name, r); err != nil {
+			return nil, err
+		}
+
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return nil, err
+		}
+
+		sums[name] = hex.EncodeToString(sum.Sum(nil))
+	}
+
+	if m == nil {
+		return nil, errors.New("the backup contains no manifest")
+	}
+
+	if m.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("the backup was created by a newer version of go-blog with the format version %d", m.FormatVersion)
+	}
+
+	if _, ok := sums[databaseName]; !ok {
+		return nil, errors.New("the backup contains no database")
+	}
+
+	if len(sums) != len(m.Checksums) {
+		return nil, fmt.Errorf("%w: the backup contains %d entries, but the manifest lists %d", ErrChecksum, len(sums), len(m.Checksums))
+	}
+
+	for name, sum := range m.Checksums {
+		if sums[name] != sum {
+			return nil, fmt.Errorf("%w: %s", ErrChecksum, name)
+		}
+	}
+
+	return m, nil
+}
+
+func verifyChecksumFile(archive string) error {
+	b, err := os.ReadFile(archive + ".sha256")
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(b))
+
+	if len(fields) == 0 {
+		return fmt.Errorf("the checksum file of the backup %s is empty", filepath.Base(archive))
+	}
+
+	f, err := os.Open(archive)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	sum := sha256.New()
+
+	if _, err := io.Copy(sum, f); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(sum.Sum(nil)) != fields[0] {
+		return fmt.Errorf("%w: %s", ErrChecksum, filepath.Base(archive))
+	}
+
+	return nil
+}
+
+// checkDatabase checks the integrity of the database and whether its schema is supported by this version
+func checkDatabase(file string) error {
+	db, err := sql.Open(database.SQLite, file)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	var result string
+
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+
+	if result != "ok" {
+		return fmt.Errorf("the integrity check of the restored database failed: %s", result)
+	}
+
+	m, err := database.NewSQLiteMigrator(db)
+
+	if err != nil {
+		return err
+	}
+
+	return m.Check()
+}
+
+func writeFile(file string, r io.Reader) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
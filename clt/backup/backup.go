@@ -0,0 +1,141 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Provides a small CLT for creating, verifying and restoring backups of the sqlite database and the uploaded files
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.hoogi.eu/snafu/go-blog/backup"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+var (
+	BuildVersion = "develop"
+	GitHash      = ""
+)
+
+func main() {
+	logger.InitLogger(ioutil.Discard, "Error")
+
+	fmt.Printf("backup version %s\n", BuildVersion)
+
+	file := flag.String("sqlite", "", "Location to the sqlite3 database file. (required for create and restore)")
+	files := flag.String("files", "", "Location of the uploaded files (file_location); the files are not backed up or restored if not set.")
+	dir := flag.String("dir", "data/backups", "The directory the backups are written to.")
+	keep := flag.Int("keep", 7, "The number of backups to keep; 0 keeps all backups.")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: backup -sqlite <file> [-files <dir>] [-dir <dir>] [-keep <n>] create|list|verify <archive>|restore <archive>\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if err := run(*file, *files, *dir, *keep, flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(sqlitefile, fileLocation, dir string, keep int, command, archive string) error {
+	switch command {
+	case "create":
+		if len(sqlitefile) == 0 {
+			return fmt.Errorf("the sqlite file (-sqlite) must be specified")
+		}
+
+		db, err := database.SQLiteConfig{
+			File: sqlitefile,
+		}.Open()
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		s := &backup.Service{
+			DB:           db,
+			FileLocation: fileLocation,
+			Directory:    dir,
+			Keep:         keep,
+		}
+
+		archive, err := s.Create()
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("The backup %s was created\n", archive)
+	case "list":
+		archives, err := backup.List(dir)
+
+		if err != nil {
+			return err
+		}
+
+		for _, a := range archives {
+			fmt.Println(filepath.Base(a))
+		}
+	case "verify":
+		if len(archive) == 0 {
+			return fmt.Errorf("the archive to verify must be specified")
+		}
+
+		m, err := backup.Verify(archive)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("The backup from %s is valid: schema version %d, %d entries\n", m.CreatedAt.Format("2006-01-02 15:04:05"),
+			m.SchemaVersion, len(m.Checksums))
+	case "restore":
+		if len(sqlitefile) == 0 || len(archive) == 0 {
+			return fmt.Errorf("the sqlite file (-sqlite) and the archive to restore must be specified")
+		}
+
+		fmt.Printf(">> Do you want to replace the database %s and the files with the backup %s? go-blog must be stopped. (y|N): ",
+			sqlitefile, filepath.Base(archive))
+
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+
+		if strings.ToLower(input) != "y\n" {
+			fmt.Println("Aborted. Nothing was restored.")
+			return nil
+		}
+
+		result, err := backup.Restore(archive, sqlitefile, fileLocation)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("The backup from %s was restored\n", result.Manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+
+		if len(result.PreviousDatabase) > 0 {
+			fmt.Printf("The replaced database was moved to %s\n", result.PreviousDatabase)
+		}
+
+		if len(result.PreviousFiles) > 0 {
+			fmt.Printf("The replaced files were moved to %s\n", result.PreviousFiles)
+		}
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+
+	return nil
+}
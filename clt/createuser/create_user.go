@@ -25,6 +25,7 @@ type createUserFlag struct {
 	displayName string
 	admin       bool
 	sqlite      string
+	postgres    string
 }
 
 var (
@@ -40,8 +41,9 @@ func main() {
 	username := flag.String("username", "", "Username for the admin user. (required)")
 	email := flag.String("email", "", "Email for the created user. (required)")
 	displayName := flag.String("displayname", "", "Display name for the admin user. (required)")
-	isAdmin := flag.Bool("admin", false, "If set a new user with admin permissions will be created; otherwise a non-admin is created.")
-	file := flag.String("sqlite", "", "Location to the sqlite3 database file. (required)")
+	isAdmin := flag.Bool("admin", false, "If set a new user with admin permissions will be created; otherwise an author is created.")
+	file := flag.String("sqlite", "", "Location to the sqlite3 database file. (required if -postgres is not set)")
+	dsn := flag.String("postgres", "", "Connection string of the postgres database, used instead of -sqlite.")
 
 	flag.Parse()
 
@@ -57,8 +59,8 @@ func main() {
 		fmt.Println("the display name (-displayname) must be specified")
 		os.Exit(1)
 	}
-	if *file == "" {
-		fmt.Println("the argument -sqlite is empty. Please specify the location of the sqlite3 database file")
+	if *file == "" && *dsn == "" {
+		fmt.Println("the arguments -sqlite and -postgres are empty. Please specify the location of the sqlite3 database file or the connection string of the postgres database")
 		os.Exit(1)
 	}
 
@@ -69,6 +71,7 @@ func main() {
 			displayName: *displayName,
 			admin:       *isAdmin,
 			sqlite:      *file,
+			postgres:    *dsn,
 		}
 
 		fmt.Printf("Password: ")
@@ -99,20 +102,26 @@ func main() {
 }
 
 func (userFlags createUserFlag) CreateUser() error {
-	dbConfig := database.SQLiteConfig{
-		File: userFlags.sqlite,
+	driver, db, err := database.Open(userFlags.sqlite, userFlags.postgres)
+
+	if err != nil {
+		return err
 	}
 
-	db, err := dbConfig.Open()
+	ds, err := models.NewDatasources(driver, db)
 
 	if err != nil {
 		return err
 	}
 
 	userService := &models.UserService{
-		Datasource: &models.SQLiteUserDatasource{
-			SQLConn: db,
-		},
+		Datasource: ds.Users,
+	}
+
+	role := models.RoleAuthor
+
+	if userFlags.admin {
+		role = models.RoleAdmin
 	}
 
 	user := &models.User{
@@ -120,11 +129,11 @@ func (userFlags createUserFlag) CreateUser() error {
 		DisplayName:   userFlags.displayName,
 		Email:         userFlags.email,
 		PlainPassword: []byte(userFlags.password),
-		IsAdmin:       userFlags.admin,
+		Role:          role,
 		Active:        true,
 	}
 
-	if _, err := userService.Create(user); err != nil {
+	if _, err := userService.Create(user, nil); err != nil {
 		return err
 	}
 	return nil
@@ -36,7 +36,7 @@ func main() {
 			os.Exit(1)
 		}
 
-		fmt.Println("The tables were created")
+		fmt.Println("The tables were created or migrated to the latest version")
 	}
 }
 
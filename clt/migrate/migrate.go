@@ -0,0 +1,127 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Provides a small CLT for applying and reverting the database migrations
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+var (
+	BuildVersion = "develop"
+	GitHash      = ""
+)
+
+func main() {
+	logger.InitLogger(ioutil.Discard, "Error")
+
+	fmt.Printf("migrate version %s\n", BuildVersion)
+
+	file := flag.String("sqlite", "", "Location to the sqlite3 database file. (required if -postgres is not set)")
+	dsn := flag.String("postgres", "", "Connection string of the postgres database, used instead of -sqlite.")
+	to := flag.Int("to", 0, "The version to migrate up to; all pending migrations are applied if not set.")
+	steps := flag.Int("steps", 1, "The number of migrations to revert.")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: migrate -sqlite <file>|-postgres <dsn> [-to <version>] [-steps <n>] up|down|status\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *file == "" && *dsn == "" {
+		fmt.Println("the sqlite file (-sqlite) or the postgres database (-postgres) must be specified")
+		os.Exit(1)
+	}
+
+	if err := migrate(*file, *dsn, flag.Arg(0), *to, *steps); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func migrate(sqlitefile, postgresDSN, command string, to, steps int) error {
+	driver, db, err := database.Open(sqlitefile, postgresDSN)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		db.Close()
+	}()
+
+	m, err := database.NewMigrator(driver, db)
+
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := m.Up(to)
+
+		for _, mg := range applied {
+			fmt.Printf("applied %04d %s\n", mg.Version, mg.Description)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("The database is up to date")
+		}
+	case "down":
+		if steps < 1 {
+			return fmt.Errorf("the number of migrations to revert (-steps) must be at least 1")
+		}
+
+		fmt.Printf(">> Do you want to revert the last %d migration(s)? The reverted tables and columns are lost. (y|N): ", steps)
+
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+
+		if strings.ToLower(input) != "y\n" {
+			fmt.Println("Aborted. No migration was reverted.")
+			return nil
+		}
+
+		reverted, err := m.Down(steps)
+
+		for _, mg := range reverted {
+			fmt.Printf("reverted %04d %s\n", mg.Version, mg.Description)
+		}
+
+		return err
+	case "status":
+		status, err := m.Status()
+
+		if err != nil {
+			return err
+		}
+
+		for _, s := range status {
+			if s.Applied {
+				fmt.Printf("%04d %-40s applied %s\n", s.Version, s.Description, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d %-40s pending\n", s.Version, s.Description)
+			}
+		}
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Provides a small CLT for exporting the content of a blog into a portable archive and importing it into another blog;
+// the content of a WordPress blog is imported from its export file, the posts of Hugo or Jekyll from their directory
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/importer"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+var (
+	BuildVersion = "develop"
+	GitHash      = ""
+)
+
+func main() {
+	logger.InitLogger(ioutil.Discard, "Error")
+
+	fmt.Printf("transfer version %s\n", BuildVersion)
+
+	config := flag.String("config", "go-blog.conf", "The config of the blog; the database and the file location are taken from the config.")
+	username := flag.String("user", "", "The user who imports the content; the content of unknown users is assigned to this user. (required for import)")
+	createUsers := flag.Bool("create-users", false, "If set users which do not exist are created as inactive users; otherwise their content is assigned to -user.")
+	uploads := flag.String("uploads", "", "The copy of the directory wp-content/uploads of the WordPress blog; the attachments are read from this directory. (wordpress only)")
+	download := flag.Bool("download", false, "If set the attachments which are not found in -uploads are downloaded from the WordPress blog. (wordpress only)")
+	static := flag.String("static", "", "The directory the absolute links of the images are resolved in, e.g. static of Hugo. (markdown only)")
+	dryRun := flag.Bool("dry-run", false, "If set nothing is imported; the planned changes are printed.")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: transfer [-config <file>] [-user <username>] [-create-users] [-dry-run] export|import <archive>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       transfer [-config <file>] -user <username> [-create-users] [-dry-run] [-uploads <dir>] [-download] wordpress <export.xml>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       transfer [-config <file>] -user <username> [-dry-run] [-static <dir>] markdown <dir>\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	opts := models.ImportOptions{
+		CreateUsers: *createUsers,
+		DryRun:      *dryRun,
+	}
+
+	wp := &importer.WordPress{
+		Uploads:  *uploads,
+		Download: *download,
+	}
+
+	md := &importer.MarkdownDir{
+		Dir:    flag.Arg(1),
+		Static: *static,
+	}
+
+	if err := run(*config, *username, opts, wp, md, flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(config, username string, opts models.ImportOptions, wp *importer.WordPress, md *importer.MarkdownDir, command, archive string) error {
+	if command != "export" && command != "import" && command != "wordpress" && command != "markdown" {
+		flag.Usage()
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+
+	if len(archive) == 0 {
+		return fmt.Errorf("the file to %s must be specified", command)
+	}
+
+	cfg, err := settings.LoadConfig(config)
+
+	if err != nil {
+		return err
+	}
+
+	dsn := ""
+	file := cfg.Database.File
+
+	if cfg.Database.Driver == "postgres" {
+		dsn = cfg.Database.PostgresDSN
+		file = ""
+	}
+
+	driver, db, err := database.Open(file, dsn)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	ds, err := models.NewDatasources(driver, db)
+
+	if err != nil {
+		return err
+	}
+
+	auditService := &models.AuditService{
+		Datasource: ds.Audit,
+	}
+
+	ts := &models.TransferService{
+		UserService: &models.UserService{
+			Datasource:   ds.Users,
+			Config:       cfg.User,
+			AuditService: auditService,
+		},
+		ArticleService: &models.ArticleService{
+			Datasource:   ds.Articles,
+			AppConfig:    cfg.Application,
+			AuditService: auditService,
+		},
+		SiteService: &models.SiteService{
+			Datasource:   ds.Sites,
+			AuditService: auditService,
+		},
+		FileService: &models.FileService{
+			Datasource:   ds.Files,
+			Config:       cfg.File,
+			AuditService: auditService,
+		},
+		CategoryService: &models.CategoryService{
+			Datasource:   ds.Categories,
+			AuditService: auditService,
+		},
+		RedirectService: &models.RedirectService{
+			Datasource: ds.Redirects,
+		},
+	}
+
+	if command == "export" {
+		return export(ts, archive)
+	}
+
+	if len(username) == 0 {
+		return fmt.Errorf("the user who imports the content (-user) must be specified")
+	}
+
+	actor, err := ts.UserService.GetByUsername(username)
+
+	if err != nil {
+		return fmt.Errorf("the user %s was not found %v", username, err)
+	}
+
+	var report *models.ImportReport
+
+	switch command {
+	case "wordpress":
+		report, err = importWordPress(ts, wp, archive, actor, opts)
+	case "markdown":
+		report, err = importMarkdown(ts, md, actor, opts)
+	default:
+		report, err = importArchive(ts, archive, actor, opts)
+	}
+
+	if report != nil {
+		if opts.DryRun {
+			fmt.Println("Dry run, nothing was imported. The planned changes are:")
+		}
+
+		printReport(report)
+	}
+
+	return err
+}
+
+func importArchive(ts *models.TransferService, archive string, actor *models.User, opts models.ImportOptions) (*models.ImportReport, error) {
+	zr, err := zip.OpenReader(archive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+
+	return ts.Import(&zr.Reader, actor, opts)
+}
+
+func importWordPress(ts *models.TransferService, wp *importer.WordPress, file string, actor *models.User, opts models.ImportOptions) (*models.ImportReport, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	c, err := wp.Read(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range wp.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+
+	return ts.ImportContent(c, actor, opts)
+}
+
+func importMarkdown(ts *models.TransferService, md *importer.MarkdownDir, actor *models.User, opts models.ImportOptions) (*models.ImportReport, error) {
+	c, err := md.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range md.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+
+	for _, r := range md.Rewrites {
+		fmt.Printf("link: %s\n", r)
+	}
+
+	return ts.ImportContent(c, actor, opts)
+}
+
+func export(ts *models.TransferService, archive string) error {
+	f, err := os.OpenFile(archive, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ts.Export(f); err != nil {
+		f.Close()
+		os.Remove(archive)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("The content was exported to %s\n", archive)
+
+	return nil
+}
+
+func printReport(report *models.ImportReport) {
+	for _, e := range report.Entries {
+		id := "-"
+
+		if e.ID > 0 {
+			id = fmt.Sprintf("%d", e.ID)
+		}
+
+		fmt.Printf("%-8s %-7s %5d -> %-5s %s", e.Type, e.Result, e.SourceID, id, e.Name)
+
+		if len(e.Note) > 0 {
+			fmt.Printf(" (%s)", e.Note)
+		}
+
+		fmt.Println()
+	}
+
+	fmt.Println()
+
+	for _, typ := range []string{models.AuditUser, models.AuditCategory, models.AuditFile, models.AuditArticle, models.AuditSite} {
+		fmt.Printf("%-8s created: %d, renamed: %d, mapped: %d, skipped: %d\n", typ,
+			report.Count(typ, models.ImportCreated),
+			report.Count(typ, models.ImportRenamed),
+			report.Count(typ, models.ImportMapped),
+			report.Count(typ, models.ImportSkipped))
+	}
+}
@@ -57,7 +57,8 @@ func RandomSecureKey(length int) []byte {
 	return k
 }
 
-// CryptPassword hashes a password with bcrypt and a given cost
+// CryptPassword hashes a password with bcrypt and a given cost;
+// new passwords are hashed with HashPassword, bcrypt hashes are only verified
 func CryptPassword(password []byte) ([]byte, error) {
 	return bcrypt.GenerateFromPassword(password, bcryptRounds)
 }
@@ -74,3 +75,10 @@ func RandomHash(length int) string {
 
 	return hex.EncodeToString(hash.Sum(nil))
 }
+
+// Hash returns the hex encoded SHA-512 hash of the given data
+func Hash(data []byte) string {
+	hash := sha512.Sum512(data)
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// ErrPasswordMismatch is returned if the password does not match the hash
+var ErrPasswordMismatch = errors.New("the password does not match the hash")
+
+// PasswordParams are the argon2id parameters for new password hashes; the memory is given in KiB
+type PasswordParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultPasswordParams are the argon2id parameters recommended by RFC 9106 for memory-constrained environments
+var DefaultPasswordParams = PasswordParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+}
+
+// HashPassword hashes the password with argon2id and a random salt; the hash is encoded in the PHC string format
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, so the parameters and the salt are stored along with the hash
+func HashPassword(password []byte, p PasswordParams) ([]byte, error) {
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+	}
+
+	salt := RandomSecureKey(argon2SaltLength)
+
+	if salt == nil {
+		return nil, errors.New("could not generate a salt")
+	}
+
+	key := argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+
+	b64 := base64.RawStdEncoding
+
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+}
+
+// VerifyPassword compares the password with the encoded hash. Legacy bcrypt hashes are verified with the legacy salt
+// appended to the password. If the password matches, rehash is true if the hash was not created with argon2id and
+// the given parameters, so it should be replaced by a new hash
+func VerifyPassword(encoded, password, legacySalt []byte, p PasswordParams) (rehash bool, err error) {
+	if bytes.HasPrefix(encoded, []byte("$2")) {
+		if err := bcrypt.CompareHashAndPassword(encoded, append(password[:len(password):len(password)], legacySalt...)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrPasswordMismatch
+			}
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	var version int
+	var hp PasswordParams
+
+	parts := bytes.Split(encoded, []byte("$"))
+
+	if len(parts) != 6 || string(parts[1]) != "argon2id" {
+		return false, errors.New("unknown password hash format")
+	}
+
+	if _, err := fmt.Sscanf(string(parts[2]), "v=%d", &version); err != nil {
+		return false, fmt.Errorf("invalid argon2id version %v", err)
+	}
+
+	if _, err := fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &hp.Memory, &hp.Iterations, &hp.Parallelism); err != nil {
+		return false, fmt.Errorf("invalid argon2id parameters %v", err)
+	}
+
+	s, err := base64.RawStdEncoding.DecodeString(string(parts[4]))
+
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id salt %v", err)
+	}
+
+	k, err := base64.RawStdEncoding.DecodeString(string(parts[5]))
+
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id hash %v", err)
+	}
+
+	if version != argon2.Version || hp.Memory == 0 || hp.Iterations == 0 || hp.Parallelism == 0 || len(k) == 0 {
+		return false, fmt.Errorf("unsupported argon2id version %d or parameters m=%d,t=%d,p=%d", version, hp.Memory, hp.Iterations, hp.Parallelism)
+	}
+
+	other := argon2.IDKey(password, s, hp.Iterations, hp.Memory, hp.Parallelism, uint32(len(k)))
+
+	if subtle.ConstantTimeCompare(k, other) != 1 {
+		return false, ErrPasswordMismatch
+	}
+
+	return hp != p || len(k) != argon2KeyLength, nil
+}
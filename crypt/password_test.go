@@ -0,0 +1,108 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package crypt_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+)
+
+var testParams = crypt.PasswordParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := crypt.HashPassword([]byte("123456789012"), testParams)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(hash, []byte("$argon2id$v=19$m=1024,t=1,p=1$")) {
+		t.Errorf("expected an encoded argon2id hash, but got %s", hash)
+	}
+
+	other, err := crypt.HashPassword([]byte("123456789012"), testParams)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(hash, other) {
+		t.Error("expected different hashes due to a random salt")
+	}
+
+	rehash, err := crypt.VerifyPassword(hash, []byte("123456789012"), nil, testParams)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rehash {
+		t.Error("expected no rehash for a hash with the current parameters")
+	}
+
+	if _, err := crypt.VerifyPassword(hash, []byte("123456789013"), nil, testParams); !errors.Is(err, crypt.ErrPasswordMismatch) {
+		t.Errorf("expected a mismatch for a wrong password, but got %v", err)
+	}
+
+	rehash, err = crypt.VerifyPassword(hash, []byte("123456789012"), nil, crypt.PasswordParams{Memory: 2048, Iterations: 1, Parallelism: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rehash {
+		t.Error("expected a rehash for a hash with outdated parameters")
+	}
+
+	// a password longer than 72 bytes is not truncated
+	long := bytes.Repeat([]byte("a"), 100)
+
+	hash, err = crypt.HashPassword(long, testParams)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := crypt.VerifyPassword(hash, append(long[:72:72], 'b'), nil, testParams); !errors.Is(err, crypt.ErrPasswordMismatch) {
+		t.Errorf("expected a mismatch for a password with the same first 72 bytes, but got %v", err)
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	salt := crypt.GenerateSalt()
+
+	hash, err := crypt.CryptPassword(append([]byte("123456789012"), salt...))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rehash, err := crypt.VerifyPassword(hash, []byte("123456789012"), salt, testParams)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rehash {
+		t.Error("expected a rehash for a legacy bcrypt hash")
+	}
+
+	if _, err := crypt.VerifyPassword(hash, []byte("123456789012"), nil, testParams); !errors.Is(err, crypt.ErrPasswordMismatch) {
+		t.Errorf("expected a mismatch without the legacy salt, but got %v", err)
+	}
+
+	for _, invalid := range []string{"", "plain", "$argon2id$v=19$m=0,t=1,p=1$c2FsdA$aGFzaA", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$"} {
+		if _, err := crypt.VerifyPassword([]byte(invalid), []byte("123456789012"), nil, testParams); err == nil {
+			t.Errorf("expected an error for the invalid hash %q, but error is nil", invalid)
+		}
+	}
+}
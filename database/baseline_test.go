@@ -0,0 +1,232 @@
+package database_test
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// preMigrationSchema is the schema created by InitTables before the migrations were introduced
+var preMigrationSchema = []string{
+	"CREATE TABLE user " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"username VARCHAR(60) NOT NULL, " +
+		"email VARCHAR(191) NOT NULL, " +
+		"display_name VARCHAR(191) NOT NULL, " +
+		"password CHAR(60) NOT NULL, " +
+		"salt CHAR(32) NOT NULL, " +
+		"is_admin boolean NOT NULL DEFAULT false, " +
+		"active boolean NOT NULL DEFAULT true, " +
+		"last_modified datetime NOT NULL," +
+		"CONSTRAINT user_email_key UNIQUE (username), " +
+		"CONSTRAINT user_email_key UNIQUE (email) " +
+		");",
+	"CREATE TABLE user_invite " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"hash VARCHAR(191) NOT NULL, " +
+		"username VARCHAR(60) NOT NULL, " +
+		"email VARCHAR(191) NOT NULL, " +
+		"display_name VARCHAR(191) NOT NULL, " +
+		"is_admin boolean NOT NULL DEFAULT false, " +
+		"active boolean NOT NULL DEFAULT true, " +
+		"created_at datetime NOT NULL," +
+		"created_by INT NOT NULL, " +
+		"FOREIGN KEY (created_by) REFERENCES user(id), " +
+		"CONSTRAINT userinvite_hash_key UNIQUE (hash), " +
+		"CONSTRAINT userinvite_username_key UNIQUE (username), " +
+		"CONSTRAINT userinvite_email_key UNIQUE (email) " +
+		");",
+	"CREATE TABLE article " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"headline VARCHAR(100) NOT NULL, " +
+		"slug VARCHAR(191) NOT NULL, " +
+		"teaser text NOT NULL, " +
+		"content text NOT NULL, " +
+		"published boolean NOT NULL DEFAULT false, " +
+		"published_on datetime, " +
+		"last_modified datetime NOT NULL, " +
+		"user_id INT NOT NULL, " +
+		"category_id INT, " +
+		"CONSTRAINT blog_slug_key UNIQUE (slug), " +
+		"CONSTRAINT `fk_article_user` " +
+		"FOREIGN KEY (user_id) REFERENCES user(id) " +
+		"ON DELETE CASCADE, " +
+		"FOREIGN KEY (category_id) REFERENCES category(id)" +
+		");",
+	"CREATE TABLE site " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"title VARCHAR(100) NOT NULL, " +
+		"link VARCHAR(100) NOT NULL, " +
+		"content text NOT NULL, " +
+		"section VARCHAR(191) NOT NULL, " +
+		"published boolean NOT NULL DEFAULT false, " +
+		"published_on datetime, " +
+		"last_modified datetime NOT NULL, " +
+		"order_no INT NOT NULL, " +
+		"user_id INT NOT NULL, " +
+		"CONSTRAINT site_link_key UNIQUE (link), " +
+		"FOREIGN KEY (user_id) REFERENCES user(id) " +
+		"ON DELETE CASCADE " +
+		");",
+	"CREATE TABLE file " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"filename VARCHAR(191) NOT NULL, " +
+		"unique_name VARCHAR(191) NOT NULL, " +
+		"size BIGINT NOT NULL, " +
+		"content_type VARCHAR(150) NOT NULL, " +
+		"inline boolean NOT NULL DEFAULT false, " +
+		"last_modified datetime NOT NULL, " +
+		"user_id INT NOT NULL, " +
+		"CONSTRAINT `fk_file_user` " +
+		"FOREIGN KEY (user_id) REFERENCES user(id) " +
+		"ON DELETE CASCADE, " +
+		"CONSTRAINT file_unique_name_key UNIQUE (unique_name) " +
+		");",
+	"CREATE TABLE category " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"name VARCHAR(191) NOT NULL, " +
+		"slug VARCHAR(191) NOT NULL, " +
+		"last_modified datetime NOT NULL, " +
+		"user_id INT NOT NULL, " +
+		"CONSTRAINT category_name_key UNIQUE (name) " +
+		");",
+	"CREATE TABLE token " +
+		"(" +
+		"id INTEGER PRIMARY KEY, " +
+		"hash VARCHAR(191) NOT NULL, " +
+		"requested_at datetime NOT NULL, " +
+		"token_type VARCHAR(100) NOT NULL, " +
+		"user_id INT NOT NULL, " +
+		"CONSTRAINT `fk_token_user` " +
+		"FOREIGN KEY (user_id) REFERENCES user(id) " +
+		"ON DELETE CASCADE, " +
+		"CONSTRAINT token_key UNIQUE (hash) " +
+		");",
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	logger.InitLogger(io.Discard, "Debug")
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// every connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range preMigrationSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO user (username, email, display_name, password, salt, is_admin, active, last_modified) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			[]interface{}{"admin", "admin@example.com", "Admin", "hash", "salt", true, true, now}},
+		{"INSERT INTO user (username, email, display_name, password, salt, is_admin, active, last_modified) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			[]interface{}{"author", "author@example.com", "Author", "hash", "salt", false, true, now}},
+		{"INSERT INTO user_invite (hash, username, email, display_name, is_admin, active, created_at, created_by) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			[]interface{}{"invite", "invited", "invited@example.com", "Invited", true, true, now, 1}},
+		{"INSERT INTO article (headline, slug, teaser, content, published, published_on, last_modified, user_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			[]interface{}{"Hello", "2018/1/hello", "teaser", "content", true, now, now, 2}},
+		{"INSERT INTO token (hash, requested_at, token_type, user_id) VALUES(?, ?, ?, ?)",
+			[]interface{}{"token", now, "password_reset", 2}},
+	} {
+		if _, err := db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := database.NewSQLiteMigrator(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Check(); !errors.Is(err, database.ErrPendingMigrations) {
+		t.Fatalf("expected the migrations to be pending, but got %v", err)
+	}
+
+	applied, err := m.Up(0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(m.Migrations)-1 || applied[0].Version != m.Baseline+1 {
+		t.Fatalf("expected all migrations except the baseline to be applied, but got %v", applied)
+	}
+
+	if err := m.Check(); err != nil {
+		t.Fatalf("expected no pending migrations, but got %v", err)
+	}
+
+	ds := models.NewSQLiteDatasources(db)
+
+	admin, err := ds.Users.GetByUsername("admin")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if admin.Role != models.RoleAdmin {
+		t.Errorf("expected the administrator to get the role admin, but got %s", admin.Role)
+	}
+
+	author, err := ds.Users.GetByUsername("author")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if author.Role != models.RoleAuthor {
+		t.Errorf("expected the user to get the role author, but got %s", author.Role)
+	}
+
+	invite, err := ds.UserInvites.GetByHash("invite")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if invite.Role != models.RoleAdmin {
+		t.Errorf("expected the invited administrator to get the role admin, but got %s", invite.Role)
+	}
+
+	if _, err := ds.Articles.GetBySlug("2018/1/hello", nil, models.All); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.Tokens.Get("token", models.PasswordReset); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.RecoveryCodes.ListByUser(author.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.Redirects.GetByPath("/unknown"); err != sql.ErrNoRows {
+		t.Fatalf("expected no redirect, but got %v", err)
+	}
+}
@@ -0,0 +1,557 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// ErrNewerSchema is returned if the database was migrated by a newer version of go-blog
+var ErrNewerSchema = errors.New("the database schema is newer than this version of go-blog")
+
+// ErrPendingMigrations is returned if the database is not migrated to the newest known version
+var ErrPendingMigrations = errors.New("the database schema is not migrated to the latest version")
+
+// sqliteGoMigrations and postgresGoMigrations are the migrations written in Go, e.g. to convert data; they are
+// applied along with the SQL migrations in the order of their version
+var (
+	sqliteGoMigrations   []Migration
+	postgresGoMigrations []Migration
+)
+
+// Migration is a versioned change of the schema; the change is either written in SQL or in Go
+type Migration struct {
+	Version     int
+	Description string
+
+	UpSQL   string
+	DownSQL string
+
+	Up   func(tx *sql.Tx) error
+	Down func(tx *sql.Tx) error
+}
+
+// MigrationStatus represents a migration and whether it is applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts the migrations; the applied versions are recorded in the table schema_migrations
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+
+	// Driver is the name of the database driver, SQLite is assumed if empty
+	Driver string
+
+	// BaselineTable is a table which exists in databases created before the migrations were introduced;
+	// those databases are considered to be at the version Baseline
+	BaselineTable string
+	Baseline      int
+}
+
+// NewSQLiteMigrator returns the migrator with the migrations for SQLite embedded in the binary
+func NewSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFS, "migrations/sqlite", sqliteGoMigrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB:            db,
+		Migrations:    migrations,
+		Driver:        SQLite,
+		BaselineTable: "user",
+		Baseline:      1,
+	}, nil
+}
+
+// NewPostgresMigrator returns the migrator with the migrations for PostgreSQL embedded in the binary; the PostgreSQL
+// schema was introduced along with the migrations, so there is no baseline
+func NewPostgresMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFS, "migrations/postgres", postgresGoMigrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB:         db,
+		Migrations: migrations,
+		Driver:     Postgres,
+	}, nil
+}
+
+// NewMigrator returns the migrator for the database driver
+func NewMigrator(driver string, db *sql.DB) (*Migrator, error) {
+	switch driver {
+	case SQLite:
+		return NewSQLiteMigrator(db)
+	case Postgres:
+		return NewPostgresMigrator(db)
+	}
+
+	return nil, fmt.Errorf("there are no migrations for the database driver '%s'", driver)
+}
+
+// loadMigrations reads the SQL migrations named <version>_<description>.up.sql and <version>_<description>.down.sql
+// from the directory and merges them with the Go migrations
+func loadMigrations(fsys fs.FS, dir string, goMigrations []Migration) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, e := range entries {
+		name := e.Name()
+
+		var direction string
+
+		if strings.HasSuffix(name, ".up.sql") {
+			direction = "up"
+		} else if strings.HasSuffix(name, ".down.sql") {
+			direction = "down"
+		} else {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+
+		v, desc, _ := strings.Cut(base, "_")
+
+		version, err := strconv.Atoi(v)
+
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("the migration %s has no valid version", name)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+
+		if !ok {
+			mg = &Migration{
+				Version:     version,
+				Description: strings.ReplaceAll(desc, "_", " "),
+			}
+			byVersion[version] = mg
+		}
+
+		if direction == "up" {
+			mg.UpSQL = string(b)
+		} else {
+			mg.DownSQL = string(b)
+		}
+	}
+
+	for _, gm := range goMigrations {
+		if _, ok := byVersion[gm.Version]; ok {
+			return nil, fmt.Errorf("the version %d of the migration '%s' is used twice", gm.Version, gm.Description)
+		}
+
+		gm := gm
+		byVersion[gm.Version] = &gm
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, mg := range byVersion {
+		if len(mg.UpSQL) == 0 && mg.Up == nil {
+			return nil, fmt.Errorf("the migration %d has no up migration", mg.Version)
+		}
+
+		migrations = append(migrations, *mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the version of the newest known migration
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Status returns all known migrations and whether they are applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	applied, err := m.prepare(ctx, conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.Migrations))
+
+	for _, mg := range m.Migrations {
+		at, ok := applied[mg.Version]
+
+		status = append(status, MigrationStatus{
+			Migration: mg,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return status, nil
+}
+
+// Check returns ErrNewerSchema if the database contains migrations which are unknown to this version and
+// ErrPendingMigrations if known migrations are not applied
+func (m *Migrator) Check() error {
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	applied, err := m.prepare(ctx, conn)
+
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+
+	for _, mg := range m.Migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%d '%s'", mg.Version, mg.Description))
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: the migrations %s are pending", ErrPendingMigrations, strings.Join(pending, ", "))
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations up to the target version, all pending migrations are applied if the target is 0;
+// every migration is applied in its own transaction
+func (m *Migrator) Up(target int) ([]Migration, error) {
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	applied, err := m.prepare(ctx, conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for _, mg := range m.Migrations {
+		if target > 0 && mg.Version > target {
+			break
+		}
+
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+
+		if err := m.apply(ctx, conn, mg, true); err != nil {
+			return done, err
+		}
+
+		done = append(done, mg)
+	}
+
+	return done, nil
+}
+
+// Down reverts the given number of the most recently applied migrations
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	applied, err := m.prepare(ctx, conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mg := m.Migrations[i]
+
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		if len(mg.DownSQL) == 0 && mg.Down == nil {
+			return done, fmt.Errorf("the migration %d '%s' can not be reverted", mg.Version, mg.Description)
+		}
+
+		if err := m.apply(ctx, conn, mg, false); err != nil {
+			return done, err
+		}
+
+		done = append(done, mg)
+	}
+
+	return done, nil
+}
+
+// apply applies or reverts the migration and records it in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := m.run(tx, mg, up); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("%v, rollback failed %v", err, rerr)
+		}
+
+		direction := "apply"
+
+		if !up {
+			direction = "revert"
+		}
+
+		return fmt.Errorf("could not %s the migration %d '%s' %v", direction, mg.Version, mg.Description, err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) run(tx *sql.Tx, mg Migration, up bool) error {
+	if up {
+		if mg.Up != nil {
+			if err := mg.Up(tx); err != nil {
+				return err
+			}
+		} else if _, err := tx.Exec(mg.UpSQL); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(m.rebind("INSERT INTO schema_migrations (version, description, applied_at) VALUES(?, ?, ?)"),
+			mg.Version, mg.Description, time.Now())
+
+		return err
+	}
+
+	if mg.Down != nil {
+		if err := mg.Down(tx); err != nil {
+			return err
+		}
+	} else if _, err := tx.Exec(mg.DownSQL); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(m.rebind("DELETE FROM schema_migrations WHERE version=?"), mg.Version)
+
+	return err
+}
+
+// prepare creates the table schema_migrations if it does not exist yet and baselines databases created before the
+// migrations; it returns the applied versions or ErrNewerSchema if an applied version is unknown
+func (m *Migrator) prepare(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	exists, err := m.tableExists(ctx, conn, "schema_migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		if err := m.create(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations ORDER BY version ASC")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	known := map[int]bool{}
+
+	for _, mg := range m.Migrations {
+		known[mg.Version] = true
+	}
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		if !known[version] {
+			return nil, fmt.Errorf("%w: the migration %d is unknown, the newest known migration is %d", ErrNewerSchema, version, m.Latest())
+		}
+
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// create creates the table schema_migrations; a database with the baseline table was created before the migrations
+// were introduced, so the migrations up to the baseline are recorded as applied
+func (m *Migrator) create(ctx context.Context, conn *sql.Conn) error {
+	baseline := false
+
+	if len(m.BaselineTable) > 0 {
+		var err error
+
+		if baseline, err = m.tableExists(ctx, conn, m.BaselineTable); err != nil {
+			return err
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	timeType := "datetime"
+
+	if m.Driver == Postgres {
+		timeType = "timestamptz"
+	}
+
+	if _, err := tx.Exec("CREATE TABLE schema_migrations " +
+		"(" +
+		"version INTEGER PRIMARY KEY, " +
+		"description VARCHAR(191) NOT NULL, " +
+		"applied_at " + timeType + " NOT NULL " +
+		");"); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logger.Log.Error(err)
+		}
+		return err
+	}
+
+	if baseline {
+		for _, mg := range m.Migrations {
+			if mg.Version > m.Baseline {
+				break
+			}
+
+			if _, err := tx.Exec(m.rebind("INSERT INTO schema_migrations (version, description, applied_at) VALUES(?, ?, ?)"),
+				mg.Version, mg.Description, time.Now()); err != nil {
+				if err := tx.Rollback(); err != nil {
+					logger.Log.Error(err)
+				}
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) tableExists(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	query := "SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?"
+
+	if m.Driver == Postgres {
+		query = "SELECT count(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name=$1"
+	}
+
+	var c int
+
+	if err := conn.QueryRowContext(ctx, query, name).Scan(&c); err != nil {
+		return false, err
+	}
+
+	return c > 0, nil
+}
+
+// rebind replaces the ? placeholders with the numbered placeholders $1, $2, ... used by PostgreSQL
+func (m *Migrator) rebind(query string) string {
+	if m.Driver != Postgres {
+		return query
+	}
+
+	return Rebind(query)
+}
+
+// Rebind replaces the ? placeholders of the query with the numbered placeholders $1, $2, ... used by PostgreSQL
+func Rebind(query string) string {
+	var b strings.Builder
+
+	n := 0
+
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	logger.InitLogger(io.Discard, "Debug")
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	return db
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := openDB(t)
+
+	defer db.Close()
+
+	m, err := NewSQLiteMigrator(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(m.Migrations) {
+		t.Fatalf("expected %d applied migrations, but got %d", len(m.Migrations), len(applied))
+	}
+
+	if applied, err := m.Up(0); err != nil || len(applied) != 0 {
+		t.Fatalf("expected no pending migrations, but got %v %v", applied, err)
+	}
+
+	status, err := m.Status()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range status {
+		if !s.Applied {
+			t.Errorf("the migration %d is not applied", s.Version)
+		}
+	}
+
+	reverted, err := m.Down(len(m.Migrations))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != len(m.Migrations) || reverted[0].Version != m.Latest() {
+		t.Fatalf("expected the migrations to be reverted from the newest, but got %v", reverted)
+	}
+
+	if exists := hasTable(t, db, "user"); exists {
+		t.Error("the table user still exists after reverting the baseline")
+	}
+
+	if _, err := m.Up(0); err != nil {
+		t.Fatalf("could not apply the migrations again %v", err)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := openDB(t)
+
+	defer db.Close()
+
+	m, err := NewSQLiteMigrator(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, description, applied_at) VALUES(?, ?, ?)", m.Latest()+1, "from the future", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(0); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected the error ErrNewerSchema, but got %v", err)
+	}
+
+	if err := m.Check(); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected the check to fail with ErrNewerSchema, but got %v", err)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"m/0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/0003_create_c.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER);")},
+		"m/README":                 {Data: []byte("ignored")},
+	}
+
+	goMigration := Migration{
+		Version:     2,
+		Description: "fill a",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO a (id) VALUES (1)")
+			return err
+		},
+	}
+
+	migrations, err := loadMigrations(fsys, "m", []Migration{goMigration})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 3 || migrations[0].Description != "create a" || migrations[1].Up == nil || migrations[2].Version != 3 {
+		t.Fatalf("unexpected migrations %v", migrations)
+	}
+
+	db := openDB(t)
+
+	defer db.Close()
+
+	m := &Migrator{
+		DB:         db,
+		Migrations: migrations,
+	}
+
+	if _, err := m.Up(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasTable(t, db, "c") {
+		t.Error("the migration beyond the target version was applied")
+	}
+
+	if _, err := m.Down(1); err == nil {
+		t.Error("expected an error for reverting a migration without down migration")
+	}
+
+	goMigration.Version = 3
+
+	if _, err := loadMigrations(fsys, "m", []Migration{goMigration}); err == nil {
+		t.Error("expected an error for a version used twice")
+	}
+}
+
+func TestPostgresMigrateUpDown(t *testing.T) {
+	dsn := os.Getenv("GOBLOG_TEST_POSTGRES_DSN")
+
+	if len(dsn) == 0 {
+		if postgresRequired {
+			t.Fatal("GOBLOG_TEST_POSTGRES_DSN is not set, but the tests were built with the tag postgres")
+		}
+
+		t.Skip("GOBLOG_TEST_POSTGRES_DSN is not set, the PostgreSQL migrations are not tested; build the tests with the tag postgres to require PostgreSQL")
+	}
+
+	logger.InitLogger(io.Discard, "Debug")
+
+	db, err := PostgresConfig{DSN: dsn}.Open()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if _, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public;"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewPostgresMigrator(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied, err := m.Up(0); err != nil || len(applied) != len(m.Migrations) {
+		t.Fatalf("expected all migrations to be applied, but got %v %v", applied, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO audit_event (actor_name, action, target_type, before, after, ip, created_at) VALUES('alice', 'login', 'user', '', '', '', now())"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("DELETE FROM audit_event"); err == nil {
+		t.Error("expected the audit events to be append-only")
+	}
+
+	if _, err := m.Down(len(m.Migrations)); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := m.Status(); err != nil || status[0].Applied {
+		t.Fatalf("expected the baseline to be reverted, but got %v %v", status, err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	got := Rebind("SELECT id FROM article WHERE user_id=? AND slug=? LIMIT ? OFFSET ?")
+
+	if got != "SELECT id FROM article WHERE user_id=$1 AND slug=$2 LIMIT $3 OFFSET $4" {
+		t.Errorf("unexpected query %s", got)
+	}
+}
+
+func hasTable(t *testing.T, db *sql.DB, name string) bool {
+	var c int
+
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='table' AND name=?", name).Scan(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	return c > 0
+}
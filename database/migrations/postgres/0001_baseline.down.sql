@@ -0,0 +1,13 @@
+DROP TABLE token;
+
+DROP TABLE file;
+
+DROP TABLE site;
+
+DROP TABLE article;
+
+DROP TABLE category;
+
+DROP TABLE user_invite;
+
+DROP TABLE users;
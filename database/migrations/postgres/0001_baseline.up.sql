@@ -0,0 +1,97 @@
+-- user is a reserved word in PostgreSQL, the table is named users instead
+CREATE TABLE users
+(
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(60) NOT NULL,
+	email VARCHAR(191) NOT NULL,
+	display_name VARCHAR(191) NOT NULL,
+	password TEXT NOT NULL,
+	salt VARCHAR(32) NOT NULL,
+	is_admin boolean NOT NULL DEFAULT false,
+	active boolean NOT NULL DEFAULT true,
+	last_modified timestamptz NOT NULL,
+	CONSTRAINT users_username_key UNIQUE (username),
+	CONSTRAINT users_email_key UNIQUE (email)
+);
+
+CREATE TABLE user_invite
+(
+	id SERIAL PRIMARY KEY,
+	hash VARCHAR(191) NOT NULL,
+	username VARCHAR(60) NOT NULL,
+	email VARCHAR(191) NOT NULL,
+	display_name VARCHAR(191) NOT NULL,
+	is_admin boolean NOT NULL DEFAULT false,
+	active boolean NOT NULL DEFAULT true,
+	created_at timestamptz NOT NULL,
+	created_by INT NOT NULL REFERENCES users (id),
+	CONSTRAINT userinvite_hash_key UNIQUE (hash),
+	CONSTRAINT userinvite_username_key UNIQUE (username),
+	CONSTRAINT userinvite_email_key UNIQUE (email)
+);
+
+CREATE TABLE category
+(
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(191) NOT NULL,
+	slug VARCHAR(191) NOT NULL,
+	last_modified timestamptz NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT category_name_key UNIQUE (name)
+);
+
+CREATE TABLE article
+(
+	id SERIAL PRIMARY KEY,
+	headline VARCHAR(100) NOT NULL,
+	slug VARCHAR(191) NOT NULL,
+	teaser text NOT NULL,
+	content text NOT NULL,
+	published boolean NOT NULL DEFAULT false,
+	published_on timestamptz,
+	last_modified timestamptz NOT NULL,
+	user_id INT NOT NULL,
+	category_id INT REFERENCES category (id),
+	CONSTRAINT blog_slug_key UNIQUE (slug),
+	CONSTRAINT fk_article_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE site
+(
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(100) NOT NULL,
+	link VARCHAR(100) NOT NULL,
+	content text NOT NULL,
+	section VARCHAR(191) NOT NULL,
+	published boolean NOT NULL DEFAULT false,
+	published_on timestamptz,
+	last_modified timestamptz NOT NULL,
+	order_no INT NOT NULL,
+	user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT site_link_key UNIQUE (link)
+);
+
+CREATE TABLE file
+(
+	id SERIAL PRIMARY KEY,
+	filename VARCHAR(191) NOT NULL,
+	unique_name VARCHAR(191) NOT NULL,
+	size BIGINT NOT NULL,
+	content_type VARCHAR(150) NOT NULL,
+	inline boolean NOT NULL DEFAULT false,
+	last_modified timestamptz NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT fk_file_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT file_unique_name_key UNIQUE (unique_name)
+);
+
+CREATE TABLE token
+(
+	id SERIAL PRIMARY KEY,
+	hash VARCHAR(191) NOT NULL,
+	requested_at timestamptz NOT NULL,
+	token_type VARCHAR(100) NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT fk_token_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT token_key UNIQUE (hash)
+);
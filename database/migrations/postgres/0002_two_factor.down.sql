@@ -0,0 +1,6 @@
+DROP TABLE recovery_code;
+
+ALTER TABLE users
+	DROP COLUMN totp_secret,
+	DROP COLUMN totp_enabled,
+	DROP COLUMN require_two_factor;
@@ -0,0 +1,13 @@
+ALTER TABLE users
+	ADD COLUMN totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false,
+	ADD COLUMN require_two_factor boolean NOT NULL DEFAULT false;
+
+CREATE TABLE recovery_code
+(
+	id SERIAL PRIMARY KEY,
+	hash VARCHAR(191) NOT NULL,
+	created_at timestamptz NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT fk_recovery_code_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+CREATE TABLE credential
+(
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL,
+	credential_id bytea NOT NULL,
+	public_key bytea NOT NULL,
+	attestation_type VARCHAR(32) NOT NULL,
+	transport VARCHAR(191) NOT NULL,
+	aaguid bytea,
+	sign_count BIGINT NOT NULL DEFAULT 0,
+	clone_warning boolean NOT NULL DEFAULT false,
+	backup_eligible boolean NOT NULL DEFAULT false,
+	backup_state boolean NOT NULL DEFAULT false,
+	created_at timestamptz NOT NULL,
+	last_used_at timestamptz,
+	user_id INT NOT NULL,
+	CONSTRAINT credential_credential_id_key UNIQUE (credential_id),
+	CONSTRAINT fk_credential_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+CREATE TABLE session
+(
+	id VARCHAR(191) PRIMARY KEY,
+	data bytea NOT NULL,
+	last_access_time timestamptz NOT NULL,
+	created_at timestamptz NOT NULL
+);
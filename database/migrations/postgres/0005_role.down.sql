@@ -0,0 +1,16 @@
+-- only the role admin can be kept, the other roles are reverted to non-administrators
+ALTER TABLE users
+	ADD COLUMN is_admin boolean NOT NULL DEFAULT false;
+
+UPDATE users SET is_admin=true WHERE role='admin';
+
+ALTER TABLE users
+	DROP COLUMN role;
+
+ALTER TABLE user_invite
+	ADD COLUMN is_admin boolean NOT NULL DEFAULT false;
+
+UPDATE user_invite SET is_admin=true WHERE role='admin';
+
+ALTER TABLE user_invite
+	DROP COLUMN role;
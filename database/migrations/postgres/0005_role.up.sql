@@ -0,0 +1,16 @@
+-- the flag is_admin is replaced by the roles; administrators get the role admin
+ALTER TABLE users
+	ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'author';
+
+UPDATE users SET role='admin' WHERE is_admin;
+
+ALTER TABLE users
+	DROP COLUMN is_admin;
+
+ALTER TABLE user_invite
+	ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'author';
+
+UPDATE user_invite SET role='admin' WHERE is_admin;
+
+ALTER TABLE user_invite
+	DROP COLUMN is_admin;
@@ -0,0 +1,13 @@
+CREATE TABLE access_token
+(
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(64) NOT NULL,
+	hash VARCHAR(191) NOT NULL,
+	scopes VARCHAR(191) NOT NULL,
+	expires_at timestamptz NOT NULL,
+	last_used_at timestamptz,
+	created_at timestamptz NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT access_token_hash_key UNIQUE (hash),
+	CONSTRAINT fk_access_token_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE audit_event;
+
+DROP FUNCTION audit_event_append_only();
@@ -0,0 +1,28 @@
+-- the audit events are not linked to the users, as they must outlast deleted users
+CREATE TABLE audit_event
+(
+	id SERIAL PRIMARY KEY,
+	actor_id INT,
+	actor_name VARCHAR(191) NOT NULL,
+	action VARCHAR(64) NOT NULL,
+	target_type VARCHAR(64) NOT NULL,
+	target_id INT,
+	before TEXT NOT NULL,
+	after TEXT NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created_at timestamptz NOT NULL
+);
+
+CREATE INDEX audit_event_created_at_idx ON audit_event (created_at);
+
+CREATE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_event_no_update BEFORE UPDATE ON audit_event
+FOR EACH ROW EXECUTE PROCEDURE audit_event_append_only();
+
+CREATE TRIGGER audit_event_no_delete BEFORE DELETE ON audit_event
+FOR EACH ROW EXECUTE PROCEDURE audit_event_append_only();
@@ -0,0 +1,8 @@
+CREATE TABLE login_throttle
+(
+	key VARCHAR(255) PRIMARY KEY,
+	failures INT NOT NULL,
+	last_failure timestamptz NOT NULL,
+	blocked_until timestamptz NOT NULL,
+	locked boolean NOT NULL DEFAULT false
+);
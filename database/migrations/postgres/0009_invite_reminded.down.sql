@@ -0,0 +1,2 @@
+ALTER TABLE user_invite
+	DROP COLUMN reminded;
@@ -0,0 +1,2 @@
+ALTER TABLE user_invite
+	ADD COLUMN reminded boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+ALTER TABLE token
+	DROP COLUMN payload;
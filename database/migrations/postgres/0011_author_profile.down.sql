@@ -0,0 +1,5 @@
+ALTER TABLE users
+	DROP COLUMN bio,
+	DROP COLUMN website,
+	DROP COLUMN avatar,
+	DROP COLUMN social_links;
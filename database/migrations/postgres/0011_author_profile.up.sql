@@ -0,0 +1,5 @@
+ALTER TABLE users
+	ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+	ADD COLUMN website VARCHAR(191) NOT NULL DEFAULT '',
+	ADD COLUMN avatar VARCHAR(191) NOT NULL DEFAULT '',
+	ADD COLUMN social_links TEXT NOT NULL DEFAULT '';
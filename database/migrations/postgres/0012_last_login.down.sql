@@ -0,0 +1,4 @@
+ALTER TABLE users
+	DROP COLUMN last_login,
+	DROP COLUMN last_login_ip,
+	DROP COLUMN dormant_warned_at;
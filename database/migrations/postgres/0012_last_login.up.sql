@@ -0,0 +1,4 @@
+ALTER TABLE users
+	ADD COLUMN last_login timestamptz,
+	ADD COLUMN last_login_ip VARCHAR(45) NOT NULL DEFAULT '',
+	ADD COLUMN dormant_warned_at timestamptz;
@@ -0,0 +1,13 @@
+DROP TABLE token;
+
+DROP TABLE category;
+
+DROP TABLE file;
+
+DROP TABLE site;
+
+DROP TABLE article;
+
+DROP TABLE user_invite;
+
+DROP TABLE user;
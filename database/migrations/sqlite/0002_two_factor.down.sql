@@ -0,0 +1,7 @@
+DROP TABLE recovery_code;
+
+ALTER TABLE user DROP COLUMN totp_secret;
+
+ALTER TABLE user DROP COLUMN totp_enabled;
+
+ALTER TABLE user DROP COLUMN require_two_factor;
@@ -0,0 +1,16 @@
+ALTER TABLE user ADD COLUMN totp_secret VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE user ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false;
+
+ALTER TABLE user ADD COLUMN require_two_factor boolean NOT NULL DEFAULT false;
+
+CREATE TABLE recovery_code
+(
+	id INTEGER PRIMARY KEY,
+	hash VARCHAR(191) NOT NULL,
+	created_at datetime NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT `fk_recovery_code_user`
+	FOREIGN KEY (user_id) REFERENCES user(id)
+	ON DELETE CASCADE
+);
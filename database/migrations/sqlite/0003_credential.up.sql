@@ -0,0 +1,21 @@
+CREATE TABLE credential
+(
+	id INTEGER PRIMARY KEY,
+	name VARCHAR(64) NOT NULL,
+	credential_id BLOB NOT NULL,
+	public_key BLOB NOT NULL,
+	attestation_type VARCHAR(32) NOT NULL,
+	transport VARCHAR(191) NOT NULL,
+	aaguid BLOB,
+	sign_count INTEGER NOT NULL DEFAULT 0,
+	clone_warning boolean NOT NULL DEFAULT false,
+	backup_eligible boolean NOT NULL DEFAULT false,
+	backup_state boolean NOT NULL DEFAULT false,
+	created_at datetime NOT NULL,
+	last_used_at datetime,
+	user_id INT NOT NULL,
+	CONSTRAINT credential_credential_id_key UNIQUE (credential_id),
+	CONSTRAINT `fk_credential_user`
+	FOREIGN KEY (user_id) REFERENCES user(id)
+	ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+CREATE TABLE session
+(
+	id VARCHAR(191) PRIMARY KEY,
+	data BLOB NOT NULL,
+	last_access_time datetime NOT NULL,
+	created_at datetime NOT NULL
+);
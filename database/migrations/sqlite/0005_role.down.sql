@@ -0,0 +1,12 @@
+-- only the role admin can be kept, the other roles are reverted to non-administrators
+ALTER TABLE user ADD COLUMN is_admin boolean NOT NULL DEFAULT false;
+
+UPDATE user SET is_admin=true WHERE role='admin';
+
+ALTER TABLE user DROP COLUMN role;
+
+ALTER TABLE user_invite ADD COLUMN is_admin boolean NOT NULL DEFAULT false;
+
+UPDATE user_invite SET is_admin=true WHERE role='admin';
+
+ALTER TABLE user_invite DROP COLUMN role;
@@ -0,0 +1,15 @@
+CREATE TABLE access_token
+(
+	id INTEGER PRIMARY KEY,
+	name VARCHAR(64) NOT NULL,
+	hash VARCHAR(191) NOT NULL,
+	scopes VARCHAR(191) NOT NULL,
+	expires_at datetime NOT NULL,
+	last_used_at datetime,
+	created_at datetime NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT access_token_hash_key UNIQUE (hash),
+	CONSTRAINT `fk_access_token_user`
+	FOREIGN KEY (user_id) REFERENCES user(id)
+	ON DELETE CASCADE
+);
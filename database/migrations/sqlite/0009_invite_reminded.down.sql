@@ -0,0 +1 @@
+ALTER TABLE user_invite DROP COLUMN reminded;
@@ -0,0 +1 @@
+ALTER TABLE user_invite ADD COLUMN reminded boolean NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE token ADD COLUMN payload VARCHAR(191) NOT NULL DEFAULT '';
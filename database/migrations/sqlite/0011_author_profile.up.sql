@@ -0,0 +1,7 @@
+ALTER TABLE user ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE user ADD COLUMN website VARCHAR(191) NOT NULL DEFAULT '';
+
+ALTER TABLE user ADD COLUMN avatar VARCHAR(191) NOT NULL DEFAULT '';
+
+ALTER TABLE user ADD COLUMN social_links TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE user DROP COLUMN last_login;
+
+ALTER TABLE user DROP COLUMN last_login_ip;
+
+ALTER TABLE user DROP COLUMN dormant_warned_at;
@@ -0,0 +1,5 @@
+ALTER TABLE user ADD COLUMN last_login datetime;
+
+ALTER TABLE user ADD COLUMN last_login_ip VARCHAR(45) NOT NULL DEFAULT '';
+
+ALTER TABLE user ADD COLUMN dormant_warned_at datetime;
@@ -0,0 +1,14 @@
+CREATE TABLE user_device
+(
+	id INTEGER PRIMARY KEY,
+	fingerprint VARCHAR(191) NOT NULL,
+	user_agent VARCHAR(255) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	first_seen datetime NOT NULL,
+	last_seen datetime NOT NULL,
+	user_id INT NOT NULL,
+	CONSTRAINT user_device_key UNIQUE (user_id, fingerprint),
+	CONSTRAINT `fk_user_device_user`
+	FOREIGN KEY (user_id) REFERENCES user(id)
+	ON DELETE CASCADE
+);
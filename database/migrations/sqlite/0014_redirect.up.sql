@@ -0,0 +1,9 @@
+-- the old paths, e.g. the permalinks of imported posts, which are redirected permanently
+CREATE TABLE redirect
+(
+	id INTEGER PRIMARY KEY,
+	path VARCHAR(255) NOT NULL,
+	target VARCHAR(255) NOT NULL,
+	last_modified datetime NOT NULL,
+	CONSTRAINT redirect_path_key UNIQUE (path)
+);
@@ -0,0 +1,2 @@
+-- the time step of the last used TOTP code, a code can't be used twice
+ALTER TABLE user ADD COLUMN totp_last_counter BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+//go:build !postgres
+
+package database
+
+// postgresRequired is set by the build tag postgres; without it PostgreSQL is skipped if GOBLOG_TEST_POSTGRES_DSN
+// is not set
+const postgresRequired = false
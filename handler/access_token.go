@@ -0,0 +1,116 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// AdminAccessTokenNewPostHandler creates a personal access token for the currently logged-in user;
+// the token is shown only once on the profile page
+func AdminAccessTokenNewPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	days, err := parseInt(r.FormValue("expires_in"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	at := &models.AccessToken{
+		Name:      r.FormValue("name"),
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	}
+
+	for _, s := range r.Form["scope"] {
+		at.Scopes = append(at.Scopes, models.AccessTokenScope(s))
+	}
+
+	token, err := ctx.AccessTokenService.Create(at, user)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	tpl := AdminProfileHandler(ctx, w, r)
+
+	if tpl.Err == nil {
+		tpl.Data["newAccessToken"] = token
+		tpl.SuccessMsg = "The access token was successfully created. Please copy the token now, it will not be shown again."
+	}
+
+	return tpl
+}
+
+// AdminAccessTokenDeleteHandler returns the form for removing a personal access token
+func AdminAccessTokenDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	tokenID, err := parseInt(getVar(r, "tokenID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	remove := models.Action{
+		ID:          "removeAccessToken",
+		ActionURL:   fmt.Sprintf("/admin/user/access-token/delete/%d", tokenID),
+		BackLinkURL: "/admin/user/profile",
+		Description: "Please confirm removing of the access token?",
+		Title:       "Confirm removing of access token",
+	}
+
+	return &middleware.Template{
+		Name:   tplAdminAction,
+		Active: "profile",
+		Data: map[string]interface{}{
+			"action": remove,
+		},
+	}
+}
+
+// AdminAccessTokenDeletePostHandler removes a personal access token of the currently logged-in user
+func AdminAccessTokenDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	tokenID, err := parseInt(getVar(r, "tokenID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	if err := ctx.AccessTokenService.Remove(user, tokenID); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/profile",
+		Active:       "profile",
+		SuccessMsg:   "The access token was successfully removed.",
+	}
+}
@@ -0,0 +1,254 @@
+package handler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/totp"
+)
+
+func TestAccessTokens(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	bob := dummyUser()
+
+	token, err := doAdminAccessTokenNewRequest(rUser, "deploy", []string{"read"}, "30")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := ctx.AccessTokenService.List(bob)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 {
+		t.Fatalf("expected one access token, but got %d", len(tokens))
+	}
+
+	if tokens[0].Hash == token || tokens[0].Hash != crypt.Hash([]byte(token)) {
+		t.Error("expected only the hash of the access token to be saved")
+	}
+
+	if tokens[0].LastUsedAt.Valid {
+		t.Error("expected the access token to be unused")
+	}
+
+	// read scope
+	rec := doTokenRequest("GET", "/admin/json/file/upload", token)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d for a read request, but got %d", http.StatusOK, rec.Code)
+	}
+
+	if rec.Body.String() != "bob" {
+		t.Errorf("expected the request to be authenticated as bob, but got '%s'", rec.Body.String())
+	}
+
+	if rec := doTokenRequest("POST", "/admin/json/file/upload", token); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d for a write request with a read token, but got %d", http.StatusForbidden, rec.Code)
+	}
+
+	if rec := doTokenRequest("GET", "/admin/articles", token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d for a non JSON route, but got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	for _, p := range []string{"/admin/json/passkey/register/begin", "/admin/json/passkey/register/finish", "/admin/json/session/keep-alive"} {
+		if rec := doTokenRequest("GET", p, token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d for the route %s which does not allow access tokens, but got %d", http.StatusUnauthorized, p, rec.Code)
+		}
+	}
+
+	if rec := doTokenRequest("GET", "/admin/json/file/upload", "gbt_invalid"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d for an invalid token, but got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	tokens, err = ctx.AccessTokenService.List(bob)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !tokens[0].LastUsedAt.Valid {
+		t.Error("expected the last usage of the access token to be recorded")
+	}
+
+	// expired
+	expired := &models.AccessToken{
+		Name:      "expired",
+		Scopes:    []models.AccessTokenScope{models.ScopeWrite},
+		ExpiresAt: time.Now().Add(time.Second),
+	}
+
+	expiredToken, err := ctx.AccessTokenService.Create(expired, bob)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if rec := doTokenRequest("POST", "/admin/json/file/upload", expiredToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d for an expired token, but got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	// foreign token
+	if err := doAdminAccessTokenDeleteRequest(rAdminUser, tokens[0].ID); err == nil {
+		t.Error("expected an error while removing a foreign access token, but error is nil")
+	}
+
+	if err := doAdminAccessTokenDeleteRequest(rUser, tokens[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doTokenRequest("GET", "/admin/json/file/upload", token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d for a removed token, but got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestAccessTokenTwoFactorRequired(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	bob := dummyUser()
+
+	token, err := ctx.AccessTokenService.Create(&models.AccessToken{
+		Name:      "ci",
+		Scopes:    []models.AccessTokenScope{models.ScopeRead},
+		ExpiresAt: time.Now().AddDate(0, 0, 1),
+	}, bob)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob.RequireTwoFactor = true
+
+	if err := ctx.UserService.Update(bob, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doTokenRequest("GET", "/api/v1/articles", token); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d for a token of an user without the required two-factor authentication, but got %d", http.StatusForbidden, rec.Code)
+	}
+
+	bob, err = ctx.UserService.GetByID(bob.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := ctx.TwoFactorService.NewSecret()
+	code, _ := totp.Code(secret, time.Now())
+
+	if _, err := ctx.TwoFactorService.Enable(bob, secret, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doTokenRequest("GET", "/api/v1/articles", token); rec.Code != http.StatusOK {
+		t.Errorf("expected status code %d after the two-factor authentication was set up, but got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestAccessTokenValidation(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	if _, err := doAdminAccessTokenNewRequest(rUser, "", []string{"read"}, "30"); err == nil {
+		t.Error("expected an error while creating an access token without name, but error is nil")
+	}
+
+	if _, err := doAdminAccessTokenNewRequest(rUser, "deploy", nil, "30"); err == nil {
+		t.Error("expected an error while creating an access token without scope, but error is nil")
+	}
+
+	if _, err := doAdminAccessTokenNewRequest(rUser, "deploy", []string{"admin"}, "30"); err == nil {
+		t.Error("expected an error while creating an access token with an unknown scope, but error is nil")
+	}
+
+	if _, err := doAdminAccessTokenNewRequest(rUser, "deploy", []string{"read"}, "400"); err == nil {
+		t.Error("expected an error while creating an access token expiring in 400 days, but error is nil")
+	}
+}
+
+// doTokenRequest sends a request with the access token through the restricted middleware chain
+func doTokenRequest(method, path, token string) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		u, _ := middleware.User(r)
+		rw.Write([]byte(u.Username))
+	})
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := httptest.NewRecorder()
+
+	ctx.TokenAuthHandler(ctx.AuthHandler(next)).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func doAdminAccessTokenNewRequest(user reqUser, name string, scopes []string, expiresIn string) (string, error) {
+	values := url.Values{}
+	values.Add("name", name)
+	values.Add("expires_in", expiresIn)
+
+	for _, s := range scopes {
+		values.Add("scope", s)
+	}
+
+	r := request{
+		url:    "/admin/user/access-token/new",
+		user:   user,
+		method: "POST",
+		values: values,
+	}
+
+	tpl := handler.AdminAccessTokenNewPostHandler(ctx, httptest.NewRecorder(), r.buildRequest())
+
+	if tpl.Err != nil {
+		return "", tpl.Err
+	}
+
+	token, _ := tpl.Data["newAccessToken"].(string)
+
+	if !strings.HasPrefix(token, "gbt_") {
+		return "", errors.New("the access token was not shown")
+	}
+
+	return token, nil
+}
+
+func doAdminAccessTokenDeleteRequest(user reqUser, tokenID int) error {
+	r := request{
+		url:    "/admin/user/access-token/delete/",
+		user:   user,
+		method: "POST",
+		pathVar: []pathVar{
+			{
+				key:   "tokenID",
+				value: strconv.Itoa(tokenID),
+			},
+		},
+	}
+
+	tpl := handler.AdminAccessTokenDeletePostHandler(ctx, httptest.NewRecorder(), r.buildRequest())
+
+	return tpl.Err
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"git.hoogi.eu/snafu/go-blog/httperror"
@@ -18,10 +19,52 @@ import (
 func AdminProfileHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
 	user, _ := middleware.User(r)
 
+	credentials, err := ctx.WebAuthnService.ListCredentials(user)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminProfile,
+			Active: "profile",
+			Err:    err,
+			Data: map[string]interface{}{
+				"user": user,
+			},
+		}
+	}
+
+	accessTokens, err := ctx.AccessTokenService.List(user)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminProfile,
+			Active: "profile",
+			Err:    err,
+			Data: map[string]interface{}{
+				"user": user,
+			},
+		}
+	}
+
+	var pendingEmail string
+
+	if tokens, err := ctx.TokenService.ListByUser(user.ID, models.EmailChange); err == nil && len(tokens) > 0 {
+		pendingEmail = tokens[0].Payload
+	}
+
+	var currentSID string
+
+	if session, err := ctx.SessionService.Get(w, r); err == nil {
+		currentSID = session.SessionID()
+	}
+
 	return &middleware.Template{
 		Name: tplAdminProfile,
 		Data: map[string]interface{}{
-			"user": user,
+			"user":         user,
+			"credentials":  credentials,
+			"accessTokens": accessTokens,
+			"sessions":     ctx.UserSessionService.List(user, currentSID),
+			"pendingEmail": pendingEmail,
 		},
 		Active: "profile",
 	}
@@ -32,17 +75,24 @@ func AdminProfilePostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 	ctxUser, _ := middleware.User(r)
 	ctxUser.PlainPassword = []byte(r.FormValue("current_password"))
 
+	// the current email address stays active until the new one is confirmed
+	email := strings.TrimSpace(r.FormValue("email"))
+
 	u := &models.User{
-		ID:            ctxUser.ID,
-		Username:      r.FormValue("username"),
-		Email:         r.FormValue("email"),
-		DisplayName:   r.FormValue("displayname"),
-		Active:        true,
-		IsAdmin:       ctxUser.IsAdmin,
-		PlainPassword: []byte(r.FormValue("password")),
+		ID:               ctxUser.ID,
+		Username:         r.FormValue("username"),
+		Email:            ctxUser.Email,
+		DisplayName:      r.FormValue("displayname"),
+		Active:           true,
+		Role:             ctxUser.Role,
+		RequireTwoFactor: ctxUser.RequireTwoFactor,
+		PlainPassword:    []byte(r.FormValue("password")),
 	}
 
-	if _, err := ctx.UserService.Authenticate(ctxUser, ctx.ConfigService.LoginMethod); err != nil {
+	// without a password login the user has no password to confirm changes with; the session was started by the single sign-on
+	if !ctx.ConfigService.OIDC.PasswordLoginEnabled() {
+		u.PlainPassword = nil
+	} else if _, err := ctx.UserService.Authenticate(ctxUser, ctx.ConfigService.LoginMethod); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminProfile,
 			Err:    httperror.New(http.StatusUnauthorized, "Your current password is invalid.", err),
@@ -72,25 +122,22 @@ func AdminProfilePostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 		}
 	}
 
-	if changePassword {
-		session, err := ctx.SessionService.Renew(w, r)
-
-		if err != nil {
-			logger.Log.Error(err)
-		}
-
-		session.SetValue("userid", u.ID)
-
-		sessions := ctx.SessionService.SessionProvider.FindByValue("userid", u.ID)
+	changeEmail := email != ctxUser.Email
 
-		for _, sid := range sessions {
-			if sid.SessionID() != session.SessionID() {
-				ctx.SessionService.SessionProvider.Remove(sid.SessionID())
+	if changeEmail {
+		if err := ctx.UserService.CheckEmail(email); err != nil {
+			return &middleware.Template{
+				Name:   tplAdminProfile,
+				Active: "profile",
+				Err:    err,
+				Data: map[string]interface{}{
+					"user": u,
+				},
 			}
 		}
 	}
 
-	if err := ctx.UserService.Update(u, changePassword); err != nil {
+	if err := ctx.UserService.Update(u, ctxUser, changePassword); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminProfile,
 			Active: "profile",
@@ -101,6 +148,34 @@ func AdminProfilePostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 		}
 	}
 
+	if changeEmail {
+		if err := requestEmailChange(ctx, u, email); err != nil {
+			return &middleware.Template{
+				Name:   tplAdminProfile,
+				Active: "profile",
+				Err:    err,
+				Data: map[string]interface{}{
+					"user": u,
+				},
+			}
+		}
+
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			SuccessMsg:   fmt.Sprintf("Your profile was successfully updated. Please confirm your new email address with the link sent to '%s'.", email),
+			Data: map[string]interface{}{
+				"user": u,
+			},
+		}
+	}
+
+	if changePassword {
+		// all sessions were revoked by changing the password, the current user stays logged in with a new session
+		session := ctx.SessionService.Create(w, r)
+		session.SetValue("userid", u.ID)
+	}
+
 	return &middleware.Template{
 		RedirectPath: "admin/user/profile",
 		Active:       "profile",
@@ -111,14 +186,115 @@ func AdminProfilePostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 	}
 }
 
+var avatarContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// AdminPublicProfilePostHandler updates the public profile of the currently logged-in user; an uploaded image replaces
+// the avatar
+func AdminPublicProfilePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	ctxUser, _ := middleware.User(r)
+
+	file, err := parseFileField(ctx, w, r)
+
+	if err != nil && !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	u := &models.User{
+		ID:          ctxUser.ID,
+		Username:    ctxUser.Username,
+		Bio:         r.FormValue("bio"),
+		Website:     r.FormValue("website"),
+		Avatar:      ctxUser.Avatar,
+		SocialLinks: strings.Split(strings.ReplaceAll(r.FormValue("social_links"), "\r\n", "\n"), "\n"),
+	}
+
+	if convertCheckbox(r, "remove_avatar") {
+		u.Avatar = ""
+	}
+
+	if file != nil {
+		if !avatarContentTypes[file.ContentType] {
+			return &middleware.Template{
+				RedirectPath: "admin/user/profile",
+				Active:       "profile",
+				Err: httperror.New(http.StatusUnprocessableEntity, "The avatar must be a PNG, JPEG, GIF or WebP image.",
+					fmt.Errorf("the avatar of user %d has the unsupported content type %s", u.ID, file.ContentType)),
+			}
+		}
+
+		file.Inline = true
+
+		if _, err := ctx.FileService.Upload(file); err != nil {
+			return &middleware.Template{
+				RedirectPath: "admin/user/profile",
+				Active:       "profile",
+				Err:          err,
+			}
+		}
+
+		u.Avatar = file.UniqueName
+	}
+
+	if err := ctx.UserService.UpdateProfile(u, ctxUser); err != nil {
+		if file != nil {
+			removeAvatar(ctx, u)
+		}
+
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	if len(ctxUser.Avatar) > 0 && ctxUser.Avatar != u.Avatar {
+		removeAvatar(ctx, ctxUser)
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/profile",
+		Active:       "profile",
+		SuccessMsg:   "Your public profile was successfully updated.",
+	}
+}
+
+// removeAvatar removes the avatar file of the user
+func removeAvatar(ctx *middleware.AppContext, u *models.User) {
+	f, err := ctx.FileService.GetByUniqueName(u.Avatar, u)
+
+	if err != nil {
+		logger.Log.Errorf("could not find the previous avatar %s of user %d %v", u.Avatar, u.ID, err)
+		return
+	}
+
+	if err := ctx.FileService.Delete(f.ID, u); err != nil {
+		logger.Log.Errorf("could not remove the previous avatar %s of user %d %v", u.Avatar, u.ID, err)
+	}
+}
+
 // ActivateAccountHandler shows the form to activate an account
 func ActivateAccountHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
 	hash := getVar(r, "hash")
 
-	_, err := ctx.UserInviteService.GetByHash(hash)
+	ui, err := ctx.UserInviteService.GetByHash(hash)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
+			countRequest(ctx, r)
+
 			return &middleware.Template{
 				Name: tplAdminLogin,
 				Err:  httperror.New(http.StatusNotFound, "Can't find an invitation.", fmt.Errorf("the invitation with hash %s was not found", hash)),
@@ -131,6 +307,10 @@ func ActivateAccountHandler(ctx *middleware.AppContext, w http.ResponseWriter, r
 		}
 	}
 
+	if ui.Expired() {
+		return inviteExpired(ui)
+	}
+
 	return &middleware.Template{
 		Name: tplAdminActivateAccount,
 		Data: map[string]interface{}{
@@ -139,11 +319,27 @@ func ActivateAccountHandler(ctx *middleware.AppContext, w http.ResponseWriter, r
 	}
 }
 
+// inviteExpired returns the page informing the invited user that the invitation expired
+func inviteExpired(ui *models.UserInvite) *middleware.Template {
+	return &middleware.Template{
+		Name: tplAdminInviteExpired,
+		Err: httperror.New(http.StatusGone, "The invitation expired.",
+			fmt.Errorf("the invitation of %s expired at %s", ui.Username, ui.ExpiresAt)),
+		Data: map[string]interface{}{
+			"invite": ui,
+		},
+	}
+}
+
 // ActivateAccountPostHandler activates an user account
 func ActivateAccountPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
 	// Delete cookie if the user is logged in
 	ctx.SessionService.Remove(w, r)
 
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
 	password := r.FormValue("password")
 	repassword := r.FormValue("password_repeat")
 	hash := getVar(r, "hash")
@@ -162,6 +358,8 @@ func ActivateAccountPostHandler(ctx *middleware.AppContext, w http.ResponseWrite
 
 	if err != nil {
 		if err == sql.ErrNoRows {
+			countRequest(ctx, r)
+
 			return &middleware.Template{
 				Name: tplAdminLogin,
 				Err:  httperror.New(http.StatusNotFound, "Can't find an invitation.", fmt.Errorf("the invitation with hash %s was not found", hash)),
@@ -174,19 +372,24 @@ func ActivateAccountPostHandler(ctx *middleware.AppContext, w http.ResponseWrite
 		}
 	}
 
+	if ui.Expired() {
+		return inviteExpired(ui)
+	}
+
 	user := ui.Copy()
 
 	user.PlainPassword = []byte(password)
 	user.Active = true
+	user.RemoteAddr = middleware.GetIP(r)
 
-	if _, err := ctx.UserService.Create(user); err != nil {
+	if _, err := ctx.UserService.Create(user, user); err != nil {
 		return &middleware.Template{
 			Name: tplAdminActivateAccount,
 			Err:  err,
 		}
 	}
 
-	if err := ctx.UserInviteService.Remove(ui.ID); err != nil {
+	if err := ctx.UserInviteService.Remove(ui.ID, user); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminLogin,
 			Active: "users",
@@ -200,13 +403,209 @@ func ActivateAccountPostHandler(ctx *middleware.AppContext, w http.ResponseWrite
 	}
 }
 
+const (
+	emailChangeExpiry = 24 * time.Hour
+	emailRevertExpiry = 7 * 24 * time.Hour
+)
+
+// requestEmailChange sends a link to confirm the new email address; links of previous requests are invalidated
+func requestEmailChange(ctx *middleware.AppContext, u *models.User, email string) error {
+	if err := ctx.TokenService.RateLimit(u.ID, models.EmailChange); err != nil {
+		return httperror.New(http.StatusTooManyRequests, "Too many email changes were requested. Please try again later.", err)
+	}
+
+	if err := ctx.TokenService.RemoveByUser(u.ID, models.EmailChange); err != nil {
+		return err
+	}
+
+	t := &models.Token{
+		Author:  u,
+		Type:    models.EmailChange,
+		Payload: email,
+	}
+
+	if err := ctx.TokenService.Create(t); err != nil {
+		return err
+	}
+
+	ctx.Mailer.SendEmailChangeLink(u, t)
+
+	return nil
+}
+
+// ConfirmEmailHandler returns the form to confirm a new email address
+func ConfirmEmailHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	return emailTokenForm(ctx, r, models.EmailChange, emailChangeExpiry)
+}
+
+// ConfirmEmailPostHandler changes the email address of the user to the confirmed one and sends a link to revert the
+// change to the previous email address
+func ConfirmEmailPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, u, tpl := emailToken(ctx, r, hash, models.EmailChange, emailChangeExpiry)
+
+	if tpl != nil {
+		return tpl
+	}
+
+	u.RemoteAddr = middleware.GetIP(r)
+
+	if _, err := ctx.UserService.ChangeEmail(u.ID, t.Payload, u); err != nil {
+		return &middleware.Template{
+			Name: tplAdminEmailToken,
+			Err:  err,
+			Data: map[string]interface{}{
+				"hash":  hash,
+				"token": t,
+			},
+		}
+	}
+
+	if err := ctx.TokenService.Remove(hash, models.EmailChange); err != nil {
+		logger.Log.Errorf("could not remove token %s error %v", hash, err)
+	}
+
+	rt := &models.Token{
+		Author:  u,
+		Type:    models.EmailRevert,
+		Payload: u.Email,
+	}
+
+	if err := ctx.TokenService.Create(rt); err != nil {
+		logger.Log.Errorf("could not create the token to revert the email change of user %d error %v", u.ID, err)
+	} else {
+		ctx.Mailer.SendEmailChanged(u, t.Payload, rt)
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin",
+		SuccessMsg:   fmt.Sprintf("Your email address was changed to '%s'.", t.Payload),
+	}
+}
+
+// RevertEmailHandler returns the form to revert an email change
+func RevertEmailHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	return emailTokenForm(ctx, r, models.EmailRevert, emailRevertExpiry)
+}
+
+// RevertEmailPostHandler restores the previous email address of the user; all sessions of the user are revoked
+func RevertEmailPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, u, tpl := emailToken(ctx, r, hash, models.EmailRevert, emailRevertExpiry)
+
+	if tpl != nil {
+		return tpl
+	}
+
+	u.RemoteAddr = middleware.GetIP(r)
+
+	if _, err := ctx.UserService.RevertEmail(u.ID, t.Payload, u); err != nil {
+		return &middleware.Template{
+			Name: tplAdminEmailToken,
+			Err:  err,
+			Data: map[string]interface{}{
+				"hash":  hash,
+				"token": t,
+			},
+		}
+	}
+
+	if err := ctx.TokenService.Remove(hash, models.EmailRevert); err != nil {
+		logger.Log.Errorf("could not remove token %s error %v", hash, err)
+	}
+
+	if err := ctx.TokenService.RemoveByUser(u.ID, models.EmailChange); err != nil {
+		logger.Log.Errorf("could not remove the pending email changes of user %d error %v", u.ID, err)
+	}
+
+	// the session of the current request was revoked along with the others
+	ctx.SessionService.Remove(w, r)
+
+	return &middleware.Template{
+		RedirectPath: "admin",
+		SuccessMsg:   fmt.Sprintf("Your email address was restored to '%s'. If you did not change it, please reset your password.", t.Payload),
+	}
+}
+
+// emailTokenForm returns the form to confirm the token of an email change or revert
+func emailTokenForm(ctx *middleware.AppContext, r *http.Request, tt models.TokenType, expireAfter time.Duration) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, _, tpl := emailToken(ctx, r, hash, tt, expireAfter)
+
+	if tpl != nil {
+		return tpl
+	}
+
+	return &middleware.Template{
+		Name: tplAdminEmailToken,
+		Data: map[string]interface{}{
+			"hash":  hash,
+			"token": t,
+		},
+	}
+}
+
+// emailToken returns the token and its user; an invalid token is counted as failed request of the client
+func emailToken(ctx *middleware.AppContext, r *http.Request, hash string, tt models.TokenType, expireAfter time.Duration) (*models.Token, *models.User, *middleware.Template) {
+	t, err := ctx.TokenService.Get(hash, tt, expireAfter)
+
+	if err != nil {
+		countRequest(ctx, r)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			err = httperror.New(http.StatusNotFound, "The link is invalid or was already used.", fmt.Errorf("the %s token was not found", tt))
+		}
+
+		return nil, nil, &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	u, err := ctx.UserService.GetByID(t.Author.ID)
+
+	if err != nil {
+		return nil, nil, &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	return t, u, nil
+}
+
 // ResetPasswordHandler returns the form to reset the password
 func ResetPasswordHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := passwordLoginDisabled(ctx); t != nil {
+		return t
+	}
+
+	if t := requestThrottled(ctx, r, tplAdminForgotPassword); t != nil {
+		return t
+	}
+
 	hash := getVar(r, "hash")
 
 	t, err := ctx.TokenService.Get(hash, models.PasswordReset, time.Duration(1)*time.Hour)
 
 	if err != nil {
+		countRequest(ctx, r)
+
 		if err == sql.ErrNoRows {
 			return &middleware.Template{
 				Name: tplAdminForgotPassword,
@@ -229,6 +628,14 @@ func ResetPasswordHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *
 
 // ResetPasswordPostHandler handles a password reset
 func ResetPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := passwordLoginDisabled(ctx); t != nil {
+		return t
+	}
+
+	if t := requestThrottled(ctx, r, tplAdminForgotPassword); t != nil {
+		return t
+	}
+
 	hash := getVar(r, "hash")
 	password := r.FormValue("password")
 	password2 := r.FormValue("password_repeat")
@@ -236,6 +643,8 @@ func ResetPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 	t, err := ctx.TokenService.Get(hash, models.PasswordReset, time.Duration(1)*time.Hour)
 
 	if err != nil {
+		countRequest(ctx, r)
+
 		return &middleware.Template{
 			Name: tplAdminResetPassword,
 			Err:  err,
@@ -259,8 +668,9 @@ func ResetPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 	}
 
 	u.PlainPassword = []byte(password)
+	u.RemoteAddr = middleware.GetIP(r)
 
-	err = ctx.UserService.Update(u, true)
+	err = ctx.UserService.Update(u, u, true)
 
 	if err != nil {
 		return &middleware.Template{
@@ -294,6 +704,10 @@ func ResetPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 
 // ForgotPasswordHandler returns the form for the password reset
 func ForgotPasswordHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := passwordLoginDisabled(ctx); t != nil {
+		return t
+	}
+
 	return &middleware.Template{
 		Name: tplAdminForgotPassword,
 	}
@@ -301,6 +715,17 @@ func ForgotPasswordHandler(ctx *middleware.AppContext, w http.ResponseWriter, r
 
 // ForgotPasswordPostHandler handles the processing for the password reset
 func ForgotPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := passwordLoginDisabled(ctx); t != nil {
+		return t
+	}
+
+	if t := requestThrottled(ctx, r, tplAdminForgotPassword); t != nil {
+		return t
+	}
+
+	// every request counts, so password reset mails can not be used to flood the mailboxes
+	countRequest(ctx, r)
+
 	email := r.FormValue("email")
 
 	u, err := ctx.UserService.GetByMail(email)
@@ -351,3 +776,34 @@ func ForgotPasswordPostHandler(ctx *middleware.AppContext, w http.ResponseWriter
 		SuccessMsg: fmt.Sprintf("An email to '%s' with password reset instructions is on the way.", email),
 	}
 }
+
+// passwordLoginDisabled returns the login template with an error if the password login is disabled in favour of the single sign-on
+func passwordLoginDisabled(ctx *middleware.AppContext) *middleware.Template {
+	if ctx.ConfigService.OIDC.PasswordLoginEnabled() {
+		return nil
+	}
+
+	return &middleware.Template{
+		Name: tplAdminLogin,
+		Err:  errPasswordLoginDisabled,
+	}
+}
+
+// requestThrottled returns the template with an error if the client sent too many password reset requests or invalid tokens
+func requestThrottled(ctx *middleware.AppContext, r *http.Request, name string) *middleware.Template {
+	if err := ctx.LoginThrottleService.CheckRequest(middleware.GetIP(r)); err != nil {
+		return &middleware.Template{
+			Name: name,
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// countRequest counts the request as attempt of the client
+func countRequest(ctx *middleware.AppContext, r *http.Request) {
+	if err := ctx.LoginThrottleService.CountRequest(middleware.GetIP(r)); err != nil {
+		logger.Log.Error(err)
+	}
+}
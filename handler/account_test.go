@@ -1,11 +1,13 @@
 package handler_test
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
 	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
 	"git.hoogi.eu/snafu/go-blog/models"
 )
 
@@ -18,7 +20,7 @@ func TestAccountActivation(t *testing.T) {
 		DisplayName: "Homer Simpson",
 		Email:       "homer@example.com",
 		Username:    "homer",
-		IsAdmin:     false,
+		Role:        models.RoleAuthor,
 	}
 
 	_, hash, err := doAdminCreateUserInviteRequest(rAdminUser, ui)
@@ -51,7 +53,7 @@ func TestProfileUpdate(t *testing.T) {
 		Username:      "homer",
 		PlainPassword: []byte("123456789012"),
 		Active:        true,
-		IsAdmin:       false,
+		Role:          models.RoleAuthor,
 	}
 
 	userID, err := doAdminCreateUserRequest(rAdminUser, user)
@@ -62,7 +64,7 @@ func TestProfileUpdate(t *testing.T) {
 	user.Username = "marge"
 	user.PlainPassword = []byte("2109876543210")
 	user.DisplayName = "Marge Simpson"
-	user.IsAdmin = true
+	user.Role = models.RoleAdmin
 	user.Email = "marge@example.com"
 
 	err = doAdminProfileRequest(reqUser(userID), user, "123456789012")
@@ -76,6 +78,96 @@ func TestProfileUpdate(t *testing.T) {
 	}
 }
 
+func TestEmailChange(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	u, err := ctx.UserService.GetByID(2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.Email = "bobby@example.org"
+
+	if err := doAdminProfileRequest(rUser, u, "123456789012"); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); u.Email != "bob@example.org" {
+		t.Errorf("the email address was changed before it was confirmed, got %s", u.Email)
+	}
+
+	if len(sender.mails) != 1 || sender.mails[0].To != "bobby@example.org" {
+		t.Fatalf("expected a confirmation mail to bobby@example.org, but got %v", sender.mails)
+	}
+
+	tokens, err := ctx.TokenService.ListByUser(2, models.EmailChange)
+
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected one email change token, but got %v %v", tokens, err)
+	}
+
+	changeHash := tokens[0].Hash
+
+	if err := doEmailTokenRequest(handler.ConfirmEmailPostHandler, changeHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); u.Email != "bobby@example.org" {
+		t.Errorf("the confirmed email address was not changed, got %s", u.Email)
+	}
+
+	if len(sender.mails) != 2 || sender.mails[1].To != "bob@example.org" {
+		t.Fatalf("expected a notification to the previous address bob@example.org, but got %v", sender.mails)
+	}
+
+	if err := doEmailTokenRequest(handler.ConfirmEmailPostHandler, changeHash); !hasStatus(err, http.StatusNotFound) {
+		t.Errorf("expected the status 404 for an used token, but got %v", err)
+	}
+
+	tokens, err = ctx.TokenService.ListByUser(2, models.EmailRevert)
+
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected one email revert token, but got %v %v", tokens, err)
+	}
+
+	if err := doEmailTokenRequest(handler.RevertEmailPostHandler, tokens[0].Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); u.Email != "bob@example.org" {
+		t.Errorf("the email address was not reverted, got %s", u.Email)
+	}
+}
+
+func doEmailTokenRequest(h middleware.Handler, hash string) error {
+	r := request{
+		url:    "/admin/confirm-email/" + hash,
+		user:   rGuest,
+		method: "POST",
+		pathVar: []pathVar{
+			{
+				key:   "hash",
+				value: hash,
+			},
+		},
+	}
+
+	rw := httptest.NewRecorder()
+	tpl := h(ctx, rw, r.buildRequest())
+
+	if tpl.Err != nil {
+		return tpl.Err
+	}
+
+	return nil
+}
+
 func doAdminProfileRequest(user reqUser, u *models.User, currentPassword string) error {
 	values := url.Values{}
 	addValue(values, "username", u.Username)
@@ -0,0 +1,80 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// apiPageLimit is the number of entries returned per page by the API
+const apiPageLimit = 20
+
+// decodeJSON decodes the JSON request body into v
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return httperror.New(http.StatusBadRequest, "The request body is not valid JSON.", err)
+	}
+	return nil
+}
+
+// apiPagination returns the pagination for the 'page' query parameter
+func apiPagination(r *http.Request, total int) *models.Pagination {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return &models.Pagination{
+		Total:       total,
+		Limit:       apiPageLimit,
+		CurrentPage: page,
+	}
+}
+
+// apiID returns the id of the path variable key
+func apiID(r *http.Request, key string) (int, error) {
+	id, err := parseInt(getVar(r, key))
+
+	if err != nil {
+		return -1, httperror.ParameterMissing(key, err)
+	}
+
+	return id, nil
+}
+
+// notFound converts a missing row of the resource res into a not found error
+func notFound(res string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return httperror.NotFound(res, err)
+	}
+	return err
+}
+
+// apiUser returns the user of the request; an error is returned if the user is not granted the permission
+func apiUser(r *http.Request, p models.Permission) (*models.User, error) {
+	u, err := middleware.User(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.Can(p) {
+		return nil, httperror.New(http.StatusForbidden,
+			"You have not the permissions to execute this action.",
+			fmt.Errorf("the user %d is not granted the permission %s", u.ID, p))
+	}
+
+	return u, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// apiArticle is the request body for creating and updating articles
+type apiArticle struct {
+	Headline   string `json:"headline"`
+	Teaser     string `json:"teaser"`
+	Content    string `json:"content"`
+	CategoryID int    `json:"category_id"`
+	UpdateSlug bool   `json:"update_slug"`
+}
+
+// APIListArticlesHandler returns the articles of the user, an user who is granted to edit all articles gets all articles
+func APIListArticlesHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	t, err := ctx.ArticleService.Count(u, nil, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := apiPagination(r, t)
+
+	a, err := ctx.ArticleService.List(u, nil, p, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data:       a,
+		Pagination: p.Meta(),
+	}, nil
+}
+
+// APIGetArticleHandler returns a specific article
+func APIGetArticleHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "articleID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := ctx.ArticleService.GetByID(id, u, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: a,
+	}, nil
+}
+
+// APIArticleNewHandler creates a new article
+func APIArticleNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	var req apiArticle
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	a := &models.Article{
+		Headline: req.Headline,
+		Teaser:   req.Teaser,
+		Content:  req.Content,
+		CID:      sql.NullInt64{Int64: int64(req.CategoryID), Valid: true},
+		Author:   u,
+	}
+
+	id, err := ctx.ArticleService.Create(a)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = ctx.ArticleService.GetByID(id, u, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: a,
+	}, nil
+}
+
+// APIArticleEditHandler updates an article
+func APIArticleEditHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "articleID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var req apiArticle
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	a := &models.Article{
+		ID:       id,
+		Headline: req.Headline,
+		Teaser:   req.Teaser,
+		Content:  req.Content,
+		CID:      sql.NullInt64{Int64: int64(req.CategoryID), Valid: true},
+		Author:   u,
+	}
+
+	if err := ctx.ArticleService.Update(a, u, req.UpdateSlug); err != nil {
+		return nil, notFound("article", err)
+	}
+
+	a, err = ctx.ArticleService.GetByID(id, u, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: a,
+	}, nil
+}
+
+// APIArticlePublishHandler publishes or 'unpublishes' an article
+func APIArticlePublishHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "articleID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.ArticleService.Publish(id, u); err != nil {
+		return nil, notFound("article", err)
+	}
+
+	a, err := ctx.ArticleService.GetByID(id, u, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: a,
+	}, nil
+}
+
+// APIArticleDeleteHandler removes an article
+func APIArticleDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "articleID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.ArticleService.Delete(id, u); err != nil {
+		return nil, notFound("article", err)
+	}
+
+	return &models.JSONData{}, nil
+}
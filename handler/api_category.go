@@ -0,0 +1,135 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// apiCategory is the request body for creating and updating categories
+type apiCategory struct {
+	Name string `json:"name"`
+}
+
+// APIListCategoriesHandler returns all categories
+func APIListCategoriesHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	c, err := ctx.CategoryService.List(models.AllCategories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: c,
+	}, nil
+}
+
+// APIGetCategoryHandler returns a specific category
+func APIGetCategoryHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	id, err := apiID(r, "categoryID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := ctx.CategoryService.GetByID(id, models.AllCategories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: c,
+	}, nil
+}
+
+// APICategoryNewHandler creates a new category
+func APICategoryNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	var req apiCategory
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	c := &models.Category{
+		Name:   req.Name,
+		Author: u,
+	}
+
+	id, err := ctx.CategoryService.Create(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err = ctx.CategoryService.GetByID(id, models.AllCategories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: c,
+	}, nil
+}
+
+// APICategoryEditHandler updates a category
+func APICategoryEditHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "categoryID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var req apiCategory
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	c := &models.Category{
+		ID:     id,
+		Name:   req.Name,
+		Author: u,
+	}
+
+	if err := ctx.CategoryService.Update(c, u); err != nil {
+		return nil, err
+	}
+
+	c, err = ctx.CategoryService.GetByID(id, models.AllCategories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: c,
+	}, nil
+}
+
+// APICategoryDeleteHandler removes a category
+func APICategoryDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "categoryID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.CategoryService.Delete(id, u); err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{}, nil
+}
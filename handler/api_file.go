@@ -0,0 +1,168 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// apiFile is the request body for updating files
+type apiFile struct {
+	Inline bool `json:"inline"`
+}
+
+// APIListFilesHandler returns the files of the user, an user who is granted to edit all files gets all files
+func APIListFilesHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	t, err := ctx.FileService.Count(u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := apiPagination(r, t)
+
+	fs, err := ctx.FileService.List(u, p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fs {
+		fs[i].Link = fileLink(ctx, &fs[i])
+	}
+
+	return &models.JSONData{
+		Data:       fs,
+		Pagination: p.Meta(),
+	}, nil
+}
+
+// APIGetFileHandler returns the meta data of a specific file
+func APIGetFileHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "fileID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ctx.FileService.GetByID(id, u)
+
+	if err != nil {
+		return nil, notFound("file", err)
+	}
+
+	f.Link = fileLink(ctx, f)
+
+	return &models.JSONData{
+		Data: f,
+	}, nil
+}
+
+// APIFileNewHandler uploads a file sent as multipart form field 'file'
+func APIFileNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	file, err := parseFileField(ctx, w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file.Inline, _ = strconv.ParseBool(r.FormValue("inline"))
+
+	id, err := ctx.FileService.Upload(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ctx.FileService.GetByID(id, u)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.Link = fileLink(ctx, f)
+
+	return &models.JSONData{
+		Data: f,
+	}, nil
+}
+
+// APIFileEditHandler changes whether the file is shown inline or as attachment
+func APIFileEditHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "fileID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var req apiFile
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	f, err := ctx.FileService.GetByID(id, u)
+
+	if err != nil {
+		return nil, notFound("file", err)
+	}
+
+	if f.Inline != req.Inline {
+		if err := ctx.FileService.ToggleInline(id, u); err != nil {
+			return nil, err
+		}
+
+		f, err = ctx.FileService.GetByID(id, u)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.Link = fileLink(ctx, f)
+
+	return &models.JSONData{
+		Data: f,
+	}, nil
+}
+
+// APIFileDeleteHandler removes a file
+func APIFileDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, _ := middleware.User(r)
+
+	id, err := apiID(r, "fileID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.FileService.Delete(id, u); err != nil {
+		if e, ok := err.(*os.PathError); !ok || e.Err != syscall.ENOENT {
+			return nil, notFound("file", err)
+		}
+	}
+
+	return &models.JSONData{}, nil
+}
+
+// fileLink returns the public link of the file
+func fileLink(ctx *middleware.AppContext, f *models.File) string {
+	return fmt.Sprintf("%s/file/%s", ctx.ConfigService.Application.Domain, f.UniqueName)
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// apiSite is the request body for creating and updating sites
+type apiSite struct {
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	Section string `json:"section"`
+	Content string `json:"content"`
+}
+
+// APIListSitesHandler returns all sites
+func APIListSitesHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	if _, err := apiUser(r, models.PermSiteManage); err != nil {
+		return nil, err
+	}
+
+	t, err := ctx.SiteService.Count(models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := apiPagination(r, t)
+
+	s, err := ctx.SiteService.List(models.All, p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data:       s,
+		Pagination: p.Meta(),
+	}, nil
+}
+
+// APIGetSiteHandler returns a specific site
+func APIGetSiteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	if _, err := apiUser(r, models.PermSiteManage); err != nil {
+		return nil, err
+	}
+
+	id, err := apiID(r, "siteID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := ctx.SiteService.GetByID(id, models.All)
+
+	if err != nil {
+		return nil, notFound("site", err)
+	}
+
+	return &models.JSONData{
+		Data: s,
+	}, nil
+}
+
+// APISiteNewHandler creates a new site, the site is not published
+func APISiteNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, err := apiUser(r, models.PermSiteManage)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var req apiSite
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	s := &models.Site{
+		Title:   req.Title,
+		Link:    req.Link,
+		Section: req.Section,
+		Content: req.Content,
+		Author:  u,
+	}
+
+	id, err := ctx.SiteService.Create(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = ctx.SiteService.GetByID(id, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: s,
+	}, nil
+}
+
+// APISiteEditHandler updates a site
+func APISiteEditHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, err := apiUser(r, models.PermSiteManage)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := apiID(r, "siteID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var req apiSite
+
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	s := &models.Site{
+		ID:      id,
+		Title:   req.Title,
+		Link:    req.Link,
+		Section: req.Section,
+		Content: req.Content,
+		Author:  u,
+	}
+
+	if err := ctx.SiteService.Update(s); err != nil {
+		return nil, notFound("site", err)
+	}
+
+	s, err = ctx.SiteService.GetByID(id, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: s,
+	}, nil
+}
+
+// APISitePublishHandler publishes or 'unpublishes' a site
+func APISitePublishHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, err := apiUser(r, models.PermSiteManage)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := apiID(r, "siteID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.SiteService.Publish(id, u); err != nil {
+		return nil, notFound("site", err)
+	}
+
+	s, err := ctx.SiteService.GetByID(id, models.All)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JSONData{
+		Data: s,
+	}, nil
+}
+
+// APISiteDeleteHandler removes a site
+func APISiteDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	u, err := apiUser(r, models.PermSiteManage)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := apiID(r, "siteID")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.SiteService.Delete(id, u); err != nil {
+		return nil, notFound("site", err)
+	}
+
+	return &models.JSONData{}, nil
+}
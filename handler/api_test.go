@@ -0,0 +1,410 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+type apiResponse struct {
+	Data           json.RawMessage        `json:"data"`
+	Pagination     *models.PaginationMeta `json:"pagination"`
+	DisplayMessage string                 `json:"display_message"`
+	Status         int                    `json:"status"`
+}
+
+func TestAPIArticles(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	categoryID, err := doAdminCategoryNewRequest(rAdminUser, &models.Category{Name: "Releases"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a map[string]interface{}
+
+	code, resp := doAPIRequest(t, handler.APIArticleNewHandler, rUser, "POST", map[string]interface{}{
+		"headline":    "Release 1.0",
+		"teaser":      "The release notes",
+		"content":     "Content",
+		"category_id": categoryID,
+	}, nil, &a)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected status code %d while creating an article, but got %d %s", http.StatusOK, code, resp.DisplayMessage)
+	}
+
+	if a["headline"] != "Release 1.0" || a["published"] != false {
+		t.Errorf("expected an unpublished article 'Release 1.0', but got %v", a)
+	}
+
+	if c, ok := a["category"].(map[string]interface{}); !ok || c["name"] != "Releases" {
+		t.Errorf("expected the category 'Releases', but got %v", a["category"])
+	}
+
+	author, _ := a["author"].(map[string]interface{})
+
+	if author["username"] != "bob" {
+		t.Errorf("expected the author bob, but got %v", author)
+	}
+
+	for _, secret := range []string{"Email", "email", "Password", "Salt", "TOTPSecret"} {
+		if _, ok := author[secret]; ok {
+			t.Errorf("expected the author to not contain the field %s", secret)
+		}
+	}
+
+	articleID := strconv.Itoa(int(a["id"].(float64)))
+
+	id := []pathVar{{key: "articleID", value: articleID}}
+
+	// update
+	code, resp = doAPIRequest(t, handler.APIArticleEditHandler, rUser, "PUT", map[string]interface{}{
+		"headline": "Release 1.0.1",
+		"teaser":   "The release notes",
+		"content":  "Changed",
+	}, id, &a)
+
+	if code != http.StatusOK || a["headline"] != "Release 1.0.1" || a["category"] != nil {
+		t.Errorf("expected the article to be updated, but got %d %s %v", code, resp.DisplayMessage, a)
+	}
+
+	code, resp = doAPIRequest(t, handler.APIArticleEditHandler, rUser, "PUT", map[string]interface{}{
+		"teaser": "No headline",
+	}, id, nil)
+
+	if code != http.StatusUnprocessableEntity || len(resp.DisplayMessage) == 0 {
+		t.Errorf("expected status code %d with a display message for an invalid article, but got %d %v", http.StatusUnprocessableEntity, code, resp)
+	}
+
+	code, _ = doAPIRequest(t, handler.APIArticleEditHandler, rUser, "PUT", "{", id, nil)
+
+	if code != http.StatusBadRequest {
+		t.Errorf("expected status code %d for an invalid body, but got %d", http.StatusBadRequest, code)
+	}
+
+	// publish
+	code, _ = doAPIRequest(t, handler.APIArticlePublishHandler, rUser, "POST", nil, id, &a)
+
+	if code != http.StatusOK || a["published"] != true {
+		t.Errorf("expected the article to be published, but got %d %v", code, a)
+	}
+
+	// permissions
+	adminArticleID, err := doAdminCreateArticleRequest(rAdminUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminID := []pathVar{{key: "articleID", value: strconv.Itoa(adminArticleID)}}
+
+	if code, _ := doAPIRequest(t, handler.APIGetArticleHandler, rUser, "GET", nil, adminID, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d while an author gets a foreign article, but got %d", http.StatusNotFound, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIArticleDeleteHandler, rUser, "DELETE", nil, adminID, nil); code != http.StatusForbidden {
+		t.Errorf("expected status code %d while an author removes a foreign article, but got %d", http.StatusForbidden, code)
+	}
+
+	// list
+	var articles []models.Article
+
+	code, resp = doAPIRequest(t, handler.APIListArticlesHandler, rAdminUser, "GET", nil, nil, &articles)
+
+	if code != http.StatusOK || len(articles) != 2 {
+		t.Fatalf("expected two articles, but got %d %d", code, len(articles))
+	}
+
+	if resp.Pagination == nil || resp.Pagination.Total != 2 || resp.Pagination.CurrentPage != 1 || resp.Pagination.HasNext {
+		t.Errorf("expected pagination meta data for two articles, but got %v", resp.Pagination)
+	}
+
+	code, _ = doAPIRequest(t, handler.APIListArticlesHandler, rUser, "GET", nil, nil, &articles)
+
+	if code != http.StatusOK || len(articles) != 1 {
+		t.Errorf("expected only the own article to be listed for an author, but got %d", len(articles))
+	}
+
+	// delete
+	if code, _ := doAPIRequest(t, handler.APIArticleDeleteHandler, rUser, "DELETE", nil, id, nil); code != http.StatusOK {
+		t.Errorf("expected status code %d while removing an article, but got %d", http.StatusOK, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIGetArticleHandler, rUser, "GET", nil, id, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a removed article, but got %d", http.StatusNotFound, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIArticlePublishHandler, rUser, "POST", nil, id, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d while publishing a removed article, but got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAPICategories(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	var c models.Category
+
+	code, resp := doAPIRequest(t, handler.APICategoryNewHandler, rAdminUser, "POST", map[string]interface{}{"name": "Go"}, nil, &c)
+
+	if code != http.StatusOK || c.Name != "Go" || c.Slug != "go" {
+		t.Fatalf("expected the category to be created, but got %d %s %v", code, resp.DisplayMessage, c)
+	}
+
+	id := []pathVar{{key: "categoryID", value: strconv.Itoa(c.ID)}}
+
+	if code, _ := doAPIRequest(t, handler.APICategoryEditHandler, rUser, "PUT", map[string]interface{}{"name": "Rust"}, id, nil); code != http.StatusForbidden {
+		t.Errorf("expected status code %d while an author updates a foreign category, but got %d", http.StatusForbidden, code)
+	}
+
+	code, _ = doAPIRequest(t, handler.APICategoryEditHandler, rAdminUser, "PUT", map[string]interface{}{"name": "Golang"}, id, &c)
+
+	if code != http.StatusOK || c.Name != "Golang" {
+		t.Errorf("expected the category to be updated, but got %d %v", code, c)
+	}
+
+	var categories []models.Category
+
+	if code, _ := doAPIRequest(t, handler.APIListCategoriesHandler, rUser, "GET", nil, nil, &categories); code != http.StatusOK || len(categories) != 1 {
+		t.Errorf("expected one category, but got %d %d", code, len(categories))
+	}
+
+	if code, _ := doAPIRequest(t, handler.APICategoryDeleteHandler, rAdminUser, "DELETE", nil, id, nil); code != http.StatusOK {
+		t.Errorf("expected status code %d while removing a category, but got %d", http.StatusOK, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIGetCategoryHandler, rAdminUser, "GET", nil, id, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a removed category, but got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAPISites(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	site := map[string]interface{}{
+		"title":   "About",
+		"link":    "about",
+		"section": "navigation",
+		"content": "About me",
+	}
+
+	if code, _ := doAPIRequest(t, handler.APISiteNewHandler, rUser, "POST", site, nil, nil); code != http.StatusForbidden {
+		t.Errorf("expected status code %d while an author creates a site, but got %d", http.StatusForbidden, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIListSitesHandler, rUser, "GET", nil, nil, nil); code != http.StatusForbidden {
+		t.Errorf("expected status code %d while an author lists the sites, but got %d", http.StatusForbidden, code)
+	}
+
+	var s models.Site
+
+	code, resp := doAPIRequest(t, handler.APISiteNewHandler, rAdminUser, "POST", site, nil, &s)
+
+	if code != http.StatusOK || s.Title != "About" || s.Published {
+		t.Fatalf("expected an unpublished site to be created, but got %d %s %v", code, resp.DisplayMessage, s)
+	}
+
+	id := []pathVar{{key: "siteID", value: strconv.Itoa(s.ID)}}
+
+	site["title"] = "About me"
+
+	if code, _ := doAPIRequest(t, handler.APISiteEditHandler, rAdminUser, "PUT", site, id, &s); code != http.StatusOK || s.Title != "About me" {
+		t.Errorf("expected the site to be updated, but got %d %v", code, s)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APISitePublishHandler, rAdminUser, "POST", nil, id, &s); code != http.StatusOK || !s.Published || !s.PublishedOn.Valid {
+		t.Errorf("expected the site to be published, but got %d %v", code, s)
+	}
+
+	var sites []models.Site
+
+	code, resp = doAPIRequest(t, handler.APIListSitesHandler, rAdminUser, "GET", nil, nil, &sites)
+
+	if code != http.StatusOK || len(sites) != 1 || resp.Pagination == nil || resp.Pagination.Total != 1 {
+		t.Errorf("expected one site with pagination meta data, but got %d %d %v", code, len(sites), resp.Pagination)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APISiteDeleteHandler, rAdminUser, "DELETE", nil, id, nil); code != http.StatusOK {
+		t.Errorf("expected status code %d while removing a site, but got %d", http.StatusOK, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIGetSiteHandler, rAdminUser, "GET", nil, id, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a removed site, but got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAPIFiles(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	r := request{
+		url:    "/api/v1/files",
+		user:   rUser,
+		method: "POST",
+		multipartReq: []multipartRequest{
+			{
+				key:  "file",
+				file: "testdata/color.png",
+			},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	middleware.JSONHandler{AppCtx: ctx, Handler: handler.APIFileNewHandler}.ServeHTTP(rec, r.buildRequest())
+
+	var resp apiResponse
+	var f models.File
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d while uploading a file, but got %d %s", http.StatusOK, rec.Code, resp.DisplayMessage)
+	}
+
+	if err := json.Unmarshal(resp.Data, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Inline || f.ContentType != "image/png" || !strings.HasSuffix(f.Link, "/file/"+f.UniqueName) {
+		t.Errorf("expected an attached png file with a link, but got %v", f)
+	}
+
+	id := []pathVar{{key: "fileID", value: strconv.Itoa(f.ID)}}
+
+	if code, _ := doAPIRequest(t, handler.APIFileEditHandler, rUser, "PUT", map[string]interface{}{"inline": true}, id, &f); code != http.StatusOK || !f.Inline {
+		t.Errorf("expected the file to be shown inline, but got %d %v", code, f)
+	}
+
+	var files []models.File
+
+	if code, resp := doAPIRequest(t, handler.APIListFilesHandler, rUser, "GET", nil, nil, &files); code != http.StatusOK || len(files) != 1 || resp.Pagination.Total != 1 {
+		t.Errorf("expected one file, but got %d %d", code, len(files))
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIFileDeleteHandler, rUser, "DELETE", nil, id, nil); code != http.StatusOK {
+		t.Errorf("expected status code %d while removing a file, but got %d", http.StatusOK, code)
+	}
+
+	if code, _ := doAPIRequest(t, handler.APIGetFileHandler, rUser, "GET", nil, id, nil); code != http.StatusNotFound {
+		t.Errorf("expected status code %d for a removed file, but got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	next := middleware.JSONHandler{AppCtx: ctx, Handler: handler.APIListArticlesHandler}
+
+	rec := httptest.NewRecorder()
+	ctx.APIAuthHandler(next).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/articles", nil))
+
+	var resp apiResponse
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Code != http.StatusUnauthorized || resp.Status != http.StatusUnauthorized || len(resp.DisplayMessage) == 0 {
+		t.Errorf("expected a structured error with status code %d without access token, but got %d %s", http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+
+	token, err := ctx.AccessTokenService.Create(&models.AccessToken{
+		Name:      "ci",
+		Scopes:    []models.AccessTokenScope{models.ScopeRead},
+		ExpiresAt: time.Now().AddDate(0, 0, 1),
+	}, dummyUser())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/articles", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	ctx.APIAuthHandler(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status code %d with a read token, but got %d %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/articles", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = httptest.NewRecorder()
+	ctx.APIAuthHandler(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d for a write request with a read token, but got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+// doAPIRequest sends the body as JSON to the API handler and decodes the data of the response into v
+func doAPIRequest(t *testing.T, h middleware.JHandler, user reqUser, method string, body interface{}, pathVars []pathVar, v interface{}) (int, apiResponse) {
+	t.Helper()
+
+	r := request{
+		url:     "/api/v1",
+		user:    user,
+		method:  method,
+		pathVar: pathVars,
+	}
+
+	req := r.buildRequest()
+	req.Method = method
+
+	var b []byte
+
+	if s, ok := body.(string); ok {
+		b = []byte(s)
+	} else if body != nil {
+		var err error
+
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+
+	middleware.JSONHandler{AppCtx: ctx, Handler: h}.ServeHTTP(rec, req)
+
+	var resp apiResponse
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode the response %s %v", rec.Body.String(), err)
+	}
+
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(resp.Data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rec.Code, resp
+}
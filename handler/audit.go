@@ -0,0 +1,134 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+const auditDateLayout = "2006-01-02"
+
+// AuditHandler serves the export of the audit log
+type AuditHandler struct {
+	Context *middleware.AppContext
+}
+
+// AdminAuditHandler returns the audit log filtered by the query parameters actor, action, target_type, from and to
+func AdminAuditHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	q := r.URL.Query()
+
+	data := map[string]interface{}{
+		"actions":      models.AuditActions(),
+		"target_types": models.AuditTargetTypes(),
+		"filter":       q,
+		"export_query": q.Encode(),
+	}
+
+	f, err := auditFilter(q)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAudit,
+			Active: "audit",
+			Err:    err,
+			Data:   data,
+		}
+	}
+
+	total, err := ctx.AuditService.Count(f)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAudit,
+			Active: "audit",
+			Err:    err,
+			Data:   data,
+		}
+	}
+
+	p := &models.Pagination{
+		Total:       total,
+		Limit:       50,
+		CurrentPage: getPageParam(r),
+		RelURL:      "admin/audit/page",
+		Query:       q,
+	}
+
+	events, err := ctx.AuditService.List(f, p)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAudit,
+			Active: "audit",
+			Err:    err,
+			Data:   data,
+		}
+	}
+
+	data["events"] = events
+	data["pagination"] = p
+
+	return &middleware.Template{
+		Name:   tplAdminAudit,
+		Active: "audit",
+		Data:   data,
+	}
+}
+
+// ExportHandler sends the audit events matching the filter as CSV file
+func (ah AuditHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilter(r.URL.Query())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=audit-%s.csv", time.Now().Format(auditDateLayout)))
+
+	if err := ah.Context.AuditService.WriteCSV(w, f); err != nil {
+		logger.Log.Errorf("could not export the audit log %v", err)
+	}
+}
+
+// auditFilter returns the filter of the query; the day of the date 'to' is included
+func auditFilter(q url.Values) (*models.AuditFilter, error) {
+	f := &models.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+	}
+
+	if from := q.Get("from"); len(from) > 0 {
+		t, err := time.ParseInLocation(auditDateLayout, from, time.Local)
+
+		if err != nil {
+			return nil, httperror.New(http.StatusBadRequest, "The date 'from' is invalid. Please use the format YYYY-MM-DD.", err)
+		}
+
+		f.From = t
+	}
+
+	if to := q.Get("to"); len(to) > 0 {
+		t, err := time.ParseInLocation(auditDateLayout, to, time.Local)
+
+		if err != nil {
+			return nil, httperror.New(http.StatusBadRequest, "The date 'to' is invalid. Please use the format YYYY-MM-DD.", err)
+		}
+
+		f.To = t.AddDate(0, 0, 1)
+	}
+
+	return f, nil
+}
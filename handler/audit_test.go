@@ -0,0 +1,102 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	categoryID, err := doAdminCategoryNewRequest(rAdminUser, &models.Category{Name: "Audited"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doLoginRequest(rGuest, "bob", "wrong-password"); err == nil {
+		t.Fatal("expected an error for a wrong password, but error is nil")
+	}
+
+	events, err := doAdminAuditRequest(rAdminUser, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) < 3 {
+		t.Fatalf("expected at least three audit events, but got %d", len(events))
+	}
+
+	if events[0].Action != models.AuditLoginFailure || events[0].ActorName != "bob" {
+		t.Errorf("expected the failed login of bob as newest event, but got %s of %s", events[0].Action, events[0].ActorName)
+	}
+
+	events, err = doAdminAuditRequest(rAdminUser, "?action=create&target_type=category")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].TargetID != categoryID || events[0].ActorName != "alice" {
+		t.Errorf("expected the created category %d of alice, but got %v", categoryID, events)
+	}
+
+	if _, err := doAdminAuditRequest(rAdminUser, "?from=yesterday"); err == nil {
+		t.Error("expected an error for an invalid date, but error is nil")
+	}
+
+	// csv export
+	rr := httptest.NewRecorder()
+	ah := handler.AuditHandler{Context: ctx}
+	ah.ExportHandler(rr, request{url: "/admin/audit/export?actor=bob", user: rAdminUser, method: "GET"}.buildRequest())
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the status 200, but got %d", rr.Code)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || records[0][1] != "actor_id" {
+		t.Fatalf("expected the header and two events of bob, but got %v", records)
+	}
+
+	for _, r := range records[1:] {
+		if r[2] != "bob" {
+			t.Errorf("expected only events of bob, but got %v", r)
+		}
+	}
+}
+
+func doAdminAuditRequest(user reqUser, query string) ([]models.AuditEvent, error) {
+	r := request{
+		url:    "/admin/audit" + query,
+		user:   user,
+		method: "GET",
+	}
+
+	tpl := handler.AdminAuditHandler(ctx, httptest.NewRecorder(), r.buildRequest())
+
+	if tpl.Err != nil {
+		return nil, tpl.Err
+	}
+
+	events, _ := tpl.Data["events"].([]models.AuditEvent)
+
+	return events, nil
+}
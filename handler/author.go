@@ -0,0 +1,74 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// AuthorHandler returns the public profile of the author with the published articles
+func AuthorHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	author, err := ctx.UserService.GetAuthor(getVar(r, "username"))
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAuthor,
+			Active: "articles",
+			Err:    err,
+		}
+	}
+
+	t, err := ctx.ArticleService.CountByAuthor(author, models.OnlyPublished)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAuthor,
+			Active: "articles",
+			Err:    err,
+		}
+	}
+
+	p := &models.Pagination{
+		Total:       t,
+		Limit:       ctx.ConfigService.ArticlesPerPage,
+		CurrentPage: getPageParam(r),
+		RelURL:      "author/" + url.PathEscape(author.Username) + "/page",
+	}
+
+	a, err := ctx.ArticleService.ListByAuthor(author, p, models.OnlyPublished)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAuthor,
+			Active: "articles",
+			Err:    err,
+		}
+	}
+
+	cs, err := ctx.CategoryService.List(models.CategoriesWithPublishedArticles)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAuthor,
+			Active: "articles",
+			Err:    err,
+		}
+	}
+
+	return &middleware.Template{
+		Name:   tplAuthor,
+		Active: "articles",
+		Data: map[string]interface{}{
+			"author":     author,
+			"articles":   a,
+			"categories": cs,
+			"pagination": p,
+		},
+	}
+}
@@ -0,0 +1,164 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestAuthorProfile(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	if tpl := doAdminPublicProfileAvatarRequest(rUser, "testdata/color.png"); tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	u, err := ctx.UserService.GetByID(2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(u.Avatar) == 0 {
+		t.Fatal("the avatar was not set")
+	}
+
+	profile := &models.User{
+		Bio:         "Writes about free software.",
+		Website:     "https://bob.example.org",
+		SocialLinks: []string{"https://social.example.org/@bob", "", "https://code.example.org/bob"},
+	}
+
+	if tpl := doAdminPublicProfileRequest(rUser, profile, false); tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	profile.Website = "javascript:alert(1)"
+
+	if tpl := doAdminPublicProfileRequest(rUser, profile, false); !hasStatus(tpl.Err, http.StatusUnprocessableEntity) {
+		t.Errorf("expected the status 422 for an invalid website, but got %v", tpl.Err)
+	}
+
+	articleID, err := doAdminCreateArticleRequest(rUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doAdminCreateArticleRequest(rUser, getSampleArticle()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminPublishArticleRequest(rUser, articleID); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := doAuthorRequest("bob")
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	author := tpl.Data["author"].(*models.User)
+
+	if author.Bio != "Writes about free software." || author.Website != "https://bob.example.org" || len(author.SocialLinks) != 2 || author.Avatar != u.Avatar {
+		t.Errorf("unexpected public profile %+v", author)
+	}
+
+	if len(author.Password) > 0 || len(author.TOTPSecret) > 0 {
+		t.Error("the author contains secrets")
+	}
+
+	articles := tpl.Data["articles"].([]models.Article)
+
+	if len(articles) != 1 || articles[0].ID != articleID {
+		t.Errorf("expected only the published article %d, but got %v", articleID, articles)
+	}
+
+	if tpl := doAuthorRequest("mallory"); !hasStatus(tpl.Err, http.StatusNotFound) {
+		t.Errorf("expected the status 404 for a deactivated author, but got %v", tpl.Err)
+	}
+
+	if tpl := doAuthorRequest("nobody"); !hasStatus(tpl.Err, http.StatusNotFound) {
+		t.Errorf("expected the status 404 for an unknown author, but got %v", tpl.Err)
+	}
+
+	if tpl := doAdminPublicProfileRequest(rUser, &models.User{}, true); tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if _, err := ctx.FileService.GetByUniqueName(u.Avatar, nil); err == nil {
+		t.Error("the removed avatar file still exists")
+	}
+}
+
+func doAdminPublicProfileAvatarRequest(user reqUser, file string) *middleware.Template {
+	r := request{
+		url:    "/admin/user/profile/public",
+		user:   user,
+		method: "POST",
+		multipartReq: []multipartRequest{
+			{
+				key:  "file",
+				file: file,
+			},
+		},
+	}
+
+	rw := httptest.NewRecorder()
+
+	return handler.AdminPublicProfilePostHandler(ctx, rw, r.buildRequest())
+}
+
+func doAdminPublicProfileRequest(user reqUser, u *models.User, removeAvatar bool) *middleware.Template {
+	values := url.Values{}
+	addValue(values, "bio", u.Bio)
+	addValue(values, "website", u.Website)
+
+	links := ""
+	for _, l := range u.SocialLinks {
+		links += l + "\r\n"
+	}
+
+	addValue(values, "social_links", links)
+
+	if removeAvatar {
+		addValue(values, "remove_avatar", "on")
+	}
+
+	r := request{
+		url:    "/admin/user/profile/public",
+		user:   user,
+		method: "POST",
+		values: values,
+	}
+
+	rw := httptest.NewRecorder()
+
+	return handler.AdminPublicProfilePostHandler(ctx, rw, r.buildRequest())
+}
+
+func doAuthorRequest(username string) *middleware.Template {
+	r := request{
+		url:    "/author/" + username,
+		user:   rGuest,
+		method: "GET",
+		pathVar: []pathVar{
+			{
+				key:   "username",
+				value: username,
+			},
+		},
+	}
+
+	rw := httptest.NewRecorder()
+
+	return handler.AuthorHandler(ctx, rw, r.buildRequest())
+}
@@ -152,7 +152,7 @@ func AdminCategoryEditPostHandler(ctx *middleware.AppContext, w http.ResponseWri
 		Author: u,
 	}
 
-	if err = ctx.CategoryService.Update(c); err != nil {
+	if err = ctx.CategoryService.Update(c, u); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminCategoryEdit,
 			Err:    err,
@@ -213,6 +213,8 @@ func AdminCategoryDeleteHandler(ctx *middleware.AppContext, w http.ResponseWrite
 
 // AdminCategoryDeletePostHandler handles the removing of a category
 func AdminCategoryDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	u, _ := middleware.User(r)
+
 	reqVar := getVar(r, "categoryID")
 
 	id, err := parseInt(reqVar)
@@ -224,7 +226,7 @@ func AdminCategoryDeletePostHandler(ctx *middleware.AppContext, w http.ResponseW
 		}
 	}
 
-	err = ctx.CategoryService.Delete(id)
+	err = ctx.CategoryService.Delete(id, u)
 
 	if err != nil {
 		return &middleware.Template{
@@ -3,9 +3,11 @@ package handler
 const (
 	tplArticle       = "front/article"
 	tplArticles      = "front/articles"
+	tplAuthor        = "front/author"
 	tplIndexArticles = "front/index"
 
-	tplAdminLogin = "admin/login"
+	tplAdminLogin          = "admin/login"
+	tplAdminLoginTwoFactor = "admin/login_two_factor"
 
 	tplAdminArticles    = "admin/articles"
 	tplAdminArticleNew  = "admin/article_add"
@@ -18,6 +20,10 @@ const (
 	tplAdminForgotPassword  = "admin/forgot_password"
 	tplAdminResetPassword   = "admin/reset_password"
 	tplAdminActivateAccount = "admin/activate_account"
+	tplAdminInviteExpired   = "admin/invite_expired"
+	tplAdminEmailToken      = "admin/email_token"
+	tplAdminAccountDelete   = "admin/account_delete"
+	tplAdminSecureAccount   = "admin/secure_account"
 
 	tplAdminFiles      = "admin/files"
 	tplAdminFileUpload = "admin/file_upload"
@@ -35,4 +41,7 @@ const (
 	tplAdminUserNew       = "admin/user_add"
 	tplAdminProfile       = "admin/user_profile"
 	tplAdminUserInviteNew = "admin/user_invite_add"
+	tplAdminTwoFactor     = "admin/two_factor"
+
+	tplAdminAudit = "admin/audit"
 )
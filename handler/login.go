@@ -5,18 +5,26 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"git.hoogi.eu/snafu/go-blog/httperror"
 	"git.hoogi.eu/snafu/go-blog/logger"
 
 	"git.hoogi.eu/snafu/go-blog/middleware"
 	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
 )
 
+var errPasswordLoginDisabled = httperror.New(http.StatusForbidden,
+	"The login with password is disabled. Please use the single sign-on.",
+	errors.New("the password login is disabled"))
+
 // LoginHandler shows the login form;
 // if the user is already logged in the user will be redirected to the administration articles page
 func LoginHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
-	_, err := ctx.SessionService.Get(rw, r)
+	session, err := ctx.SessionService.Get(rw, r)
 
 	if err != nil {
 		return &middleware.Template{
@@ -24,6 +32,12 @@ func LoginHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if _, ok := session.GetValue("userid").(int); !ok {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+		}
+	}
+
 	return &middleware.Template{
 		RedirectPath: "admin/articles",
 	}
@@ -33,6 +47,10 @@ func LoginHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Re
 // LoginPostHandler receives the login information from the form; checks the login and
 // starts a session for the user. The session will be stored in a cookie
 func LoginPostHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := passwordLoginDisabled(ctx); t != nil {
+		return t
+	}
+
 	if err := r.ParseForm(); err != nil {
 		return &middleware.Template{
 			Name: tplAdminLogin,
@@ -54,9 +72,30 @@ func LoginPostHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *htt
 		PlainPassword: password,
 	}
 
+	ip := middleware.GetIP(r)
+
+	if err := ctx.LoginThrottleService.CheckLogin(username, ip); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+			Data: map[string]interface{}{
+				"user": u,
+			},
+		}
+	}
+
 	user, err := ctx.UserService.Authenticate(u, ctx.ConfigService.LoginMethod)
 
 	if err != nil {
+		recordLogin(ctx, rw, r, &models.User{Username: username}, models.AuditLoginFailure, "password")
+
+		var he *httperror.Error
+
+		// only wrong credentials are counted, not e.g. deactivated accounts or an unreachable directory server
+		if errors.As(err, &he) && he.HTTPStatus == http.StatusUnauthorized {
+			loginFailed(ctx, username, ip)
+		}
+
 		return &middleware.Template{
 			Name:   tplAdminLogin,
 			Active: "users",
@@ -67,14 +106,95 @@ func LoginPostHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *htt
 		}
 	}
 
-	session := ctx.SessionService.Create(rw, r)
+	return startLoginSession(ctx, rw, r, ctx.SessionService.Create(rw, r), user, redirectTo, "password")
+}
+
+// loginFailed counts the failed login; the user is notified by mail if the account was locked by this failure
+func loginFailed(ctx *middleware.AppContext, login, ip string) {
+	locked, err := ctx.LoginThrottleService.LoginFailed(login, ip)
+
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+
+	if !locked {
+		return
+	}
+
+	var u *models.User
+
+	if ctx.ConfigService.LoginMethod == settings.EMail {
+		u, err = ctx.UserService.GetByMail(login)
+	} else {
+		u, err = ctx.UserService.GetByUsername(login)
+	}
+
+	if err != nil {
+		logger.Log.Warnf("the login %s was locked after too many failures, but no user was found %v", login, err)
+		return
+	}
+
+	logger.Log.Warnf("the account of the user %s was locked after too many failed logins from %s", u.Username, ip)
+
+	ctx.Mailer.SendAccountLocked(u, ctx.ConfigService.User.LoginLockoutDuration)
+}
+
+// loginName returns the name the user logs in with; the failed logins are counted by this name
+func loginName(ctx *middleware.AppContext, user *models.User) string {
+	if ctx.ConfigService.LoginMethod == settings.EMail {
+		return user.Email
+	}
+
+	return user.Username
+}
+
+// startLoginSession logs the authenticated user in with the new session;
+// if two-factor authentication is enabled the user is redirected to enter the code first
+func startLoginSession(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request, session *models.Session, user *models.User, redirectTo, method string) *middleware.Template {
+	if user.TOTPEnabled {
+		session.SetValue("two_factor_userid", user.ID)
+		session.SetValue("two_factor_started", time.Now().Unix())
+		session.SetValue("two_factor_attempts", 0)
+		session.SetValue("two_factor_state", redirectTo)
+
+		return &middleware.Template{
+			RedirectPath: "admin/login/two-factor",
+		}
+	}
+
 	session.SetValue("userid", user.ID)
 
+	recordLogin(ctx, w, r, user, models.AuditLogin, method)
+
 	return &middleware.Template{
 		RedirectPath: redirectTo,
 	}
 }
 
+// recordLogin records a login or a failed login attempt with the used login method in the audit log; on a login the
+// failed logins are reset, the last login of the user is updated and the user is notified about a login from an
+// unfamiliar device
+func recordLogin(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request, user *models.User, action, method string) {
+	user.RemoteAddr = middleware.GetIP(r)
+
+	ctx.AuditService.Record(user, action, models.AuditUser, user.ID, "", "method="+method)
+
+	if action != models.AuditLogin {
+		return
+	}
+
+	if err := ctx.LoginThrottleService.LoginSucceeded(loginName(ctx, user)); err != nil {
+		logger.Log.Error(err)
+	}
+
+	if err := ctx.UserService.RecordLogin(user); err != nil {
+		logger.Log.Errorf("could not record the login of user %d %v", user.ID, err)
+	}
+
+	recognizeDevice(ctx, w, r, user)
+}
+
 // LogoutHandler logs the user out by removing the cookie and removing the session from the session store
 func LogoutHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
 	if err := ctx.SessionService.Remove(rw, r); err != nil {
@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/mail"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	for i := 0; i < 4; i++ {
+		if _, err := doLoginRequest(rGuest, "bob", "wrong-password"); !hasStatus(err, http.StatusUnauthorized) {
+			t.Fatalf("expected the status 401 for the failure %d, but got %v", i+1, err)
+		}
+	}
+
+	// even the correct password is rejected while the login is delayed
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 after four failures, but got %v", err)
+	}
+
+	// the client ip address is delayed as well
+	if _, err := doLoginRequest(rGuest, "alice", "123456789012"); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 for another login from the same address, but got %v", err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	ctx.LoginThrottleService.Config.LoginMaxFailures = 3
+	ctx.LoginThrottleService.Config.LoginBackoffMax = 0
+
+	for i := 0; i < 3; i++ {
+		if _, err := doLoginRequest(rGuest, "bob", "wrong-password"); !hasStatus(err, http.StatusUnauthorized) {
+			t.Fatalf("expected the status 401 for the failure %d, but got %v", i+1, err)
+		}
+	}
+
+	if len(sender.mails) != 1 || sender.mails[0].To != "bob@example.org" {
+		t.Errorf("expected a mail to bob about the locked account, but got %v", sender.mails)
+	}
+
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 for a locked account, but got %v", err)
+	}
+
+	tpl := handler.AdminUsersHandler(ctx, httptest.NewRecorder(), request{url: "/admin/users", user: rAdminUser, method: "GET"}.buildRequest())
+
+	locked, _ := tpl.Data["locked_logins"].([]models.LoginThrottle)
+
+	if len(locked) != 1 || locked[0].Login() != "bob" {
+		t.Fatalf("expected bob as locked account, but got %v", locked)
+	}
+
+	values := url.Values{}
+	addValue(values, "login", "bob")
+
+	r := request{
+		url:    "/admin/user/unlock",
+		user:   rAdminUser,
+		method: "POST",
+		values: values,
+	}
+
+	if tpl := handler.AdminUserUnlockPostHandler(ctx, httptest.NewRecorder(), r.buildRequest()); tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); err != nil {
+		t.Errorf("expected a successful login after unlocking the account, but got %v", err)
+	}
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	var err error
+
+	for i := 0; i < 5 && err == nil; i++ {
+		values := url.Values{}
+		addValue(values, "email", "bob@example.org")
+
+		r := request{
+			url:    "/admin/forgot-password",
+			user:   rGuest,
+			method: "POST",
+			values: values,
+		}
+
+		err = handler.ForgotPasswordPostHandler(ctx, httptest.NewRecorder(), r.buildRequest()).Err
+	}
+
+	if !hasStatus(err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 after too many password reset requests, but got %v", err)
+	}
+
+	r := request{
+		url:     "/admin/reset-password/invalid",
+		user:    rGuest,
+		method:  "GET",
+		pathVar: []pathVar{{key: "hash", value: "invalid"}},
+	}
+
+	if tpl := handler.ResetPasswordHandler(ctx, httptest.NewRecorder(), r.buildRequest()); !hasStatus(tpl.Err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 for a reset token, but got %v", tpl.Err)
+	}
+}
+
+func hasStatus(err error, status int) bool {
+	var e *httperror.Error
+	return errors.As(err, &e) && e.HTTPStatus == status
+}
+
+type recordingSender struct {
+	mails []mail.Mail
+}
+
+func (rs *recordingSender) Send(m mail.Mail) error {
+	rs.mails = append(rs.mails, m)
+	return nil
+}
+
+func (rs *recordingSender) SendAsync(m mail.Mail) {
+	rs.mails = append(rs.mails, m)
+}
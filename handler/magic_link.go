@@ -0,0 +1,249 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+const (
+	magicLinkExpiry = 15 * time.Minute
+	magicLinkCookie = "magic-link"
+	magicLinkPath   = "/admin/login/magic-link"
+	// magicLinkNonces is the maximum number of nonces kept in the cookie
+	magicLinkNonces = 4
+)
+
+var errMagicLinkDisabled = httperror.New(http.StatusForbidden,
+	"The login with a sign-in link is disabled.",
+	errors.New("the magic link login is disabled"))
+
+// MagicLinkPostHandler sends a sign-in link to the email address; the link can only be used in the browser which
+// requested it, so a nonce is stored in a cookie and its hash along with the token
+// The cookie is set for every request, so the response does not tell if an account exists; it keeps the nonces of
+// the previous requests, so a pending link is still valid after a request for another email address
+func MagicLinkPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if !ctx.ConfigService.User.MagicLinkLogin {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  errMagicLinkDisabled,
+		}
+	}
+
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	// every request counts, so sign-in links can not be used to flood the mailboxes
+	countRequest(ctx, r)
+
+	email := r.FormValue("email")
+
+	nonce := crypt.RandomHash(32)
+
+	// the response does not tell if an account with the email address exists
+	sent := &middleware.Template{
+		Name:       tplAdminLogin,
+		SuccessMsg: fmt.Sprintf("If an account with the email address '%s' exists, a sign-in link is on the way. Please open it in this browser.", email),
+	}
+
+	nonces := append([]string{nonce}, magicLinkNoncesOf(r)...)
+
+	if len(nonces) > magicLinkNonces {
+		nonces = nonces[:magicLinkNonces]
+	}
+
+	setMagicLinkCookie(ctx, w, nonces)
+
+	u, err := ctx.UserService.GetByMail(email)
+
+	if err != nil {
+		var e *httperror.Error
+		if errors.As(err, &e) && errors.Is(e.Err, sql.ErrNoRows) {
+			logger.Log.Debugf("a sign-in link was requested for an unknown email address %v", err)
+			return sent
+		}
+
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	if !u.Active {
+		logger.Log.Warnf("a sign-in link was requested for the deactivated user %s", u.Username)
+		return sent
+	}
+
+	if err := ctx.TokenService.RateLimit(u.ID, models.MagicLink); err != nil {
+		logger.Log.Error(err)
+		return sent
+	}
+
+	if err := ctx.TokenService.RemoveByUser(u.ID, models.MagicLink); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	t := &models.Token{
+		Author:  u,
+		Type:    models.MagicLink,
+		Payload: crypt.Hash([]byte(nonce)),
+	}
+
+	if err := ctx.TokenService.Create(t); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	ctx.Mailer.SendMagicLink(u, t, magicLinkExpiry)
+
+	return sent
+}
+
+// magicLinkNoncesOf returns the nonces of the magic link cookie of the request
+func magicLinkNoncesOf(r *http.Request) []string {
+	c, err := r.Cookie(magicLinkCookie)
+
+	if err != nil || len(c.Value) == 0 {
+		return nil
+	}
+
+	return strings.Split(c.Value, ".")
+}
+
+// setMagicLinkCookie sets the cookie with the nonces or removes it if there are no nonces left
+func setMagicLinkCookie(ctx *middleware.AppContext, w http.ResponseWriter, nonces []string) {
+	if len(nonces) == 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:    magicLinkCookie,
+			Path:    magicLinkPath,
+			MaxAge:  -1,
+			Expires: time.Unix(1, 0),
+		})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    strings.Join(nonces, "."),
+		Path:     magicLinkPath,
+		MaxAge:   int(magicLinkExpiry.Seconds()),
+		Secure:   ctx.ConfigService.Session.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// MagicLinkHandler logs the user in with the sign-in link; the link is valid once and only in the requesting browser
+func MagicLinkHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if !ctx.ConfigService.User.MagicLinkLogin {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  errMagicLinkDisabled,
+		}
+	}
+
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, err := ctx.TokenService.Get(hash, models.MagicLink, magicLinkExpiry)
+
+	if err != nil {
+		countRequest(ctx, r)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			err = httperror.New(http.StatusNotFound, "The sign-in link is invalid or was already used. Please request a new one.", errors.New("the magic link token was not found"))
+		}
+
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	// the token is not removed on a mismatch, so it can not be consumed by opening the link elsewhere e.g. by a link scanner
+	nonces := magicLinkNoncesOf(r)
+	match := -1
+
+	for i, n := range nonces {
+		if subtle.ConstantTimeCompare([]byte(crypt.Hash([]byte(n))), []byte(t.Payload)) == 1 {
+			match = i
+		}
+	}
+
+	if match < 0 {
+		countRequest(ctx, r)
+
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err: httperror.New(http.StatusForbidden, "Please open the sign-in link in the browser where you requested it.",
+				fmt.Errorf("the nonce of the magic link of user %d does not match", t.Author.ID)),
+		}
+	}
+
+	if err := ctx.TokenService.Remove(hash, models.MagicLink); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	setMagicLinkCookie(ctx, w, append(nonces[:match], nonces[match+1:]...))
+
+	u, err := ctx.UserService.GetByID(t.Author.ID)
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	login := u.Username
+
+	if ctx.ConfigService.LoginMethod == settings.EMail {
+		login = u.Email
+	}
+
+	if err := ctx.LoginThrottleService.CheckLogin(login, middleware.GetIP(r)); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	if !u.Active {
+		recordLogin(ctx, w, r, u, models.AuditLoginFailure, "magic_link")
+
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err: httperror.New(http.StatusUnauthorized, "Your account is deactivated.",
+				fmt.Errorf("the user with id %d tried to logged in with a magic link but the account is deactivated", u.ID)),
+		}
+	}
+
+	return startLoginSession(ctx, w, r, ctx.SessionService.Create(w, r), u, "admin/articles", "magic_link")
+}
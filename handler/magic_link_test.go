@@ -0,0 +1,154 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestMagicLinkLogin(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	if _, tpl := doMagicLinkRequest("bob@example.org", nil); !hasStatus(tpl.Err, http.StatusForbidden) {
+		t.Fatalf("expected the status 403 if the sign-in links are disabled, but got %v", tpl.Err)
+	}
+
+	ctx.ConfigService.User.MagicLinkLogin = true
+	// the requests are counted by the throttle, they should not be delayed
+	ctx.LoginThrottleService.Config.LoginBackoffMax = 0
+
+	nonce, tpl := doMagicLinkRequest("bob@example.org", nil)
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if len(sender.mails) != 1 || sender.mails[0].To != "bob@example.org" {
+		t.Fatalf("expected a sign-in link to bob@example.org, but got %v", sender.mails)
+	}
+
+	// the response for an unknown email address is not distinguishable and keeps the pending link valid
+	unknown, unknownTpl := doMagicLinkRequest("nobody@example.org", nonce)
+
+	if unknownTpl.Err != nil {
+		t.Fatalf("expected the same response for an unknown email address, but got %v", unknownTpl.Err)
+	}
+
+	if unknownTpl.Name != tpl.Name || strings.Replace(unknownTpl.SuccessMsg, "nobody@example.org", "bob@example.org", 1) != tpl.SuccessMsg {
+		t.Errorf("expected the same message for an unknown email address, but got '%s' and '%s'", unknownTpl.SuccessMsg, tpl.SuccessMsg)
+	}
+
+	if unknown == nil || unknown.Path != nonce.Path || unknown.MaxAge != nonce.MaxAge || unknown.HttpOnly != nonce.HttpOnly || !strings.HasSuffix(unknown.Value, nonce.Value) {
+		t.Fatalf("expected the same nonce cookie for an unknown email address which keeps the pending nonce, but got %v", unknown)
+	}
+
+	if len(sender.mails) != 1 {
+		t.Fatalf("expected no mail for an unknown email address, but got %v", sender.mails)
+	}
+
+	nonce = unknown
+
+	tokens, err := ctx.TokenService.ListByUser(2, models.MagicLink)
+
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected one sign-in token, but got %v %v", tokens, err)
+	}
+
+	hash := tokens[0].Hash
+
+	if _, tpl := doMagicLinkLoginRequest(hash, nil); !hasStatus(tpl.Err, http.StatusForbidden) {
+		t.Fatalf("expected the status 403 for a sign-in link opened in another browser, but got %v", tpl.Err)
+	}
+
+	rw, tpl := doMagicLinkLoginRequest(hash, nonce)
+
+	if tpl.Err != nil {
+		t.Fatalf("the sign-in link was not accepted in the requesting browser %v", tpl.Err)
+	}
+
+	if tpl.RedirectPath != "admin/articles" {
+		t.Errorf("expected a redirect to admin/articles, but got %s", tpl.RedirectPath)
+	}
+
+	if !hasCookie(rw, "test-session") {
+		t.Error("no session was started by the sign-in link")
+	}
+
+	if _, tpl := doMagicLinkLoginRequest(hash, nonce); !hasStatus(tpl.Err, http.StatusNotFound) {
+		t.Errorf("expected the status 404 for an used sign-in link, but got %v", tpl.Err)
+	}
+}
+
+func doMagicLinkRequest(email string, nonce *http.Cookie) (*http.Cookie, *middleware.Template) {
+	values := url.Values{}
+	addValue(values, "email", email)
+
+	r := request{
+		url:    "/admin/login/magic-link",
+		user:   rGuest,
+		method: "POST",
+		values: values,
+	}
+
+	req := r.buildRequest()
+
+	if nonce != nil {
+		req.AddCookie(nonce)
+	}
+
+	rw := httptest.NewRecorder()
+	tpl := handler.MagicLinkPostHandler(ctx, rw, req)
+
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == "magic-link" {
+			return c, tpl
+		}
+	}
+
+	return nil, tpl
+}
+
+func doMagicLinkLoginRequest(hash string, nonce *http.Cookie) (*httptest.ResponseRecorder, *middleware.Template) {
+	r := request{
+		url:    "/admin/login/magic-link/" + hash,
+		user:   rGuest,
+		method: "GET",
+		pathVar: []pathVar{
+			{
+				key:   "hash",
+				value: hash,
+			},
+		},
+	}
+
+	req := r.buildRequest()
+
+	if nonce != nil {
+		req.AddCookie(nonce)
+	}
+
+	rw := httptest.NewRecorder()
+
+	return rw, handler.MagicLinkHandler(ctx, rw, req)
+}
+
+func hasCookie(rw *httptest.ResponseRecorder, name string) bool {
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
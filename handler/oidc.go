@@ -0,0 +1,117 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// OIDCHandler starts the single sign-on; it is no template handler as the user is redirected to the identity provider
+type OIDCHandler struct {
+	Context *middleware.AppContext
+}
+
+// LoginHandler starts the login at the identity provider; the values to verify the callback are kept in a new session
+func (oh OIDCHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	url, l, err := oh.Context.OIDCService.Begin(r.Context())
+
+	if err != nil {
+		middleware.TemplateHandler{
+			AppCtx: oh.Context,
+			Handler: func(*middleware.AppContext, http.ResponseWriter, *http.Request) *middleware.Template {
+				return &middleware.Template{
+					Name: tplAdminLogin,
+					Err:  err,
+				}
+			},
+		}.ServeHTTP(w, r)
+		return
+	}
+
+	session := oh.Context.SessionService.Create(w, r)
+
+	session.SetValue("oidc_state", l.State)
+	session.SetValue("oidc_nonce", l.Nonce)
+	session.SetValue("oidc_verifier", l.Verifier)
+	session.SetValue("oidc_redirect", r.URL.Query().Get("state"))
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// OIDCCallbackHandler receives the authorization code from the identity provider and logs in the user
+func OIDCCallbackHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
+	session, err := ctx.SessionService.Get(rw, r)
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", err),
+		}
+	}
+
+	state, _ := session.GetValue("oidc_state").(string)
+	nonce, _ := session.GetValue("oidc_nonce").(string)
+	verifier, _ := session.GetValue("oidc_verifier").(string)
+	redirectTo, _ := session.GetValue("oidc_redirect").(string)
+
+	// the values are valid for one callback only
+	session.RemoveKey("oidc_state")
+	session.RemoveKey("oidc_nonce")
+	session.RemoveKey("oidc_verifier")
+	session.RemoveKey("oidc_redirect")
+
+	q := r.URL.Query()
+
+	if e := q.Get("error"); len(e) > 0 {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err: httperror.New(http.StatusUnauthorized, "The login at the identity provider failed.",
+				fmt.Errorf("the identity provider returned the error %s: %s", e, q.Get("error_description"))),
+		}
+	}
+
+	if len(state) == 0 {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", errors.New("no pending oidc login in session")),
+		}
+	}
+
+	l := &models.OIDCLogin{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}
+
+	user, err := ctx.OIDCService.Finish(r.Context(), l, q.Get("state"), q.Get("code"))
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	if len(redirectTo) == 0 {
+		redirectTo = "admin/articles"
+	}
+
+	session, err = ctx.SessionService.Renew(rw, r)
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	return startLoginSession(ctx, rw, r, session, user, redirectTo, "oidc")
+}
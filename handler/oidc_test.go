@@ -0,0 +1,431 @@
+package handler_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	issuer := newMockIssuer(t)
+	enableOIDC(issuer.URL, false, false)
+
+	resp, err := doOIDCLogin(t, issuer, "alice@example.org", true, "admin/sites", false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.template.RedirectPath != "admin/sites" {
+		t.Errorf("expected a redirect to the state 'admin/sites', but got '%s'", resp.template.RedirectPath)
+	}
+
+	if id := sessionUserID(t, resp); id != 1 {
+		t.Errorf("expected the user id 1 in the session, but got %d", id)
+	}
+
+	// the values of the login must not be reused
+	if _, err := doOIDCLogin(t, issuer, "alice@example.org", true, "", true); err == nil {
+		t.Error("expected an error for a replayed callback, but got nil")
+	}
+
+	if _, err := doOIDCLogin(t, issuer, "alice@example.org", false, "", false); err == nil {
+		t.Error("expected an error for an unverified email, but got nil")
+	}
+
+	if _, err := doOIDCLogin(t, issuer, "mallory@example.org", true, "", false); err == nil {
+		t.Error("expected an error for a deactivated user, but got nil")
+	}
+}
+
+func TestOIDCMissingEmailVerified(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	issuer := newMockIssuer(t)
+	issuer.omitEmailVerified = true
+
+	enableOIDC(issuer.URL, false, false)
+
+	if _, err := doOIDCLogin(t, issuer, "alice@example.org", true, "", false); !hasStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("expected the status 401 for an id token without the email_verified claim, but got %v", err)
+	}
+
+	ctx.OIDCService.Config.TrustUnverifiedEmail = true
+
+	resp, err := doOIDCLogin(t, issuer, "alice@example.org", true, "", false)
+
+	if err != nil {
+		t.Fatalf("expected the missing email_verified claim to be accepted if configured, but got %v", err)
+	}
+
+	if id := sessionUserID(t, resp); id != 1 {
+		t.Errorf("expected the user id 1 in the session, but got %d", id)
+	}
+
+	issuer.omitEmailVerified = false
+
+	if _, err := doOIDCLogin(t, issuer, "alice@example.org", false, "", false); !hasStatus(err, http.StatusUnauthorized) {
+		t.Errorf("expected the status 401 for an unverified email even if configured, but got %v", err)
+	}
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	issuer := newMockIssuer(t)
+	enableOIDC(issuer.URL, false, false)
+
+	rr := httptest.NewRecorder()
+	handler.OIDCHandler{Context: ctx}.LoginHandler(rr, httptest.NewRequest("GET", "/admin/login/oidc", nil))
+
+	loc, err := url.Parse(rr.Header().Get("Location"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := issuer.authorize(t, loc.Query(), "alice@example.org", true)
+
+	r := httptest.NewRequest("GET", models.OIDCCallbackPath+"?state=forged&code="+code, nil)
+	r.AddCookie(rr.Result().Cookies()[0])
+
+	tpl := handler.OIDCCallbackHandler(ctx, httptest.NewRecorder(), r)
+
+	if tpl.Err == nil {
+		t.Fatal("expected an error for a forged state, but got nil")
+	}
+
+	if e, ok := tpl.Err.(*httperror.Error); !ok || e.HTTPStatus != http.StatusUnauthorized {
+		t.Errorf("expected the status 401, but got %v", tpl.Err)
+	}
+}
+
+func TestOIDCAutoProvision(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	issuer := newMockIssuer(t)
+	enableOIDC(issuer.URL, false, false)
+
+	if _, err := doOIDCLogin(t, issuer, "carol@example.org", true, "", false); err == nil {
+		t.Fatal("expected an error for an unknown user without auto provisioning, but got nil")
+	}
+
+	enableOIDC(issuer.URL, true, false)
+
+	resp, err := doOIDCLogin(t, issuer, "carol@example.org", true, "", false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := ctx.UserService.GetByMail("carol@example.org")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Role != models.RoleAuthor || u.Username != "carol" || u.DisplayName != "Carol Lovelace" {
+		t.Errorf("expected the provisioned author carol, but got %s %s %s", u.Role, u.Username, u.DisplayName)
+	}
+
+	if id := sessionUserID(t, resp); id != u.ID {
+		t.Errorf("expected the user id %d in the session, but got %d", u.ID, id)
+	}
+}
+
+func TestOIDCPasswordLoginDisabled(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	issuer := newMockIssuer(t)
+	enableOIDC(issuer.URL, false, true)
+
+	if err := login("alice", "123456789012"); err == nil {
+		t.Error("expected an error for a password login, but got nil")
+	}
+
+	values := url.Values{}
+	addValue(values, "email", "alice@example.org")
+
+	r := request{
+		url:    "/admin/forgot-password",
+		method: "POST",
+		user:   rGuest,
+		values: values,
+	}
+
+	if tpl := handler.ForgotPasswordPostHandler(ctx, httptest.NewRecorder(), r.buildRequest()); tpl.Err == nil {
+		t.Error("expected an error for a password reset, but got nil")
+	}
+
+	if _, err := doOIDCLogin(t, issuer, "alice@example.org", true, "", false); err != nil {
+		t.Error(err)
+	}
+}
+
+func enableOIDC(issuer string, autoProvision, disablePasswordLogin bool) {
+	ctx.ConfigService.OIDC.Enabled = true
+	ctx.ConfigService.OIDC.Issuer = issuer
+	ctx.ConfigService.OIDC.ClientID = "go-blog"
+	ctx.ConfigService.OIDC.ClientSecret = "secret"
+	ctx.ConfigService.OIDC.AutoProvision = autoProvision
+	ctx.ConfigService.OIDC.DisablePasswordLogin = disablePasswordLogin
+
+	ctx.OIDCService = &models.OIDCService{
+		UserService: ctx.UserService,
+		AppConfig:   ctx.ConfigService.Application,
+		Config:      ctx.ConfigService.OIDC,
+	}
+}
+
+// doOIDCLogin runs the login with the mock issuer; if replay is set the callback is sent twice and the result of the second one is returned
+func doOIDCLogin(t *testing.T, issuer *mockIssuer, email string, verified bool, state string, replay bool) (responseWrapper, error) {
+	rr := httptest.NewRecorder()
+	handler.OIDCHandler{Context: ctx}.LoginHandler(rr, httptest.NewRequest("GET", "/admin/login/oidc?state="+url.QueryEscape(state), nil))
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to the identity provider, but got %d", rr.Code)
+	}
+
+	loc, err := url.Parse(rr.Header().Get("Location"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := issuer.authorize(t, loc.Query(), email, verified)
+
+	callback := func() (responseWrapper, error) {
+		r := httptest.NewRequest("GET", models.OIDCCallbackPath+"?state="+url.QueryEscape(loc.Query().Get("state"))+"&code="+code, nil)
+		r.AddCookie(rr.Result().Cookies()[0])
+
+		cr := httptest.NewRecorder()
+		tpl := handler.OIDCCallbackHandler(ctx, cr, r)
+
+		return responseWrapper{response: cr, template: tpl}, tpl.Err
+	}
+
+	if replay {
+		if _, err := callback(); err != nil {
+			return responseWrapper{}, err
+		}
+	}
+
+	return callback()
+}
+
+func sessionUserID(t *testing.T, resp responseWrapper) int {
+	c, err := resp.getCookie("test-session")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/admin", nil)
+	r.AddCookie(c)
+
+	s, err := ctx.SessionService.Get(httptest.NewRecorder(), r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := s.GetValue("userid").(int)
+
+	return id
+}
+
+// mockIssuer is a minimal OpenID Connect provider issuing RS256 signed ID tokens
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mutex sync.Mutex
+	codes map[string]mockGrant
+
+	// omitEmailVerified removes the email_verified claim from the id tokens
+	omitEmailVerified bool
+}
+
+type mockGrant struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mi := &mockIssuer{
+		key:   key,
+		codes: make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", mi.discovery)
+	mux.HandleFunc("/keys", mi.keys)
+	mux.HandleFunc("/token", mi.token)
+
+	mi.Server = httptest.NewServer(mux)
+	t.Cleanup(mi.Close)
+
+	return mi
+}
+
+// authorize simulates the login of the user at the authorization endpoint and returns the authorization code
+func (mi *mockIssuer) authorize(t *testing.T, q url.Values, email string, verified bool) string {
+	if q.Get("code_challenge_method") != "S256" || len(q.Get("code_challenge")) == 0 {
+		t.Fatalf("expected a PKCE S256 challenge, but got %v", q)
+	}
+
+	if q.Get("redirect_uri") != ctx.ConfigService.Application.Domain+models.OIDCCallbackPath {
+		t.Fatalf("expected the redirect uri to the callback, but got %s", q.Get("redirect_uri"))
+	}
+
+	user := email[:len(email)-len("@example.org")]
+
+	code := hex.EncodeToString([]byte(email + time.Now().String()))
+
+	mi.mutex.Lock()
+	defer mi.mutex.Unlock()
+
+	mi.codes[code] = mockGrant{
+		challenge: q.Get("code_challenge"),
+		claims: map[string]interface{}{
+			"iss":                mi.URL,
+			"sub":                user,
+			"aud":                q.Get("client_id"),
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(5 * time.Minute).Unix(),
+			"nonce":              q.Get("nonce"),
+			"email":              email,
+			"email_verified":     verified,
+			"name":               "Carol Lovelace",
+			"preferred_username": user,
+		},
+	}
+
+	if mi.omitEmailVerified {
+		delete(mi.codes[code].claims, "email_verified")
+	}
+
+	return code
+}
+
+func (mi *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                mi.URL,
+		"authorization_endpoint":                mi.URL + "/authorize",
+		"token_endpoint":                        mi.URL + "/token",
+		"jwks_uri":                              mi.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (mi *mockIssuer) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(mi.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(mi.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (mi *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mi.mutex.Lock()
+	grant, ok := mi.codes[r.PostFormValue("code")]
+	delete(mi.codes, r.PostFormValue("code"))
+	mi.mutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := mi.sign(grant.claims)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (mi *mockIssuer) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, mi.key, crypto.SHA256, sum[:])
+
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
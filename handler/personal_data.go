@@ -0,0 +1,170 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// PersonalDataHandler serves the export of the personal data
+type PersonalDataHandler struct {
+	Context *middleware.AppContext
+}
+
+// ExportHandler sends the personal data of the currently logged-in user as ZIP archive
+func (ph PersonalDataHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := middleware.User(r)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=personal-data-%d-%s.zip", u.ID, time.Now().Format("2006-01-02")))
+
+	if err := ph.Context.PersonalDataService.Export(w, u); err != nil {
+		logger.Log.Errorf("could not export the personal data of user %d %v", u.ID, err)
+	}
+}
+
+// AdminAccountDeleteHandler returns the form to remove the account of the currently logged-in user
+func AdminAccountDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	u, _ := middleware.User(r)
+
+	successors, err := accountSuccessors(ctx, u)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAccountDelete,
+			Active: "profile",
+			Err:    err,
+		}
+	}
+
+	return &middleware.Template{
+		Name:   tplAdminAccountDelete,
+		Active: "profile",
+		Data: map[string]interface{}{
+			"successors": successors,
+		},
+	}
+}
+
+// AdminAccountDeletePostHandler removes the account of the currently logged-in user; the articles, sites and files
+// are transferred to the chosen successor or removed along with the account
+func AdminAccountDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	ctxUser, _ := middleware.User(r)
+
+	successors, err := accountSuccessors(ctx, ctxUser)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAccountDelete,
+			Active: "profile",
+			Err:    err,
+		}
+	}
+
+	data := map[string]interface{}{
+		"successors": successors,
+	}
+
+	if !convertCheckbox(r, "confirm") {
+		return &middleware.Template{
+			Name:   tplAdminAccountDelete,
+			Active: "profile",
+			Err: httperror.New(http.StatusUnprocessableEntity, "Please confirm that your account should be removed.",
+				errors.New("the removal of the account was not confirmed")),
+			Data: data,
+		}
+	}
+
+	if ctx.ConfigService.OIDC.PasswordLoginEnabled() {
+		ctxUser.PlainPassword = []byte(r.FormValue("current_password"))
+
+		if _, err := ctx.UserService.Authenticate(ctxUser, ctx.ConfigService.LoginMethod); err != nil {
+			return &middleware.Template{
+				Name:   tplAdminAccountDelete,
+				Active: "profile",
+				Err:    httperror.New(http.StatusUnauthorized, "Your current password is invalid.", err),
+				Data:   data,
+			}
+		}
+	}
+
+	var successor *models.User
+
+	if v := r.FormValue("successor"); len(v) > 0 && v != "0" {
+		successorID, err := parseInt(v)
+
+		if err != nil {
+			return &middleware.Template{
+				Name:   tplAdminAccountDelete,
+				Active: "profile",
+				Err:    err,
+				Data:   data,
+			}
+		}
+
+		successor, err = ctx.UserService.GetByID(successorID)
+
+		if err != nil {
+			return &middleware.Template{
+				Name:   tplAdminAccountDelete,
+				Active: "profile",
+				Err:    err,
+				Data:   data,
+			}
+		}
+	}
+
+	ctxUser.RemoteAddr = middleware.GetIP(r)
+
+	if err := ctx.PersonalDataService.RemoveAccount(ctxUser, successor, ctxUser); err != nil {
+		return &middleware.Template{
+			Name:   tplAdminAccountDelete,
+			Active: "profile",
+			Err:    err,
+			Data:   data,
+		}
+	}
+
+	if err := ctx.SessionService.Remove(w, r); err != nil {
+		logger.Log.Infof("could not remove the session of the removed user %d %v", ctxUser.ID, err)
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin",
+		SuccessMsg:   "Your account was successfully removed.",
+	}
+}
+
+// accountSuccessors returns the active users which can take over the content of the user
+func accountSuccessors(ctx *middleware.AppContext, u *models.User) ([]models.User, error) {
+	users, err := ctx.UserService.List(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	successors := []models.User{}
+
+	for _, s := range users {
+		if s.Active && s.ID != u.ID {
+			successors = append(successors, s)
+		}
+	}
+
+	return successors, nil
+}
@@ -0,0 +1,218 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestPersonalDataExport(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	if _, err := doAdminCreateArticleRequest(rUser, getSampleArticle()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doAdminCreateArticleRequest(rAdminUser, getSampleArticle()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doAdminSiteCreateRequest(rUser, &models.Site{Title: "about", Link: "about/bob", Content: "content", Section: "navigation"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminUploadFileRequest(rUser, "testdata/color.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	rw := doPersonalDataExportRequest(rUser)
+
+	if ct := rw.Result().Header.Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("expected a zip archive, but got %s", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rw.Body.Bytes()), int64(rw.Body.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := map[string]*zip.File{}
+	articles, sites, files := 0, 0, 0
+
+	for _, f := range zr.File {
+		entries[f.Name] = f
+
+		switch {
+		case strings.HasPrefix(f.Name, "articles/"):
+			articles++
+		case strings.HasPrefix(f.Name, "sites/"):
+			sites++
+		case strings.HasPrefix(f.Name, "files/"):
+			files++
+		}
+	}
+
+	if articles != 1 || sites != 1 || files != 1 {
+		t.Errorf("expected one article, site and file of the user, but got %d %d %d", articles, sites, files)
+	}
+
+	pf, ok := entries["profile.json"]
+
+	if !ok {
+		t.Fatal("the profile is missing in the export")
+	}
+
+	rc, err := pf.Open()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rc.Close()
+
+	var profile map[string]interface{}
+
+	if err := json.NewDecoder(rc).Decode(&profile); err != nil {
+		t.Fatal(err)
+	}
+
+	if profile["username"] != "bob" || profile["email"] != "bob@example.org" {
+		t.Errorf("unexpected profile %v", profile)
+	}
+
+	if _, ok := profile["password"]; ok {
+		t.Error("the export contains the password")
+	}
+
+	if _, ok := entries["files.json"]; !ok {
+		t.Error("the file metadata is missing in the export")
+	}
+}
+
+func TestAccountDelete(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	articleID, err := doAdminCreateArticleRequest(rUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tpl := doAccountDeleteRequest(rUser, "123456789012", 1, false); !hasStatus(tpl.Err, http.StatusUnprocessableEntity) {
+		t.Errorf("expected the status 422 for an unconfirmed removal, but got %v", tpl.Err)
+	}
+
+	if tpl := doAccountDeleteRequest(rUser, "wrong", 1, true); !hasStatus(tpl.Err, http.StatusUnauthorized) {
+		t.Errorf("expected the status 401 for an invalid password, but got %v", tpl.Err)
+	}
+
+	if tpl := doAccountDeleteRequest(rUser, "123456789012", 3, true); !hasStatus(tpl.Err, http.StatusUnprocessableEntity) {
+		t.Errorf("expected the status 422 for a deactivated successor, but got %v", tpl.Err)
+	}
+
+	tpl := doAccountDeleteRequest(rUser, "123456789012", 1, true)
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if _, err := ctx.UserService.GetByID(2); !hasStatus(err, http.StatusNotFound) {
+		t.Errorf("expected the removed user to be gone, but got %v", err)
+	}
+
+	a, err := ctx.ArticleService.GetByID(articleID, nil, models.All)
+
+	if err != nil {
+		t.Fatalf("the article was not transferred to the successor %v", err)
+	}
+
+	if a.Author.ID != 1 {
+		t.Errorf("expected the article to belong to the successor, but got the author %d", a.Author.ID)
+	}
+}
+
+func TestAccountDeleteWithContent(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	articleID, err := doAdminCreateArticleRequest(rUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminUploadFileRequest(rUser, "testdata/color.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := doAdminListFilesRequest(rUser)
+
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one file, but got %v %v", files, err)
+	}
+
+	if tpl := doAccountDeleteRequest(rUser, "123456789012", 0, true); tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if _, err := ctx.ArticleService.GetByID(articleID, nil, models.All); !hasStatus(err, http.StatusNotFound) {
+		t.Errorf("expected the article to be removed along with the account, but got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(ctx.ConfigService.File.Location, files[0].UniqueName)); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed from the disk, but got %v", err)
+	}
+}
+
+func doPersonalDataExportRequest(user reqUser) *httptest.ResponseRecorder {
+	r := request{
+		url:    "/admin/user/profile/export",
+		user:   user,
+		method: "GET",
+	}
+
+	rw := httptest.NewRecorder()
+
+	handler.PersonalDataHandler{Context: ctx}.ExportHandler(rw, r.buildRequest())
+
+	return rw
+}
+
+func doAccountDeleteRequest(user reqUser, currentPassword string, successor int, confirm bool) *middleware.Template {
+	values := url.Values{}
+	addValue(values, "current_password", currentPassword)
+	addValue(values, "successor", strconv.Itoa(successor))
+
+	if confirm {
+		addValue(values, "confirm", "on")
+	}
+
+	r := request{
+		url:    "/admin/user/profile/delete",
+		user:   user,
+		method: "POST",
+		values: values,
+	}
+
+	rw := httptest.NewRecorder()
+
+	return handler.AdminAccountDeletePostHandler(ctx, rw, r.buildRequest())
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+)
+
+// RedirectHandler redirects the requests of old paths, e.g. the permalinks of imported posts, permanently to their
+// target; all other requests are passed to the NotFound handler
+type RedirectHandler struct {
+	Context  *middleware.AppContext
+	NotFound http.Handler
+}
+
+func (rh RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		target, err := rh.Context.RedirectService.Target(r.URL.Path)
+
+		if err == nil {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Log.Errorf("could not get the redirect of %s %v", r.URL.Path, err)
+		}
+	}
+
+	rh.NotFound.ServeHTTP(w, r)
+}
@@ -0,0 +1,49 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestRedirect(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	if _, err := ctx.RedirectService.Create(&models.Redirect{Path: "https://blog.example.com/2019/05/hello-world/", Target: "/article/2019/5/hello-world"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ctx.RedirectService.Create(&models.Redirect{Path: "/2019/05/hello-world", Target: "/article/2019/5/other"}); err == nil {
+		t.Error("expected an error as the path is already redirected")
+	}
+
+	rh := handler.RedirectHandler{
+		Context: ctx,
+		NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	}
+
+	for _, c := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/2019/05/hello-world/", http.StatusMovedPermanently, "/article/2019/5/hello-world"},
+		{"/2019/05/hello-world?replytocom=1", http.StatusMovedPermanently, "/article/2019/5/hello-world"},
+		{"/2019/05/unknown", http.StatusNotFound, ""},
+	} {
+		rw := httptest.NewRecorder()
+
+		rh.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+		if rw.Code != c.status || rw.Header().Get("Location") != c.location {
+			t.Errorf("expected the status %d and the location '%s' for %s, but got %d '%s'", c.status, c.location, c.path, rw.Code, rw.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestRolePermissions(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	adminCategoryID, err := doAdminCategoryNewRequest(rAdminUser, &models.Category{Name: "Admin Category"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownCategoryID, err := doAdminCategoryNewRequest(rUser, &models.Category{Name: "Own Category"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// author
+	if err := doAdminCategoryEditRequest(rUser, &models.Category{ID: adminCategoryID, Name: "Changed"}); err == nil {
+		t.Error("expected an error while an author updates a foreign category, but error is nil")
+	}
+
+	if err := doAdminDeleteCategoryRequest(rUser, adminCategoryID); err == nil {
+		t.Error("expected an error while an author removes a foreign category, but error is nil")
+	}
+
+	if err := doAdminCategoryEditRequest(rUser, &models.Category{ID: ownCategoryID, Name: "Changed"}); err != nil {
+		t.Errorf("expected that an author can update an own category, but got %v", err)
+	}
+
+	articleID, err := doAdminCreateArticleRequest(rUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminPublishArticleRequest(rUser, articleID); err != nil {
+		t.Errorf("expected that an author can publish an own article, but got %v", err)
+	}
+
+	// contributor
+	bob := dummyUser()
+	bob.Role = models.RoleContributor
+
+	if err := ctx.UserService.Update(bob, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminPublishArticleRequest(rUser, articleID); err == nil {
+		t.Error("expected an error while a contributor publishes an article, but error is nil")
+	}
+
+	// editor
+	bob.Role = models.RoleEditor
+
+	if err := ctx.UserService.Update(bob, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	adminArticleID, err := doAdminCreateArticleRequest(rAdminUser, getSampleArticle())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doAdminPublishArticleRequest(rUser, adminArticleID); err != nil {
+		t.Errorf("expected that an editor can publish a foreign article, but got %v", err)
+	}
+
+	if err := doAdminCategoryEditRequest(rUser, &models.Category{ID: adminCategoryID, Name: "Changed by editor"}); err != nil {
+		t.Errorf("expected that an editor can update a foreign category, but got %v", err)
+	}
+
+	c, err := doAdminGetCategoryRequest(rAdminUser, adminCategoryID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Author.ID != dummyAdminUser().ID {
+		t.Errorf("expected the author of the category to be kept, but got %d", c.Author.ID)
+	}
+
+	if bob.Can(models.PermUserManage) {
+		t.Error("expected that an editor is not granted to manage users")
+	}
+
+	bob.Role = "superuser"
+
+	if err := ctx.UserService.Update(bob, nil, false); err == nil {
+		t.Error("expected an error while assigning an unknown role, but error is nil")
+	}
+}
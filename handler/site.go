@@ -271,7 +271,9 @@ func AdminSiteOrderHandler(ctx *middleware.AppContext, w http.ResponseWriter, r
 		}
 	}
 
-	if err := ctx.SiteService.Order(siteID, d); err != nil {
+	u, _ := middleware.User(r)
+
+	if err := ctx.SiteService.Order(siteID, d, u); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminSites,
 			Err:    err,
@@ -352,7 +354,9 @@ func AdminSitePublishPostHandler(ctx *middleware.AppContext, w http.ResponseWrit
 		}
 	}
 
-	if err := ctx.SiteService.Publish(siteID); err != nil {
+	u, _ := middleware.User(r)
+
+	if err := ctx.SiteService.Publish(siteID, u); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminSites,
 			Err:    err,
@@ -422,7 +426,9 @@ func AdminSiteDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWrite
 		}
 	}
 
-	err = ctx.SiteService.Delete(siteID)
+	u, _ := middleware.User(r)
+
+	err = ctx.SiteService.Delete(siteID, u)
 	if err != nil {
 		return &middleware.Template{
 			Name:   tplAdminSites,
@@ -0,0 +1,411 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+const (
+	// twoFactorLoginTimeout the time in which the second factor has to be entered after the password was verified
+	twoFactorLoginTimeout = 5 * time.Minute
+	// twoFactorMaxAttempts the maximum number of invalid verification codes before the login has to be restarted
+	twoFactorMaxAttempts = 5
+)
+
+// TwoFactorLoginHandler shows the form for the second login step
+func TwoFactorLoginHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
+	session, err := ctx.SessionService.Get(rw, r)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin",
+		}
+	}
+
+	if _, ok := session.GetValue("two_factor_userid").(int); !ok {
+		return &middleware.Template{
+			RedirectPath: "admin",
+		}
+	}
+
+	return &middleware.Template{
+		Name: tplAdminLoginTwoFactor,
+	}
+}
+
+// TwoFactorLoginPostHandler verifies the TOTP or recovery code of the user, whose password was already checked;
+// if the code is valid the session is renewed and the user is logged in
+func TwoFactorLoginPostHandler(ctx *middleware.AppContext, rw http.ResponseWriter, r *http.Request) *middleware.Template {
+	session, err := ctx.SessionService.Get(rw, r)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin",
+			Err:          httperror.New(http.StatusUnauthorized, "Your login has expired. Please log in again.", err),
+		}
+	}
+
+	userID, ok := session.GetValue("two_factor_userid").(int)
+
+	if !ok {
+		return &middleware.Template{
+			RedirectPath: "admin",
+			Err:          httperror.New(http.StatusUnauthorized, "Please provide login credentials.", errors.New("no pending two-factor login in session")),
+		}
+	}
+
+	started, _ := session.GetValue("two_factor_started").(int64)
+	attempts, _ := session.GetValue("two_factor_attempts").(int)
+
+	if time.Since(time.Unix(started, 0)) > twoFactorLoginTimeout || attempts >= twoFactorMaxAttempts {
+		if err := ctx.SessionService.Remove(rw, r); err != nil {
+			logger.Log.Error(err)
+		}
+
+		return &middleware.Template{
+			RedirectPath: "admin",
+			Err: httperror.New(http.StatusUnauthorized, "Your login has expired. Please log in again.",
+				fmt.Errorf("two-factor login of user %d expired after %d attempts", userID, attempts)),
+		}
+	}
+
+	user, err := ctx.UserService.GetByID(userID)
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLoginTwoFactor,
+			Err:  err,
+		}
+	}
+
+	ip := middleware.GetIP(r)
+
+	// the failed codes are counted like failed passwords, the second factor can't be guessed by restarting the login
+	if err := ctx.LoginThrottleService.CheckLogin(loginName(ctx, user), ip); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLoginTwoFactor,
+			Err:  err,
+		}
+	}
+
+	if err := ctx.TwoFactorService.Verify(user, r.PostFormValue("code")); err != nil {
+		session.SetValue("two_factor_attempts", attempts+1)
+
+		recordLogin(ctx, rw, r, user, models.AuditLoginFailure, "two-factor")
+
+		var he *httperror.Error
+
+		if errors.As(err, &he) && he.HTTPStatus == http.StatusUnauthorized {
+			loginFailed(ctx, loginName(ctx, user), ip)
+		}
+
+		return &middleware.Template{
+			Name: tplAdminLoginTwoFactor,
+			Err:  err,
+		}
+	}
+
+	redirectTo, _ := session.GetValue("two_factor_state").(string)
+
+	if len(redirectTo) == 0 {
+		redirectTo = "admin/articles"
+	}
+
+	session, err = ctx.SessionService.Renew(rw, r)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin",
+			Err:          err,
+		}
+	}
+
+	session.SetValue("two_factor_userid", nil)
+	session.SetValue("userid", user.ID)
+
+	recordLogin(ctx, rw, r, user, models.AuditLogin, "two-factor")
+
+	return &middleware.Template{
+		RedirectPath: redirectTo,
+	}
+}
+
+// AdminTwoFactorHandler shows the two-factor authentication settings of the currently logged-in user;
+// if two-factor authentication is not enabled yet a new secret is generated and shown as QR code
+func AdminTwoFactorHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	if user.TOTPEnabled {
+		count, err := ctx.TwoFactorService.CountRecoveryCodes(user)
+
+		if err != nil {
+			return &middleware.Template{
+				Name:   tplAdminTwoFactor,
+				Active: "profile",
+				Err:    err,
+			}
+		}
+
+		return &middleware.Template{
+			Name:   tplAdminTwoFactor,
+			Active: "profile",
+			Data: map[string]interface{}{
+				"user":          user,
+				"required":      ctx.TwoFactorService.Required(user),
+				"recoveryCodes": count,
+			},
+		}
+	}
+
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return &middleware.Template{
+			Name:   tplAdminTwoFactor,
+			Active: "profile",
+			Err:    err,
+		}
+	}
+
+	secret, ok := session.GetValue("totp_secret").(string)
+
+	if !ok || len(secret) == 0 {
+		secret = ctx.TwoFactorService.NewSecret()
+		session.SetValue("totp_secret", secret)
+	}
+
+	return twoFactorEnrollTemplate(ctx, user, secret, nil)
+}
+
+// AdminTwoFactorEnablePostHandler enables two-factor authentication if the entered code matches the secret shown;
+// the recovery codes are shown only once
+func AdminTwoFactorEnablePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	secret, ok := session.GetValue("totp_secret").(string)
+
+	if !ok || len(secret) == 0 {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err:          httperror.New(http.StatusUnprocessableEntity, "Please scan the QR code again.", errors.New("no pending TOTP secret in session")),
+		}
+	}
+
+	codes, err := ctx.TwoFactorService.Enable(user, secret, r.FormValue("code"))
+
+	if err != nil {
+		return twoFactorEnrollTemplate(ctx, user, secret, err)
+	}
+
+	session.SetValue("totp_secret", "")
+
+	return &middleware.Template{
+		Name:       tplAdminTwoFactor,
+		Active:     "profile",
+		SuccessMsg: "Two-factor authentication was successfully enabled.",
+		Data: map[string]interface{}{
+			"user":             user,
+			"required":         ctx.TwoFactorService.Required(user),
+			"newRecoveryCodes": codes,
+			"recoveryCodes":    len(codes),
+		},
+	}
+}
+
+// AdminTwoFactorRecoveryCodesPostHandler invalidates the recovery codes and shows new ones
+func AdminTwoFactorRecoveryCodesPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	if !user.TOTPEnabled {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err: httperror.New(http.StatusUnprocessableEntity, "Two-factor authentication is not enabled.",
+				fmt.Errorf("user %d requested recovery codes, but two-factor authentication is not enabled", user.ID)),
+		}
+	}
+
+	codes, err := ctx.TwoFactorService.RegenerateRecoveryCodes(user)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		Name:       tplAdminTwoFactor,
+		Active:     "profile",
+		SuccessMsg: "New recovery codes were generated. The old ones are no longer valid.",
+		Data: map[string]interface{}{
+			"user":             user,
+			"required":         ctx.TwoFactorService.Required(user),
+			"newRecoveryCodes": codes,
+			"recoveryCodes":    len(codes),
+		},
+	}
+}
+
+// AdminTwoFactorDisablePostHandler disables two-factor authentication of the currently logged-in user;
+// the current password is required
+func AdminTwoFactorDisablePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	u := &models.User{
+		Username:      user.Username,
+		Email:         user.Email,
+		PlainPassword: []byte(r.FormValue("current_password")),
+	}
+
+	if _, err := ctx.UserService.Authenticate(u, ctx.ConfigService.LoginMethod); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err:          httperror.New(http.StatusUnauthorized, "Your current password is invalid.", err),
+		}
+	}
+
+	if err := ctx.TwoFactorService.Disable(user, user); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/two-factor",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/two-factor",
+		Active:       "profile",
+		SuccessMsg:   "Two-factor authentication was disabled.",
+	}
+}
+
+// AdminUserTwoFactorResetHandler returns the form for resetting the two-factor authentication of a user
+func AdminUserTwoFactorResetHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	userID, err := parseInt(getVar(r, "userID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	user, err := ctx.UserService.GetByID(userID)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	reset := models.Action{
+		ID:          "resetTwoFactor",
+		ActionURL:   fmt.Sprintf("/admin/user/two-factor/reset/%d", user.ID),
+		BackLinkURL: fmt.Sprintf("/admin/user/edit/%d", user.ID),
+		Description: fmt.Sprintf("Please confirm resetting the two-factor authentication of user %s?", user.Username),
+		WarnMsg:     "The authenticator app and all recovery codes of this user will no longer be valid.",
+		Title:       "Confirm resetting of two-factor authentication",
+	}
+
+	return &middleware.Template{
+		Name:   tplAdminAction,
+		Active: "users",
+		Data: map[string]interface{}{
+			"action": reset,
+		},
+	}
+}
+
+// AdminUserTwoFactorResetPostHandler resets the two-factor authentication of a user, e.g. if the device was lost
+func AdminUserTwoFactorResetPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	cu, _ := middleware.User(r)
+
+	userID, err := parseInt(getVar(r, "userID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	user, err := ctx.UserService.GetByID(userID)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	if err := ctx.TwoFactorService.Disable(user, cu); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/users",
+		Active:       "users",
+		SuccessMsg:   "Successfully reset the two-factor authentication of user " + user.Username,
+	}
+}
+
+func twoFactorEnrollTemplate(ctx *middleware.AppContext, user *models.User, secret string, err error) *middleware.Template {
+	png, qrErr := ctx.TwoFactorService.QRCode(user, secret)
+
+	if qrErr != nil {
+		return &middleware.Template{
+			Name:   tplAdminTwoFactor,
+			Active: "profile",
+			Err:    qrErr,
+		}
+	}
+
+	return &middleware.Template{
+		Name:   tplAdminTwoFactor,
+		Active: "profile",
+		Err:    err,
+		Data: map[string]interface{}{
+			"user":            user,
+			"required":        ctx.TwoFactorService.Required(user),
+			"secret":          secret,
+			"provisioningURI": ctx.TwoFactorService.ProvisioningURI(user, secret),
+			"qrCode":          template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		},
+	}
+}
@@ -0,0 +1,297 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/totp"
+)
+
+func TestTwoFactorLogin(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	alice := dummyAdminUser()
+	secret := ctx.TwoFactorService.NewSecret()
+
+	if _, err := ctx.TwoFactorService.Enable(alice, secret, "000000"); err == nil {
+		t.Fatal("expected an error while enabling two-factor authentication with an invalid code")
+	}
+
+	code, err := totp.Code(secret, time.Now())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recoveryCodes, err := ctx.TwoFactorService.Enable(alice, secret, code)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, but got %d", len(recoveryCodes))
+	}
+
+	resp, err := doLoginRequest(rGuest, "alice", "123456789012")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.template.RedirectPath != "admin/login/two-factor" {
+		t.Fatalf("expected a redirect to the second login step, but got '%s'", resp.template.RedirectPath)
+	}
+
+	c, err := resp.getCookie("test-session")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = doTwoFactorLoginRequest(c, "123")
+
+	if resp.getTemplateError() == nil {
+		t.Error("expected an error for an invalid verification code, but error is nil")
+	}
+
+	resp = doTwoFactorLoginRequest(c, recoveryCodes[0])
+
+	if resp.getTemplateError() != nil {
+		t.Fatalf("expected a successful login with a recovery code, but got %v", resp.getTemplateError())
+	}
+
+	if resp.template.RedirectPath != "admin/articles" {
+		t.Errorf("expected a redirect to the articles, but got '%s'", resp.template.RedirectPath)
+	}
+
+	if _, err := resp.getCookie("test-session"); err != nil {
+		t.Errorf("expected a renewed session cookie, but got %v", err)
+	}
+
+	count, err := ctx.TwoFactorService.CountRecoveryCodes(alice)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 9 {
+		t.Errorf("expected the recovery code to be removed after usage; %d recovery codes left", count)
+	}
+
+	if err := ctx.TwoFactorService.Verify(alice, recoveryCodes[0]); err == nil {
+		t.Error("expected a recovery code to be usable only once")
+	}
+}
+
+func TestTwoFactorLoginMaxAttempts(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	alice := dummyAdminUser()
+	secret := ctx.TwoFactorService.NewSecret()
+	code, _ := totp.Code(secret, time.Now())
+
+	if _, err := ctx.TwoFactorService.Enable(alice, secret, code); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := doLoginRequest(rGuest, "alice", "123456789012")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := resp.getCookie("test-session")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the invalid codes are not delayed, only the attempts of the session are limited
+	ctx.LoginThrottleService.Config.LoginBackoffMax = 0
+
+	for i := 0; i < 5; i++ {
+		doTwoFactorLoginRequest(c, "abc")
+	}
+
+	code, _ = totp.Code(secret, time.Now())
+	resp = doTwoFactorLoginRequest(c, code)
+
+	if resp.getTemplateError() == nil {
+		t.Error("expected an error after too many invalid verification codes, but error is nil")
+	}
+
+	if resp.template.RedirectPath != "admin" {
+		t.Errorf("expected a redirect to the login, but got '%s'", resp.template.RedirectPath)
+	}
+}
+
+func TestTwoFactorLoginThrottle(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	ctx.LoginThrottleService.Config.LoginMaxFailures = 3
+	ctx.LoginThrottleService.Config.LoginBackoffMax = 0
+
+	alice := dummyAdminUser()
+	secret := ctx.TwoFactorService.NewSecret()
+	code, _ := totp.Code(secret, time.Now())
+
+	if _, err := ctx.TwoFactorService.Enable(alice, secret, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doLoginRequest(rGuest, "alice", "wrong-password"); !hasStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("expected the status 401 for a wrong password, but got %v", err)
+	}
+
+	// the correct password does not reset the failures before the second factor is verified
+	resp, err := doLoginRequest(rGuest, "alice", "123456789012")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := resp.getCookie("test-session")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := doTwoFactorLoginRequest(c, "000000"); !hasStatus(resp.getTemplateError(), http.StatusUnauthorized) {
+			t.Fatalf("expected the status 401 for the invalid code %d, but got %v", i+1, resp.getTemplateError())
+		}
+	}
+
+	code, _ = totp.Code(secret, time.Now().Add(totp.Period*time.Second))
+
+	if resp := doTwoFactorLoginRequest(c, code); !hasStatus(resp.getTemplateError(), http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 for a locked account, but got %v", resp.getTemplateError())
+	}
+
+	if _, err := doLoginRequest(rGuest, "alice", "123456789012"); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Errorf("expected the status 429 for a new login of the locked account, but got %v", err)
+	}
+}
+
+func TestTwoFactorReplay(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	alice := dummyAdminUser()
+	secret := ctx.TwoFactorService.NewSecret()
+	now := time.Now()
+	code, _ := totp.Code(secret, now)
+
+	if _, err := ctx.TwoFactorService.Enable(alice, secret, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.TwoFactorService.Verify(alice, code); err == nil {
+		t.Error("expected the code used for enabling to be rejected, but error is nil")
+	}
+
+	// the code of the next time step is accepted within the skew
+	next, _ := totp.Code(secret, now.Add(totp.Period*time.Second))
+
+	if err := ctx.TwoFactorService.Verify(alice, next); err != nil {
+		t.Fatalf("expected the code of the next time step to be accepted, but got %v", err)
+	}
+
+	if err := ctx.TwoFactorService.Verify(alice, next); err == nil {
+		t.Error("expected a replayed code to be rejected, but error is nil")
+	}
+
+	if err := ctx.TwoFactorService.Verify(alice, code); err == nil {
+		t.Error("expected the code of an earlier time step to be rejected, but error is nil")
+	}
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	bob := dummyUser()
+	bob.RequireTwoFactor = true
+
+	if err := ctx.UserService.Update(bob, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	r := request{
+		url:    "/admin/articles",
+		user:   rUser,
+		method: "GET",
+	}
+
+	req := r.buildRequest()
+	req.URL.Path = "/admin/articles"
+
+	rr := httptest.NewRecorder()
+	ctx.AuthHandler(next).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/admin/user/two-factor" {
+		t.Errorf("expected a redirect to the two-factor setup, but got status %d location '%s'", rr.Code, rr.Header().Get("Location"))
+	}
+
+	req.URL.Path = "/admin/user/two-factor"
+
+	rr = httptest.NewRecorder()
+	ctx.AuthHandler(next).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the two-factor setup to be accessible, but got status %d", rr.Code)
+	}
+
+	bob, _ = ctx.UserService.GetByID(bob.ID)
+	secret := ctx.TwoFactorService.NewSecret()
+	code, _ := totp.Code(secret, time.Now())
+
+	if _, err := ctx.TwoFactorService.Enable(bob, secret, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.TwoFactorService.Disable(bob, bob); err == nil {
+		t.Error("expected an error while disabling the required two-factor authentication, but error is nil")
+	}
+
+	if err := ctx.TwoFactorService.Disable(bob, dummyAdminUser()); err != nil {
+		t.Errorf("expected that an admin can reset the two-factor authentication, but got %v", err)
+	}
+}
+
+func doTwoFactorLoginRequest(c *http.Cookie, code string) responseWrapper {
+	values := url.Values{}
+	addValue(values, "code", code)
+
+	r := request{
+		url:    "/admin/login/two-factor",
+		method: "POST",
+		user:   rGuest,
+		values: values,
+	}
+
+	req := r.buildRequest()
+	req.AddCookie(c)
+
+	rr := httptest.NewRecorder()
+	tpl := handler.TwoFactorLoginPostHandler(ctx, rr, req)
+
+	return responseWrapper{response: rr, template: tpl}
+}
@@ -45,10 +45,15 @@ func AdminUsersHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *htt
 	}
 
 	var userInvites []models.UserInvite
+	var lockedLogins []models.LoginThrottle
 
-	if cu, _ := middleware.User(r); cu.IsAdmin {
+	if cu, _ := middleware.User(r); cu.Can(models.PermUserManage) {
 		userInvites, err = ctx.UserInviteService.List()
 
+		if err == nil {
+			lockedLogins, err = ctx.LoginThrottleService.ListLocked()
+		}
+
 		if err != nil {
 			return &middleware.Template{
 				Name:   tplAdminUsers,
@@ -66,13 +71,37 @@ func AdminUsersHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *htt
 		Name:   tplAdminUsers,
 		Active: "users",
 		Data: map[string]interface{}{
-			"users":        users,
-			"user_invites": userInvites,
-			"pagination":   p,
+			"users":         users,
+			"user_invites":  userInvites,
+			"locked_logins": lockedLogins,
+			"pagination":    p,
 		},
 	}
 }
 
+// AdminUserUnlockPostHandler unlocks an account which was locked after too many failed logins
+func AdminUserUnlockPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	login := r.FormValue("login")
+
+	if err := ctx.LoginThrottleService.Unlock(login); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/users",
+			Active:       "users",
+			Err:          err,
+		}
+	}
+
+	cu, _ := middleware.User(r)
+
+	ctx.AuditService.Record(cu, models.AuditUnlock, models.AuditUser, 0, "", fmt.Sprintf("login=%q", login))
+
+	return &middleware.Template{
+		RedirectPath: "admin/users",
+		Active:       "users",
+		SuccessMsg:   fmt.Sprintf("The account %s was successfully unlocked.", login),
+	}
+}
+
 // AdminUserNewHandler returns the form for adding new user
 func AdminUserNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
 	return &middleware.Template{
@@ -84,15 +113,18 @@ func AdminUserNewHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *h
 // AdminUserNewPostHandler handles the creation of new users
 func AdminUserNewPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
 	u := &models.User{
-		DisplayName:   r.FormValue("displayname"),
-		Username:      r.FormValue("username"),
-		Email:         r.FormValue("email"),
-		PlainPassword: []byte(r.FormValue("password")),
-		Active:        convertCheckbox(r, "active"),
-		IsAdmin:       convertCheckbox(r, "admin"),
+		DisplayName:      r.FormValue("displayname"),
+		Username:         r.FormValue("username"),
+		Email:            r.FormValue("email"),
+		PlainPassword:    []byte(r.FormValue("password")),
+		Active:           convertCheckbox(r, "active"),
+		Role:             models.Role(r.FormValue("role")),
+		RequireTwoFactor: convertCheckbox(r, "require_two_factor"),
 	}
 
-	userID, err := ctx.UserService.Create(u)
+	cu, _ := middleware.User(r)
+
+	userID, err := ctx.UserService.Create(u, cu)
 	if err != nil {
 		return &middleware.Template{
 			Name:   tplAdminUserNew,
@@ -156,13 +188,14 @@ func AdminUserEditPostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 	}
 
 	u := &models.User{
-		ID:            userID,
-		Email:         r.FormValue("email"),
-		DisplayName:   r.FormValue("displayname"),
-		Username:      r.FormValue("username"),
-		PlainPassword: []byte(r.FormValue("password")),
-		Active:        convertCheckbox(r, "active"),
-		IsAdmin:       convertCheckbox(r, "admin"),
+		ID:               userID,
+		Email:            r.FormValue("email"),
+		DisplayName:      r.FormValue("displayname"),
+		Username:         r.FormValue("username"),
+		PlainPassword:    []byte(r.FormValue("password")),
+		Active:           convertCheckbox(r, "active"),
+		Role:             models.Role(r.FormValue("role")),
+		RequireTwoFactor: convertCheckbox(r, "require_two_factor"),
 	}
 
 	changePassword := false
@@ -171,7 +204,9 @@ func AdminUserEditPostHandler(ctx *middleware.AppContext, w http.ResponseWriter,
 		changePassword = true
 	}
 
-	if err := ctx.UserService.Update(u, changePassword); err != nil {
+	cu, _ := middleware.User(r)
+
+	if err := ctx.UserService.Update(u, cu, changePassword); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminUserEdit,
 			Err:    err,
@@ -228,7 +263,7 @@ func AdminUserDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r
 
 	oneAdmin, err := ctx.UserService.OneAdmin()
 
-	if oneAdmin && user.IsAdmin {
+	if oneAdmin && user.Role == models.RoleAdmin {
 		return &middleware.Template{
 			RedirectPath: "admin/users",
 			Active:       "users",
@@ -278,7 +313,9 @@ func AdminUserDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWrite
 		}
 	}
 
-	if err := ctx.UserService.Remove(user); err != nil {
+	cu, _ := middleware.User(r)
+
+	if err := ctx.UserService.Remove(user, cu); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminUsers,
 			Active: "users",
@@ -0,0 +1,191 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+const (
+	deviceCookie        = "device"
+	deviceCookieMaxAge  = 365 * 24 * time.Hour
+	secureAccountExpiry = 7 * 24 * time.Hour
+)
+
+// recognizeDevice remembers the device the user logged in with in a long-living cookie; on a login from an unfamiliar
+// device a mail with a link to secure the account is sent to the user
+func recognizeDevice(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request, u *models.User) {
+	var cookie string
+
+	if c, err := r.Cookie(deviceCookie); err == nil && len(c.Value) > 0 {
+		cookie = c.Value
+	} else {
+		cookie = crypt.RandomHash(32)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     deviceCookie,
+		Value:    cookie,
+		Path:     "/admin",
+		MaxAge:   int(deviceCookieMaxAge.Seconds()),
+		Secure:   ctx.ConfigService.Session.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	d, unfamiliar, err := ctx.UserDeviceService.Recognize(u, cookie, r.UserAgent())
+
+	if err != nil {
+		logger.Log.Errorf("could not recognize the device of user %d %v", u.ID, err)
+		return
+	}
+
+	if !unfamiliar {
+		return
+	}
+
+	t := &models.Token{
+		Author:  u,
+		Type:    models.SecureAccount,
+		Payload: d.Fingerprint,
+	}
+
+	if err := ctx.TokenService.Create(t); err != nil {
+		logger.Log.Errorf("could not create the token to secure the account of user %d %v", u.ID, err)
+		return
+	}
+
+	mu, err := ctx.UserService.GetByID(u.ID)
+
+	if err != nil {
+		logger.Log.Errorf("could not notify user %d about the login from an unfamiliar device %v", u.ID, err)
+		return
+	}
+
+	ctx.Mailer.SendNewDeviceLogin(mu, d, t)
+}
+
+// SecureAccountHandler returns the form to secure the account after a login from an unfamiliar device
+func SecureAccountHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, u, tpl := emailToken(ctx, r, hash, models.SecureAccount, secureAccountExpiry)
+
+	if tpl != nil {
+		return tpl
+	}
+
+	data := map[string]interface{}{
+		"hash": hash,
+	}
+
+	if d, err := ctx.UserDeviceService.Get(u, t.Payload); err == nil {
+		data["device"] = d
+	}
+
+	// the passkeys could have been registered by the unfamiliar device, they are removed along with the access tokens
+	credentials, err := ctx.WebAuthnService.ListCredentials(u)
+
+	if err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	data["credentials"] = credentials
+	data["totpEnabled"] = u.TOTPEnabled
+
+	return &middleware.Template{
+		Name: tplAdminSecureAccount,
+		Data: data,
+	}
+}
+
+// SecureAccountPostHandler logs out all sessions of the user, revokes the access tokens, removes the passkeys and
+// forgets the unfamiliar device; if the password login is enabled the user is redirected to choose a new password
+func SecureAccountPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	if t := requestThrottled(ctx, r, tplAdminLogin); t != nil {
+		return t
+	}
+
+	hash := getVar(r, "hash")
+
+	t, u, tpl := emailToken(ctx, r, hash, models.SecureAccount, secureAccountExpiry)
+
+	if tpl != nil {
+		return tpl
+	}
+
+	if err := ctx.TokenService.Remove(hash, models.SecureAccount); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	if err := ctx.UserDeviceService.Forget(u, t.Payload); err != nil {
+		logger.Log.Errorf("could not forget the device of user %d error %v", u.ID, err)
+	}
+
+	ctx.UserSessionService.RevokeAll(u, "")
+
+	// the session of the current request was revoked along with the others
+	ctx.SessionService.Remove(w, r)
+
+	if err := ctx.AccessTokenService.RemoveAll(u); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	if err := ctx.WebAuthnService.RemoveCredentials(u); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	logger.Log.Warnf("the user %s secured the account after a login from an unfamiliar device", u.Username)
+
+	u.RemoteAddr = middleware.GetIP(r)
+
+	ctx.AuditService.Record(u, models.AuditUpdate, models.AuditUser, u.ID, "", "secured=true sessions=revoked access_tokens=revoked passkeys=removed")
+
+	if !ctx.ConfigService.OIDC.PasswordLoginEnabled() {
+		return &middleware.Template{
+			RedirectPath: "admin",
+			SuccessMsg:   "All sessions of your account were logged out.",
+		}
+	}
+
+	rt := &models.Token{
+		Author: u,
+		Type:   models.PasswordReset,
+	}
+
+	if err := ctx.TokenService.Create(rt); err != nil {
+		return &middleware.Template{
+			Name: tplAdminLogin,
+			Err:  err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/reset-password/" + rt.Hash,
+		SuccessMsg:   "All sessions of your account were logged out. Please choose a new password.",
+	}
+}
@@ -0,0 +1,195 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestNewDeviceLogin(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	device, tpl := doDeviceLoginRequest(nil, "Firefox")
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if device == nil {
+		t.Fatal("no device cookie was set on the login")
+	}
+
+	if len(sender.mails) != 0 {
+		t.Fatalf("expected no mail for the first device, but got %v", sender.mails)
+	}
+
+	if _, tpl := doDeviceLoginRequest(device, "Firefox"); tpl.Err != nil || len(sender.mails) != 0 {
+		t.Fatalf("expected no mail for a known device, but got %v %v", sender.mails, tpl.Err)
+	}
+
+	if _, tpl := doDeviceLoginRequest(device, "Chromium"); tpl.Err != nil || len(sender.mails) != 1 {
+		t.Fatalf("expected a mail for a known cookie with another user agent, but got %v %v", sender.mails, tpl.Err)
+	}
+
+	unknown, tpl := doDeviceLoginRequest(nil, "Firefox")
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if len(sender.mails) != 2 || sender.mails[1].To != "bob@example.org" || !strings.Contains(sender.mails[1].Body, "/admin/secure-account/") {
+		t.Fatalf("expected a mail with a link to secure the account, but got %v", sender.mails)
+	}
+
+	tokens, err := ctx.TokenService.ListByUser(2, models.SecureAccount)
+
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("expected two tokens to secure the account, but got %v %v", tokens, err)
+	}
+
+	hash := tokens[0].Hash
+
+	bob := dummyUser()
+
+	if _, err := ctx.AccessTokenService.Create(&models.AccessToken{
+		Name:      "ci",
+		Scopes:    []models.AccessTokenScope{models.ScopeWrite},
+		ExpiresAt: time.Now().AddDate(0, 0, 1),
+	}, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ctx.WebAuthnService.Datasource.Create(&models.Credential{
+		Name:         "YubiKey",
+		CredentialID: []byte("credential"),
+		PublicKey:    []byte("public key"),
+		Author:       bob,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl = doSecureAccountRequest(hash, "GET")
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if d, ok := tpl.Data["device"].(*models.UserDevice); !ok || d.UserAgent != "Firefox" {
+		t.Errorf("expected the unfamiliar device, but got %v", tpl.Data["device"])
+	}
+
+	if c, ok := tpl.Data["credentials"].([]models.Credential); !ok || len(c) != 1 || c[0].Name != "YubiKey" {
+		t.Errorf("expected the passkeys to be listed, but got %v", tpl.Data["credentials"])
+	}
+
+	tpl = doSecureAccountRequest(hash, "POST")
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if !strings.HasPrefix(tpl.RedirectPath, "admin/reset-password/") {
+		t.Errorf("expected a redirect to reset the password, but got %s", tpl.RedirectPath)
+	}
+
+	u, err := ctx.UserService.GetByID(2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := ctx.UserSessionService.List(u, ""); len(sessions) != 0 {
+		t.Errorf("expected all sessions to be revoked, but got %d sessions", len(sessions))
+	}
+
+	if tokens, err := ctx.AccessTokenService.List(u); err != nil || len(tokens) != 0 {
+		t.Errorf("expected all access tokens to be revoked, but got %v %v", tokens, err)
+	}
+
+	if credentials, err := ctx.WebAuthnService.ListCredentials(u); err != nil || len(credentials) != 0 {
+		t.Errorf("expected all passkeys to be removed, but got %v %v", credentials, err)
+	}
+
+	events, err := ctx.AuditService.List(&models.AuditFilter{Actor: "bob", Action: models.AuditUpdate, TargetType: models.AuditUser}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || !strings.Contains(events[0].After, "secured=true") {
+		t.Errorf("expected an audit event for the secured account, but got %v", events)
+	}
+
+	if tpl := doSecureAccountRequest(hash, "POST"); !hasStatus(tpl.Err, http.StatusNotFound) {
+		t.Errorf("expected the status 404 for an used link, but got %v", tpl.Err)
+	}
+
+	if _, tpl := doDeviceLoginRequest(unknown, "Firefox"); tpl.Err != nil || len(sender.mails) != 3 {
+		t.Errorf("expected the forgotten device to be unfamiliar again, but got %v %v", sender.mails, tpl.Err)
+	}
+}
+
+func doDeviceLoginRequest(device *http.Cookie, userAgent string) (*http.Cookie, *middleware.Template) {
+	values := url.Values{}
+	addValue(values, "username", "bob")
+	addValue(values, "password", "123456789012")
+
+	r := request{
+		url:    "/admin/login",
+		user:   rGuest,
+		method: "POST",
+		values: values,
+	}
+
+	req := r.buildRequest()
+	req.Header.Set("User-Agent", userAgent)
+
+	if device != nil {
+		req.AddCookie(device)
+	}
+
+	rw := httptest.NewRecorder()
+	tpl := handler.LoginPostHandler(ctx, rw, req)
+
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == "device" {
+			return c, tpl
+		}
+	}
+
+	return nil, tpl
+}
+
+func doSecureAccountRequest(hash, method string) *middleware.Template {
+	r := request{
+		url:    "/admin/secure-account/" + hash,
+		user:   rGuest,
+		method: method,
+		pathVar: []pathVar{
+			{
+				key:   "hash",
+				value: hash,
+			},
+		},
+	}
+
+	rw := httptest.NewRecorder()
+
+	if method == http.MethodPost {
+		return handler.SecureAccountPostHandler(ctx, rw, r.buildRequest())
+	}
+
+	return handler.SecureAccountHandler(ctx, rw, r.buildRequest())
+}
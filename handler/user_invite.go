@@ -24,7 +24,7 @@ func AdminUserInviteNewPostHandler(ctx *middleware.AppContext, w http.ResponseWr
 		DisplayName: r.FormValue("displayname"),
 		Username:    r.FormValue("username"),
 		Email:       r.FormValue("email"),
-		IsAdmin:     convertCheckbox(r, "admin"),
+		Role:        models.Role(r.FormValue("role")),
 		CreatedBy:   user,
 	}
 
@@ -66,7 +66,9 @@ func AdminUserInviteResendPostHandler(ctx *middleware.AppContext, w http.Respons
 		}
 	}
 
-	ui, err := ctx.UserInviteService.Get(inviteID)
+	cu, _ := middleware.User(r)
+
+	ui, err := ctx.UserInviteService.Renew(inviteID, cu)
 
 	if err != nil {
 		return &middleware.Template{
@@ -140,7 +142,9 @@ func AdminUserInviteDeletePostHandler(ctx *middleware.AppContext, w http.Respons
 		}
 	}
 
-	if err := ctx.UserInviteService.Remove(inviteID); err != nil {
+	cu, _ := middleware.User(r)
+
+	if err := ctx.UserInviteService.Remove(inviteID, cu); err != nil {
 		return &middleware.Template{
 			Name:   tplAdminUsers,
 			Active: "users",
@@ -1,10 +1,12 @@
 package handler_test
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"git.hoogi.eu/snafu/go-blog/handler"
 	"git.hoogi.eu/snafu/go-blog/models"
@@ -19,7 +21,7 @@ func TestUserInviteWorkflow(t *testing.T) {
 		DisplayName: "Homer Simpson",
 		Email:       "homer@example.com",
 		Username:    "homer",
-		IsAdmin:     false,
+		Role:        models.RoleAuthor,
 	}
 
 	inviteID, hash, err := doAdminCreateUserInviteRequest(rAdminUser, ui)
@@ -55,12 +57,119 @@ func TestUserInviteWorkflow(t *testing.T) {
 	}
 }
 
+func TestUserInviteExpiry(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	ctx.UserInviteService.Config.InviteLifetime = 200 * time.Millisecond
+
+	ui := &models.UserInvite{
+		DisplayName: "Homer Simpson",
+		Email:       "homer@example.com",
+		Username:    "homer",
+		Role:        models.RoleAuthor,
+	}
+
+	inviteID, hash, err := doAdminCreateUserInviteRequest(rAdminUser, ui)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	// the expired invitation is not removed by the maintenance yet
+	if err := ctx.UserInviteService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doActivateAccountRequest(rGuest, "123456789012", "123456789012", hash); !hasStatus(err, http.StatusGone) {
+		t.Fatalf("expected the status 410 for an expired invitation, but got %v", err)
+	}
+
+	ctx.UserInviteService.Config.InviteLifetime = time.Hour
+
+	_, renewed, err := doAdminResendUserInviteRequest(rAdminUser, inviteID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if renewed == hash {
+		t.Error("the hash of the renewed invitation was not changed")
+	}
+
+	if err := doActivateAccountRequest(rGuest, "123456789012", "123456789012", renewed); err != nil {
+		t.Fatalf("the renewed invitation could not be accepted %v", err)
+	}
+}
+
+func TestUserInviteMaintenance(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	ctx.UserInviteService.Config.InviteLifetime = time.Hour
+	ctx.UserInviteService.Config.InviteReminder = 2 * time.Hour
+
+	soon := &models.UserInvite{
+		DisplayName: "Homer Simpson",
+		Email:       "homer@example.com",
+		Username:    "homer",
+		Role:        models.RoleAuthor,
+	}
+
+	if _, _, err := doAdminCreateUserInviteRequest(rAdminUser, soon); err != nil {
+		t.Fatal(err)
+	}
+
+	sender.mails = nil
+
+	if err := ctx.UserInviteService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.mails) != 1 || sender.mails[0].To != "homer@example.com" {
+		t.Fatalf("expected a reminder to homer@example.com, but got %v", sender.mails)
+	}
+
+	if err := ctx.UserInviteService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.mails) != 1 {
+		t.Errorf("expected the reminder to be sent once, but got %d mails", len(sender.mails))
+	}
+
+	ctx.UserInviteService.Config.InviteLifetime = time.Millisecond
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err := ctx.UserInviteService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	invites, err := ctx.UserInviteService.List()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invites) != 0 {
+		t.Errorf("expected the expired invitation to be removed, but got %d invitations", len(invites))
+	}
+}
+
 func doAdminCreateUserInviteRequest(user reqUser, ui *models.UserInvite) (int, string, error) {
 	values := url.Values{}
 	addValue(values, "displayname", ui.DisplayName)
 	addValue(values, "username", ui.Username)
 	addValue(values, "email", ui.Email)
-	addCheckboxValue(values, "admin", ui.IsAdmin)
+	addValue(values, "role", string(ui.Role))
 
 	r := request{
 		url:    "/admin/user-invite/new",
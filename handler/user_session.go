@@ -0,0 +1,53 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/middleware"
+)
+
+// AdminSessionRevokePostHandler signs out a session of the currently logged-in user
+func AdminSessionRevokePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	if err := ctx.UserSessionService.Revoke(user, getVar(r, "sessionID")); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/profile",
+		Active:       "profile",
+		SuccessMsg:   "The session was successfully signed out.",
+	}
+}
+
+// AdminSessionRevokeOthersPostHandler signs out all sessions of the currently logged-in user except the current one
+func AdminSessionRevokeOthersPostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	ctx.UserSessionService.RevokeAll(user, session.SessionID())
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/profile",
+		Active:       "profile",
+		SuccessMsg:   "All other sessions were successfully signed out.",
+	}
+}
@@ -0,0 +1,152 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/handler"
+)
+
+func TestUserSessions(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	alice := dummyAdminUser()
+
+	r := request{
+		url:    "/admin/user/profile",
+		user:   rAdminUser,
+		method: "GET",
+	}
+
+	current := r.buildRequest()
+	current.Header.Set("User-Agent", "Firefox")
+	current.RemoteAddr = "192.0.2.1:4711"
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	ctx.AuthHandler(next).ServeHTTP(httptest.NewRecorder(), current)
+
+	other := r.buildRequest()
+
+	sessions := ctx.UserSessionService.List(alice, sessionID(t, current))
+
+	if len(sessions) != 2 {
+		t.Fatalf("expected two sessions, but got %d", len(sessions))
+	}
+
+	for _, s := range sessions {
+		if s.Current && (s.UserAgent != "Firefox" || s.IP != "192.0.2.1") {
+			t.Errorf("expected the user agent and ip address of the current session to be tracked, but got %v", s)
+		}
+	}
+
+	// sign out this session
+	revoke := request{
+		url:    "/admin/user/session/revoke/",
+		user:   rAdminUser,
+		method: "POST",
+		pathVar: []pathVar{
+			{
+				key:   "sessionID",
+				value: crypt.Hash([]byte(sessionID(t, other))),
+			},
+		},
+	}
+
+	tpl := handler.AdminSessionRevokePostHandler(ctx, httptest.NewRecorder(), revoke.buildRequest())
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	if _, err := ctx.SessionService.SessionProvider.Get(sessionID(t, other)); err == nil {
+		t.Error("expected the session to be signed out, but the session still exists")
+	}
+
+	tpl = handler.AdminSessionRevokePostHandler(ctx, httptest.NewRecorder(), revoke.buildRequest())
+
+	if tpl.Err == nil {
+		t.Error("expected an error while signing out an unknown session, but error is nil")
+	}
+
+	// sign out all other sessions
+	r.buildRequest()
+	r.buildRequest()
+
+	tpl = handler.AdminSessionRevokeOthersPostHandler(ctx, httptest.NewRecorder(), current)
+
+	if tpl.Err != nil {
+		t.Fatal(tpl.Err)
+	}
+
+	sessions = ctx.UserSessionService.List(alice, sessionID(t, current))
+
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("expected only the current session to remain, but got %v", sessions)
+	}
+}
+
+func TestUserSessionsRevokedByUserService(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	r := request{
+		url:    "/admin/articles",
+		user:   rUser,
+		method: "GET",
+	}
+
+	r.buildRequest()
+	r.buildRequest()
+
+	bob := dummyUser()
+	bob.PlainPassword = []byte("abcdefghijklm")
+
+	if err := ctx.UserService.Update(bob, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := ctx.UserSessionService.List(bob, ""); len(sessions) != 0 {
+		t.Errorf("expected all sessions to be revoked after a password change, but got %d sessions", len(sessions))
+	}
+
+	r.buildRequest()
+
+	bob = dummyUser()
+	bob.Active = false
+
+	if err := ctx.UserService.Update(bob, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := ctx.UserSessionService.List(bob, ""); len(sessions) != 0 {
+		t.Errorf("expected all sessions to be revoked after a deactivation, but got %d sessions", len(sessions))
+	}
+
+	r.buildRequest()
+
+	if err := ctx.UserService.Remove(dummyUser(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := ctx.UserSessionService.List(bob, ""); len(sessions) != 0 {
+		t.Errorf("expected all sessions to be revoked after removing the user, but got %d sessions", len(sessions))
+	}
+}
+
+func sessionID(t *testing.T, r *http.Request) string {
+	c, err := r.Cookie("test-session")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c.Value
+}
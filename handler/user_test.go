@@ -6,10 +6,12 @@ package handler_test
 
 import (
 	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"git.hoogi.eu/snafu/go-blog/handler"
 	"git.hoogi.eu/snafu/go-blog/models"
@@ -26,7 +28,7 @@ func TestUserWorklfow(t *testing.T) {
 		Username:      "homer",
 		PlainPassword: []byte("123456789012"),
 		Active:        false,
-		IsAdmin:       false,
+		Role:          models.RoleAuthor,
 	}
 
 	userID, err := doAdminCreateUserRequest(rAdminUser, expectedUser)
@@ -56,7 +58,7 @@ func TestUserWorklfow(t *testing.T) {
 		Username:      "homer",
 		PlainPassword: []byte("12345678901234"),
 		Active:        true,
-		IsAdmin:       true,
+		Role:          models.RoleAdmin,
 	}
 
 	err = doAdminEditUsersRequest(rAdminUser, expectedUser)
@@ -80,6 +82,104 @@ func TestUserWorklfow(t *testing.T) {
 	}
 }
 
+func TestDormantUsers(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sender := &recordingSender{}
+	ctx.Mailer.Sender = sender
+
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := doAdminListUsersRequest(rAdminUser)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range users {
+		if u.ID == 2 && !u.LastLogin.Valid {
+			t.Error("the login of bob was not recorded")
+		}
+	}
+
+	ctx.UserService.Config.DormantAfter = time.Hour
+	ctx.UserService.Config.DormantWarning = time.Hour
+
+	if err := ctx.UserService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.mails) != 1 || sender.mails[0].To != "bob@example.org" {
+		t.Fatalf("expected a warning to bob@example.org only, but got %v", sender.mails)
+	}
+
+	if err := ctx.UserService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.mails) != 1 {
+		t.Errorf("expected the warning to be sent once, but got %d mails", len(sender.mails))
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); !u.Active {
+		t.Fatal("the user was deactivated before the warning period passed")
+	}
+
+	// the user is edited after the warning, so the idle user has to be warned again
+	time.Sleep(5 * time.Millisecond)
+
+	bob, err := ctx.UserService.GetByID(2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob.DisplayName = "Bobby"
+
+	if err := ctx.UserService.Update(bob, bob, false); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.UserService.Config.DormantAfter = time.Millisecond
+	ctx.UserService.Config.DormantWarning = time.Millisecond
+
+	time.Sleep(5 * time.Millisecond)
+
+	if err := ctx.UserService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.mails) != 2 || sender.mails[1].To != "bob@example.org" {
+		t.Fatalf("expected a new warning to bob@example.org after the edit, but got %v", sender.mails)
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); !u.Active {
+		t.Fatal("the user was deactivated with an outdated warning")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if err := ctx.UserService.Maintain(ctx.Mailer); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := ctx.UserService.GetByID(2); u.Active {
+		t.Fatal("the dormant user was not deactivated")
+	}
+
+	if u, _ := ctx.UserService.GetByID(1); !u.Active {
+		t.Error("the administrator was deactivated")
+	}
+
+	if _, err := doLoginRequest(rGuest, "bob", "123456789012"); !hasStatus(err, http.StatusUnprocessableEntity) {
+		t.Errorf("expected the status 422 for the login of a deactivated user, but got %v", err)
+	}
+}
+
 func checkUser(user, expectedUser *models.User) error {
 	if user.DisplayName != expectedUser.DisplayName {
 		return fmt.Errorf("got an unexpected displayname. expected: %s, actual: %s", expectedUser.DisplayName, user.DisplayName)
@@ -90,8 +190,8 @@ func checkUser(user, expectedUser *models.User) error {
 	if user.Active != expectedUser.Active {
 		return fmt.Errorf("got an unexpected active. expected: %t, actual: %t", expectedUser.Active, user.Active)
 	}
-	if user.IsAdmin != expectedUser.IsAdmin {
-		return fmt.Errorf("got an unexpected isAdmin. expected: %t, actual: %t", expectedUser.IsAdmin, user.IsAdmin)
+	if user.Role != expectedUser.Role {
+		return fmt.Errorf("got an unexpected role. expected: %s, actual: %s", expectedUser.Role, user.Role)
 	}
 	return nil
 }
@@ -136,11 +236,7 @@ func doAdminEditUsersRequest(user reqUser, u *models.User) error {
 	}
 	addValue(values, "active", s)
 
-	s = "on"
-	if u.IsAdmin == false {
-		s = "off"
-	}
-	addValue(values, "admin", s)
+	addValue(values, "role", string(u.Role))
 
 	r := request{
 		url:    "/admin/user/edit" + strconv.Itoa(u.ID),
@@ -192,7 +288,7 @@ func doAdminCreateUserRequest(user reqUser, u *models.User) (int, error) {
 	addValue(values, "email", u.Email)
 	addValue(values, "password", string(u.PlainPassword))
 	addCheckboxValue(values, "active", u.Active)
-	addCheckboxValue(values, "is_admin", u.IsAdmin)
+	addValue(values, "role", string(u.Role))
 
 	r := request{
 		url:    "/admin/user/edit",
@@ -35,22 +35,73 @@ import (
 var ctx *middleware.AppContext
 var db *sql.DB
 
+// testDB is the database of the current test, it is closed by the next setup
+var testDB *sql.DB
+
+// testDriver is the database driver of the current run of the tests
+var testDriver string
+
+// the handler tests run against PostgreSQL as well if GOBLOG_TEST_POSTGRES_DSN contains the connection string of a
+// test database; the database is wiped before every test
+const postgresDSNEnv = "GOBLOG_TEST_POSTGRES_DSN"
+
+// TestMain runs all tests once per database; built with the tag postgres ('make test') the tests fail without a
+// PostgreSQL test database
+func TestMain(m *testing.M) {
+	drivers := []string{database.SQLite}
+
+	if len(os.Getenv(postgresDSNEnv)) > 0 {
+		drivers = append(drivers, database.Postgres)
+	} else if postgresRequired {
+		fmt.Fprintf(os.Stderr, "FAIL: %s is not set, but the tests were built with the tag postgres\n", postgresDSNEnv)
+		os.Exit(1)
+	} else {
+		fmt.Fprintf(os.Stderr, "SKIP: %s is not set, the handler tests run against SQLite only; "+
+			"build the tests with the tag postgres to require PostgreSQL\n", postgresDSNEnv)
+	}
+
+	for _, driver := range drivers {
+		testDriver = driver
+
+		if code := m.Run(); code != 0 {
+			fmt.Fprintf(os.Stderr, "the handler tests failed against %s\n", driver)
+			os.Exit(code)
+		}
+	}
+
+	os.Exit(0)
+}
+
 func setup(t *testing.T) {
 	logger.InitLogger(ioutil.Discard, "Debug")
 
-	db, err := sql.Open("sqlite3", ":memory:")
+	if testDB != nil {
+		testDB.Close()
+	}
+
+	driver, db, err := openTestDatabase()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := database.NewMigrator(driver, db)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = database.InitTables(db)
+	if _, err = migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	err = fillSeeds(driver, db)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = fillSeeds(db)
+	ds, err := models.NewDatasources(driver, db)
 
 	if err != nil {
 		t.Fatal(err)
@@ -64,50 +115,98 @@ func setup(t *testing.T) {
 
 	cfg.File.Location = os.TempDir()
 
+	sessionProvider := session.NewInMemoryProvider()
+
+	auditService := &models.AuditService{
+		Datasource: ds.Audit,
+	}
+
+	loginThrottleService := &models.LoginThrottleService{
+		Datasource: ds.LoginThrottles,
+		Config:     cfg.User,
+	}
+
+	userSessionService := &models.UserSessionService{
+		SessionProvider: sessionProvider,
+	}
+
 	userService := &models.UserService{
-		Datasource: &models.SQLiteUserDatasource{
-			SQLConn: db,
-		},
-		Config: cfg.User,
+		Datasource:         ds.Users,
+		Config:             cfg.User,
+		UserSessionService: userSessionService,
+		AuditService:       auditService,
 	}
 
 	userInviteService := &models.UserInviteService{
-		Datasource: &models.SQLiteUserInviteDatasource{
-			SQLConn: db,
-		},
-		UserService: userService,
+		Datasource:   ds.UserInvites,
+		UserService:  userService,
+		AuditService: auditService,
+		Config:       cfg.User,
 	}
 
 	articleService := &models.ArticleService{
-		AppConfig: cfg.Application,
-		Datasource: &models.SQLiteArticleDatasource{
-			SQLConn: db,
-		},
+		AppConfig:    cfg.Application,
+		Datasource:   ds.Articles,
+		AuditService: auditService,
 	}
 
 	siteService := &models.SiteService{
-		Datasource: &models.SQLiteSiteDatasource{
-			SQLConn: db,
-		},
+		Datasource:   ds.Sites,
+		AuditService: auditService,
 	}
 
 	fileService := &models.FileService{
-		Config: cfg.File,
-		Datasource: &models.SQLiteFileDatasource{
-			SQLConn: db,
-		},
+		Config:       cfg.File,
+		Datasource:   ds.Files,
+		AuditService: auditService,
 	}
 
 	categoryService := &models.CategoryService{
-		Datasource: &models.SQLiteCategoryDatasource{
-			SQLConn: db,
-		},
+		Datasource:   ds.Categories,
+		AuditService: auditService,
 	}
 
 	tokenService := &models.TokenService{
-		Datasource: &models.SQLiteTokenDatasource{
-			SQLConn: db,
-		},
+		Datasource: ds.Tokens,
+	}
+
+	twoFactorService := &models.TwoFactorService{
+		Datasource:  ds.RecoveryCodes,
+		UserService: userService,
+		AppConfig:   cfg.Application,
+		Config:      cfg.User,
+	}
+
+	webAuthnService := &models.WebAuthnService{
+		Datasource:  ds.Credentials,
+		UserService: userService,
+		AppConfig:   cfg.Application,
+	}
+
+	userDeviceService := &models.UserDeviceService{
+		Datasource: ds.UserDevices,
+	}
+
+	accessTokenService := &models.AccessTokenService{
+		Datasource:  ds.AccessTokens,
+		UserService: userService,
+	}
+
+	oidcService := &models.OIDCService{
+		UserService: userService,
+		AppConfig:   cfg.Application,
+		Config:      cfg.OIDC,
+	}
+
+	personalDataService := &models.PersonalDataService{
+		UserService:    userService,
+		ArticleService: articleService,
+		SiteService:    siteService,
+		FileService:    fileService,
+	}
+
+	redirectService := &models.RedirectService{
+		Datasource: ds.Redirects,
 	}
 
 	mailer := &models.Mailer{
@@ -115,26 +214,36 @@ func setup(t *testing.T) {
 		AppConfig: &cfg.Application,
 	}
 
-	sessionService := session.Service{
+	sessionService := models.SessionService{Service: session.Service{
 		Path:            "/admin",
 		Name:            "test-session",
 		HTTPOnly:        true,
 		Secure:          true,
-		SessionProvider: session.NewInMemoryProvider(),
+		SessionProvider: sessionProvider,
 		IdleSessionTTL:  10,
-	}
+	}}
 
 	ctx = &middleware.AppContext{
-		UserService:       userService,
-		UserInviteService: userInviteService,
-		ArticleService:    articleService,
-		CategoryService:   categoryService,
-		SiteService:       siteService,
-		FileService:       fileService,
-		TokenService:      tokenService,
-		SessionService:    &sessionService,
-		Mailer:            mailer,
-		ConfigService:     cfg,
+		UserService:          userService,
+		UserInviteService:    userInviteService,
+		UserSessionService:   userSessionService,
+		UserDeviceService:    userDeviceService,
+		ArticleService:       articleService,
+		CategoryService:      categoryService,
+		SiteService:          siteService,
+		FileService:          fileService,
+		TokenService:         tokenService,
+		TwoFactorService:     twoFactorService,
+		WebAuthnService:      webAuthnService,
+		AccessTokenService:   accessTokenService,
+		OIDCService:          oidcService,
+		PersonalDataService:  personalDataService,
+		AuditService:         auditService,
+		LoginThrottleService: loginThrottleService,
+		RedirectService:      redirectService,
+		SessionService:       &sessionService,
+		Mailer:               mailer,
+		ConfigService:        cfg,
 	}
 }
 
@@ -144,7 +253,27 @@ func teardown() {
 	}
 }
 
-func fillSeeds(db *sql.DB) error {
+func openTestDatabase() (string, *sql.DB, error) {
+	var err error
+
+	if testDriver == database.Postgres {
+		if testDB, err = (database.PostgresConfig{DSN: os.Getenv(postgresDSNEnv)}).Open(); err != nil {
+			return "", nil, err
+		}
+
+		if _, err = testDB.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public;"); err != nil {
+			return "", nil, err
+		}
+
+		return database.Postgres, testDB, nil
+	}
+
+	testDB, err = sql.Open("sqlite3", ":memory:")
+
+	return database.SQLite, testDB, err
+}
+
+func fillSeeds(driver string, db *sql.DB) error {
 	salt := crypt.GenerateSalt()
 	saltedPassword := append([]byte("123456789012"), salt[:]...)
 	password, err := crypt.CryptPassword([]byte(saltedPassword))
@@ -153,25 +282,29 @@ func fillSeeds(db *sql.DB) error {
 		return err
 	}
 
-	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, is_admin, last_modified) VALUES (1, 'alice', 'alice@example.org', 'Alice Schneier', ?, ?, 1, 1, date('now'))", string(salt), password)
+	if driver == database.Postgres {
+		return fillPostgresSeeds(db, salt, password)
+	}
+
+	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, role, last_modified) VALUES (1, 'alice', 'alice@example.org', 'Alice Schneier', ?, ?, 1, 'admin', date('now'))", string(salt), password)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, is_admin, last_modified) VALUES (2, 'bob', 'bob@example.org', 'Bob Stallman', ?, ?, 1, 0, date('now'))", string(salt), string(password))
+	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, role, last_modified) VALUES (2, 'bob', 'bob@example.org', 'Bob Stallman', ?, ?, 1, 'author', date('now'))", string(salt), string(password))
 
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, is_admin, last_modified) VALUES (3, 'mallory', 'mallory@example.org', 'Mallory Pike', ?, ?, 0, 1, date('now'))", string(salt), string(password))
+	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, role, last_modified) VALUES (3, 'mallory', 'mallory@example.org', 'Mallory Pike', ?, ?, 0, 'admin', date('now'))", string(salt), string(password))
 
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, is_admin, last_modified) VALUES (4, 'eve', 'eve@example.org', 'Mallory Pike', ?, ?, 0, 0, date('now'))", string(salt), string(password))
+	_, err = db.Exec("INSERT INTO user (id, username, email, display_name, salt, password, active, role, last_modified) VALUES (4, 'eve', 'eve@example.org', 'Mallory Pike', ?, ?, 0, 'author', date('now'))", string(salt), string(password))
 
 	if err != nil {
 		return err
@@ -181,6 +314,33 @@ func fillSeeds(db *sql.DB) error {
 
 }
 
+func fillPostgresSeeds(db *sql.DB, salt, password []byte) error {
+	seeds := []struct {
+		id          int
+		username    string
+		displayName string
+		active      bool
+		role        string
+	}{
+		{1, "alice", "Alice Schneier", true, "admin"},
+		{2, "bob", "Bob Stallman", true, "author"},
+		{3, "mallory", "Mallory Pike", false, "admin"},
+		{4, "eve", "Mallory Pike", false, "author"},
+	}
+
+	for _, u := range seeds {
+		if _, err := db.Exec("INSERT INTO users (id, username, email, display_name, salt, password, active, role, last_modified) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, current_date)",
+			u.id, u.username, u.username+"@example.org", u.displayName, string(salt), string(password), u.active, u.role); err != nil {
+			return err
+		}
+	}
+
+	// the ids were set explicitly, so the sequence must continue after the seeds
+	_, err := db.Exec("SELECT setval(pg_get_serial_sequence('users', 'id'), $1)", len(seeds))
+
+	return err
+}
+
 func dummyAdminUser() *models.User {
 	u, _ := ctx.UserService.GetByID(1)
 	return u
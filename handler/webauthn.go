@@ -0,0 +1,192 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+// AdminPasskeyRegisterBeginHandler returns the options for registering a new passkey of the currently logged-in user
+func AdminPasskeyRegisterBeginHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	user, _ := middleware.User(r)
+
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	creation, sessionData, err := ctx.WebAuthnService.BeginRegistration(user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session.SetValue("webauthn_registration", sessionData)
+
+	return &models.JSONData{
+		Data: creation,
+	}, nil
+}
+
+// AdminPasskeyRegisterFinishHandler verifies the response of the authenticator and saves the passkey;
+// the name of the passkey is passed as query parameter
+func AdminPasskeyRegisterFinishHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	user, _ := middleware.User(r)
+
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessionData, ok := session.GetValue("webauthn_registration").(string)
+
+	if !ok || len(sessionData) == 0 {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "The registration has expired. Please try again.", errors.New("no pending passkey registration in session"))
+	}
+
+	c, err := ctx.WebAuthnService.FinishRegistration(user, r.URL.Query().Get("name"), sessionData, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session.SetValue("webauthn_registration", "")
+
+	return &models.JSONData{
+		Data: map[string]interface{}{
+			"id":   c.ID,
+			"name": c.Name,
+		},
+	}, nil
+}
+
+// AdminPasskeyDeleteHandler returns the form for removing a passkey
+func AdminPasskeyDeleteHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	credentialID, err := parseInt(getVar(r, "credentialID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	remove := models.Action{
+		ID:          "removePasskey",
+		ActionURL:   fmt.Sprintf("/admin/user/passkey/delete/%d", credentialID),
+		BackLinkURL: "/admin/user/profile",
+		Description: "Please confirm removing of the passkey?",
+		Title:       "Confirm removing of passkey",
+	}
+
+	return &middleware.Template{
+		Name:   tplAdminAction,
+		Active: "profile",
+		Data: map[string]interface{}{
+			"action": remove,
+		},
+	}
+}
+
+// AdminPasskeyDeletePostHandler removes a passkey of the currently logged-in user
+func AdminPasskeyDeletePostHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) *middleware.Template {
+	user, _ := middleware.User(r)
+
+	credentialID, err := parseInt(getVar(r, "credentialID"))
+
+	if err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	if err := ctx.WebAuthnService.RemoveCredential(user, credentialID); err != nil {
+		return &middleware.Template{
+			RedirectPath: "admin/user/profile",
+			Active:       "profile",
+			Err:          err,
+		}
+	}
+
+	return &middleware.Template{
+		RedirectPath: "admin/user/profile",
+		Active:       "profile",
+		SuccessMsg:   "The passkey was successfully removed.",
+	}
+}
+
+// PasskeyLoginBeginHandler starts a passwordless login; the challenge is kept in a new session
+func PasskeyLoginBeginHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	assertion, sessionData, err := ctx.WebAuthnService.BeginLogin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	session := ctx.SessionService.Create(w, r)
+	session.SetValue("webauthn_login", sessionData)
+
+	return &models.JSONData{
+		Data: assertion,
+	}, nil
+}
+
+// PasskeyLoginFinishHandler verifies the assertion of the authenticator; if valid the session is renewed
+// and the user is logged in. The path to redirect to is returned
+func PasskeyLoginFinishHandler(ctx *middleware.AppContext, w http.ResponseWriter, r *http.Request) (*models.JSONData, error) {
+	session, err := ctx.SessionService.Get(w, r)
+
+	if err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", err)
+	}
+
+	sessionData, ok := session.GetValue("webauthn_login").(string)
+
+	if !ok || len(sessionData) == 0 {
+		return nil, httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", errors.New("no pending passkey login in session"))
+	}
+
+	session.SetValue("webauthn_login", "")
+
+	user, err := ctx.WebAuthnService.FinishLogin(sessionData, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session, err = ctx.SessionService.Renew(w, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session.SetValue("userid", user.ID)
+
+	recordLogin(ctx, w, r, user, models.AuditLogin, "passkey")
+
+	redirectTo := r.URL.Query().Get("state")
+
+	if len(redirectTo) == 0 {
+		redirectTo = "admin/articles"
+	}
+
+	return &models.JSONData{
+		Data: map[string]string{
+			"redirect": path.Clean("/" + redirectTo),
+		},
+	}, nil
+}
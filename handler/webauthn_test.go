@@ -0,0 +1,320 @@
+package handler_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/handler"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sa := newSoftAuthenticator(t, ctx.ConfigService.Application.Domain)
+
+	// registration
+	req := request{
+		url:    "/admin/json/passkey/register/begin",
+		user:   rAdminUser,
+		method: "POST",
+	}.buildRequest()
+
+	rr := httptest.NewRecorder()
+	data, err := handler.AdminPasskeyRegisterBeginHandler(ctx, rr, req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creation := data.Data.(*protocol.CredentialCreation)
+
+	r := httptest.NewRequest("POST", "/admin/json/passkey/register/finish?name=YubiKey", bytes.NewReader(sa.attestation(t, creation)))
+	r = r.WithContext(req.Context())
+
+	for _, c := range req.Cookies() {
+		r.AddCookie(c)
+	}
+
+	if _, err = handler.AdminPasskeyRegisterFinishHandler(ctx, httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := ctx.WebAuthnService.ListCredentials(dummyAdminUser())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(credentials) != 1 || credentials[0].Name != "YubiKey" {
+		t.Fatalf("expected one credential named YubiKey, but got %v", credentials)
+	}
+
+	// login
+	r = httptest.NewRequest("POST", "/admin/json/passkey/login/begin", nil)
+	rr = httptest.NewRecorder()
+
+	data, err = handler.PasskeyLoginBeginHandler(ctx, rr, r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion := data.Data.(*protocol.CredentialAssertion)
+	session := rr.Result().Cookies()[0]
+
+	resp, err := doPasskeyLoginFinish(session, sa.assertion(t, assertion, "webauthn.get"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Result().Cookies()[0].Name != "test-session" {
+		t.Error("expected a renewed session cookie after the login")
+	}
+
+	credentials, _ = ctx.WebAuthnService.ListCredentials(dummyAdminUser())
+
+	if credentials[0].SignCount != 1 || !credentials[0].LastUsedAt.Valid {
+		t.Errorf("expected an updated sign count and usage time, but got %d", credentials[0].SignCount)
+	}
+
+	// the challenge can not be used twice
+	if _, err := doPasskeyLoginFinish(session, sa.assertion(t, assertion, "webauthn.get")); err == nil {
+		t.Error("expected an error while replaying an assertion, but error is nil")
+	}
+}
+
+func TestPasskeyLoginInvalidSignature(t *testing.T) {
+	setup(t)
+
+	defer teardown()
+
+	sa := newSoftAuthenticator(t, ctx.ConfigService.Application.Domain)
+
+	creation, sessionData, err := ctx.WebAuthnService.BeginRegistration(dummyUser())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(sa.attestation(t, creation)))
+
+	if _, err := ctx.WebAuthnService.FinishRegistration(dummyUser(), "Phone", sessionData, r); err != nil {
+		t.Fatal(err)
+	}
+
+	assertion, sessionData, err := ctx.WebAuthnService.BeginLogin()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a different key signs the assertion
+	other := newSoftAuthenticator(t, ctx.ConfigService.Application.Domain)
+	other.credentialID = sa.credentialID
+	other.userHandle = sa.userHandle
+
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(other.assertion(t, assertion, "webauthn.get")))
+
+	if _, err := ctx.WebAuthnService.FinishLogin(sessionData, r); err == nil {
+		t.Error("expected an error for an invalid signature, but error is nil")
+	}
+
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(sa.assertion(t, assertion, "webauthn.create")))
+
+	if _, err := ctx.WebAuthnService.FinishLogin(sessionData, r); err == nil {
+		t.Error("expected an error for an invalid client data type, but error is nil")
+	}
+
+	r = httptest.NewRequest("POST", "/", bytes.NewReader(sa.assertion(t, assertion, "webauthn.get")))
+
+	u, err := ctx.WebAuthnService.FinishLogin(sessionData, r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.ID != dummyUser().ID {
+		t.Errorf("expected user %d, but got %d", dummyUser().ID, u.ID)
+	}
+}
+
+func doPasskeyLoginFinish(session *http.Cookie, body []byte) (*httptest.ResponseRecorder, error) {
+	r := httptest.NewRequest("POST", "/admin/json/passkey/login/finish", bytes.NewReader(body))
+	r.AddCookie(session)
+
+	rr := httptest.NewRecorder()
+
+	_, err := handler.PasskeyLoginFinishHandler(ctx, rr, r)
+
+	return rr, err
+}
+
+// softAuthenticator is a software authenticator creating "none" attestations and ES256 signed assertions
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+	rpID         string
+}
+
+func newSoftAuthenticator(t *testing.T, domain string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(domain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 16)
+
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{
+		key:          key,
+		credentialID: id,
+		origin:       u.Scheme + "://" + u.Host,
+		rpID:         u.Hostname(),
+	}
+}
+
+func (sa *softAuthenticator) clientData(t *testing.T, typ string, challenge protocol.URLEncodedBase64) []byte {
+	cd, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge.String(),
+		"origin":    sa.origin,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cd
+}
+
+func (sa *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(sa.rpID))
+
+	var buf bytes.Buffer
+	buf.Write(rpIDHash[:])
+	buf.WriteByte(flags)
+
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, sa.signCount)
+	buf.Write(counter)
+	buf.Write(attested)
+
+	return buf.Bytes()
+}
+
+func (sa *softAuthenticator) attestation(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	sa.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,
+		3:  -7,
+		-1: 1,
+		-2: sa.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: sa.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attested bytes.Buffer
+	attested.Write(make([]byte, 16))
+
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(sa.credentialID)))
+	attested.Write(l)
+	attested.Write(sa.credentialID)
+	attested.Write(publicKey)
+
+	// user present, user verified, attested credential data included
+	authData := sa.authData(0x01|0x04|0x40, attested.Bytes())
+
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(sa.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(sa.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(sa.clientData(t, "webauthn.create", creation.Response.Challenge)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func (sa *softAuthenticator) assertion(t *testing.T, assertion *protocol.CredentialAssertion, typ string) []byte {
+	sa.signCount++
+
+	// user present, user verified
+	authData := sa.authData(0x01|0x04, nil)
+	clientData := sa.clientData(t, typ, assertion.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, sa.key, digest[:])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(sa.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(sa.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(sa.userHandle),
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
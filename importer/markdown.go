@@ -0,0 +1,310 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// lineBreak marks a <br>; the whitespace around the line break is removed when the markdown is cleaned
+const lineBreak = "\x00br\x00"
+
+var (
+	whitespace     = regexp.MustCompile(`\s+`)
+	lineBreaks     = regexp.MustCompile("[ \t]*\x00br\x00[ \t]*")
+	emptyLines     = regexp.MustCompile(`\n{3,}`)
+	trailingSpaces = regexp.MustCompile(`(?m)[ \t]+$`)
+	paragraphTag   = regexp.MustCompile(`(?i)<p[\s>]`)
+	blankLine      = regexp.MustCompile(`\n[ \t]*\n`)
+	blockTag       = regexp.MustCompile(`(?i)^<(h[1-6]|ul|ol|li|div|blockquote|pre|table|figure|hr|p|!--)[\s>/]`)
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", "&lt;",
+)
+
+// HTMLToMarkdown converts HTML to markdown; elements without an equivalent in markdown, e.g. tables, are kept as HTML.
+// The URLs of links and images are passed to rewriteURL, if it is not nil
+func HTMLToMarkdown(s string, rewriteURL func(string) string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	c := &converter{
+		rewriteURL: rewriteURL,
+	}
+
+	var sb strings.Builder
+
+	for _, n := range nodes {
+		sb.WriteString(c.node(n))
+	}
+
+	return cleanMarkdown(sb.String()), nil
+}
+
+// autoParagraphs wraps the paragraphs, which are separated by blank lines, of HTML without paragraphs in <p> and
+// replaces the line breaks with <br>; older WordPress posts are written without paragraphs
+func autoParagraphs(s string) string {
+	if paragraphTag.MatchString(s) {
+		return s
+	}
+
+	paragraphs := blankLine.Split(strings.ReplaceAll(s, "\r\n", "\n"), -1)
+
+	var sb strings.Builder
+
+	for _, p := range paragraphs {
+		p = strings.TrimSpace(p)
+
+		if len(p) == 0 {
+			continue
+		}
+
+		if blockTag.MatchString(p) {
+			sb.WriteString(p)
+		} else {
+			sb.WriteString("<p>")
+			sb.WriteString(strings.ReplaceAll(p, "\n", "<br>\n"))
+			sb.WriteString("</p>")
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+type converter struct {
+	rewriteURL func(string) string
+}
+
+func (c *converter) url(u string) string {
+	if c.rewriteURL == nil {
+		return u
+	}
+
+	return c.rewriteURL(u)
+}
+
+func (c *converter) children(n *html.Node) string {
+	var sb strings.Builder
+
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.node(ch))
+	}
+
+	return sb.String()
+}
+
+func (c *converter) node(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(whitespace.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+		return c.element(n)
+	case html.DocumentNode:
+		return c.children(n)
+	}
+
+	return ""
+}
+
+func (c *converter) element(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Figure, atom.Figcaption:
+		return block(c.children(n))
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(lineBreaks.ReplaceAllString(c.children(n), " "))
+		return block(strings.Repeat("#", level) + " " + text)
+	case atom.Br:
+		return lineBreak
+	case atom.Hr:
+		return block("---")
+	case atom.Strong, atom.B:
+		return wrap("**", c.children(n))
+	case atom.Em, atom.I:
+		return wrap("*", c.children(n))
+	case atom.Del, atom.S, atom.Strike:
+		return wrap("~~", c.children(n))
+	case atom.Code:
+		return "`" + text(n) + "`"
+	case atom.Pre:
+		return block(codeBlock(n))
+	case atom.A:
+		content := c.children(n)
+		href := attr(n, "href")
+
+		if len(href) == 0 || len(strings.TrimSpace(content)) == 0 {
+			return content
+		}
+
+		return "[" + strings.TrimSpace(content) + "](" + c.url(href) + ")"
+	case atom.Img:
+		src := attr(n, "src")
+
+		if len(src) == 0 {
+			return ""
+		}
+
+		return "![" + markdownEscaper.Replace(attr(n, "alt")) + "](" + c.url(src) + ")"
+	case atom.Ul, atom.Ol:
+		return block(c.list(n))
+	case atom.Blockquote:
+		lines := strings.Split(cleanMarkdown(c.children(n)), "\n")
+
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+
+		return block(strings.Join(lines, "\n"))
+	case atom.Script, atom.Style, atom.Noscript:
+		return ""
+	case atom.Table, atom.Iframe, atom.Video, atom.Audio, atom.Object, atom.Embed, atom.Dl:
+		var sb strings.Builder
+
+		if err := html.Render(&sb, n); err != nil {
+			return ""
+		}
+
+		return block(sb.String())
+	}
+
+	return c.children(n)
+}
+
+func (c *converter) list(n *html.Node) string {
+	var items []string
+
+	i := 0
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+
+		i++
+
+		marker := "- "
+
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+		}
+
+		// the paragraphs of an item are not separated by blank lines to keep the list tight
+		content := emptyLines.ReplaceAllString(cleanMarkdown(c.children(li)), "\n")
+		content = strings.ReplaceAll(content, "\n\n", "\n")
+
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(content, "\n")
+
+		for j := 1; j < len(lines); j++ {
+			lines[j] = indent + lines[j]
+		}
+
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// codeBlock returns the fenced code block of the preformatted text; the language is taken from the class language-*
+func codeBlock(n *html.Node) string {
+	lang := ""
+
+	for _, cl := range strings.Fields(attr(n, "class")) {
+		if strings.HasPrefix(cl, "language-") {
+			lang = strings.TrimPrefix(cl, "language-")
+		}
+	}
+
+	if code := n.FirstChild; code != nil && code.DataAtom == atom.Code {
+		for _, cl := range strings.Fields(attr(code, "class")) {
+			if strings.HasPrefix(cl, "language-") {
+				lang = strings.TrimPrefix(cl, "language-")
+			}
+		}
+	}
+
+	return "```" + lang + "\n" + strings.Trim(text(n), "\n") + "\n```"
+}
+
+// text returns the text of the node and its descendants unchanged
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	if n.DataAtom == atom.Br {
+		return "\n"
+	}
+
+	var sb strings.Builder
+
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(text(ch))
+	}
+
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// block separates the markdown block by blank lines
+func block(s string) string {
+	s = strings.TrimSpace(cleanMarkdown(s))
+
+	if len(s) == 0 {
+		return ""
+	}
+
+	return "\n\n" + s + "\n\n"
+}
+
+// wrap surrounds the text with the marker; the marker must be next to the text, the whitespace is moved outside
+func wrap(marker, s string) string {
+	t := strings.TrimSpace(s)
+
+	if len(t) == 0 {
+		return s
+	}
+
+	i := strings.Index(s, t)
+
+	return s[:i] + marker + t + marker + s[i+len(t):]
+}
+
+func cleanMarkdown(s string) string {
+	s = lineBreaks.ReplaceAllString(s, "\n")
+	s = trailingSpaces.ReplaceAllString(s, "")
+	s = emptyLines.ReplaceAllString(s, "\n\n")
+
+	return strings.Trim(s, "\n ")
+}
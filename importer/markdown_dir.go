@@ -0,0 +1,410 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/slug"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// jekyllFilename matches the filenames of Jekyll posts, e.g. 2019-05-01-hello-world.md
+	jekyllFilename = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+	markdownImage  = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	sourceAttr     = regexp.MustCompile(`\bsrc\s*=\s*["']([^"']+)["']`)
+	siteURL        = regexp.MustCompile(`^(\{\{-?\s*site\.(url|baseurl)\s*-?\}\})+`)
+)
+
+var frontMatterTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// MarkdownDir reads the posts of static site generators like Hugo or Jekyll; the posts are markdown files with YAML
+// (---) or TOML (+++) front matter. The images referenced by the posts are added to the files and their links are
+// rewritten to the files
+type MarkdownDir struct {
+	// Dir is the directory with the posts, e.g. content/posts of Hugo or _posts of Jekyll
+	Dir string
+	// Static is the directory the absolute links of the images are resolved in, e.g. static of Hugo or the root
+	// directory of Jekyll; relative links are resolved in the directory of the post
+	Static string
+
+	// Rewrites lists the links of the images, which are rewritten to the files
+	Rewrites []string
+	// Warnings contains the problems, which did not stop the conversion, e.g. images which were not found
+	Warnings []string
+
+	files map[string]string
+	names map[string]bool
+}
+
+// Read walks the directory and converts the posts into articles
+func (md *MarkdownDir) Read() (*models.Content, error) {
+	md.files = make(map[string]string)
+	md.names = make(map[string]bool)
+
+	c := &models.Content{}
+
+	err := filepath.WalkDir(md.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !isMarkdown(p) || strings.HasPrefix(d.Name(), "_index.") {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+
+		if err != nil {
+			return err
+		}
+
+		a, err := md.article(p, data, c)
+
+		if err != nil {
+			md.warn("the post %s is not imported %v", p, err)
+			return nil
+		}
+
+		c.Articles = append(c.Articles, *a)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the older posts get the slug if two posts would get the same slug
+	sort.SliceStable(c.Articles, func(i, j int) bool {
+		return c.Articles[i].PublishedOn.Time.Before(c.Articles[j].PublishedOn.Time)
+	})
+
+	return c, nil
+}
+
+func (md *MarkdownDir) warn(format string, args ...interface{}) {
+	md.Warnings = append(md.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (md *MarkdownDir) article(file string, data []byte, c *models.Content) (*models.TransferArticle, error) {
+	fm, body, err := parseFrontMatter(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+	// the page bundles of Hugo are named by their directory
+	if name == "index" {
+		name = filepath.Base(filepath.Dir(file))
+	}
+
+	date, ok := fm.time("date")
+
+	if m := jekyllFilename.FindStringSubmatch(name); m != nil {
+		name = m[2]
+
+		if !ok {
+			date, err = time.ParseInLocation("2006-01-02", m[1], time.Local)
+			ok = err == nil
+		}
+	}
+
+	draft, _ := fm.bool("draft")
+	published, isSet := fm.bool("published")
+
+	a := &models.TransferArticle{
+		Headline:  fm.string("title"),
+		Published: !draft && (published || !isSet),
+	}
+
+	if ok {
+		a.PublishedOn = models.NullTime{Time: date, Valid: true}
+	}
+
+	if lastmod, ok := fm.time("lastmod"); ok {
+		a.LastModified = lastmod
+	} else if lastmod, ok := fm.time("last_modified_at"); ok {
+		a.LastModified = lastmod
+	}
+
+	if categories := fm.strings("categories"); len(categories) > 0 {
+		a.Category = categories[0]
+	} else {
+		a.Category = fm.string("category")
+	}
+
+	if s := fm.string("slug"); len(s) > 0 {
+		name = s
+	}
+
+	// the words of the slug are separated by dashes like in the slugs built from the headline
+	if s := slug.CreateURLSafeSlug(strings.ReplaceAll(name, "-", " "), 0); len(s) > 0 && a.PublishedOn.Valid {
+		a.Slug = fmt.Sprintf("%d/%d/%s", date.Year(), int(date.Month()), s)
+	}
+
+	teaser := strings.TrimSpace(md.rewriteImages(file, fm.string("summary"), c))
+	content := strings.TrimSpace(md.rewriteImages(file, body, c))
+
+	if i := strings.Index(content, moreTag); i >= 0 {
+		if len(teaser) == 0 {
+			teaser = content[:i]
+		}
+		content = content[i+len(moreTag):]
+	}
+
+	// the first paragraph is the teaser if the post has neither a summary nor a more tag
+	if len(teaser) == 0 {
+		teaser, content, _ = strings.Cut(content, "\n\n")
+	}
+
+	a.Teaser = strings.TrimSpace(teaser)
+	a.Content = strings.TrimSpace(content)
+
+	return a, nil
+}
+
+// rewriteImages adds the local images referenced by markdown images or src attributes to the files; the links are
+// replaced by the links to the files
+func (md *MarkdownDir) rewriteImages(file, s string, c *models.Content) string {
+	for _, pattern := range []*regexp.Regexp{markdownImage, sourceAttr} {
+		var sb strings.Builder
+
+		last := 0
+
+		for _, m := range pattern.FindAllStringSubmatchIndex(s, -1) {
+			link := s[m[2]:m[3]]
+
+			sb.WriteString(s[last:m[2]])
+			sb.WriteString(md.image(file, link, c))
+
+			last = m[3]
+		}
+
+		sb.WriteString(s[last:])
+
+		s = sb.String()
+	}
+
+	return s
+}
+
+// image returns the link to the file of the image; the link is not changed if the image is not a local file
+func (md *MarkdownDir) image(file, link string, c *models.Content) string {
+	l := siteURL.ReplaceAllString(link, "")
+
+	u, err := url.Parse(l)
+
+	if err != nil || len(u.Scheme) > 0 || len(u.Host) > 0 || len(u.Path) == 0 || strings.HasPrefix(u.Path, "/file/") {
+		return link
+	}
+
+	if !strings.HasPrefix(mime.TypeByExtension(path.Ext(u.Path)), "image/") {
+		return link
+	}
+
+	var p string
+
+	if strings.HasPrefix(u.Path, "/") {
+		if len(md.Static) == 0 {
+			md.warn("the image %s referenced in %s is not imported, the static directory is not set", link, file)
+			return link
+		}
+
+		p = filepath.Join(md.Static, filepath.FromSlash(path.Clean(u.Path)))
+	} else {
+		p = filepath.Join(filepath.Dir(file), filepath.FromSlash(u.Path))
+	}
+
+	name, ok := md.files[p]
+
+	if !ok {
+		data, err := os.ReadFile(p)
+
+		if err != nil {
+			md.warn("the image %s referenced in %s is not imported %v", link, file, err)
+			return link
+		}
+
+		filename := filepath.Base(p)
+		name = uniqueName(md.names, filename)
+
+		var lastModified time.Time
+
+		if fi, err := os.Stat(p); err == nil {
+			lastModified = fi.ModTime()
+		}
+
+		contentType := mime.TypeByExtension(path.Ext(u.Path))
+
+		c.Files = append(c.Files, models.TransferFile{
+			Filename:     filename,
+			UniqueName:   name,
+			ContentType:  contentType,
+			Inline:       true,
+			Size:         int64(len(data)),
+			LastModified: lastModified,
+			Data:         data,
+		})
+
+		md.files[p] = name
+	}
+
+	md.Rewrites = append(md.Rewrites, fmt.Sprintf("%s: %s -> /file/%s", file, link, name))
+
+	return "/file/" + name
+}
+
+func isMarkdown(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+
+	return false
+}
+
+// frontMatterValues are the values of the front matter by the key
+type frontMatterValues map[string]interface{}
+
+// parseFrontMatter splits the post into the front matter and the body; the front matter is YAML if it is surrounded
+// by --- and TOML if it is surrounded by +++
+func parseFrontMatter(data []byte) (frontMatterValues, string, error) {
+	s := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+
+	var delimiter string
+
+	switch {
+	case strings.HasPrefix(s, "---\n"):
+		delimiter = "---"
+	case strings.HasPrefix(s, "+++\n"):
+		delimiter = "+++"
+	default:
+		return nil, "", fmt.Errorf("the front matter is missing")
+	}
+
+	header, body, ok := strings.Cut(s[len(delimiter)+1:], "\n"+delimiter+"\n")
+
+	if !ok {
+		header, ok = strings.CutSuffix(strings.TrimRight(s[len(delimiter)+1:], "\n"), "\n"+delimiter)
+	}
+
+	if !ok {
+		return nil, "", fmt.Errorf("the end of the front matter is missing")
+	}
+
+	fm := frontMatterValues{}
+
+	var err error
+
+	if delimiter == "---" {
+		err = yaml.Unmarshal([]byte(header), &fm)
+	} else {
+		_, err = toml.Decode(header, &fm)
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("the front matter is invalid %v", err)
+	}
+
+	return fm, body, nil
+}
+
+func (fm frontMatterValues) string(key string) string {
+	switch v := fm[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (fm frontMatterValues) bool(key string) (bool, bool) {
+	switch v := fm[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		return v == "true", true
+	}
+
+	return false, false
+}
+
+// strings returns the list; Jekyll separates the values of a list given as string by spaces
+func (fm frontMatterValues) strings(key string) []string {
+	switch v := fm[key].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+
+		for _, e := range v {
+			if s := strings.TrimSpace(fmt.Sprint(e)); len(s) > 0 {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+func (fm frontMatterValues) time(key string) (time.Time, bool) {
+	switch v := fm[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range frontMatterTimeLayouts {
+			if t, err := time.ParseInLocation(layout, strings.TrimSpace(v), time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// uniqueName returns the filename; a number is appended to the filename if the name is already used
+func uniqueName(names map[string]bool, filename string) string {
+	name := filename
+
+	for i := 1; names[name]; i++ {
+		ext := path.Ext(filename)
+		name = strings.TrimSuffix(filename, ext) + strconv.Itoa(i) + ext
+	}
+
+	names[name] = true
+
+	return name
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+func readMarkdownDir(t *testing.T) (*MarkdownDir, *models.Content) {
+	md := &MarkdownDir{
+		Dir:    "testdata/markdown/posts",
+		Static: "testdata/markdown/static",
+	}
+
+	c, err := md.Read()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return md, c
+}
+
+func TestMarkdownDirRead(t *testing.T) {
+	md, c := readMarkdownDir(t)
+
+	if len(c.Articles) != 3 {
+		t.Fatalf("expected three articles, but got %d %v", len(c.Articles), md.Warnings)
+	}
+
+	jekyll, hugo, bundle := c.Articles[0], c.Articles[1], c.Articles[2]
+
+	if jekyll.Headline != "Jekyll post" || jekyll.Slug != "2019/5/jekyll-post" || jekyll.Category != "travel" || !jekyll.Published {
+		t.Errorf("unexpected article %v", jekyll)
+	}
+
+	if jekyll.Teaser != "The teaser of the post" || !strings.Contains(jekyll.Content, `<img src="/file/photo.png" alt="photo">`) ||
+		!strings.Contains(jekyll.Content, `<img src="/images/missing.png">`) {
+		t.Errorf("unexpected teaser or content %q %q", jekyll.Teaser, jekyll.Content)
+	}
+
+	if hugo.Slug != "2020/3/hello-hugo" || hugo.Category != "Go" || hugo.Teaser != "The summary" || !hugo.Published {
+		t.Errorf("unexpected article %v", hugo)
+	}
+
+	if !hugo.PublishedOn.Time.Equal(time.Date(2020, 3, 4, 10, 0, 0, 0, time.UTC)) || !hugo.LastModified.Equal(time.Date(2020, 3, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the dates of the front matter, but got %v %v", hugo.PublishedOn, hugo.LastModified)
+	}
+
+	for _, s := range []string{`![image](/file/photo.png "title")`, `{{< figure src="/file/photo.png" >}}`, "![remote](https://example.com/remote.png)"} {
+		if !strings.Contains(hugo.Content, s) {
+			t.Errorf("expected the content to contain %q, but got %q", s, hugo.Content)
+		}
+	}
+
+	if bundle.Published || !bundle.PublishedOn.Valid || bundle.Teaser != "Teaser with ![photo](/file/photo1.png)" || bundle.Content != "Content" {
+		t.Errorf("unexpected draft %v", bundle)
+	}
+
+	if len(c.Files) != 2 || c.Files[0].UniqueName != "photo.png" || c.Files[1].UniqueName != "photo1.png" {
+		t.Errorf("unexpected files %v", c.Files)
+	}
+
+	if len(md.Rewrites) != 4 {
+		t.Errorf("expected four rewritten links, but got %v", md.Rewrites)
+	}
+
+	if len(md.Warnings) != 2 {
+		t.Errorf("expected warnings about the missing front matter and the missing image, but got %v", md.Warnings)
+	}
+}
+
+func TestMarkdownDirImportDryRun(t *testing.T) {
+	_, c := readMarkdownDir(t)
+
+	db := setupDB(t)
+	defer db.Close()
+
+	ds := models.NewSQLiteDatasources(db)
+	location := t.TempDir()
+
+	ts := &models.TransferService{
+		UserService:    &models.UserService{Datasource: ds.Users},
+		ArticleService: &models.ArticleService{Datasource: ds.Articles},
+		SiteService:    &models.SiteService{Datasource: ds.Sites},
+		FileService: &models.FileService{
+			Datasource: ds.Files,
+			Config: settings.File{
+				Location:              location,
+				AllowedFileExtensions: settings.AllowedFileExts{".png": ".png"},
+			},
+		},
+		CategoryService: &models.CategoryService{Datasource: ds.Categories},
+	}
+
+	actor := &models.User{
+		Username:      "admin",
+		Email:         "admin@example.com",
+		DisplayName:   "admin",
+		PlainPassword: []byte("secret-password"),
+		Role:          models.RoleAdmin,
+		Active:        true,
+	}
+
+	id, err := ts.UserService.Create(actor, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actor.ID = id
+
+	for _, dryRun := range []bool{true, false} {
+		report, err := ts.ImportContent(c, actor, models.ImportOptions{DryRun: dryRun})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if report.Count(models.AuditArticle, models.ImportCreated) != 3 || report.Count(models.AuditCategory, models.ImportCreated) != 2 ||
+			report.Count(models.AuditFile, models.ImportCreated) != 2 {
+			t.Fatalf("unexpected report of the import (dry run %t) %v", dryRun, report.Entries)
+		}
+
+		articles, err := ts.ArticleService.ListByAuthor(actor, nil, models.All)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files, err := os.ReadDir(location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dryRun && (len(articles) > 0 || len(files) > 0) {
+			t.Fatalf("expected the dry run to change nothing, but got %d articles and %d files", len(articles), len(files))
+		}
+
+		if !dryRun && (len(articles) != 3 || len(files) != 2) {
+			t.Fatalf("expected three articles and two files, but got %d articles and %d files", len(articles), len(files))
+		}
+	}
+
+	a, err := ts.ArticleService.GetBySlug("2020/3/hello-hugo", nil, models.All)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.PublishedOn.Time.Equal(time.Date(2020, 3, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the article to be published on the date of the front matter, but got %v", a.PublishedOn)
+	}
+}
@@ -0,0 +1,466 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package importer converts the content of other blog engines into content, which is imported by the TransferService
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/slug"
+)
+
+const (
+	wxrTimeLayout = "2006-01-02 15:04:05"
+	uploadsPath   = "/wp-content/uploads/"
+	moreTag       = "<!--more-->"
+
+	// maxAttachmentSize is the maximum size of a downloaded attachment
+	maxAttachmentSize = 100 << 20
+)
+
+var (
+	// imageSize matches the size WordPress appends to the filename of scaled images, e.g. image-300x200.png
+	imageSize = regexp.MustCompile(`-\d+x\d+(\.[^./]+)$`)
+	caption   = regexp.MustCompile(`(?s)\[caption[^\]]*\](.*?)\[/caption\]`)
+)
+
+// WordPress reads the content of a WordPress export file (WXR). Posts are converted to articles, pages to sites and
+// attachments to files; the HTML of the posts and pages is converted to markdown
+type WordPress struct {
+	// Uploads is a copy of the directory wp-content/uploads; the attachments are read from this directory
+	Uploads string
+	// Download downloads the attachments from the blog, which are not found in the directory Uploads
+	Download bool
+	Client   *http.Client
+
+	// Warnings contains the problems, which did not stop the conversion, e.g. attachments which were not found
+	Warnings []string
+}
+
+type wxrRSS struct {
+	Channel wxrChannel `xml:"channel"`
+}
+
+type wxrChannel struct {
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link"`
+	Authors    []wxrAuthor   `xml:"author"`
+	Categories []wxrCategory `xml:"category"`
+	Items      []wxrItem     `xml:"item"`
+}
+
+type wxrAuthor struct {
+	ID          int    `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+type wxrCategory struct {
+	ID       int    `xml:"term_id"`
+	Nicename string `xml:"category_nicename"`
+	Name     string `xml:"cat_name"`
+}
+
+type wxrItem struct {
+	Title         string            `xml:"title"`
+	Link          string            `xml:"link"`
+	Creator       string            `xml:"creator"`
+	Encoded       []wxrEncoded      `xml:"encoded"`
+	ID            int               `xml:"post_id"`
+	Date          string            `xml:"post_date"`
+	DateGMT       string            `xml:"post_date_gmt"`
+	ModifiedGMT   string            `xml:"post_modified_gmt"`
+	Name          string            `xml:"post_name"`
+	Status        string            `xml:"status"`
+	MenuOrder     int               `xml:"menu_order"`
+	Type          string            `xml:"post_type"`
+	AttachmentURL string            `xml:"attachment_url"`
+	Categories    []wxrItemCategory `xml:"category"`
+}
+
+type wxrItemCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// wxrEncoded is the content (content:encoded) or the excerpt (excerpt:encoded) of an item
+type wxrEncoded struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+func (item wxrItem) encoded(space string) string {
+	for _, e := range item.Encoded {
+		if strings.Contains(e.XMLName.Space, space) {
+			return e.Value
+		}
+	}
+
+	return ""
+}
+
+// Read reads the export file and converts its content
+func (wp *WordPress) Read(r io.Reader) (*models.Content, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	var rss wxrRSS
+
+	if err := d.Decode(&rss); err != nil {
+		return nil, fmt.Errorf("the file is not a WordPress export %v", err)
+	}
+
+	c := &models.Content{}
+
+	for _, a := range rss.Channel.Authors {
+		displayName := a.DisplayName
+
+		if len(strings.TrimSpace(displayName)) == 0 {
+			displayName = a.Login
+		}
+
+		c.Users = append(c.Users, models.TransferUser{
+			ID:          a.ID,
+			Username:    a.Login,
+			Email:       a.Email,
+			DisplayName: displayName,
+			Role:        models.RoleAuthor,
+			Active:      true,
+		})
+	}
+
+	for _, cat := range rss.Channel.Categories {
+		c.Categories = append(c.Categories, models.TransferCategory{
+			ID:   cat.ID,
+			Name: cat.Name,
+			Slug: cat.Nicename,
+		})
+	}
+
+	attachments := wp.readAttachments(rss.Channel.Items, c)
+
+	rewriteURL := func(u string) string {
+		return attachmentURL(attachments, u)
+	}
+
+	skipped := make(map[string]int)
+
+	for _, item := range rss.Channel.Items {
+		switch item.Status {
+		case "trash", "auto-draft", "inherit":
+			continue
+		}
+
+		switch item.Type {
+		case "post":
+			a, err := wp.article(item, rewriteURL)
+
+			if err != nil {
+				wp.warn("the post %d '%s' is not imported %v", item.ID, item.Title, err)
+				continue
+			}
+
+			c.Articles = append(c.Articles, *a)
+		case "page":
+			s, err := wp.site(item, rewriteURL)
+
+			if err != nil {
+				wp.warn("the page %d '%s' is not imported %v", item.ID, item.Title, err)
+				continue
+			}
+
+			c.Sites = append(c.Sites, *s)
+		case "attachment":
+		default:
+			skipped[item.Type]++
+		}
+	}
+
+	types := make([]string, 0, len(skipped))
+
+	for t := range skipped {
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+
+	for _, t := range types {
+		wp.warn("%d items of the type %s are not imported", skipped[t], t)
+	}
+
+	sort.SliceStable(c.Sites, func(i, j int) bool {
+		return c.Sites[i].OrderNo < c.Sites[j].OrderNo
+	})
+
+	return c, nil
+}
+
+func (wp *WordPress) warn(format string, args ...interface{}) {
+	wp.Warnings = append(wp.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (wp *WordPress) article(item wxrItem, rewriteURL func(string) string) (*models.TransferArticle, error) {
+	content := caption.ReplaceAllString(item.encoded("content"), "$1")
+
+	var teaser string
+
+	if i := strings.Index(content, moreTag); i >= 0 {
+		teaser, content = content[:i], content[i+len(moreTag):]
+	} else {
+		teaser = item.encoded("excerpt")
+	}
+
+	teaserMD, err := HTMLToMarkdown(autoParagraphs(teaser), rewriteURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contentMD, err := HTMLToMarkdown(autoParagraphs(content), rewriteURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the first paragraph is the teaser if the post has neither an excerpt nor a more tag
+	if len(teaserMD) == 0 {
+		teaserMD, contentMD, _ = strings.Cut(contentMD, "\n\n")
+	}
+
+	a := &models.TransferArticle{
+		ID:           item.ID,
+		Headline:     strings.TrimSpace(item.Title),
+		Teaser:       teaserMD,
+		Content:      contentMD,
+		Author:       item.Creator,
+		Published:    item.Status == "publish",
+		PublishedOn:  publishedOn(item),
+		LastModified: parseTime(item.ModifiedGMT, time.UTC).Time,
+		Permalinks:   []string{item.Link},
+	}
+
+	for _, cat := range item.Categories {
+		if cat.Domain == "category" {
+			a.Category = strings.TrimSpace(cat.Name)
+			break
+		}
+	}
+
+	// the words of the slug are separated by dashes like in the slugs built from the headline
+	if name := slug.CreateURLSafeSlug(strings.ReplaceAll(postName(item), "-", " "), 0); len(name) > 0 {
+		t := time.Now()
+
+		if a.PublishedOn.Valid {
+			t = a.PublishedOn.Time
+		}
+
+		a.Slug = fmt.Sprintf("%d/%d/%s", t.Year(), int(t.Month()), name)
+	}
+
+	return a, nil
+}
+
+func (wp *WordPress) site(item wxrItem, rewriteURL func(string) string) (*models.TransferSite, error) {
+	content := strings.Replace(caption.ReplaceAllString(item.encoded("content"), "$1"), moreTag, "", 1)
+
+	md, err := HTMLToMarkdown(autoParagraphs(content), rewriteURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	link := postName(item)
+
+	if len(strings.TrimSpace(link)) == 0 {
+		link = item.Title
+	}
+
+	link = slug.CreateURLSafeSlug(link, 0)
+
+	return &models.TransferSite{
+		ID:           item.ID,
+		Title:        strings.TrimSpace(item.Title),
+		Link:         link,
+		Section:      "navigation",
+		Content:      md,
+		OrderNo:      item.MenuOrder,
+		Author:       item.Creator,
+		Published:    item.Status == "publish",
+		PublishedOn:  publishedOn(item),
+		LastModified: parseTime(item.ModifiedGMT, time.UTC).Time,
+		Permalinks:   []string{item.Link},
+	}, nil
+}
+
+// readAttachments adds the attachments to the files of the content; the names of the files are returned by the path
+// of the attachment in the uploads directory
+func (wp *WordPress) readAttachments(items []wxrItem, c *models.Content) map[string]string {
+	attachments := make(map[string]string)
+	names := make(map[string]bool)
+
+	for _, item := range items {
+		if item.Type != "attachment" || len(item.AttachmentURL) == 0 {
+			continue
+		}
+
+		u, err := url.Parse(item.AttachmentURL)
+
+		if err != nil {
+			wp.warn("the attachment %d has the invalid URL %s", item.ID, item.AttachmentURL)
+			continue
+		}
+
+		filename := path.Base(u.Path)
+
+		// the files of different months may have the same name
+		name := uniqueName(names, filename)
+
+		data, err := wp.attachment(u)
+
+		if err != nil {
+			wp.warn("the attachment %s is not imported %v", item.AttachmentURL, err)
+		}
+
+		contentType := mime.TypeByExtension(path.Ext(filename))
+
+		if len(contentType) == 0 && data != nil {
+			contentType = http.DetectContentType(data)
+		}
+
+		attachments[u.Path] = name
+
+		c.Files = append(c.Files, models.TransferFile{
+			ID:           item.ID,
+			Filename:     filename,
+			UniqueName:   name,
+			ContentType:  contentType,
+			Inline:       strings.HasPrefix(contentType, "image/"),
+			Size:         int64(len(data)),
+			LastModified: parseTime(item.DateGMT, time.UTC).Time,
+			Author:       item.Creator,
+			Data:         data,
+		})
+	}
+
+	return attachments
+}
+
+// attachment reads the attachment from the uploads directory or downloads it
+func (wp *WordPress) attachment(u *url.URL) ([]byte, error) {
+	if i := strings.Index(u.Path, uploadsPath); i >= 0 && len(wp.Uploads) > 0 {
+		rel := path.Clean("/" + u.Path[i+len(uploadsPath):])
+
+		data, err := os.ReadFile(filepath.Join(wp.Uploads, filepath.FromSlash(rel)))
+
+		if err == nil {
+			return data, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) || !wp.Download {
+			return nil, err
+		}
+	}
+
+	if !wp.Download {
+		return nil, errors.New("the attachment is not in the uploads directory and downloads are disabled")
+	}
+
+	client := wp.Client
+
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+
+	resp, err := client.Get(u.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the download failed with status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("the attachment is larger than %d bytes", maxAttachmentSize)
+	}
+
+	return data, nil
+}
+
+// attachmentURL returns the link to the file of the attachment; the scaled images link to the original image
+func attachmentURL(attachments map[string]string, rawURL string) string {
+	u, err := url.Parse(rawURL)
+
+	if err != nil || !strings.Contains(u.Path, uploadsPath) {
+		return rawURL
+	}
+
+	name, ok := attachments[u.Path]
+
+	if !ok {
+		name, ok = attachments[imageSize.ReplaceAllString(u.Path, "$1")]
+	}
+
+	if !ok {
+		return rawURL
+	}
+
+	return "/file/" + name
+}
+
+// postName returns the unescaped name of the post used in the permalink
+func postName(item wxrItem) string {
+	name, err := url.PathUnescape(item.Name)
+
+	if err != nil {
+		return item.Name
+	}
+
+	return name
+}
+
+func publishedOn(item wxrItem) models.NullTime {
+	if t := parseTime(item.DateGMT, time.UTC); t.Valid {
+		return t
+	}
+
+	return parseTime(item.Date, time.Local)
+}
+
+// parseTime parses the time of the export; drafts have the time 0000-00-00 00:00:00
+func parseTime(value string, loc *time.Location) models.NullTime {
+	t, err := time.ParseInLocation(wxrTimeLayout, strings.TrimSpace(value), loc)
+
+	if err != nil || t.Year() < 1970 {
+		return models.NullTime{}
+	}
+
+	return models.NullTime{Time: t, Valid: true}
+}
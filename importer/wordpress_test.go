@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	logger.InitLogger(io.Discard, "Debug")
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	if err := database.InitTables(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func readWordPress(t *testing.T) (*WordPress, *models.Content) {
+	f, err := os.Open("testdata/wordpress.xml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	wp := &WordPress{Uploads: "testdata/uploads"}
+
+	c, err := wp.Read(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wp, c
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	for _, c := range []struct {
+		html     string
+		markdown string
+	}{
+		{"<p>a <strong>bold</strong> and <em>em</em> text</p><p>second</p>", "a **bold** and *em* text\n\nsecond"},
+		{"<h2>Title</h2><p>line<br>break</p>", "## Title\n\nline\nbreak"},
+		{`<p><a href="https://example.com">link</a> <img src="/a.png" alt="alt"></p>`, "[link](https://example.com) ![alt](/a.png)"},
+		{"<ol><li>one</li><li>two<ul><li>nested</li></ul></li></ol>", "1. one\n2. two\n   - nested"},
+		{"<blockquote><p>quote</p><p>more</p></blockquote>", "> quote\n>\n> more"},
+		{`<pre><code class="language-go">x := *y</code></pre>`, "```go\nx := *y\n```"},
+		{"<p>1 * 2 < 3 [x]</p>", `1 \* 2 &lt; 3 \[x\]`},
+		{"<table><tr><td>a</td></tr></table>", "<table><tbody><tr><td>a</td></tr></tbody></table>"},
+		{"<script>alert(1)</script><p>text</p>", "text"},
+	} {
+		md, err := HTMLToMarkdown(c.html, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if md != c.markdown {
+			t.Errorf("expected the markdown %q of %q, but got %q", c.markdown, c.html, md)
+		}
+	}
+}
+
+func TestWordPressRead(t *testing.T) {
+	wp, c := readWordPress(t)
+
+	if len(c.Users) != 1 || c.Users[0].Username != "dave" || c.Users[0].DisplayName != "Dave" {
+		t.Errorf("unexpected users %v", c.Users)
+	}
+
+	if len(c.Categories) != 1 || c.Categories[0].Name != "Travel" {
+		t.Errorf("unexpected categories %v", c.Categories)
+	}
+
+	if len(c.Files) != 1 || c.Files[0].UniqueName != "photo.png" || !c.Files[0].Inline || len(c.Files[0].Data) == 0 {
+		t.Fatalf("unexpected files %v", c.Files)
+	}
+
+	if len(c.Articles) != 2 {
+		t.Fatalf("expected two articles, but got %d", len(c.Articles))
+	}
+
+	a := c.Articles[0]
+
+	if a.Headline != "Hello & welcome" || a.Slug != "2019/5/hello-welcome" || a.Category != "Travel" || !a.Published {
+		t.Errorf("unexpected article %v", a)
+	}
+
+	if !a.PublishedOn.Valid || a.PublishedOn.Time.Hour() != 8 {
+		t.Errorf("expected the article to be published on the GMT date, but got %v", a.PublishedOn)
+	}
+
+	if a.Teaser != "The **first** post." {
+		t.Errorf("expected the text before the more tag as teaser, but got %q", a.Teaser)
+	}
+
+	for _, s := range []string{"## Pictures", "![a photo](/file/photo.png) A photo", "- one\n- two", "```go\nfmt.Println(\"*\")\n```"} {
+		if !strings.Contains(a.Content, s) {
+			t.Errorf("expected the content to contain %q, but got %q", s, a.Content)
+		}
+	}
+
+	draft := c.Articles[1]
+
+	if draft.Published || draft.Slug != "" || draft.Teaser != "Line one\nline two" || draft.Content != "Second paragraph" {
+		t.Errorf("unexpected draft %v", draft)
+	}
+
+	if len(c.Sites) != 1 || c.Sites[0].Link != "aboutme" || c.Sites[0].Content != "About [me](https://example.com)." {
+		t.Errorf("unexpected sites %v", c.Sites)
+	}
+
+	if len(wp.Warnings) != 1 || !strings.Contains(wp.Warnings[0], "nav_menu_item") {
+		t.Errorf("expected a warning about the menu items, but got %v", wp.Warnings)
+	}
+}
+
+func TestWordPressImport(t *testing.T) {
+	_, c := readWordPress(t)
+
+	db := setupDB(t)
+	defer db.Close()
+
+	ds := models.NewSQLiteDatasources(db)
+
+	ts := &models.TransferService{
+		UserService:    &models.UserService{Datasource: ds.Users},
+		ArticleService: &models.ArticleService{Datasource: ds.Articles},
+		SiteService:    &models.SiteService{Datasource: ds.Sites},
+		FileService: &models.FileService{
+			Datasource: ds.Files,
+			Config: settings.File{
+				Location:              t.TempDir(),
+				AllowedFileExtensions: settings.AllowedFileExts{".png": ".png"},
+			},
+		},
+		CategoryService: &models.CategoryService{Datasource: ds.Categories},
+		RedirectService: &models.RedirectService{Datasource: ds.Redirects},
+	}
+
+	actor := &models.User{
+		Username:      "admin",
+		Email:         "admin@example.com",
+		DisplayName:   "admin",
+		PlainPassword: []byte("secret-password"),
+		Role:          models.RoleAdmin,
+		Active:        true,
+	}
+
+	id, err := ts.UserService.Create(actor, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actor.ID = id
+
+	report, err := ts.ImportContent(c, actor, models.ImportOptions{CreateUsers: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count(models.AuditArticle, models.ImportCreated) != 2 || report.Count(models.AuditSite, models.ImportCreated) != 1 ||
+		report.Count(models.AuditFile, models.ImportCreated) != 1 {
+		t.Fatalf("unexpected report %v", report.Entries)
+	}
+
+	for path, target := range map[string]string{
+		"/2019/05/hello-welcome": "/article/2019/5/hello-welcome",
+		"/about-me":              "/site/aboutme",
+	} {
+		got, err := ts.RedirectService.Target(path)
+
+		if err != nil {
+			t.Fatalf("expected a redirect of %s %v", path, err)
+		}
+
+		if got != target {
+			t.Errorf("expected the redirect of %s to %s, but got %s", path, target, got)
+		}
+	}
+}
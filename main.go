@@ -6,6 +6,7 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"git.hoogi.eu/snafu/cfg"
+	"git.hoogi.eu/snafu/go-blog/backup"
 	"git.hoogi.eu/snafu/go-blog/database"
 	"git.hoogi.eu/snafu/go-blog/logger"
 	"git.hoogi.eu/snafu/go-blog/mail"
@@ -93,11 +95,7 @@ func main() {
 	logger.Log.Infof("Go-Blog version: %s, commit: %s", BuildVersion, GitHash)
 	logger.Log.Infof("running in %s mode", config.Environment)
 
-	dbConf := database.SQLiteConfig{
-		File: config.Database.File,
-	}
-
-	db, err := dbConf.Open()
+	driver, db, err := openDatabase(config.Database)
 
 	if err != nil {
 		logger.Log.Error(err)
@@ -112,7 +110,7 @@ func main() {
 		}
 	}()
 
-	ctx, err := context(db, config)
+	migrator, err := database.NewMigrator(driver, db)
 
 	if err != nil {
 		logger.Log.Error(err)
@@ -120,6 +118,45 @@ func main() {
 		return
 	}
 
+	// refuses to run on a schema migrated by a newer version or with pending migrations; the migrations are
+	// applied with the CLT migrate
+	if err := migrator.Check(); err != nil {
+		logger.Log.Error(err)
+
+		if errors.Is(err, database.ErrPendingMigrations) {
+			logger.Log.Error("apply the pending migrations with 'migrate up' before starting go-blog")
+		}
+
+		exitCode = 1
+		return
+	}
+
+	ctx, err := context(driver, db, config)
+
+	if err != nil {
+		logger.Log.Error(err)
+		exitCode = 1
+		return
+	}
+
+	if config.Backup.Interval > 0 {
+		// the online backup copies the SQLite database; a PostgreSQL database is backed up with its own tools
+		if driver != database.SQLite {
+			logger.Log.Errorf("the scheduled backup (backup_interval) is only supported for the sqlite database, but the driver is %s", driver)
+			exitCode = 1
+			return
+		}
+
+		backupService := &backup.Service{
+			DB:           db,
+			FileLocation: config.File.Location,
+			Directory:    config.Backup.Directory,
+			Keep:         config.Backup.Keep,
+		}
+
+		backupService.InitSchedule(time.NewTicker(config.Backup.Interval))
+	}
+
 	r := routers.InitRoutes(ctx, config)
 
 	s := &http.Server{
@@ -145,54 +182,128 @@ func main() {
 	}
 }
 
-func context(db *sql.DB, cfg *settings.Settings) (*m.AppContext, error) {
+// openDatabase opens the configured database and returns the name of its driver
+func openDatabase(cfg settings.Database) (string, *sql.DB, error) {
+	if cfg.Driver == "postgres" {
+		return database.Open("", cfg.PostgresDSN)
+	}
+
+	return database.Open(cfg.File, "")
+}
+
+func context(driver string, db *sql.DB, cfg *settings.Settings) (*m.AppContext, error) {
 	ic := loadUserInterceptor(cfg.User.InterceptorPlugin)
 
+	ds, err := models.NewDatasources(driver, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sessionProvider session.SessionProvider = session.NewInMemoryProvider()
+
+	if cfg.Session.Provider == settings.InDatabase {
+		sessionProvider = ds.NewSessionProvider()
+	}
+
+	auditService := &models.AuditService{
+		Datasource: ds.Audit,
+	}
+
+	loginThrottleService := &models.LoginThrottleService{
+		Datasource: ds.LoginThrottles,
+		Config:     cfg.User,
+	}
+
+	userSessionService := &models.UserSessionService{
+		SessionProvider: sessionProvider,
+	}
+
 	userService := &models.UserService{
-		Datasource: &models.SQLiteUserDatasource{
-			SQLConn: db,
-		},
-		Config:          cfg.User,
-		UserInterceptor: ic,
+		Datasource:         ds.Users,
+		Config:             cfg.User,
+		UserInterceptor:    ic,
+		UserSessionService: userSessionService,
+		AuditService:       auditService,
+	}
+
+	if cfg.LDAP.Enabled {
+		userService.Authenticator = &models.LDAPAuthenticator{
+			UserService: userService,
+			Config:      cfg.LDAP,
+		}
 	}
 
 	userInviteService := &models.UserInviteService{
-		Datasource: &models.SQLiteUserInviteDatasource{
-			SQLConn: db,
-		},
-		UserService: userService,
+		Datasource:   ds.UserInvites,
+		UserService:  userService,
+		AuditService: auditService,
+		Config:       cfg.User,
 	}
 
 	articleService := &models.ArticleService{
-		AppConfig: cfg.Application,
-		Datasource: &models.SQLiteArticleDatasource{
-			SQLConn: db,
-		},
+		AppConfig:    cfg.Application,
+		Datasource:   ds.Articles,
+		AuditService: auditService,
 	}
 
 	siteService := &models.SiteService{
-		Datasource: &models.SQLiteSiteDatasource{
-			SQLConn: db,
-		},
+		Datasource:   ds.Sites,
+		AuditService: auditService,
 	}
 
 	fileService := &models.FileService{
-		Config: cfg.File,
-		Datasource: &models.SQLiteFileDatasource{
-			SQLConn: db,
-		},
+		Config:       cfg.File,
+		Datasource:   ds.Files,
+		AuditService: auditService,
 	}
 
 	categoryService := &models.CategoryService{
-		Datasource: &models.SQLiteCategoryDatasource{
-			SQLConn: db,
-		},
+		Datasource:   ds.Categories,
+		AuditService: auditService,
 	}
 
 	tokenService := &models.TokenService{
-		Datasource: &models.SQLiteTokenDatasource{
-			SQLConn: db,
-		},
+		Datasource: ds.Tokens,
+	}
+
+	twoFactorService := &models.TwoFactorService{
+		Datasource:  ds.RecoveryCodes,
+		UserService: userService,
+		AppConfig:   cfg.Application,
+		Config:      cfg.User,
+	}
+
+	webAuthnService := &models.WebAuthnService{
+		Datasource:  ds.Credentials,
+		UserService: userService,
+		AppConfig:   cfg.Application,
+	}
+
+	userDeviceService := &models.UserDeviceService{
+		Datasource: ds.UserDevices,
+	}
+
+	accessTokenService := &models.AccessTokenService{
+		Datasource:  ds.AccessTokens,
+		UserService: userService,
+	}
+
+	oidcService := &models.OIDCService{
+		UserService: userService,
+		AppConfig:   cfg.Application,
+		Config:      cfg.OIDC,
+	}
+
+	personalDataService := &models.PersonalDataService{
+		UserService:    userService,
+		ArticleService: articleService,
+		SiteService:    siteService,
+		FileService:    fileService,
+	}
+
+	redirectService := &models.RedirectService{
+		Datasource: ds.Redirects,
 	}
 
 	smtpConfig := mail.SMTPConfig{
@@ -220,30 +331,43 @@ func context(db *sql.DB, cfg *settings.Settings) (*m.AppContext, error) {
 		return nil, err
 	}
 
-	sessionService := session.Service{
+	sessionService := models.SessionService{Service: session.Service{
 		Path:            cfg.Session.CookiePath,
 		Name:            cfg.Session.CookieName,
 		Secure:          cfg.Session.CookieSecure,
 		HTTPOnly:        true,
-		SessionProvider: session.NewInMemoryProvider(),
+		SessionProvider: sessionProvider,
 		IdleSessionTTL:  cfg.Session.TTL.Nanoseconds() / 1e9,
-	}
+	}}
 
 	ticker := time.NewTicker(cfg.Session.GarbageCollection)
 	sessionService.InitGC(ticker, cfg.Session.TTL)
 
+	userInviteService.InitMaintenance(time.NewTicker(time.Hour), mailer)
+	userService.InitMaintenance(time.NewTicker(time.Hour), mailer)
+
 	return &m.AppContext{
-		Templates:         tpl,
-		UserService:       userService,
-		UserInviteService: userInviteService,
-		ArticleService:    articleService,
-		CategoryService:   categoryService,
-		SiteService:       siteService,
-		FileService:       fileService,
-		TokenService:      tokenService,
-		Mailer:            mailer,
-		SessionService:    &sessionService,
-		ConfigService:     cfg,
+		Templates:            tpl,
+		UserService:          userService,
+		UserInviteService:    userInviteService,
+		UserSessionService:   userSessionService,
+		UserDeviceService:    userDeviceService,
+		ArticleService:       articleService,
+		CategoryService:      categoryService,
+		SiteService:          siteService,
+		FileService:          fileService,
+		TokenService:         tokenService,
+		TwoFactorService:     twoFactorService,
+		WebAuthnService:      webAuthnService,
+		AccessTokenService:   accessTokenService,
+		OIDCService:          oidcService,
+		PersonalDataService:  personalDataService,
+		AuditService:         auditService,
+		LoginThrottleService: loginThrottleService,
+		RedirectService:      redirectService,
+		Mailer:               mailer,
+		SessionService:       &sessionService,
+		ConfigService:        cfg,
 	}, nil
 }
 
@@ -9,20 +9,29 @@ import (
 
 	"git.hoogi.eu/snafu/go-blog/models"
 	"git.hoogi.eu/snafu/go-blog/settings"
-	"git.hoogi.eu/snafu/session"
 )
 
 // AppContext contains the services, session store, templates, ...
 type AppContext struct {
-	SessionService    *session.Service
-	ArticleService    *models.ArticleService
-	CategoryService   *models.CategoryService
-	UserService       *models.UserService
-	UserInviteService *models.UserInviteService
-	SiteService       *models.SiteService
-	FileService       *models.FileService
-	TokenService      *models.TokenService
-	Mailer            *models.Mailer
-	ConfigService     *settings.Settings
-	Templates         *template.Template
+	SessionService       *models.SessionService
+	ArticleService       *models.ArticleService
+	CategoryService      *models.CategoryService
+	UserService          *models.UserService
+	UserInviteService    *models.UserInviteService
+	UserSessionService   *models.UserSessionService
+	UserDeviceService    *models.UserDeviceService
+	SiteService          *models.SiteService
+	FileService          *models.FileService
+	TokenService         *models.TokenService
+	TwoFactorService     *models.TwoFactorService
+	WebAuthnService      *models.WebAuthnService
+	AccessTokenService   *models.AccessTokenService
+	OIDCService          *models.OIDCService
+	PersonalDataService  *models.PersonalDataService
+	AuditService         *models.AuditService
+	LoginThrottleService *models.LoginThrottleService
+	RedirectService      *models.RedirectService
+	Mailer               *models.Mailer
+	ConfigService        *settings.Settings
+	Templates            *template.Template
 }
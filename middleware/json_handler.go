@@ -6,6 +6,7 @@ package middleware
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"git.hoogi.eu/snafu/go-blog/httperror"
@@ -23,7 +24,7 @@ type JSONHandler struct {
 type JHandler func(*AppContext, http.ResponseWriter, *http.Request) (*models.JSONData, error)
 
 func (fn JSONHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	logWithIP := logger.Log.WithField("ip", getIP(r))
+	logWithIP := logger.Log.WithField("ip", GetIP(r))
 	code := http.StatusOK
 
 	rw.Header().Set("Content-Type", "application/json")
@@ -31,16 +32,17 @@ func (fn JSONHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	data, err := fn.Handler(fn.AppCtx, rw, r)
 
 	if err != nil {
-		switch e := err.(type) {
-		case *httperror.Error:
-			code = e.HTTPStatus
-		default:
-			code = http.StatusInternalServerError
+		var e *httperror.Error
+
+		if !errors.As(err, &e) {
+			e = httperror.InternalServerError(err)
 		}
 
+		code = e.HTTPStatus
+
 		logWithIP.Error(err)
 
-		j, err := json.Marshal(err)
+		j, err := json.Marshal(e)
 
 		if err != nil {
 			logWithIP.Error(err)
@@ -29,7 +29,8 @@ var locals = [...]net.IPNet{
 	},
 }
 
-func getIP(r *http.Request) string {
+// GetIP returns the IP address of the client; the headers X-Real-IP and X-Forwarded-For of a proxy are considered
+func GetIP(r *http.Request) string {
 	xfo := r.Header.Get("X-Forwarded-For")
 	xre := r.Header.Get("X-Real-IP")
 
@@ -140,6 +140,18 @@ func FuncMap(ss *models.SiteService, settings *settings.Settings) template.FuncM
 		"KeepAliveInterval": func() int64 {
 			return (settings.Session.TTL.Nanoseconds() / 1e9) - 5
 		},
+		"OIDCEnabled": func() bool {
+			return settings.OIDC.Enabled
+		},
+		"OIDCProviderName": func() string {
+			return settings.OIDC.ProviderName
+		},
+		"PasswordLoginEnabled": func() bool {
+			return settings.OIDC.PasswordLoginEnabled()
+		},
+		"MagicLinkLoginEnabled": func() bool {
+			return settings.User.MagicLinkLogin
+		},
 		"PageTitle": func() string {
 			return settings.Title
 		},
@@ -176,6 +188,7 @@ func FuncMap(ss *models.SiteService, settings *settings.Settings) template.FuncM
 		"FormatDate": func(t time.Time) string {
 			return t.In(time.Local).Format("January 2, 2006")
 		},
+		"Roles": models.Roles,
 		"BoolToIcon": func(b bool) template.HTML {
 			if b {
 				return template.HTML(`<img alt="circle-checked" src="../assets/svg/circle-check.svg">`)
@@ -7,8 +7,10 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/gorilla/csrf"
 
@@ -20,7 +22,8 @@ import (
 type contextKey string
 
 var (
-	UserContextKey = contextKey("user")
+	UserContextKey        = contextKey("user")
+	AccessTokenContextKey = contextKey("access_token")
 )
 
 // TemplateHandler enriches handlers with a application context containing 'services'
@@ -44,7 +47,7 @@ func (fn TemplateHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	warnMsg = t.WarnMsg
 	code := http.StatusOK
 
-	logWithIP := logger.Log.WithField("ip", getIP(r))
+	logWithIP := logger.Log.WithField("ip", GetIP(r))
 
 	t.Data["CSRFToken"] = csrf.Token(r)
 
@@ -116,10 +119,129 @@ func (fn TemplateHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// TokenAuthHandler authenticates requests to JSON routes sending a personal access token as 'Authorization: Bearer' header;
+// as no cookies are involved the CSRF check is skipped for these requests. Requests without a bearer token are passed unchanged.
+// This handler must be placed before the CSRF handler in the chain.
+func (ctx AppContext) TokenAuthHandler(handler http.Handler) http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+
+		if !ok {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+
+		at, u, err := ctx.authenticateToken(r, token)
+
+		if err != nil {
+			ctx.writeJSONError(rw, r, err)
+			return
+		}
+
+		handler.ServeHTTP(rw, withAccessToken(csrf.UnsafeSkipCheck(r), at, u))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIAuthHandler authenticates requests to the REST API; only personal access tokens sent as 'Authorization: Bearer' header are accepted
+func (ctx AppContext) APIAuthHandler(handler http.Handler) http.Handler {
+	fn := func(rw http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+
+		if !ok {
+			ctx.writeJSONError(rw, r, httperror.New(http.StatusUnauthorized,
+				"Please provide an access token.",
+				fmt.Errorf("the API was requested without an access token %s", r.URL.EscapedPath())))
+			return
+		}
+
+		at, u, err := ctx.authenticateToken(r, token)
+
+		if err != nil {
+			ctx.writeJSONError(rw, r, err)
+			return
+		}
+
+		handler.ServeHTTP(rw, withAccessToken(r, at, u))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func withAccessToken(r *http.Request, at *models.AccessToken, u *models.User) *http.Request {
+	u.RemoteAddr = GetIP(r)
+
+	c := context.WithValue(r.Context(), AccessTokenContextKey, at)
+	return r.WithContext(context.WithValue(c, UserContextKey, u))
+}
+
+func (ctx AppContext) writeJSONError(rw http.ResponseWriter, r *http.Request, err error) {
+	JSONHandler{AppCtx: &ctx, Handler: func(*AppContext, http.ResponseWriter, *http.Request) (*models.JSONData, error) {
+		return nil, err
+	}}.ServeHTTP(rw, r)
+}
+
+func (ctx AppContext) authenticateToken(r *http.Request, token string) (*models.AccessToken, *models.User, error) {
+	if !tokenRouteAllowed(r) {
+		return nil, nil, httperror.New(http.StatusUnauthorized,
+			"Access tokens can not be used for this request.",
+			fmt.Errorf("an access token was sent to the route %s which does not allow access tokens", r.URL.EscapedPath()))
+	}
+
+	at, u, err := ctx.AccessTokenService.Authenticate(token)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !at.AllowsMethod(r.Method) {
+		return nil, nil, httperror.New(http.StatusForbidden,
+			"The scope of the access token does not allow this request.",
+			fmt.Errorf("the access token %d has no scope for the method %s", at.ID, r.Method))
+	}
+
+	// the redirect to the two-factor setup is not possible without a session, so the tokens are refused until the
+	// user has enabled the two-factor authentication
+	if ctx.TwoFactorService != nil && ctx.TwoFactorService.Required(u) && !u.TOTPEnabled {
+		return nil, nil, httperror.New(http.StatusForbidden,
+			"Two-factor authentication is required for your account. Please set it up before using access tokens.",
+			fmt.Errorf("the user %d has to set up the two-factor authentication before using the access token %d", u.ID, at.ID))
+	}
+
+	return at, u, nil
+}
+
+// tokenRouteAllowed returns true if the path can be requested with an access token; the account security routes like
+// the passkey registration are never allowed
+func tokenRouteAllowed(r *http.Request) bool {
+	p := r.URL.EscapedPath()
+
+	return strings.HasPrefix(p, "/api/v1/") || p == "/admin/json/file/upload"
+}
+
+// bearerToken returns the token of the 'Authorization: Bearer' header
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[7:]), true
+}
+
 // AuthHandler checks if the user is authenticated; if not next handler in chain is not called
+// Requests already authenticated by a personal access token are passed without a session; the two-factor requirement
+// of these users is checked while authenticating the token
 func (ctx AppContext) AuthHandler(handler http.Handler) http.Handler {
 	fn := func(rw http.ResponseWriter, r *http.Request) {
-		logWithIP := logger.Log.WithField("ip", getIP(r))
+		logWithIP := logger.Log.WithField("ip", GetIP(r))
+
+		if _, ok := r.Context().Value(AccessTokenContextKey).(*models.AccessToken); ok {
+			handler.ServeHTTP(rw, r)
+			return
+		}
 
 		session, err := ctx.SessionService.Get(rw, r)
 
@@ -171,15 +293,40 @@ func (ctx AppContext) AuthHandler(handler http.Handler) http.Handler {
 			return
 		}
 
+		u.RemoteAddr = GetIP(r)
+
+		if ctx.UserSessionService != nil {
+			ctx.UserSessionService.Track(session, u.RemoteAddr, r.UserAgent())
+		}
+
+		if ctx.TwoFactorService != nil && ctx.TwoFactorService.Required(u) && !u.TOTPEnabled && !twoFactorSetupAllowed(r) {
+			setCookie(rw, "WarnMsg", "/", "Two-factor authentication is required for your account. Please set it up to continue.")
+			http.Redirect(rw, r, "/admin/user/two-factor", http.StatusFound)
+			return
+		}
+
 		handler.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), UserContextKey, u)))
 	}
 	return http.HandlerFunc(fn)
 }
 
-// RequireAdmin ensures that the user is an admin, if not next handler in chain is not called.
-func (ctx AppContext) RequireAdmin(handler http.Handler) http.Handler {
+// twoFactorSetupAllowed returns true if the path can be requested by an user who still has to set up the two-factor authentication
+func twoFactorSetupAllowed(r *http.Request) bool {
+	p := r.URL.EscapedPath()
+
+	return strings.HasPrefix(p, "/admin/user/two-factor") || p == "/admin/logout" || p == "/admin/json/session/keep-alive"
+}
+
+// RequirePermission ensures that the user is granted the permission, if not next handler in chain is not called.
+func (ctx AppContext) RequirePermission(p models.Permission) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return ctx.requirePermission(p, handler)
+	}
+}
+
+func (ctx AppContext) requirePermission(p models.Permission, handler http.Handler) http.Handler {
 	fn := func(rw http.ResponseWriter, r *http.Request) {
-		logWithIP := logger.Log.WithField("ip", getIP(r))
+		logWithIP := logger.Log.WithField("ip", GetIP(r))
 
 		u, err := User(r)
 
@@ -194,7 +341,7 @@ func (ctx AppContext) RequireAdmin(handler http.Handler) http.Handler {
 			return
 		}
 
-		if u.IsAdmin == false {
+		if !u.Can(p) {
 			if err := ctx.Templates.ExecuteTemplate(rw, "admin/error", map[string]interface{}{
 				"ErrorMsg":    "You have not the permissions to execute this action",
 				"currentUser": u,
@@ -19,7 +19,7 @@ type XMLHandler struct {
 type XHandler func(*AppContext, http.ResponseWriter, *http.Request) (*models.XMLData, error)
 
 func (fn XMLHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	logWithIP := logger.Log.WithField("ip", getIP(r))
+	logWithIP := logger.Log.WithField("ip", GetIP(r))
 
 	rw.Header().Set("Content-Type", "application/xml")
 
@@ -0,0 +1,226 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// AccessTokenDatasourceService defines an interface for CRUD operations of personal access tokens
+type AccessTokenDatasourceService interface {
+	Create(at *AccessToken) (int, error)
+	List(userID int) ([]AccessToken, error)
+	Get(tokenID, userID int) (*AccessToken, error)
+	GetByHash(hash string) (*AccessToken, error)
+	UpdateLastUsed(tokenID int) error
+	Remove(tokenID int) error
+	RemoveByUser(userID int) error
+}
+
+// AccessTokenScope limits what a personal access token can be used for
+type AccessTokenScope string
+
+const (
+	// ScopeRead allows reading requests (GET, HEAD)
+	ScopeRead AccessTokenScope = "read"
+	// ScopeWrite allows all requests
+	ScopeWrite AccessTokenScope = "write"
+)
+
+const (
+	accessTokenPrefix          = "gbt_"
+	maxAccessTokenNameLength   = 64
+	maxAccessTokenLifetimeDays = 365
+)
+
+// AccessToken represents a personal access token of an user; only the hash of the token is saved
+type AccessToken struct {
+	ID         int
+	Name       string
+	Hash       string
+	Scopes     []AccessTokenScope
+	ExpiresAt  time.Time
+	LastUsedAt NullTime
+	CreatedAt  time.Time
+
+	Author *User
+}
+
+// AccessTokenService containing the service to create and verify personal access tokens
+type AccessTokenService struct {
+	Datasource  AccessTokenDatasourceService
+	UserService *UserService
+}
+
+// HasScope returns true if the token was granted the scope
+func (at AccessToken) HasScope(scope AccessTokenScope) bool {
+	for _, s := range at.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired returns true if the token is expired
+func (at AccessToken) Expired() bool {
+	return time.Now().After(at.ExpiresAt)
+}
+
+// AllowsMethod returns true if a request with the HTTP method can be made with the token
+func (at AccessToken) AllowsMethod(method string) bool {
+	if at.HasScope(ScopeWrite) {
+		return true
+	}
+
+	return at.HasScope(ScopeRead) && (method == http.MethodGet || method == http.MethodHead)
+}
+
+func (at *AccessToken) validate() error {
+	at.Name = strings.TrimSpace(at.Name)
+
+	if len(at.Name) == 0 {
+		return httperror.ValueRequired("name")
+	}
+
+	if len([]rune(at.Name)) > maxAccessTokenNameLength {
+		return httperror.ValueTooLong("name", maxAccessTokenNameLength)
+	}
+
+	if len(at.Scopes) == 0 {
+		return httperror.ValueRequired("scope")
+	}
+
+	for _, s := range at.Scopes {
+		if s != ScopeRead && s != ScopeWrite {
+			return httperror.New(http.StatusUnprocessableEntity, "Please select a valid scope.", fmt.Errorf("the scope '%s' is not valid", s))
+		}
+	}
+
+	if !at.ExpiresAt.After(time.Now()) || at.ExpiresAt.After(time.Now().AddDate(0, 0, maxAccessTokenLifetimeDays+1)) {
+		return httperror.New(http.StatusUnprocessableEntity,
+			fmt.Sprintf("The token must expire within %d days.", maxAccessTokenLifetimeDays),
+			fmt.Errorf("the expiration date %v is not valid", at.ExpiresAt))
+	}
+
+	return nil
+}
+
+// List returns all personal access tokens of the user
+func (ats *AccessTokenService) List(u *User) ([]AccessToken, error) {
+	return ats.Datasource.List(u.ID)
+}
+
+// Create creates a new personal access token for the user; the returned token is shown only once
+func (ats *AccessTokenService) Create(at *AccessToken, u *User) (string, error) {
+	if err := at.validate(); err != nil {
+		return "", err
+	}
+
+	token := accessTokenPrefix + hex.EncodeToString(crypt.RandomSecureKey(32))
+
+	at.Hash = crypt.Hash([]byte(token))
+	at.Author = u
+
+	id, err := ats.Datasource.Create(at)
+
+	if err != nil {
+		return "", err
+	}
+
+	at.ID = id
+
+	return token, nil
+}
+
+// Remove removes a personal access token of the user
+func (ats *AccessTokenService) Remove(u *User, tokenID int) error {
+	at, err := ats.Datasource.Get(tokenID, u.ID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("access token", err)
+		}
+		return err
+	}
+
+	return ats.Datasource.Remove(at.ID)
+}
+
+// RemoveAll removes all personal access tokens of the user
+func (ats *AccessTokenService) RemoveAll(u *User) error {
+	return ats.Datasource.RemoveByUser(u.ID)
+}
+
+// Authenticate returns the token and its active owner; an error is returned if the token is unknown or expired
+func (ats *AccessTokenService) Authenticate(token string) (*AccessToken, *User, error) {
+	if !strings.HasPrefix(token, accessTokenPrefix) {
+		return nil, nil, httperror.New(http.StatusUnauthorized, "The access token is invalid.", errors.New("the access token has no valid prefix"))
+	}
+
+	at, err := ats.Datasource.GetByHash(crypt.Hash([]byte(token)))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, httperror.New(http.StatusUnauthorized, "The access token is invalid.", errors.New("the access token was not found"))
+		}
+		return nil, nil, err
+	}
+
+	if at.Expired() {
+		return nil, nil, httperror.New(http.StatusUnauthorized, "The access token is expired.", fmt.Errorf("the access token %d is expired", at.ID))
+	}
+
+	u, err := ats.UserService.GetByID(at.Author.ID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !u.Active {
+		return nil, nil, httperror.New(http.StatusUnauthorized,
+			"Your account is deactivated.",
+			fmt.Errorf("the user with id %d used an access token but the account is deactivated", u.ID))
+	}
+
+	if err := ats.Datasource.UpdateLastUsed(at.ID); err != nil {
+		logger.Log.Errorf("could not update the last usage of access token %d %v", at.ID, err)
+	}
+
+	return at, u, nil
+}
+
+func joinScopes(scopes []AccessTokenScope) string {
+	s := make([]string, 0, len(scopes))
+
+	for _, scope := range scopes {
+		s = append(s, string(scope))
+	}
+
+	return strings.Join(s, ",")
+}
+
+func splitScopes(s string) []AccessTokenScope {
+	var scopes []AccessTokenScope
+
+	for _, scope := range strings.Split(s, ",") {
+		if len(scope) > 0 {
+			scopes = append(scopes, AccessTokenScope(scope))
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PostgresAccessTokenDatasource providing an implementation of AccessTokenDatasourceService for PostgreSQL
+type PostgresAccessTokenDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new personal access token
+func (rdb *PostgresAccessTokenDatasource) Create(at *AccessToken) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO access_token (name, hash, scopes, expires_at, created_at, user_id) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+		at.Name, at.Hash, joinScopes(at.Scopes), at.ExpiresAt, time.Now(), at.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// List returns all personal access tokens of the user
+func (rdb *PostgresAccessTokenDatasource) List(userID int) ([]AccessToken, error) {
+	rows, err := rdb.SQLConn.Query("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.user_id=$1 ORDER BY at.created_at ASC ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var tokens []AccessToken
+
+	for rows.Next() {
+		var u User
+		var at AccessToken
+		var scopes string
+
+		if err = rows.Scan(&at.ID, &at.Name, &at.Hash, &scopes, &at.ExpiresAt, &at.LastUsedAt, &at.CreatedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		at.Scopes = splitScopes(scopes)
+		at.Author = &u
+
+		tokens = append(tokens, at)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Get returns the personal access token of the user
+func (rdb *PostgresAccessTokenDatasource) Get(tokenID, userID int) (*AccessToken, error) {
+	return rdb.scanToken(rdb.SQLConn.QueryRow("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.id=$1 AND at.user_id=$2 ", tokenID, userID))
+}
+
+// GetByHash returns the personal access token by the hash
+func (rdb *PostgresAccessTokenDatasource) GetByHash(hash string) (*AccessToken, error) {
+	return rdb.scanToken(rdb.SQLConn.QueryRow("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.hash=$1 ", hash))
+}
+
+// UpdateLastUsed sets the time of the last usage to now
+func (rdb *PostgresAccessTokenDatasource) UpdateLastUsed(tokenID int) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE access_token SET last_used_at=$1 WHERE id=$2 ", time.Now(), tokenID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes the personal access token
+func (rdb *PostgresAccessTokenDatasource) Remove(tokenID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM access_token WHERE id=$1 ", tokenID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveByUser removes all personal access tokens of the user
+func (rdb *PostgresAccessTokenDatasource) RemoveByUser(userID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM access_token WHERE user_id=$1 ", userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (rdb *PostgresAccessTokenDatasource) scanToken(row *sql.Row) (*AccessToken, error) {
+	var u User
+	var at AccessToken
+	var scopes string
+
+	if err := row.Scan(&at.ID, &at.Name, &at.Hash, &scopes, &at.ExpiresAt, &at.LastUsedAt, &at.CreatedAt, &u.ID); err != nil {
+		return nil, err
+	}
+
+	at.Scopes = splitScopes(scopes)
+	at.Author = &u
+
+	return &at, nil
+}
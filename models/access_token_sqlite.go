@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// SQLiteAccessTokenDatasource providing an implementation of AccessTokenDatasourceService for SQLite
+type SQLiteAccessTokenDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new personal access token
+func (rdb *SQLiteAccessTokenDatasource) Create(at *AccessToken) (int, error) {
+	res, err := rdb.SQLConn.Exec("INSERT INTO access_token (name, hash, scopes, expires_at, created_at, user_id) VALUES(?, ?, ?, ?, ?, ?)",
+		at.Name, at.Hash, joinScopes(at.Scopes), at.ExpiresAt, time.Now(), at.Author.ID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// List returns all personal access tokens of the user
+func (rdb *SQLiteAccessTokenDatasource) List(userID int) ([]AccessToken, error) {
+	rows, err := rdb.SQLConn.Query("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.user_id=? ORDER BY at.created_at ASC ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var tokens []AccessToken
+
+	for rows.Next() {
+		var u User
+		var at AccessToken
+		var scopes string
+
+		if err = rows.Scan(&at.ID, &at.Name, &at.Hash, &scopes, &at.ExpiresAt, &at.LastUsedAt, &at.CreatedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		at.Scopes = splitScopes(scopes)
+		at.Author = &u
+
+		tokens = append(tokens, at)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Get returns the personal access token of the user
+func (rdb *SQLiteAccessTokenDatasource) Get(tokenID, userID int) (*AccessToken, error) {
+	return rdb.scanToken(rdb.SQLConn.QueryRow("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.id=? AND at.user_id=? ", tokenID, userID))
+}
+
+// GetByHash returns the personal access token by the hash
+func (rdb *SQLiteAccessTokenDatasource) GetByHash(hash string) (*AccessToken, error) {
+	return rdb.scanToken(rdb.SQLConn.QueryRow("SELECT at.id, at.name, at.hash, at.scopes, at.expires_at, at.last_used_at, at.created_at, at.user_id "+
+		"FROM access_token as at WHERE at.hash=? ", hash))
+}
+
+// UpdateLastUsed sets the time of the last usage to now
+func (rdb *SQLiteAccessTokenDatasource) UpdateLastUsed(tokenID int) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE access_token SET last_used_at=? WHERE id=? ", time.Now(), tokenID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes the personal access token
+func (rdb *SQLiteAccessTokenDatasource) Remove(tokenID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM access_token WHERE id=? ", tokenID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveByUser removes all personal access tokens of the user
+func (rdb *SQLiteAccessTokenDatasource) RemoveByUser(userID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM access_token WHERE user_id=? ", userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (rdb *SQLiteAccessTokenDatasource) scanToken(row *sql.Row) (*AccessToken, error) {
+	var u User
+	var at AccessToken
+	var scopes string
+
+	if err := row.Scan(&at.ID, &at.Name, &at.Hash, &scopes, &at.ExpiresAt, &at.LastUsedAt, &at.CreatedAt, &u.ID); err != nil {
+		return nil, err
+	}
+
+	at.Scopes = splitScopes(scopes)
+	at.Author = &u
+
+	return &at, nil
+}
@@ -6,6 +6,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -21,25 +22,50 @@ import (
 
 // Article represents an article
 type Article struct {
-	ID           int
-	Headline     string
-	PublishedOn  NullTime
-	Published    bool
-	Teaser       string
-	Content      string
-	Slug         string
-	LastModified time.Time
-	Author       *User
+	ID           int       `json:"id"`
+	Headline     string    `json:"headline"`
+	PublishedOn  NullTime  `json:"published_on"`
+	Published    bool      `json:"published"`
+	Teaser       string    `json:"teaser"`
+	Content      string    `json:"content"`
+	Slug         string    `json:"slug"`
+	LastModified time.Time `json:"last_modified"`
+	Author       *User     `json:"author"`
 
 	//duplicate category struct to support left joins with nulls
 	//TODO: find a better solution
-	CID   sql.NullInt64
-	CName sql.NullString
+	CID   sql.NullInt64  `json:"-"`
+	CName sql.NullString `json:"-"`
+}
+
+// MarshalJSON adds the category of the article, the category is null if the article has no category
+func (a Article) MarshalJSON() ([]byte, error) {
+	type article Article
+
+	type category struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var c *category
+
+	if a.CID.Valid && a.CID.Int64 > 0 {
+		c = &category{ID: int(a.CID.Int64), Name: a.CName.String}
+	}
+
+	return json.Marshal(struct {
+		article
+		Category *category `json:"category"`
+	}{
+		article:  article(a),
+		Category: c,
+	})
 }
 
 // ArticleDatasourceService defines an interface for CRUD operations of articles
 type ArticleDatasourceService interface {
 	Create(a *Article) (int, error)
+	Import(a *Article) (int, error)
 	List(u *User, c *Category, p *Pagination, pc PublishedCriteria) ([]Article, error)
 	Count(u *User, c *Category, pc PublishedCriteria) (int, error)
 	Get(articleID int, u *User, pc PublishedCriteria) (*Article, error)
@@ -83,6 +109,27 @@ func (a *Article) slug(as *ArticleService, now time.Time) error {
 	return nil
 }
 
+// importSlug keeps the slug of an imported article if it is valid and not taken;
+// otherwise a new slug is built from the date the article was published on
+func (a *Article) importSlug(as *ArticleService) error {
+	if strings.Count(a.Slug, "/") == 2 {
+		if _, err := as.Datasource.GetBySlug(a.Slug, nil, All); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	t := time.Now()
+
+	if a.PublishedOn.Valid {
+		t = a.PublishedOn.Time
+	}
+
+	return a.slug(as, t)
+}
+
 // validate validates if mandatory article fields are set
 func (a *Article) validate() error {
 	a.Headline = strings.TrimSpace(a.Headline)
@@ -108,8 +155,9 @@ func (a *Article) validate() error {
 
 // ArticleService containing the service to access articles
 type ArticleService struct {
-	Datasource ArticleDatasourceService
-	AppConfig  settings.Application
+	Datasource   ArticleDatasourceService
+	AppConfig    settings.Application
+	AuditService *AuditService
 }
 
 // Create creates an article
@@ -126,7 +174,50 @@ func (as *ArticleService) Create(a *Article) (int, error) {
 		return -1, err
 	}
 
-	return as.Datasource.Create(a)
+	id, err := as.Datasource.Create(a)
+
+	if err != nil {
+		return -1, err
+	}
+
+	as.AuditService.Record(a.Author, AuditCreate, AuditArticle, id, "", a.auditSummary())
+
+	return id, nil
+}
+
+// Import creates an imported article; unlike Create the publish state and the dates of the article are kept.
+// The actor is the user who imports the article
+func (as *ArticleService) Import(a *Article, actor *User) (int, error) {
+	if err := as.prepareImport(a); err != nil {
+		return -1, err
+	}
+
+	id, err := as.Datasource.Import(a)
+
+	if err != nil {
+		return -1, err
+	}
+
+	as.AuditService.Record(actor, AuditCreate, AuditArticle, id, "", a.auditSummary())
+
+	return id, nil
+}
+
+// prepareImport validates the imported article and resolves its slug without saving the article
+func (as *ArticleService) prepareImport(a *Article) error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	if err := a.importSlug(as); err != nil {
+		return err
+	}
+
+	if a.LastModified.IsZero() {
+		a.LastModified = time.Now()
+	}
+
+	return nil
 }
 
 // Update updates an article
@@ -151,13 +242,19 @@ func (as *ArticleService) Update(a *Article, u *User, updateSlug bool) error {
 		}
 	}
 
-	if !u.IsAdmin {
+	if !u.Can(PermArticleEditAny) {
 		if oldArt.Author.ID != u.ID {
 			return httperror.PermissionDenied("update", "article", fmt.Errorf("could not update article %d user %d has no permission", a.ID, u.ID))
 		}
 	}
 
-	return as.Datasource.Update(a)
+	if err := as.Datasource.Update(a); err != nil {
+		return err
+	}
+
+	as.AuditService.Record(u, AuditUpdate, AuditArticle, a.ID, oldArt.auditSummary(), a.auditSummary())
+
+	return nil
 }
 
 // Publish publishes or 'unpublishes' an article
@@ -168,13 +265,26 @@ func (as *ArticleService) Publish(id int, u *User) error {
 		return err
 	}
 
-	if !u.IsAdmin {
+	if !u.Can(PermArticlePublish) {
+		return httperror.PermissionDenied("publish", "article", fmt.Errorf("could not publish article %d user %d has no permission", a.ID, u.ID))
+	}
+
+	if !u.Can(PermArticleEditAny) {
 		if a.Author.ID != u.ID {
 			return httperror.PermissionDenied("publish", "article", fmt.Errorf("could not publish article %d user %d has no permission", a.ID, u.ID))
 		}
 	}
 
-	return as.Datasource.Publish(a)
+	if err := as.Datasource.Publish(a); err != nil {
+		return err
+	}
+
+	published := *a
+	published.Published = !a.Published
+
+	as.AuditService.Record(u, AuditPublish, AuditArticle, a.ID, a.auditSummary(), published.auditSummary())
+
+	return nil
 }
 
 // Delete deletes an article
@@ -185,13 +295,19 @@ func (as *ArticleService) Delete(id int, u *User) error {
 		return err
 	}
 
-	if !u.IsAdmin {
+	if !u.Can(PermArticleEditAny) {
 		if a.Author.ID != u.ID {
 			return httperror.PermissionDenied("delete", "article", fmt.Errorf("could not delete article %d user %d has no permission", a.ID, u.ID))
 		}
 	}
 
-	return as.Datasource.Delete(a.ID)
+	if err := as.Datasource.Delete(a.ID); err != nil {
+		return err
+	}
+
+	as.AuditService.Record(u, AuditDelete, AuditArticle, a.ID, a.auditSummary(), "")
+
+	return nil
 }
 
 // GetBySlug gets an article by the slug.
@@ -207,7 +323,7 @@ func (as *ArticleService) GetBySlug(s string, u *User, pc PublishedCriteria) (*A
 	}
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermArticleEditAny) {
 			if a.Author.ID != u.ID {
 				return nil, httperror.PermissionDenied("view", "article", fmt.Errorf("could not get article %s user %d has no permission", a.Slug, u.ID))
 			}
@@ -230,7 +346,7 @@ func (as *ArticleService) GetByID(id int, u *User, pc PublishedCriteria) (*Artic
 	}
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermArticleEditAny) {
 			if a.Author.ID != u.ID {
 				return nil, httperror.PermissionDenied("get", "article", fmt.Errorf("could not get article %d user %d has no permission", a.ID, u.ID))
 			}
@@ -252,6 +368,22 @@ func (as *ArticleService) List(u *User, c *Category, p *Pagination, pc Published
 	return as.Datasource.List(u, c, p, pc)
 }
 
+// ListByAuthor returns the articles written by the author
+// The publishedCriteria defines whether the published and/or unpublished articles should be considered
+func (as *ArticleService) ListByAuthor(author *User, p *Pagination, pc PublishedCriteria) ([]Article, error) {
+	return as.Datasource.List(authorOnly(author), nil, p, pc)
+}
+
+// CountByAuthor returns the number of articles written by the author
+func (as *ArticleService) CountByAuthor(author *User, pc PublishedCriteria) (int, error) {
+	return as.Datasource.Count(authorOnly(author), nil, pc)
+}
+
+// authorOnly returns a user without permissions; the datasources restrict the articles of such an user to the own articles
+func authorOnly(author *User) *User {
+	return &User{ID: author.ID}
+}
+
 // RSSFeed receives a specified number of articles in RSS
 func (as *ArticleService) RSSFeed(p *Pagination, pc PublishedCriteria) (RSS, error) {
 	c := RSSChannel{
@@ -39,6 +39,33 @@ func (rdb *SQLiteArticleDatasource) Create(a *Article) (int, error) {
 	return int(id), nil
 }
 
+// Import creates an article keeping the publish state and the dates
+func (rdb *SQLiteArticleDatasource) Import(a *Article) (int, error) {
+	res, err := rdb.SQLConn.Exec("INSERT INTO article (headline, teaser, content, slug, published_on, published, last_modified, category_id, user_id) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		a.Headline,
+		a.Teaser,
+		a.Content,
+		a.Slug,
+		a.PublishedOn,
+		a.Published,
+		a.LastModified,
+		a.CID,
+		a.Author.ID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
 // List returns a slice of articles; if the user is not nil the number of articles for this explcit user is returned
 // the PublishedCritera specifies which articles should be considered
 func (rdb *SQLiteArticleDatasource) List(u *User, c *Category, p *Pagination, pc PublishedCriteria) ([]Article, error) {
@@ -61,7 +88,7 @@ func (rdb *SQLiteArticleDatasource) List(u *User, c *Category, p *Pagination, pc
 		var ru User
 
 		if err := rows.Scan(&a.ID, &a.Headline, &a.Teaser, &a.Content, &a.Published, &a.PublishedOn, &a.Slug, &a.LastModified, &ru.ID, &ru.DisplayName,
-			&ru.Email, &ru.Username, &ru.IsAdmin, &a.CID, &a.CName); err != nil {
+			&ru.Email, &ru.Username, &ru.Role, &a.CID, &a.CName); err != nil {
 			return nil, err
 		}
 
@@ -100,7 +127,7 @@ func (rdb *SQLiteArticleDatasource) Count(u *User, c *Category, pc PublishedCrit
 	}
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermArticleEditAny) {
 			stmt.WriteString("a.user_id=? AND ")
 			args = append(args, u.ID)
 		}
@@ -128,7 +155,7 @@ func (rdb *SQLiteArticleDatasource) Get(articleID int, u *User, pc PublishedCrit
 	var ru User
 
 	if err := selectArticleStmt(rdb.SQLConn, articleID, "", u, pc).Scan(&a.ID, &a.Headline, &a.PublishedOn, &a.Published, &a.Slug, &a.Teaser, &a.Content,
-		&a.LastModified, &ru.ID, &ru.DisplayName, &ru.Email, &ru.Username, &ru.IsAdmin, &a.CID, &a.CName); err != nil {
+		&a.LastModified, &ru.ID, &ru.DisplayName, &ru.Email, &ru.Username, &ru.Role, &a.CID, &a.CName); err != nil {
 		return nil, err
 	}
 
@@ -144,7 +171,7 @@ func (rdb *SQLiteArticleDatasource) GetBySlug(slug string, u *User, pc Published
 	var ru User
 
 	if err := selectArticleStmt(rdb.SQLConn, -1, slug, u, pc).Scan(&a.ID, &a.Headline, &a.PublishedOn, &a.Published, &a.Slug, &a.Teaser, &a.Content,
-		&a.LastModified, &ru.ID, &ru.DisplayName, &ru.Email, &ru.Username, &ru.IsAdmin, &a.CID, &a.CName); err != nil {
+		&a.LastModified, &ru.ID, &ru.DisplayName, &ru.Email, &ru.Username, &ru.Role, &a.CID, &a.CName); err != nil {
 		return nil, err
 	}
 
@@ -193,7 +220,7 @@ func selectArticleStmt(db *sql.DB, articleID int, slug string, u *User, pc Publi
 	var args []interface{}
 
 	stmt.WriteString("SELECT a.id, a.headline, a.published_on, a.published, a.slug, a.teaser, a.content, a.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.email, u.username, u.is_admin, ")
+	stmt.WriteString("u.id, u.display_name, u.email, u.username, u.role, ")
 	stmt.WriteString("c.id, c.name ")
 	stmt.WriteString("FROM article a ")
 	stmt.WriteString("INNER JOIN user u ON (a.user_id = u.id) ")
@@ -217,7 +244,7 @@ func selectArticleStmt(db *sql.DB, articleID int, slug string, u *User, pc Publi
 	}
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermArticleEditAny) {
 			stmt.WriteString("AND a.user_id=? ")
 			args = append(args, u.ID)
 		}
@@ -233,7 +260,7 @@ func selectArticlesStmt(db *sql.DB, u *User, c *Category, p *Pagination, pc Publ
 	var args []interface{}
 
 	stmt.WriteString("SELECT a.id, a.headline, a.teaser, a.content, a.published, a.published_on, a.slug, a.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.email, u.username, u.is_admin, ")
+	stmt.WriteString("u.id, u.display_name, u.email, u.username, u.role, ")
 	stmt.WriteString("c.id, c.name ")
 	stmt.WriteString("FROM article a ")
 	stmt.WriteString("INNER JOIN user u ON (a.user_id = u.id) ")
@@ -252,7 +279,7 @@ func selectArticlesStmt(db *sql.DB, u *User, c *Category, p *Pagination, pc Publ
 	}
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermArticleEditAny) {
 			stmt.WriteString("a.user_id=? AND ")
 			args = append(args, u.ID)
 		}
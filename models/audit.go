@@ -0,0 +1,203 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// AuditDatasourceService defines an interface for the append-only audit log; events can not be updated or removed
+type AuditDatasourceService interface {
+	Create(e *AuditEvent) (int, error)
+	List(f *AuditFilter, p *Pagination) ([]AuditEvent, error)
+	Count(f *AuditFilter) (int, error)
+}
+
+// Actions recorded in the audit log
+const (
+	AuditCreate       = "create"
+	AuditUpdate       = "update"
+	AuditDelete       = "delete"
+	AuditPublish      = "publish"
+	AuditOrder        = "order"
+	AuditLogin        = "login"
+	AuditLoginFailure = "login_failure"
+	AuditUnlock       = "unlock"
+)
+
+// Target types recorded in the audit log
+const (
+	AuditArticle    = "article"
+	AuditCategory   = "category"
+	AuditFile       = "file"
+	AuditSite       = "site"
+	AuditUser       = "user"
+	AuditUserInvite = "user_invite"
+)
+
+var auditActions = []string{AuditCreate, AuditUpdate, AuditDelete, AuditPublish, AuditOrder, AuditLogin, AuditLoginFailure, AuditUnlock}
+
+var auditTargetTypes = []string{AuditArticle, AuditCategory, AuditFile, AuditSite, AuditUser, AuditUserInvite}
+
+// AuditEvent represents an action of an user; the name of the actor is kept as the user could be removed later on.
+// Before and after contain a short summary of the target, they are empty for created or deleted targets respectively
+type AuditEvent struct {
+	ID         int
+	ActorID    int
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   int
+	Before     string
+	After      string
+	IP         string
+	CreatedAt  time.Time
+}
+
+// AuditFilter restricts the listed audit events; empty fields are not considered
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	From       time.Time
+	To         time.Time
+}
+
+// AuditService containing the service to record and list audit events
+type AuditService struct {
+	Datasource AuditDatasourceService
+}
+
+// AuditActions returns all actions recorded in the audit log
+func AuditActions() []string {
+	return auditActions
+}
+
+// AuditTargetTypes returns all target types recorded in the audit log
+func AuditTargetTypes() []string {
+	return auditTargetTypes
+}
+
+// Record appends an event to the audit log; the IP address is taken from the actor.
+// Actions without an actor are recorded as system actions. An error while recording does not undo the action, so it is only logged
+func (aus *AuditService) Record(actor *User, action, targetType string, targetID int, before, after string) {
+	if aus == nil {
+		return
+	}
+
+	e := &AuditEvent{
+		ActorName:  "system",
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+	}
+
+	if actor != nil {
+		e.ActorID = actor.ID
+		e.ActorName = actor.Username
+		e.IP = actor.RemoteAddr
+	}
+
+	if _, err := aus.Datasource.Create(e); err != nil {
+		logger.Log.Errorf("could not record the audit event %s %s %d of %s %v", action, targetType, targetID, e.ActorName, err)
+	}
+}
+
+// List returns the audit events matching the filter, the newest first
+func (aus *AuditService) List(f *AuditFilter, p *Pagination) ([]AuditEvent, error) {
+	return aus.Datasource.List(f, p)
+}
+
+// Count returns the amount of audit events matching the filter
+func (aus *AuditService) Count(f *AuditFilter) (int, error) {
+	return aus.Datasource.Count(f)
+}
+
+// WriteCSV writes all audit events matching the filter as CSV
+func (aus *AuditService) WriteCSV(w io.Writer, f *AuditFilter) error {
+	events, err := aus.Datasource.List(f, nil)
+
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"time", "actor_id", "actor", "ip", "action", "target_type", "target_id", "before", "after"}); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		record := []string{
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(e.ActorID),
+			e.ActorName,
+			e.IP,
+			e.Action,
+			e.TargetType,
+			strconv.Itoa(e.TargetID),
+			e.Before,
+			e.After,
+		}
+
+		for i, v := range record {
+			record[i] = csvSafe(v)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// csvSafe prevents that values are interpreted as formula by spreadsheet applications
+func csvSafe(v string) string {
+	if len(v) > 0 && strings.ContainsAny(v[:1], "=+-@\t\r") {
+		return "'" + v
+	}
+
+	return v
+}
+
+func (a *Article) auditSummary() string {
+	return fmt.Sprintf("headline=%q slug=%q published=%t category=%d", a.Headline, a.Slug, a.Published, a.CID.Int64)
+}
+
+func (c *Category) auditSummary() string {
+	return fmt.Sprintf("name=%q slug=%q", c.Name, c.Slug)
+}
+
+func (f *File) auditSummary() string {
+	return fmt.Sprintf("name=%q inline=%t size=%d", f.FullFilename, f.Inline, f.Size)
+}
+
+func (s *Site) auditSummary() string {
+	return fmt.Sprintf("title=%q link=%q published=%t order=%d", s.Title, s.Link, s.Published, s.OrderNo)
+}
+
+func (u *User) auditSummary() string {
+	return fmt.Sprintf("username=%q email=%q role=%s active=%t", u.Username, u.Email, u.Role, u.Active)
+}
+
+func (u *User) profileSummary() string {
+	return fmt.Sprintf("username=%q website=%q avatar=%q social_links=%q bio_length=%d", u.Username, u.Website, u.Avatar, u.SocialLinks, len(u.Bio))
+}
+
+func (ui *UserInvite) auditSummary() string {
+	return fmt.Sprintf("username=%q email=%q role=%s", ui.Username, ui.Email, ui.Role)
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PostgresAuditDatasource providing an implementation of AuditDatasourceService for PostgreSQL
+type PostgresAuditDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create appends an audit event
+func (rdb *PostgresAuditDatasource) Create(e *AuditEvent) (int, error) {
+	var actorID, targetID sql.NullInt64
+
+	if e.ActorID > 0 {
+		actorID = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+
+	if e.TargetID > 0 {
+		targetID = sql.NullInt64{Int64: int64(e.TargetID), Valid: true}
+	}
+
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO audit_event (actor_id, actor_name, action, target_type, target_id, before, after, ip, created_at) "+
+		"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id", actorID, e.ActorName, e.Action, e.TargetType, targetID, e.Before, e.After, e.IP, time.Now()).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// List returns the audit events matching the filter, the newest first; if the pagination is nil all events are returned
+func (rdb *PostgresAuditDatasource) List(f *AuditFilter, p *Pagination) ([]AuditEvent, error) {
+	var stmt strings.Builder
+
+	stmt.WriteString("SELECT ae.id, ae.actor_id, ae.actor_name, ae.action, ae.target_type, ae.target_id, ae.before, ae.after, ae.ip, ae.created_at " +
+		"FROM audit_event as ae ")
+
+	args := auditWhere(&stmt, f)
+
+	stmt.WriteString("ORDER BY ae.created_at DESC, ae.id DESC ")
+
+	if p != nil {
+		stmt.WriteString("LIMIT ? OFFSET ? ")
+		args = append(args, p.Limit, p.Offset())
+	}
+
+	rows, err := rdb.SQLConn.Query(database.Rebind(stmt.String()), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+		var actorID, targetID sql.NullInt64
+
+		if err = rows.Scan(&e.ID, &actorID, &e.ActorName, &e.Action, &e.TargetType, &targetID, &e.Before, &e.After, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		e.ActorID = int(actorID.Int64)
+		e.TargetID = int(targetID.Int64)
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Count returns the amount of audit events matching the filter
+func (rdb *PostgresAuditDatasource) Count(f *AuditFilter) (int, error) {
+	var stmt strings.Builder
+	var total int
+
+	stmt.WriteString("SELECT count(ae.id) FROM audit_event as ae ")
+
+	args := auditWhere(&stmt, f)
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), args...).Scan(&total); err != nil {
+		return -1, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// SQLiteAuditDatasource providing an implementation of AuditDatasourceService for SQLite
+type SQLiteAuditDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create appends an audit event
+func (rdb *SQLiteAuditDatasource) Create(e *AuditEvent) (int, error) {
+	var actorID, targetID sql.NullInt64
+
+	if e.ActorID > 0 {
+		actorID = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+
+	if e.TargetID > 0 {
+		targetID = sql.NullInt64{Int64: int64(e.TargetID), Valid: true}
+	}
+
+	res, err := rdb.SQLConn.Exec("INSERT INTO audit_event (actor_id, actor_name, action, target_type, target_id, before, after, ip, created_at) "+
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)", actorID, e.ActorName, e.Action, e.TargetType, targetID, e.Before, e.After, e.IP, time.Now())
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// List returns the audit events matching the filter, the newest first; if the pagination is nil all events are returned
+func (rdb *SQLiteAuditDatasource) List(f *AuditFilter, p *Pagination) ([]AuditEvent, error) {
+	var stmt strings.Builder
+
+	stmt.WriteString("SELECT ae.id, ae.actor_id, ae.actor_name, ae.action, ae.target_type, ae.target_id, ae.before, ae.after, ae.ip, ae.created_at " +
+		"FROM audit_event as ae ")
+
+	args := auditWhere(&stmt, f)
+
+	stmt.WriteString("ORDER BY ae.created_at DESC, ae.id DESC ")
+
+	if p != nil {
+		stmt.WriteString("LIMIT ? OFFSET ? ")
+		args = append(args, p.Limit, p.Offset())
+	}
+
+	rows, err := rdb.SQLConn.Query(stmt.String(), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+		var actorID, targetID sql.NullInt64
+
+		if err = rows.Scan(&e.ID, &actorID, &e.ActorName, &e.Action, &e.TargetType, &targetID, &e.Before, &e.After, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		e.ActorID = int(actorID.Int64)
+		e.TargetID = int(targetID.Int64)
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Count returns the amount of audit events matching the filter
+func (rdb *SQLiteAuditDatasource) Count(f *AuditFilter) (int, error) {
+	var stmt strings.Builder
+	var total int
+
+	stmt.WriteString("SELECT count(ae.id) FROM audit_event as ae ")
+
+	args := auditWhere(&stmt, f)
+
+	if err := rdb.SQLConn.QueryRow(stmt.String(), args...).Scan(&total); err != nil {
+		return -1, err
+	}
+
+	return total, nil
+}
+
+func auditWhere(stmt *strings.Builder, f *AuditFilter) []interface{} {
+	var args []interface{}
+
+	stmt.WriteString("WHERE 1=1 ")
+
+	if f == nil {
+		return args
+	}
+
+	if len(f.Actor) > 0 {
+		stmt.WriteString("AND ae.actor_name=? ")
+		args = append(args, f.Actor)
+	}
+
+	if len(f.Action) > 0 {
+		stmt.WriteString("AND ae.action=? ")
+		args = append(args, f.Action)
+	}
+
+	if len(f.TargetType) > 0 {
+		stmt.WriteString("AND ae.target_type=? ")
+		args = append(args, f.TargetType)
+	}
+
+	if !f.From.IsZero() {
+		stmt.WriteString("AND ae.created_at >= ? ")
+		args = append(args, f.From)
+	}
+
+	if !f.To.IsZero() {
+		stmt.WriteString("AND ae.created_at < ? ")
+		args = append(args, f.To)
+	}
+
+	return args
+}
@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+)
+
+func TestAuditService(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	as := &models.AuditService{
+		Datasource: &models.SQLiteAuditDatasource{
+			SQLConn: db,
+		},
+	}
+
+	alice := &models.User{ID: 1, Username: "alice", RemoteAddr: "192.0.2.1"}
+
+	as.Record(alice, models.AuditCreate, models.AuditArticle, 7, "", "=cmd()")
+	as.Record(alice, models.AuditDelete, models.AuditArticle, 7, "=cmd()", "")
+	as.Record(nil, models.AuditUpdate, models.AuditUser, 2, "role=admin", "role=author")
+
+	events, err := as.List(&models.AuditFilter{Actor: "alice"}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].Action != models.AuditDelete || events[0].IP != "192.0.2.1" {
+		t.Fatalf("expected two events of alice, the newest first, but got %v", events)
+	}
+
+	total, err := as.Count(&models.AuditFilter{Actor: "system"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 1 {
+		t.Errorf("expected one system event, but got %d", total)
+	}
+
+	// the audit log is append-only
+	if _, err := db.Exec("UPDATE audit_event SET actor_name='mallory'"); err == nil {
+		t.Error("expected an error while updating an audit event, but error is nil")
+	}
+
+	if _, err := db.Exec("DELETE FROM audit_event"); err == nil {
+		t.Error("expected an error while removing an audit event, but error is nil")
+	}
+
+	var buf bytes.Buffer
+
+	if err := as.WriteCSV(&buf, &models.AuditFilter{Action: models.AuditCreate}); err != nil {
+		t.Fatal(err)
+	}
+
+	// values are not interpreted as formula by spreadsheet applications
+	if !strings.Contains(buf.String(), ",'=cmd()\n") {
+		t.Errorf("expected the escaped summary in the export, but got %s", buf.String())
+	}
+}
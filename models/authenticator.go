@@ -0,0 +1,93 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+// Authenticator checks the credentials of an user and returns the user stored in the database
+type Authenticator interface {
+	Authenticate(u *User, loginMethod settings.LoginMethod) (*User, error)
+}
+
+// SQLiteAuthenticator checks the password against the hash stored in the user table;
+// the params are used to rehash outdated password hashes
+type SQLiteAuthenticator struct {
+	Datasource UserDatasourceService
+	Params     crypt.PasswordParams
+}
+
+// Authenticate checks the password of the user found by the given login method (email or username);
+// if the user was found but the password is wrong the found user and an error will be returned.
+// Legacy bcrypt hashes and hashes with outdated parameters are replaced by an argon2id hash
+func (sa SQLiteAuthenticator) Authenticate(u *User, loginMethod settings.LoginMethod) (*User, error) {
+	var err error
+
+	if len(u.Username) == 0 || len(u.PlainPassword) == 0 {
+		return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", errors.New("no username or password were given"))
+	}
+
+	var password = u.PlainPassword
+
+	if loginMethod == settings.EMail {
+		u, err = sa.Datasource.GetByMail(u.Email)
+	} else {
+		u, err = sa.Datasource.GetByUsername(u.Username)
+	}
+
+	if err != nil {
+		//Do some extra work, so the response time does not reveal whether the user exists
+		_, _ = crypt.HashPassword(password, sa.Params)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", err)
+		}
+		return nil, err
+	}
+
+	rehash, err := crypt.VerifyPassword(u.Password, password, u.Salt, sa.Params)
+
+	if err != nil {
+		return u, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", err)
+	}
+
+	if !u.Active {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Your account is deactivated.",
+			fmt.Errorf("the user with id %d tried to logged in but the account is deactivated", u.ID))
+	}
+
+	if rehash {
+		sa.rehash(u, password)
+	}
+
+	u.PlainPassword = nil
+	u.Password = nil
+	u.Salt = nil
+
+	return u, nil
+}
+
+// rehash replaces the password hash with a hash of the current parameters; the login is not denied if this fails
+func (sa SQLiteAuthenticator) rehash(u *User, password []byte) {
+	hash, err := crypt.HashPassword(password, sa.Params)
+
+	if err != nil {
+		logger.Log.Errorf("could not rehash the password of the user %d %v", u.ID, err)
+		return
+	}
+
+	if err := sa.Datasource.UpdatePassword(u.ID, hash); err != nil {
+		logger.Log.Errorf("could not update the password hash of the user %d %v", u.ID, err)
+	}
+}
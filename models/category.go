@@ -13,11 +13,11 @@ import (
 )
 
 type Category struct {
-	ID           int
-	Name         string
-	Slug         string
-	LastModified time.Time
-	Author       *User
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Slug         string    `json:"slug"`
+	LastModified time.Time `json:"last_modified"`
+	Author       *User     `json:"author"`
 }
 
 type FilterCriteria int
@@ -55,7 +55,8 @@ type CategoryDatasourceService interface {
 
 // CategoryService containing the service to access categories
 type CategoryService struct {
-	Datasource CategoryDatasourceService
+	Datasource   CategoryDatasourceService
+	AuditService *AuditService
 }
 
 // SlugEscape escapes the slug for use in URLs
@@ -114,25 +115,73 @@ func (cs *CategoryService) Create(c *Category) (int, error) {
 		return 0, err
 	}
 
-	return cs.Datasource.Create(c)
+	id, err := cs.Datasource.Create(c)
+
+	if err != nil {
+		return -1, err
+	}
+
+	cs.AuditService.Record(c.Author, AuditCreate, AuditCategory, id, "", c.auditSummary())
+
+	return id, nil
 }
 
-// Update updates a category
-func (cs *CategoryService) Update(c *Category) error {
+// Update updates a category; only the author of the category or an user who is granted to manage categories
+// can update the category
+func (cs *CategoryService) Update(c *Category, u *User) error {
 	if err := c.validate(); err != nil {
 		return err
 	}
 
-	return cs.Datasource.Update(c)
+	oldCategory, err := cs.Datasource.Get(c.ID, AllCategories)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("category", fmt.Errorf("the category with id %d was not found", c.ID))
+		}
+		return err
+	}
+
+	if !u.Can(PermCategoryManage) {
+		if oldCategory.Author.ID != u.ID {
+			return httperror.PermissionDenied("update", "category", fmt.Errorf("could not update category %d user %d has no permission", c.ID, u.ID))
+		}
+	}
+
+	c.Author = oldCategory.Author
+
+	if err := cs.Datasource.Update(c); err != nil {
+		return err
+	}
+
+	cs.AuditService.Record(u, AuditUpdate, AuditCategory, c.ID, oldCategory.auditSummary(), c.auditSummary())
+
+	return nil
 }
 
-// Delete removes a category
-func (cs *CategoryService) Delete(id int) error {
+// Delete removes a category; only the author of the category or an user who is granted to manage categories
+// can remove the category
+func (cs *CategoryService) Delete(id int, u *User) error {
 	c, err := cs.Datasource.Get(id, AllCategories)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("category", fmt.Errorf("the category with id %d was not found", id))
+		}
 		return err
 	}
 
-	return cs.Datasource.Delete(c.ID)
+	if !u.Can(PermCategoryManage) {
+		if c.Author.ID != u.ID {
+			return httperror.PermissionDenied("delete", "category", fmt.Errorf("could not delete category %d user %d has no permission", id, u.ID))
+		}
+	}
+
+	if err := cs.Datasource.Delete(c.ID); err != nil {
+		return err
+	}
+
+	cs.AuditService.Record(u, AuditDelete, AuditCategory, c.ID, c.auditSummary(), "")
+
+	return nil
 }
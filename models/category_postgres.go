@@ -0,0 +1,185 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"strings"
+	"time"
+)
+
+// PostgresCategoryDatasource providing an implementation of CategoryDatasourceService for PostgreSQL
+type PostgresCategoryDatasource struct {
+	SQLConn *sql.DB
+}
+
+func (rdb *PostgresCategoryDatasource) Create(c *Category) (int, error) {
+	var id int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO category (name, slug, last_modified, user_id) "+
+		"VALUES ($1, $2, $3, $4) RETURNING id",
+		c.Name,
+		c.Slug,
+		time.Now(),
+		c.Author.ID).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (rdb *PostgresCategoryDatasource) List(fc FilterCriteria) ([]Category, error) {
+	var args []interface{}
+	var stmt strings.Builder
+
+	stmt.WriteString("SELECT DISTINCT c.id, c.name, c.slug, c.last_modified, ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM category as c ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON c.user_id = u.id ")
+
+	if fc == CategoriesWithPublishedArticles {
+		stmt.WriteString("INNER JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.published = true ")
+	} else if fc == CategoriesWithoutArticles {
+		stmt.WriteString("LEFT JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.category_id IS NULL ")
+	}
+
+	stmt.WriteString("ORDER BY c.last_modified DESC ")
+
+	rows, err := rdb.SQLConn.Query(database.Rebind(stmt.String()), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var cs []Category
+
+	for rows.Next() {
+		var c Category
+		var ru User
+
+		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID, &ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
+			return nil, err
+		}
+
+		c.Author = &ru
+
+		cs = append(cs, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func (rdb *PostgresCategoryDatasource) Count(fc FilterCriteria) (int, error) {
+	var total int
+
+	if err := rdb.SQLConn.QueryRow("SELECT count(id) FROM category ").Scan(&total); err != nil {
+		return -1, err
+	}
+
+	return total, nil
+}
+
+func (rdb *PostgresCategoryDatasource) Get(categoryID int, fc FilterCriteria) (*Category, error) {
+	var stmt bytes.Buffer
+
+	stmt.WriteString("SELECT c.id, c.name, c.slug, c.last_modified, ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM category as c ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON u.id = c.user_id ")
+
+	if fc == CategoriesWithPublishedArticles {
+		stmt.WriteString("INNER JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.published = true ")
+		stmt.WriteString("AND c.id=? ")
+	} else if fc == CategoriesWithoutArticles {
+		stmt.WriteString("LEFT JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.category_id IS NULL ")
+		stmt.WriteString("AND c.id=? ")
+	} else {
+		stmt.WriteString("WHERE c.id=? ")
+	}
+
+	var c Category
+	var ru User
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), categoryID).Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID,
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
+		return nil, err
+	}
+
+	c.Author = &ru
+
+	return &c, nil
+}
+
+func (rdb *PostgresCategoryDatasource) GetBySlug(slug string, fc FilterCriteria) (*Category, error) {
+	var stmt strings.Builder
+
+	stmt.WriteString("SELECT c.id, c.name, c.slug, c.last_modified, ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM category as c ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON u.id = c.user_id ")
+
+	if fc == CategoriesWithPublishedArticles {
+		stmt.WriteString("INNER JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.published = true ")
+		stmt.WriteString("AND c.slug=? ")
+	} else if fc == CategoriesWithoutArticles {
+		stmt.WriteString("LEFT JOIN article as a ")
+		stmt.WriteString("ON c.id = a.category_id ")
+		stmt.WriteString("WHERE a.category_id IS NULL ")
+		stmt.WriteString("AND c.slug=? ")
+	} else {
+		stmt.WriteString("WHERE c.slug=? ")
+	}
+
+	var c Category
+	var ru User
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), slug).Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID,
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
+		return nil, err
+	}
+
+	c.Author = &ru
+
+	return &c, nil
+}
+
+func (rdb *PostgresCategoryDatasource) Update(c *Category) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE category SET name=$1, slug=$2, last_modified=$3, user_id=$4 WHERE id=$5",
+		c.Name, c.Slug, time.Now(), c.Author.ID, c.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (rdb *PostgresCategoryDatasource) Delete(categoryID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM category WHERE id=$1", categoryID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -39,7 +39,7 @@ func (rdb *SQLiteCategoryDatasource) List(fc FilterCriteria) ([]Category, error)
 	var stmt strings.Builder
 
 	stmt.WriteString("SELECT DISTINCT c.id, c.name, c.slug, c.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM category as c ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON c.user_id = u.id ")
@@ -74,7 +74,7 @@ func (rdb *SQLiteCategoryDatasource) List(fc FilterCriteria) ([]Category, error)
 		var c Category
 		var ru User
 
-		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID, &ru.DisplayName, &ru.Username, &ru.Email, &ru.IsAdmin); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID, &ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
 			return nil, err
 		}
 
@@ -104,7 +104,7 @@ func (rdb *SQLiteCategoryDatasource) Get(categoryID int, fc FilterCriteria) (*Ca
 	var stmt bytes.Buffer
 
 	stmt.WriteString("SELECT c.id, c.name, c.slug, c.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM category as c ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON u.id = c.user_id ")
@@ -127,7 +127,7 @@ func (rdb *SQLiteCategoryDatasource) Get(categoryID int, fc FilterCriteria) (*Ca
 	var ru User
 
 	if err := rdb.SQLConn.QueryRow(stmt.String(), categoryID).Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID,
-		&ru.DisplayName, &ru.Username, &ru.Email, &ru.IsAdmin); err != nil {
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
 		return nil, err
 	}
 
@@ -140,7 +140,7 @@ func (rdb *SQLiteCategoryDatasource) GetBySlug(slug string, fc FilterCriteria) (
 	var stmt strings.Builder
 
 	stmt.WriteString("SELECT c.id, c.name, c.slug, c.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM category as c ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON u.id = c.user_id ")
@@ -163,7 +163,7 @@ func (rdb *SQLiteCategoryDatasource) GetBySlug(slug string, fc FilterCriteria) (
 	var ru User
 
 	if err := rdb.SQLConn.QueryRow(stmt.String(), slug).Scan(&c.ID, &c.Name, &c.Slug, &c.LastModified, &ru.ID,
-		&ru.DisplayName, &ru.Username, &ru.Email, &ru.IsAdmin); err != nil {
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
 		return nil, err
 	}
 
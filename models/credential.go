@@ -0,0 +1,396 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/settings"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// CredentialDatasourceService defines an interface for CRUD operations of WebAuthn credentials
+type CredentialDatasourceService interface {
+	Create(c *Credential) (int, error)
+	List(userID int) ([]Credential, error)
+	Get(credentialID, userID int) (*Credential, error)
+	UpdateSignCount(credentialID int, signCount uint32, cloneWarning bool) error
+	Remove(credentialID int) error
+}
+
+// Credential represents a WebAuthn credential (security key or passkey) of an user
+type Credential struct {
+	ID              int
+	Name            string
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Transport       string
+	AAGUID          []byte
+	SignCount       uint32
+	CloneWarning    bool
+	BackupEligible  bool
+	BackupState     bool
+	CreatedAt       time.Time
+	LastUsedAt      NullTime
+
+	Author *User
+}
+
+const (
+	maxCredentialNameLength = 64
+	webAuthnTimeout         = 5 * time.Minute
+)
+
+// WebAuthnService containing the service to register WebAuthn credentials and to log in with them;
+// the relying party is scoped to the configured application domain
+type WebAuthnService struct {
+	Datasource  CredentialDatasourceService
+	UserService *UserService
+	AppConfig   settings.Application
+}
+
+// webAuthnUser wraps the user and its credentials to implement the webauthn.User interface
+type webAuthnUser struct {
+	user        *User
+	credentials []Credential
+}
+
+func (wu webAuthnUser) WebAuthnID() []byte {
+	return userHandle(wu.user.ID)
+}
+
+func (wu webAuthnUser) WebAuthnName() string {
+	return wu.user.Username
+}
+
+func (wu webAuthnUser) WebAuthnDisplayName() string {
+	return wu.user.DisplayName
+}
+
+func (wu webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (wu webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	wcs := make([]webauthn.Credential, 0, len(wu.credentials))
+
+	for _, c := range wu.credentials {
+		wcs = append(wcs, c.webAuthnCredential())
+	}
+
+	return wcs
+}
+
+// userHandle returns the opaque WebAuthn user handle of the user id
+func userHandle(userID int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userID))
+	return b
+}
+
+func (c Credential) webAuthnCredential() webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+
+	if len(c.Transport) > 0 {
+		for _, t := range strings.Split(c.Transport, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              c.CredentialID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       c.AAGUID,
+			SignCount:    c.SignCount,
+			CloneWarning: c.CloneWarning,
+		},
+	}
+}
+
+func (was *WebAuthnService) relyingParty() (*webauthn.WebAuthn, error) {
+	u, err := url.Parse(was.AppConfig.Domain)
+
+	if err != nil {
+		return nil, httperror.InternalServerError(fmt.Errorf("invalid application domain %s for WebAuthn %v", was.AppConfig.Domain, err))
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: was.AppConfig.Title,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login: webauthn.TimeoutConfig{
+				Enforce:    true,
+				Timeout:    webAuthnTimeout,
+				TimeoutUVD: webAuthnTimeout,
+			},
+			Registration: webauthn.TimeoutConfig{
+				Enforce:    true,
+				Timeout:    webAuthnTimeout,
+				TimeoutUVD: webAuthnTimeout,
+			},
+		},
+	})
+}
+
+// ListCredentials returns all credentials of the user
+func (was *WebAuthnService) ListCredentials(u *User) ([]Credential, error) {
+	return was.Datasource.List(u.ID)
+}
+
+// RemoveCredential removes a credential of the user
+func (was *WebAuthnService) RemoveCredential(u *User, credentialID int) error {
+	c, err := was.Datasource.Get(credentialID, u.ID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return httperror.NotFound("passkey", err)
+		}
+		return err
+	}
+
+	return was.Datasource.Remove(c.ID)
+}
+
+// RemoveCredentials removes all credentials of the user
+func (was *WebAuthnService) RemoveCredentials(u *User) error {
+	credentials, err := was.Datasource.List(u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range credentials {
+		if err := was.Datasource.Remove(c.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BeginRegistration starts the registration of a new credential for the user;
+// the returned session data has to be passed to FinishRegistration
+func (was *WebAuthnService) BeginRegistration(u *User) (*protocol.CredentialCreation, string, error) {
+	wa, err := was.relyingParty()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	credentials, err := was.Datasource.List(u.ID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	wu := webAuthnUser{user: u, credentials: credentials}
+
+	var exclusions []protocol.CredentialDescriptor
+
+	for _, c := range wu.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := wa.BeginRegistration(wu, webauthn.WithExclusions(exclusions))
+
+	if err != nil {
+		return nil, "", httperror.InternalServerError(err)
+	}
+
+	sd, err := json.Marshal(session)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return creation, string(sd), nil
+}
+
+// FinishRegistration verifies the response of the authenticator and saves the new credential
+func (was *WebAuthnService) FinishRegistration(u *User, name, sessionData string, r *http.Request) (*Credential, error) {
+	name = strings.TrimSpace(name)
+
+	if len(name) == 0 {
+		return nil, httperror.ValueRequired("name")
+	}
+
+	if len(name) > maxCredentialNameLength {
+		return nil, httperror.ValueTooLong("name", maxCredentialNameLength)
+	}
+
+	wa, err := was.relyingParty()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var session webauthn.SessionData
+
+	if err := json.Unmarshal([]byte(sessionData), &session); err != nil {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "The registration has expired. Please try again.", err)
+	}
+
+	wc, err := wa.FinishRegistration(webAuthnUser{user: u}, session, r)
+
+	if err != nil {
+		return nil, httperror.New(http.StatusUnprocessableEntity, "The passkey could not be verified.", err)
+	}
+
+	var transports []string
+
+	for _, t := range wc.Transport {
+		transports = append(transports, string(t))
+	}
+
+	c := &Credential{
+		Name:            name,
+		CredentialID:    wc.ID,
+		PublicKey:       wc.PublicKey,
+		AttestationType: wc.AttestationType,
+		Transport:       strings.Join(transports, ","),
+		AAGUID:          wc.Authenticator.AAGUID,
+		SignCount:       wc.Authenticator.SignCount,
+		BackupEligible:  wc.Flags.BackupEligible,
+		BackupState:     wc.Flags.BackupState,
+		Author:          u,
+	}
+
+	id, err := was.Datasource.Create(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.ID = id
+
+	return c, nil
+}
+
+// BeginLogin starts a passwordless login with a discoverable credential;
+// the returned session data has to be passed to FinishLogin
+func (was *WebAuthnService) BeginLogin() (*protocol.CredentialAssertion, string, error) {
+	wa, err := was.relyingParty()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	assertion, session, err := wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+
+	if err != nil {
+		return nil, "", httperror.InternalServerError(err)
+	}
+
+	sd, err := json.Marshal(session)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return assertion, string(sd), nil
+}
+
+// FinishLogin verifies the assertion of the authenticator and returns the user owning the credential;
+// as user verification is required, the credential counts as both factors
+func (was *WebAuthnService) FinishLogin(sessionData string, r *http.Request) (*User, error) {
+	wa, err := was.relyingParty()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var session webauthn.SessionData
+
+	if err := json.Unmarshal([]byte(sessionData), &session); err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", err)
+	}
+
+	var wu webAuthnUser
+
+	handler := func(rawID, handle []byte) (webauthn.User, error) {
+		if len(handle) != 8 {
+			return nil, fmt.Errorf("invalid user handle %x", handle)
+		}
+
+		u, err := was.UserService.GetByID(int(binary.BigEndian.Uint64(handle)))
+
+		if err != nil {
+			return nil, err
+		}
+
+		credentials, err := was.Datasource.List(u.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		wu = webAuthnUser{user: u, credentials: credentials}
+
+		return wu, nil
+	}
+
+	wc, err := wa.FinishDiscoverableLogin(handler, session, r)
+
+	if err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The passkey could not be verified.", err)
+	}
+
+	var c *Credential
+
+	for i := range wu.credentials {
+		if bytes.Equal(wu.credentials[i].CredentialID, wc.ID) {
+			c = &wu.credentials[i]
+			break
+		}
+	}
+
+	if c == nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The passkey could not be verified.", fmt.Errorf("credential %x not found", wc.ID))
+	}
+
+	if err := was.Datasource.UpdateSignCount(c.ID, wc.Authenticator.SignCount, wc.Authenticator.CloneWarning); err != nil {
+		return nil, err
+	}
+
+	if wc.Authenticator.CloneWarning {
+		logger.Log.Warnf("the sign counter of passkey %d of user %d decreased; the authenticator may be cloned", c.ID, wu.user.ID)
+
+		return nil, httperror.New(http.StatusUnauthorized, "The passkey could not be verified.",
+			fmt.Errorf("clone warning for passkey %d of user %d", c.ID, wu.user.ID))
+	}
+
+	if !wu.user.Active {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Your account is deactivated.",
+			fmt.Errorf("the user with id %d tried to log in with a passkey but the account is deactivated", wu.user.ID))
+	}
+
+	return wu.user, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PostgresCredentialDatasource providing an implementation of CredentialDatasourceService for PostgreSQL
+type PostgresCredentialDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new credential
+func (rdb *PostgresCredentialDatasource) Create(c *Credential) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO credential (name, credential_id, public_key, attestation_type, transport, aaguid, sign_count, "+
+		"clone_warning, backup_eligible, backup_state, created_at, user_id) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
+		c.Name, c.CredentialID, c.PublicKey, c.AttestationType, c.Transport, c.AAGUID, c.SignCount,
+		c.CloneWarning, c.BackupEligible, c.BackupState, time.Now(), c.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// List returns all credentials of the user
+func (rdb *PostgresCredentialDatasource) List(userID int) ([]Credential, error) {
+	rows, err := rdb.SQLConn.Query("SELECT c.id, c.name, c.credential_id, c.public_key, c.attestation_type, c.transport, c.aaguid, c.sign_count, "+
+		"c.clone_warning, c.backup_eligible, c.backup_state, c.created_at, c.last_used_at, c.user_id "+
+		"FROM credential as c WHERE c.user_id=$1 ORDER BY c.created_at ASC ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var credentials []Credential
+
+	for rows.Next() {
+		var u User
+		var c Credential
+
+		if err = rows.Scan(&c.ID, &c.Name, &c.CredentialID, &c.PublicKey, &c.AttestationType, &c.Transport, &c.AAGUID, &c.SignCount,
+			&c.CloneWarning, &c.BackupEligible, &c.BackupState, &c.CreatedAt, &c.LastUsedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		c.Author = &u
+
+		credentials = append(credentials, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Get returns the credential of the user
+func (rdb *PostgresCredentialDatasource) Get(credentialID, userID int) (*Credential, error) {
+	var u User
+	var c Credential
+
+	if err := rdb.SQLConn.QueryRow("SELECT c.id, c.name, c.credential_id, c.public_key, c.attestation_type, c.transport, c.aaguid, c.sign_count, "+
+		"c.clone_warning, c.backup_eligible, c.backup_state, c.created_at, c.last_used_at, c.user_id "+
+		"FROM credential as c WHERE c.id=$1 AND c.user_id=$2 ", credentialID, userID).
+		Scan(&c.ID, &c.Name, &c.CredentialID, &c.PublicKey, &c.AttestationType, &c.Transport, &c.AAGUID, &c.SignCount,
+			&c.CloneWarning, &c.BackupEligible, &c.BackupState, &c.CreatedAt, &c.LastUsedAt, &u.ID); err != nil {
+		return nil, err
+	}
+
+	c.Author = &u
+
+	return &c, nil
+}
+
+// UpdateSignCount updates the signature counter and the time the credential was last used
+func (rdb *PostgresCredentialDatasource) UpdateSignCount(credentialID int, signCount uint32, cloneWarning bool) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE credential SET sign_count=$1, clone_warning=$2, last_used_at=$3 WHERE id=$4 ",
+		signCount, cloneWarning, time.Now(), credentialID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes a credential
+func (rdb *PostgresCredentialDatasource) Remove(credentialID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM credential WHERE id=$1 ", credentialID); err != nil {
+		return err
+	}
+
+	return nil
+}
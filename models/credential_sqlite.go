@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// SQLiteCredentialDatasource providing an implementation of CredentialDatasourceService for SQLite
+type SQLiteCredentialDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new credential
+func (rdb *SQLiteCredentialDatasource) Create(c *Credential) (int, error) {
+	res, err := rdb.SQLConn.Exec("INSERT INTO credential (name, credential_id, public_key, attestation_type, transport, aaguid, sign_count, "+
+		"clone_warning, backup_eligible, backup_state, created_at, user_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		c.Name, c.CredentialID, c.PublicKey, c.AttestationType, c.Transport, c.AAGUID, c.SignCount,
+		c.CloneWarning, c.BackupEligible, c.BackupState, time.Now(), c.Author.ID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// List returns all credentials of the user
+func (rdb *SQLiteCredentialDatasource) List(userID int) ([]Credential, error) {
+	rows, err := rdb.SQLConn.Query("SELECT c.id, c.name, c.credential_id, c.public_key, c.attestation_type, c.transport, c.aaguid, c.sign_count, "+
+		"c.clone_warning, c.backup_eligible, c.backup_state, c.created_at, c.last_used_at, c.user_id "+
+		"FROM credential as c WHERE c.user_id=? ORDER BY c.created_at ASC ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var credentials []Credential
+
+	for rows.Next() {
+		var u User
+		var c Credential
+
+		if err = rows.Scan(&c.ID, &c.Name, &c.CredentialID, &c.PublicKey, &c.AttestationType, &c.Transport, &c.AAGUID, &c.SignCount,
+			&c.CloneWarning, &c.BackupEligible, &c.BackupState, &c.CreatedAt, &c.LastUsedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		c.Author = &u
+
+		credentials = append(credentials, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Get returns the credential of the user
+func (rdb *SQLiteCredentialDatasource) Get(credentialID, userID int) (*Credential, error) {
+	var u User
+	var c Credential
+
+	if err := rdb.SQLConn.QueryRow("SELECT c.id, c.name, c.credential_id, c.public_key, c.attestation_type, c.transport, c.aaguid, c.sign_count, "+
+		"c.clone_warning, c.backup_eligible, c.backup_state, c.created_at, c.last_used_at, c.user_id "+
+		"FROM credential as c WHERE c.id=? AND c.user_id=? ", credentialID, userID).
+		Scan(&c.ID, &c.Name, &c.CredentialID, &c.PublicKey, &c.AttestationType, &c.Transport, &c.AAGUID, &c.SignCount,
+			&c.CloneWarning, &c.BackupEligible, &c.BackupState, &c.CreatedAt, &c.LastUsedAt, &u.ID); err != nil {
+		return nil, err
+	}
+
+	c.Author = &u
+
+	return &c, nil
+}
+
+// UpdateSignCount updates the signature counter and the time the credential was last used
+func (rdb *SQLiteCredentialDatasource) UpdateSignCount(credentialID int, signCount uint32, cloneWarning bool) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE credential SET sign_count=?, clone_warning=?, last_used_at=? WHERE id=? ",
+		signCount, cloneWarning, time.Now(), credentialID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes a credential
+func (rdb *SQLiteCredentialDatasource) Remove(credentialID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM credential WHERE id=? ", credentialID); err != nil {
+		return err
+	}
+
+	return nil
+}
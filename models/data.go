@@ -6,7 +6,8 @@ package models
 
 // JSONData represents arbitrary JSON data
 type JSONData struct {
-	Data interface{} `json:"data,-" xml:"data,-"`
+	Data       interface{}     `json:"data,-" xml:"data,-"`
+	Pagination *PaginationMeta `json:"pagination,omitempty" xml:"-"`
 }
 
 // XMLData represents arbitrary XML data
@@ -0,0 +1,91 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.hoogi.eu/snafu/go-blog/database"
+)
+
+// Datasources contains the datasources of all services backed by one database
+type Datasources struct {
+	Articles       ArticleDatasourceService
+	Users          UserDatasourceService
+	UserInvites    UserInviteDatasourceService
+	UserDevices    UserDeviceDatasourceService
+	Sites          SiteDatasourceService
+	Files          FileDatasourceService
+	Categories     CategoryDatasourceService
+	Tokens         TokenDatasourceService
+	RecoveryCodes  RecoveryCodeDatasourceService
+	Credentials    CredentialDatasourceService
+	AccessTokens   AccessTokenDatasourceService
+	Audit          AuditDatasourceService
+	LoginThrottles LoginThrottleDatasourceService
+	Redirects      RedirectDatasourceService
+
+	// NewSessionProvider returns the session provider persisting the sessions in the database
+	NewSessionProvider func() *DatabaseSessionProvider
+}
+
+// NewDatasources returns the datasources for the database driver
+func NewDatasources(driver string, db *sql.DB) (*Datasources, error) {
+	switch driver {
+	case database.SQLite:
+		return NewSQLiteDatasources(db), nil
+	case database.Postgres:
+		return NewPostgresDatasources(db), nil
+	}
+
+	return nil, fmt.Errorf("there are no datasources for the database driver '%s'", driver)
+}
+
+// NewSQLiteDatasources returns the datasources for SQLite
+func NewSQLiteDatasources(db *sql.DB) *Datasources {
+	return &Datasources{
+		Articles:       &SQLiteArticleDatasource{SQLConn: db},
+		Users:          &SQLiteUserDatasource{SQLConn: db},
+		UserInvites:    &SQLiteUserInviteDatasource{SQLConn: db},
+		UserDevices:    &SQLiteUserDeviceDatasource{SQLConn: db},
+		Sites:          &SQLiteSiteDatasource{SQLConn: db},
+		Files:          &SQLiteFileDatasource{SQLConn: db},
+		Categories:     &SQLiteCategoryDatasource{SQLConn: db},
+		Tokens:         &SQLiteTokenDatasource{SQLConn: db},
+		RecoveryCodes:  &SQLiteRecoveryCodeDatasource{SQLConn: db},
+		Credentials:    &SQLiteCredentialDatasource{SQLConn: db},
+		AccessTokens:   &SQLiteAccessTokenDatasource{SQLConn: db},
+		Audit:          &SQLiteAuditDatasource{SQLConn: db},
+		LoginThrottles: &SQLiteLoginThrottleDatasource{SQLConn: db},
+		Redirects:      &SQLiteRedirectDatasource{SQLConn: db},
+		NewSessionProvider: func() *DatabaseSessionProvider {
+			return NewSQLiteSessionProvider(db)
+		},
+	}
+}
+
+// NewPostgresDatasources returns the datasources for PostgreSQL
+func NewPostgresDatasources(db *sql.DB) *Datasources {
+	return &Datasources{
+		Articles:       &PostgresArticleDatasource{SQLConn: db},
+		Users:          &PostgresUserDatasource{SQLConn: db},
+		UserInvites:    &PostgresUserInviteDatasource{SQLConn: db},
+		UserDevices:    &PostgresUserDeviceDatasource{SQLConn: db},
+		Sites:          &PostgresSiteDatasource{SQLConn: db},
+		Files:          &PostgresFileDatasource{SQLConn: db},
+		Categories:     &PostgresCategoryDatasource{SQLConn: db},
+		Tokens:         &PostgresTokenDatasource{SQLConn: db},
+		RecoveryCodes:  &PostgresRecoveryCodeDatasource{SQLConn: db},
+		Credentials:    &PostgresCredentialDatasource{SQLConn: db},
+		AccessTokens:   &PostgresAccessTokenDatasource{SQLConn: db},
+		Audit:          &PostgresAuditDatasource{SQLConn: db},
+		LoginThrottles: &PostgresLoginThrottleDatasource{SQLConn: db},
+		Redirects:      &PostgresRedirectDatasource{SQLConn: db},
+		NewSessionProvider: func() *DatabaseSessionProvider {
+			return NewPostgresSessionProvider(db)
+		},
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -20,7 +21,7 @@ import (
 
 // File represents a file
 type File struct {
-	ID           int
+	ID           int       `json:"id"`
 	UniqueName   string    `json:"unique_name"`
 	FullFilename string    `json:"full_name"`
 	Link         string    `json:"link"`
@@ -29,8 +30,8 @@ type File struct {
 	Size         int64     `json:"size"`
 	LastModified time.Time `json:"last_modified"`
 	Data         []byte    `json:"-"`
-	FileInfo     FileInfo
-	Author       *User
+	FileInfo     FileInfo  `json:"-"`
+	Author       *User     `json:"author"`
 }
 
 // FileInfo contains Path, Name and Extension of a file.
@@ -106,8 +107,9 @@ func SplitFilename(filename string) FileInfo {
 
 // FileService containing the service to interact with files
 type FileService struct {
-	Datasource FileDatasourceService
-	Config     settings.File
+	Datasource   FileDatasourceService
+	Config       settings.File
+	AuditService *AuditService
 }
 
 // GetByID returns the file based on the fileID; it the user is given and it is a non admin
@@ -141,6 +143,8 @@ func (fs *FileService) ToggleInline(fileID int, u *User) error {
 		return err
 	}
 
+	before := f.auditSummary()
+
 	f.FileInfo = SplitFilename(f.FullFilename)
 	newName := f.randomFilename()
 	f.Inline = !f.Inline
@@ -153,7 +157,13 @@ func (fs *FileService) ToggleInline(fileID int, u *User) error {
 		return err
 	}
 
-	return fs.Datasource.Update(f)
+	if err := fs.Datasource.Update(f); err != nil {
+		return err
+	}
+
+	fs.AuditService.Record(u, AuditUpdate, AuditFile, f.ID, before, f.auditSummary())
+
+	return nil
 }
 
 // Delete deletes a file based on fileID; users which are not the owner are not allowed to remove files; except admins
@@ -164,7 +174,7 @@ func (fs *FileService) Delete(fileID int, u *User) error {
 		return err
 	}
 
-	if !u.IsAdmin {
+	if !u.Can(PermFileEditAny) {
 		if file.Author.ID != u.ID {
 			return httperror.PermissionDenied("delete", "file", fmt.Errorf("could not remove file %d user %d has no permission", fileID, u.ID))
 		}
@@ -176,19 +186,15 @@ func (fs *FileService) Delete(fileID int, u *User) error {
 		return err
 	}
 
+	fs.AuditService.Record(u, AuditDelete, AuditFile, file.ID, file.auditSummary(), "")
+
 	return os.Remove(filepath.Join(fs.Config.Location, file.UniqueName))
 }
 
-// Upload uploaded files will be saved at the configured file location, filename is saved in the database
-func (fs *FileService) Upload(f *File) (int, error) {
-	if err := f.validate(); err != nil {
-		return -1, err
-	}
-
-	f.FileInfo = SplitFilename(f.FullFilename)
-
+// checkType checks if the file has an allowed extension or contains plain text
+func (fs *FileService) checkType(f *File) error {
 	if len(f.FileInfo.Extension) == 0 && !strings.HasPrefix(f.ContentType, "text/plain") {
-		return -1, httperror.New(
+		return httperror.New(
 			http.StatusUnprocessableEntity,
 			"The file has no extension and does not contain plain text.",
 			fmt.Errorf("the file %s has no extension and does not contain plain text, content type is: %s", f.FullFilename, f.ContentType))
@@ -196,13 +202,28 @@ func (fs *FileService) Upload(f *File) (int, error) {
 
 	if len(f.FileInfo.Extension) > 0 {
 		if _, ok := fs.Config.AllowedFileExtensions[f.FileInfo.Extension]; !ok {
-			return -1, httperror.New(
+			return httperror.New(
 				http.StatusUnprocessableEntity,
 				"The file type is not supported.",
 				fmt.Errorf("error during upload, the file type %s is not supported", f.FileInfo.Extension))
 		}
 	}
 
+	return nil
+}
+
+// Upload uploaded files will be saved at the configured file location, filename is saved in the database
+func (fs *FileService) Upload(f *File) (int, error) {
+	if err := f.validate(); err != nil {
+		return -1, err
+	}
+
+	f.FileInfo = SplitFilename(f.FullFilename)
+
+	if err := fs.checkType(f); err != nil {
+		return -1, err
+	}
+
 	f.UniqueName = f.randomFilename()
 
 	file, err := fs.GetByUniqueName(f.UniqueName, nil)
@@ -238,9 +259,88 @@ func (fs *FileService) Upload(f *File) (int, error) {
 
 	f.Data = nil
 
+	fs.AuditService.Record(f.Author, AuditCreate, AuditFile, i, "", f.auditSummary())
+
 	return i, nil
 }
 
+// Import saves an imported file like Upload; the unique name of the file is kept if it is not taken, otherwise
+// a number is appended to the name
+func (fs *FileService) Import(f *File) (int, error) {
+	if err := fs.prepareImport(f); err != nil {
+		return -1, err
+	}
+
+	fi := filepath.Join(fs.Config.Location, f.UniqueName)
+
+	if err := ioutil.WriteFile(fi, f.Data, 0640); err != nil {
+		return -1, err
+	}
+
+	f.Size = int64(len(f.Data))
+
+	i, err := fs.Datasource.Create(f)
+
+	if err != nil {
+		if err2 := os.Remove(fi); err2 != nil {
+			logger.Log.Error(err2)
+		}
+		return -1, err
+	}
+
+	f.Data = nil
+
+	fs.AuditService.Record(f.Author, AuditCreate, AuditFile, i, "", f.auditSummary())
+
+	return i, nil
+}
+
+// prepareImport validates the imported file and resolves its unique name without saving the file
+func (fs *FileService) prepareImport(f *File) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	f.FileInfo = SplitFilename(f.FullFilename)
+
+	if err := fs.checkType(f); err != nil {
+		return err
+	}
+
+	if len(f.UniqueName) > 0 {
+		f.FileInfo = SplitFilename(f.UniqueName)
+	}
+
+	name := f.randomFilename()
+
+	for i := 0; i < 10; i++ {
+		f.UniqueName = importName(name, i)
+
+		if _, err := fs.Datasource.GetByUniqueName(f.UniqueName, nil); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return httperror.New(
+		http.StatusUnprocessableEntity,
+		"A file with this filename already exist. Please choose another filename.",
+		fmt.Errorf("the file %s already exists", name))
+}
+
+// importName returns the name of an imported file with the number appended to the name, the first name has no number
+func importName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + strconv.Itoa(i) + ext
+}
+
 var filenameSubs = map[rune]string{
 	'/':  "",
 	'\\': "",
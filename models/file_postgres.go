@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"strings"
+	"time"
+)
+
+// PostgresFileDatasource providing an implementation of FileDatasourceService for PostgreSQL
+type PostgresFileDatasource struct {
+	SQLConn *sql.DB
+}
+
+// GetByUniqueName returns the file based on the unique filename; it the user is given and it is a non admin
+// only file specific to this user is returned
+func (rdb *PostgresFileDatasource) GetByUniqueName(uniqueName string, u *User) (*File, error) {
+	var stmt strings.Builder
+	var args []interface{}
+
+	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.inline, f.size, f.last_modified, f.user_id, ")
+	stmt.WriteString("u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM file as f ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON u.id = f.user_id ")
+	stmt.WriteString("WHERE f.unique_name=? ")
+
+	args = append(args, uniqueName)
+
+	if u != nil {
+		if !u.Can(PermFileEditAny) {
+			stmt.WriteString("AND f.user_id=? ")
+			args = append(args, u.ID)
+		}
+	}
+
+	var f File
+	var ru User
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), args...).Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &ru.ID,
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
+		return nil, err
+	}
+
+	f.Author = &ru
+
+	return &f, nil
+}
+
+// Get returns the file based on the filename; it the user is given and it is a non admin
+// only file specific to this user is returned
+func (rdb *PostgresFileDatasource) Get(fileID int, u *User) (*File, error) {
+	var stmt strings.Builder
+	var args []interface{}
+
+	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.inline, f.size, f.last_modified, f.user_id, ")
+	stmt.WriteString("u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM file as f ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON u.id = f.user_id ")
+	stmt.WriteString("WHERE f.id=? ")
+
+	args = append(args, fileID)
+
+	if u != nil {
+		if !u.Can(PermFileEditAny) {
+			stmt.WriteString("AND f.user_id=? ")
+			args = append(args, u.ID)
+		}
+	}
+
+	var f File
+	var ru User
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), args...).Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &ru.ID,
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
+		return nil, err
+	}
+
+	f.Author = &ru
+
+	return &f, nil
+}
+
+// Create inserts some file meta information into the database
+func (rdb *PostgresFileDatasource) Create(f *File) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO file (filename, unique_name, content_type, inline, size, last_modified, user_id) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		f.FullFilename, f.UniqueName, f.ContentType, f.Inline, f.Size, time.Now(), f.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+func (rdb *PostgresFileDatasource) Update(f *File) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE file SET filename=$1, unique_name=$2, content_type=$3, inline=$4, size=$5, last_modified=$6, user_id=$7 WHERE id=$8",
+		f.FullFilename, f.UniqueName, f.ContentType, f.Inline, f.Size, time.Now(), f.Author.ID, f.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// List returns a list of files based on the filename; it the user is given and it is a non admin
+// only files specific to this user are returned
+func (rdb *PostgresFileDatasource) List(u *User, p *Pagination) ([]File, error) {
+	var stmt strings.Builder
+
+	var args []interface{}
+
+	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.Inline, f.size, f.last_modified, ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
+	stmt.WriteString("FROM file as f ")
+	stmt.WriteString("INNER JOIN users as u ")
+	stmt.WriteString("ON f.user_id = u.id ")
+
+	if u != nil {
+		if !u.Can(PermFileEditAny) {
+			stmt.WriteString("WHERE f.user_id=? ")
+			args = append(args, u.ID)
+		}
+	}
+
+	stmt.WriteString("ORDER BY f.last_modified DESC ")
+
+	if p != nil {
+		stmt.WriteString("LIMIT ? OFFSET ? ")
+		args = append(args, p.Limit, p.Offset())
+	}
+
+	rows, err := rdb.SQLConn.Query(database.Rebind(stmt.String()), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var files []File
+
+	for rows.Next() {
+		var f File
+		var us User
+
+		if err = rows.Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &us.ID, &us.DisplayName,
+			&us.Username, &us.Email, &us.Role); err != nil {
+			return nil, err
+		}
+
+		f.Author = &us
+
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+
+}
+
+// Count returns a number of files based on the filename; it the user is given and it is a non admin
+// only files specific to this user are counted
+func (rdb *PostgresFileDatasource) Count(u *User) (int, error) {
+	var stmt strings.Builder
+
+	var args []interface{}
+
+	stmt.WriteString("SELECT count(id) FROM file ")
+
+	if u != nil {
+		if !u.Can(PermFileEditAny) {
+			stmt.WriteString("WHERE user_id = ?")
+			args = append(args, u.ID)
+		}
+	}
+
+	var total int
+
+	if err := rdb.SQLConn.QueryRow(database.Rebind(stmt.String()), args...).Scan(&total); err != nil {
+		return -1, err
+	}
+
+	return total, nil
+}
+
+// Delete deletes a file based on fileID; users which are not the owner are not allowed to remove files;
+// except admins
+func (rdb *PostgresFileDatasource) Delete(fileID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM file WHERE id=$1", fileID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -19,7 +19,7 @@ func (rdb *SQLiteFileDatasource) GetByUniqueName(uniqueName string, u *User) (*F
 	var args []interface{}
 
 	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.inline, f.size, f.last_modified, f.user_id, ")
-	stmt.WriteString("u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM file as f ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON u.id = f.user_id ")
@@ -28,7 +28,7 @@ func (rdb *SQLiteFileDatasource) GetByUniqueName(uniqueName string, u *User) (*F
 	args = append(args, uniqueName)
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermFileEditAny) {
 			stmt.WriteString("AND f.user_id=? ")
 			args = append(args, u.ID)
 		}
@@ -38,7 +38,7 @@ func (rdb *SQLiteFileDatasource) GetByUniqueName(uniqueName string, u *User) (*F
 	var ru User
 
 	if err := rdb.SQLConn.QueryRow(stmt.String(), args...).Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &ru.ID,
-		&ru.DisplayName, &ru.Username, &ru.Email, &ru.IsAdmin); err != nil {
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
 		return nil, err
 	}
 
@@ -54,7 +54,7 @@ func (rdb *SQLiteFileDatasource) Get(fileID int, u *User) (*File, error) {
 	var args []interface{}
 
 	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.inline, f.size, f.last_modified, f.user_id, ")
-	stmt.WriteString("u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM file as f ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON u.id = f.user_id ")
@@ -63,7 +63,7 @@ func (rdb *SQLiteFileDatasource) Get(fileID int, u *User) (*File, error) {
 	args = append(args, fileID)
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermFileEditAny) {
 			stmt.WriteString("AND f.user_id=? ")
 			args = append(args, u.ID)
 		}
@@ -73,7 +73,7 @@ func (rdb *SQLiteFileDatasource) Get(fileID int, u *User) (*File, error) {
 	var ru User
 
 	if err := rdb.SQLConn.QueryRow(stmt.String(), args...).Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &ru.ID,
-		&ru.DisplayName, &ru.Username, &ru.Email, &ru.IsAdmin); err != nil {
+		&ru.DisplayName, &ru.Username, &ru.Email, &ru.Role); err != nil {
 		return nil, err
 	}
 
@@ -117,13 +117,13 @@ func (rdb *SQLiteFileDatasource) List(u *User, p *Pagination) ([]File, error) {
 	var args []interface{}
 
 	stmt.WriteString("SELECT f.id, f.filename, f.unique_name, f.content_type, f.Inline, f.size, f.last_modified, ")
-	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.is_admin ")
+	stmt.WriteString("u.id, u.display_name, u.username, u.email, u.role ")
 	stmt.WriteString("FROM file as f ")
 	stmt.WriteString("INNER JOIN user as u ")
 	stmt.WriteString("ON f.user_id = u.id ")
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermFileEditAny) {
 			stmt.WriteString("WHERE f.user_id=? ")
 			args = append(args, u.ID)
 		}
@@ -149,12 +149,13 @@ func (rdb *SQLiteFileDatasource) List(u *User, p *Pagination) ([]File, error) {
 	}()
 
 	var files []File
-	var f File
-	var us User
 
 	for rows.Next() {
+		var f File
+		var us User
+
 		if err = rows.Scan(&f.ID, &f.FullFilename, &f.UniqueName, &f.ContentType, &f.Inline, &f.Size, &f.LastModified, &us.ID, &us.DisplayName,
-			&us.Username, &us.Email, &u.IsAdmin); err != nil {
+			&us.Username, &us.Email, &us.Role); err != nil {
 			return nil, err
 		}
 
@@ -181,7 +182,7 @@ func (rdb *SQLiteFileDatasource) Count(u *User) (int, error) {
 	stmt.WriteString("SELECT count(id) FROM file ")
 
 	if u != nil {
-		if !u.IsAdmin {
+		if !u.Can(PermFileEditAny) {
 			stmt.WriteString("WHERE user_id = ?")
 			args = append(args, u.ID)
 		}
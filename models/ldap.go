@@ -0,0 +1,263 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/settings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// LDAPAuthenticator checks the password by a bind against a directory server; the local user is created on the first
+// login and updated with the attributes of the directory on every login, so that articles still have an author
+type LDAPAuthenticator struct {
+	UserService *UserService
+	Config      settings.LDAP
+}
+
+// ldapEntry contains the attributes of the directory entry of the user
+type ldapEntry struct {
+	Mail        string
+	DisplayName string
+	Admin       bool
+}
+
+// Authenticate binds with the login name and the password of the user; the local user is returned
+func (la *LDAPAuthenticator) Authenticate(u *User, loginMethod settings.LoginMethod) (*User, error) {
+	login := u.Username
+
+	if loginMethod == settings.EMail {
+		login = u.Email
+	}
+
+	// an empty password would be an unauthenticated bind, which succeeds at most directory servers
+	if len(login) == 0 || len(u.PlainPassword) == 0 {
+		return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", errors.New("no username or password were given"))
+	}
+
+	e, err := la.bind(login, string(u.PlainPassword))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return la.sync(login, loginMethod, e)
+}
+
+func (la *LDAPAuthenticator) bind(login, password string) (*ldapEntry, error) {
+	conn, err := la.dial()
+
+	if err != nil {
+		return nil, httperror.New(http.StatusBadGateway,
+			"The directory server is not reachable. Please try again later.",
+			fmt.Errorf("could not connect to the ldap server %s %v", la.Config.URL, err))
+	}
+
+	defer conn.Close()
+
+	dn := strings.ReplaceAll(la.Config.BindDN, "{username}", ldap.EscapeDN(login))
+
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", fmt.Errorf("the ldap bind of %s failed %v", dn, err))
+		}
+
+		return nil, httperror.New(http.StatusBadGateway,
+			"The directory server is not reachable. Please try again later.",
+			fmt.Errorf("the ldap bind of %s failed %v", dn, err))
+	}
+
+	req := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(ldapTimeout.Seconds()), false,
+		"(objectClass=*)", []string{la.Config.MailAttribute, la.Config.DisplayNameAttribute, la.Config.GroupAttribute}, nil)
+
+	res, err := conn.Search(req)
+
+	if err != nil {
+		return nil, httperror.InternalServerError(fmt.Errorf("could not read the ldap entry %s %v", dn, err))
+	}
+
+	if len(res.Entries) != 1 {
+		return nil, httperror.InternalServerError(fmt.Errorf("expected one ldap entry %s, but got %d", dn, len(res.Entries)))
+	}
+
+	entry := res.Entries[0]
+
+	e := &ldapEntry{
+		Mail:        entry.GetAttributeValue(la.Config.MailAttribute),
+		DisplayName: entry.GetAttributeValue(la.Config.DisplayNameAttribute),
+	}
+
+	for _, g := range entry.GetAttributeValues(la.Config.GroupAttribute) {
+		if strings.EqualFold(g, la.Config.AdminGroup) {
+			e.Admin = true
+		}
+	}
+
+	return e, nil
+}
+
+func (la *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(la.Config.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &tls.Config{
+		ServerName: u.Hostname(),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(la.Config.CAFile) > 0 {
+		pem, err := os.ReadFile(la.Config.CAFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tc.RootCAs = x509.NewCertPool()
+
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", la.Config.CAFile)
+		}
+	}
+
+	conn, err := ldap.DialURL(la.Config.URL, ldap.DialWithTLSConfig(tc), ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(ldapTimeout)
+
+	if la.Config.StartTLS {
+		if err := conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// sync creates the local user on the first login or updates the email, display name and the role mapped by the admin group
+func (la *LDAPAuthenticator) sync(login string, loginMethod settings.LoginMethod, e *ldapEntry) (*User, error) {
+	us := la.UserService
+
+	var u *User
+	var err error
+
+	if loginMethod == settings.EMail {
+		u, err = us.GetByMail(login)
+	} else {
+		u, err = us.GetByUsername(login)
+	}
+
+	if err != nil {
+		var he *httperror.Error
+
+		if !errors.As(err, &he) || he.HTTPStatus != http.StatusNotFound {
+			return nil, err
+		}
+
+		return la.provision(login, e)
+	}
+
+	if !u.Active {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Your account is deactivated.",
+			fmt.Errorf("the user with id %d tried to logged in but the account is deactivated", u.ID))
+	}
+
+	u.Password = nil
+	u.Salt = nil
+
+	updated := *u
+
+	if len(e.Mail) > 0 {
+		updated.Email = e.Mail
+	}
+
+	if len(e.DisplayName) > 0 {
+		updated.DisplayName = e.DisplayName
+	}
+
+	if len(la.Config.AdminGroup) > 0 {
+		if e.Admin {
+			updated.Role = RoleAdmin
+		} else if u.Role == RoleAdmin {
+			updated.Role = Role(la.Config.DefaultRole)
+		}
+	}
+
+	if updated.Email == u.Email && updated.DisplayName == u.DisplayName && updated.Role == u.Role {
+		return u, nil
+	}
+
+	// the login is not denied if the directory is not in sync, e.g. the last administrator was removed from the admin group
+	if err := us.Update(&updated, nil, false); err != nil {
+		logger.Log.Errorf("could not update the user %s with the ldap attributes %v", u.Username, err)
+		return u, nil
+	}
+
+	return &updated, nil
+}
+
+// provision creates the user with a random password, which is never used as the password is checked by the directory server
+func (la *LDAPAuthenticator) provision(login string, e *ldapEntry) (*User, error) {
+	if len(e.Mail) == 0 {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Your account in the directory has no email address.",
+			fmt.Errorf("the ldap entry of %s has no attribute %s", login, la.Config.MailAttribute))
+	}
+
+	displayName := e.DisplayName
+
+	if len(displayName) == 0 {
+		displayName = login
+	}
+
+	role := Role(la.Config.DefaultRole)
+
+	if e.Admin {
+		role = RoleAdmin
+	}
+
+	u := &User{
+		Username:      login,
+		Email:         e.Mail,
+		DisplayName:   displayName,
+		Active:        true,
+		Role:          role,
+		PlainPassword: []byte(hex.EncodeToString(crypt.RandomSecureKey(16))),
+	}
+
+	id, err := la.UserService.Create(u, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Infof("the user %s with id %d was created by the ldap login", u.Username, id)
+
+	return la.UserService.GetByID(id)
+}
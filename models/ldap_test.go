@@ -0,0 +1,405 @@
+package models_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const ldapAdminGroup = "cn=admins,ou=groups,dc=example,dc=org"
+
+func TestLDAPAuthenticator(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	srv := newLDAPTestServer(t, map[string]ldapTestEntry{
+		"uid=alice,ou=people,dc=example,dc=org": {
+			password: "secret-alice",
+			attributes: map[string][]string{
+				"mail":        {"alice@example.org"},
+				"displayName": {"Alice Schneier"},
+				"memberOf":    {ldapAdminGroup},
+			},
+		},
+		"uid=bob,ou=people,dc=example,dc=org": {
+			password: "secret-bob",
+			attributes: map[string][]string{
+				"mail":        {"bob@example.org"},
+				"displayName": {"Bob Stallman"},
+			},
+		},
+	})
+
+	us := &models.UserService{
+		Datasource: &models.SQLiteUserDatasource{
+			SQLConn: db,
+		},
+		Config: settings.User{
+			MinPasswordLength: 12,
+		},
+	}
+
+	us.Authenticator = &models.LDAPAuthenticator{
+		UserService: us,
+		Config: settings.LDAP{
+			URL:                  "ldap://" + srv.Addr().String(),
+			BindDN:               "uid={username},ou=people,dc=example,dc=org",
+			MailAttribute:        "mail",
+			DisplayNameAttribute: "displayName",
+			GroupAttribute:       "memberOf",
+			AdminGroup:           ldapAdminGroup,
+			DefaultRole:          "author",
+		},
+	}
+
+	// the first login creates the local user
+	alice, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("secret-alice")}, settings.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alice.Email != "alice@example.org" || alice.DisplayName != "Alice Schneier" || alice.Role != models.RoleAdmin {
+		t.Errorf("expected the admin alice created from the directory, but got %s %s %s", alice.Email, alice.DisplayName, alice.Role)
+	}
+
+	bob, err := us.Authenticate(&models.User{Username: "bob", PlainPassword: []byte("secret-bob")}, settings.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bob.Role != models.RoleAuthor {
+		t.Errorf("expected the default role author, but got %s", bob.Role)
+	}
+
+	// the following logins update the local user
+	srv.setAttribute("uid=bob,ou=people,dc=example,dc=org", "displayName", "Robert Stallman")
+	srv.setAttribute("uid=bob,ou=people,dc=example,dc=org", "memberOf", ldapAdminGroup)
+
+	u, err := us.Authenticate(&models.User{Username: "bob", PlainPassword: []byte("secret-bob")}, settings.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.ID != bob.ID || u.DisplayName != "Robert Stallman" || u.Role != models.RoleAdmin {
+		t.Errorf("expected the updated admin bob with id %d, but got %d %s %s", bob.ID, u.ID, u.DisplayName, u.Role)
+	}
+
+	srv.setAttribute("uid=alice,ou=people,dc=example,dc=org", "memberOf")
+
+	u, err = us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("secret-alice")}, settings.Username)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Role != models.RoleAuthor {
+		t.Errorf("expected alice to be no admin after leaving the admin group, but got %s", u.Role)
+	}
+
+	if u.Password != nil || u.Salt != nil {
+		t.Error("expected that the password hash is not returned")
+	}
+
+	for _, tc := range []struct {
+		name     string
+		username string
+		password string
+		status   int
+	}{
+		{"wrong password", "alice", "secret-bob", http.StatusUnauthorized},
+		{"empty password", "alice", "", http.StatusUnauthorized},
+		{"unknown user", "mallory", "secret-mallory", http.StatusUnauthorized},
+		{"injection", "alice,ou=people,dc=example,dc=org", "secret-alice", http.StatusUnauthorized},
+	} {
+		_, err := us.Authenticate(&models.User{Username: tc.username, PlainPassword: []byte(tc.password)}, settings.Username)
+
+		var e *httperror.Error
+
+		if !errors.As(err, &e) || e.HTTPStatus != tc.status {
+			t.Errorf("%s: expected the status %d, but got %v", tc.name, tc.status, err)
+		}
+	}
+
+	srv.Close()
+
+	_, err = us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("secret-alice")}, settings.Username)
+
+	var e *httperror.Error
+
+	if !errors.As(err, &e) || e.HTTPStatus != http.StatusBadGateway {
+		t.Errorf("expected the status 502 for an unreachable directory, but got %v", err)
+	}
+}
+
+func TestSQLiteAuthenticator(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	us := &models.UserService{
+		Datasource: &models.SQLiteUserDatasource{
+			SQLConn: db,
+		},
+		Config: settings.User{
+			MinPasswordLength: 12,
+		},
+	}
+
+	createUser(t, us, "alice", "123456789012")
+
+	if _, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("123456789012")}, settings.Username); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("1234567890")}, settings.Username); err == nil {
+		t.Error("expected an error for a wrong password, but error is nil")
+	}
+}
+
+func TestSQLiteAuthenticatorRehash(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	us := &models.UserService{
+		Datasource: &models.SQLiteUserDatasource{
+			SQLConn: db,
+		},
+		Config: settings.User{
+			MinPasswordLength:       12,
+			PasswordHashMemory:      1024,
+			PasswordHashIterations:  1,
+			PasswordHashParallelism: 1,
+		},
+	}
+
+	// a user with a legacy bcrypt hash over the password and the salt
+	salt := crypt.GenerateSalt()
+	legacy, err := crypt.CryptPassword(append([]byte("123456789012"), salt...))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("INSERT INTO user (username, email, display_name, salt, password, active, role, last_modified) "+
+		"VALUES ('alice', 'alice@example.org', 'Alice', ?, ?, 1, 'admin', date('now'))", salt, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("123456789012")}, settings.Username); err != nil {
+		t.Fatal(err)
+	}
+
+	var hash, newSalt string
+
+	if err := db.QueryRow("SELECT password, salt FROM user WHERE username='alice'").Scan(&hash, &newSalt); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") || len(newSalt) != 0 {
+		t.Fatalf("expected the legacy hash to be replaced by an argon2id hash, but got %s with salt %q", hash, newSalt)
+	}
+
+	// the parameters are raised
+	us.Config.PasswordHashIterations = 2
+
+	if _, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("123456789012")}, settings.Username); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.QueryRow("SELECT password FROM user WHERE username='alice'").Scan(&hash); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Errorf("expected a rehash with the raised parameters, but got %s", hash)
+	}
+
+	if _, err := us.Authenticate(&models.User{Username: "alice", PlainPassword: []byte("123456789013")}, settings.Username); err == nil {
+		t.Error("expected an error for a wrong password, but error is nil")
+	}
+}
+
+func createUser(t *testing.T, us *models.UserService, username, password string) {
+	_, err := us.Create(&models.User{
+		Username:      username,
+		Email:         username + "@example.org",
+		DisplayName:   username,
+		Active:        true,
+		Role:          models.RoleAdmin,
+		PlainPassword: []byte(password),
+	}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ldapTestServer is an in-process directory server supporting simple binds and base object searches of the bound entry
+type ldapTestServer struct {
+	net.Listener
+
+	mutex   sync.Mutex
+	entries map[string]ldapTestEntry
+}
+
+type ldapTestEntry struct {
+	password   string
+	attributes map[string][]string
+}
+
+const (
+	ldapBindRequest        = 0
+	ldapBindResponse       = 1
+	ldapUnbindRequest      = 2
+	ldapSearchRequest      = 3
+	ldapSearchEntry        = 4
+	ldapSearchDone         = 5
+	ldapSuccess            = 0
+	ldapNoSuchObject       = 32
+	ldapInvalidCredentials = 49
+)
+
+func newLDAPTestServer(t *testing.T, entries map[string]ldapTestEntry) *ldapTestServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &ldapTestServer{
+		Listener: l,
+		entries:  entries,
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go srv.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() { l.Close() })
+
+	return srv
+}
+
+func (srv *ldapTestServer) setAttribute(dn, name string, values ...string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	srv.entries[dn].attributes[name] = values
+}
+
+func (srv *ldapTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var bound string
+
+	for {
+		p, err := ber.ReadPacket(conn)
+
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldapBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+
+			srv.mutex.Lock()
+			e, ok := srv.entries[dn]
+			srv.mutex.Unlock()
+
+			code := ldapInvalidCredentials
+
+			if ok && len(password) > 0 && e.password == password {
+				bound = dn
+				code = ldapSuccess
+			}
+
+			srv.write(conn, id, ldapResult(ldapBindResponse, code))
+		case ldapSearchRequest:
+			base := op.Children[0].Data.String()
+
+			srv.mutex.Lock()
+			e, ok := srv.entries[base]
+
+			if ok && strings.EqualFold(base, bound) {
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchEntry, nil, "Search Result Entry")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, base, "Object Name"))
+
+				attributes := ber.NewSequence("Attributes")
+
+				for name, values := range e.attributes {
+					if len(values) == 0 {
+						continue
+					}
+
+					attr := ber.NewSequence("Attribute")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+					vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+
+					for _, v := range values {
+						vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+					}
+
+					attr.AppendChild(vals)
+					attributes.AppendChild(attr)
+				}
+
+				entry.AppendChild(attributes)
+				srv.write(conn, id, entry)
+			}
+			srv.mutex.Unlock()
+
+			code := ldapSuccess
+
+			if !ok {
+				code = ldapNoSuchObject
+			}
+
+			srv.write(conn, id, ldapResult(ldapSearchDone, code))
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+func (srv *ldapTestServer) write(conn net.Conn, id int64, op *ber.Packet) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+
+	_, _ = conn.Write(p.Bytes())
+}
+
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+
+	return p
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+// LoginThrottleDatasourceService defines an interface for storing failed attempts
+type LoginThrottleDatasourceService interface {
+	Get(key string) (*LoginThrottle, error)
+	Save(lt *LoginThrottle) error
+	ListLocked(now time.Time) ([]LoginThrottle, error)
+	Remove(key string) error
+}
+
+const (
+	// freeAttempts is the number of failures before further attempts are delayed
+	freeAttempts = 3
+	// failureWindow is the time after the last failure until the failures are forgotten
+	failureWindow = time.Hour
+)
+
+const (
+	throttleLogin   = "login:"
+	throttleIP      = "ip:"
+	throttleRequest = "request:"
+)
+
+// LoginThrottle contains the failed attempts of a login name or a client ip address; further attempts are
+// rejected until BlockedUntil. Locked is set if the account was locked after too many failed logins
+type LoginThrottle struct {
+	Key          string
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+	Locked       bool
+}
+
+// Login returns the login name of a locked account
+func (lt LoginThrottle) Login() string {
+	return strings.TrimPrefix(lt.Key, throttleLogin)
+}
+
+// LoginThrottleService delays attempts with an exponential backoff after some failures and locks accounts temporarily;
+// failures are tracked per login name and per client ip address
+type LoginThrottleService struct {
+	Datasource LoginThrottleDatasourceService
+	Config     settings.User
+
+	mutex sync.Mutex
+}
+
+// CheckLogin returns an error if the login name or the client ip address is blocked
+func (lts *LoginThrottleService) CheckLogin(login, ip string) error {
+	if err := lts.check(throttleLogin + strings.ToLower(login)); err != nil {
+		return err
+	}
+
+	return lts.check(throttleIP + ip)
+}
+
+// LoginFailed records a failed login; locked is true if the account was locked by this failure
+func (lts *LoginThrottleService) LoginFailed(login, ip string) (locked bool, err error) {
+	locked, err = lts.failure(throttleLogin+strings.ToLower(login), lts.Config.LoginMaxFailures)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := lts.failure(throttleIP+ip, 0); err != nil {
+		return false, err
+	}
+
+	return locked, nil
+}
+
+// LoginSucceeded resets the failed logins of the login name; failures of the ip address expire by themselves
+func (lts *LoginThrottleService) LoginSucceeded(login string) error {
+	return lts.Datasource.Remove(throttleLogin + strings.ToLower(login))
+}
+
+// CheckRequest returns an error if the client ip address is blocked from requesting password resets or activating accounts
+func (lts *LoginThrottleService) CheckRequest(ip string) error {
+	return lts.check(throttleRequest + ip)
+}
+
+// CountRequest counts a requested password reset or an invalid token as failed attempt of the client ip address
+func (lts *LoginThrottleService) CountRequest(ip string) error {
+	_, err := lts.failure(throttleRequest+ip, 0)
+	return err
+}
+
+// ListLocked returns the currently locked accounts
+func (lts *LoginThrottleService) ListLocked() ([]LoginThrottle, error) {
+	return lts.Datasource.ListLocked(time.Now())
+}
+
+// Unlock unlocks the account with the login name and resets the failed logins
+func (lts *LoginThrottleService) Unlock(login string) error {
+	return lts.Datasource.Remove(throttleLogin + strings.ToLower(login))
+}
+
+func (lts *LoginThrottleService) check(key string) error {
+	lt, err := lts.Datasource.Get(key)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+
+	if !now.Before(lt.BlockedUntil) {
+		return nil
+	}
+
+	if lt.Locked {
+		return httperror.New(http.StatusTooManyRequests,
+			fmt.Sprintf("Your account is locked due to too many failed logins. Please try again at %s.", lt.BlockedUntil.Format("15:04")),
+			fmt.Errorf("the account %s is locked until %s after %d failures", lt.Login(), lt.BlockedUntil, lt.Failures))
+	}
+
+	retry := lt.BlockedUntil.Sub(now).Round(time.Second)
+
+	if retry < time.Second {
+		retry = time.Second
+	}
+
+	return httperror.New(http.StatusTooManyRequests,
+		fmt.Sprintf("Too many failed attempts. Please try again in %s.", retry),
+		fmt.Errorf("%s is blocked for %s after %d failures", key, retry, lt.Failures))
+}
+
+// failure increments the failures of the key; the key is locked if maxFailures is reached, otherwise blocked with an
+// exponential backoff
+func (lts *LoginThrottleService) failure(key string, maxFailures int) (bool, error) {
+	lts.mutex.Lock()
+	defer lts.mutex.Unlock()
+
+	lt, err := lts.Datasource.Get(key)
+
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		}
+
+		lt = &LoginThrottle{Key: key}
+	}
+
+	now := time.Now()
+
+	if now.Sub(lt.LastFailure) > failureWindow || (lt.Locked && !now.Before(lt.BlockedUntil)) {
+		lt.Failures = 0
+		lt.Locked = false
+	}
+
+	lt.Failures++
+	lt.LastFailure = now
+
+	locked := false
+
+	if maxFailures > 0 && lt.Failures >= maxFailures {
+		locked = !lt.Locked
+		lt.Locked = true
+		lt.BlockedUntil = now.Add(lts.Config.LoginLockoutDuration)
+	} else {
+		lt.BlockedUntil = now.Add(lts.backoff(lt.Failures))
+	}
+
+	if err := lts.Datasource.Save(lt); err != nil {
+		return false, err
+	}
+
+	return locked, nil
+}
+
+// backoff returns the delay after the failures; it doubles with every failure after the free attempts
+func (lts *LoginThrottleService) backoff(failures int) time.Duration {
+	if failures <= freeAttempts {
+		return 0
+	}
+
+	d := time.Second
+
+	for i := freeAttempts + 1; i < failures && d < lts.Config.LoginBackoffMax; i++ {
+		d *= 2
+	}
+
+	if d > lts.Config.LoginBackoffMax {
+		return lts.Config.LoginBackoffMax
+	}
+
+	return d
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PostgresLoginThrottleDatasource providing an implementation of LoginThrottleDatasourceService for PostgreSQL
+type PostgresLoginThrottleDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Get returns the failed attempts of the key
+func (rdb *PostgresLoginThrottleDatasource) Get(key string) (*LoginThrottle, error) {
+	var lt LoginThrottle
+
+	if err := rdb.SQLConn.QueryRow("SELECT key, failures, last_failure, blocked_until, locked FROM login_throttle WHERE key=$1", key).
+		Scan(&lt.Key, &lt.Failures, &lt.LastFailure, &lt.BlockedUntil, &lt.Locked); err != nil {
+		return nil, err
+	}
+
+	return &lt, nil
+}
+
+// Save inserts or replaces the failed attempts of the key
+func (rdb *PostgresLoginThrottleDatasource) Save(lt *LoginThrottle) error {
+	if _, err := rdb.SQLConn.Exec("INSERT INTO login_throttle (key, failures, last_failure, blocked_until, locked) VALUES($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (key) DO UPDATE SET failures=excluded.failures, last_failure=excluded.last_failure, "+
+		"blocked_until=excluded.blocked_until, locked=excluded.locked",
+		lt.Key, lt.Failures, lt.LastFailure, lt.BlockedUntil, lt.Locked); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListLocked returns the keys which are locked at the given time
+func (rdb *PostgresLoginThrottleDatasource) ListLocked(now time.Time) ([]LoginThrottle, error) {
+	rows, err := rdb.SQLConn.Query("SELECT key, failures, last_failure, blocked_until, locked FROM login_throttle "+
+		"WHERE locked=$1 AND blocked_until > $2 ORDER BY last_failure DESC", true, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var throttles []LoginThrottle
+
+	for rows.Next() {
+		var lt LoginThrottle
+
+		if err = rows.Scan(&lt.Key, &lt.Failures, &lt.LastFailure, &lt.BlockedUntil, &lt.Locked); err != nil {
+			return nil, err
+		}
+
+		throttles = append(throttles, lt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+// Remove removes the failed attempts of the key
+func (rdb *PostgresLoginThrottleDatasource) Remove(key string) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM login_throttle WHERE key=$1", key); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// SQLiteLoginThrottleDatasource providing an implementation of LoginThrottleDatasourceService for SQLite
+type SQLiteLoginThrottleDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Get returns the failed attempts of the key
+func (rdb *SQLiteLoginThrottleDatasource) Get(key string) (*LoginThrottle, error) {
+	var lt LoginThrottle
+
+	if err := rdb.SQLConn.QueryRow("SELECT key, failures, last_failure, blocked_until, locked FROM login_throttle WHERE key=?", key).
+		Scan(&lt.Key, &lt.Failures, &lt.LastFailure, &lt.BlockedUntil, &lt.Locked); err != nil {
+		return nil, err
+	}
+
+	return &lt, nil
+}
+
+// Save inserts or replaces the failed attempts of the key
+func (rdb *SQLiteLoginThrottleDatasource) Save(lt *LoginThrottle) error {
+	if _, err := rdb.SQLConn.Exec("INSERT OR REPLACE INTO login_throttle (key, failures, last_failure, blocked_until, locked) VALUES(?, ?, ?, ?, ?)",
+		lt.Key, lt.Failures, lt.LastFailure, lt.BlockedUntil, lt.Locked); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListLocked returns the keys which are locked at the given time
+func (rdb *SQLiteLoginThrottleDatasource) ListLocked(now time.Time) ([]LoginThrottle, error) {
+	rows, err := rdb.SQLConn.Query("SELECT key, failures, last_failure, blocked_until, locked FROM login_throttle "+
+		"WHERE locked=? AND blocked_until > ? ORDER BY last_failure DESC", true, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var throttles []LoginThrottle
+
+	for rows.Next() {
+		var lt LoginThrottle
+
+		if err = rows.Scan(&lt.Key, &lt.Failures, &lt.LastFailure, &lt.BlockedUntil, &lt.Locked); err != nil {
+			return nil, err
+		}
+
+		throttles = append(throttles, lt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+// Remove removes the failed attempts of the key
+func (rdb *SQLiteLoginThrottleDatasource) Remove(key string) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM login_throttle WHERE key=?", key); err != nil {
+		return err
+	}
+
+	return nil
+}
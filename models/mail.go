@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"git.hoogi.eu/snafu/go-blog/mail"
 	"git.hoogi.eu/snafu/go-blog/settings"
@@ -15,10 +16,56 @@ type Mailer struct {
 func (m *Mailer) SendActivationLink(ui *UserInvite) {
 	activation := m.AppConfig.Domain + "/admin/activate-account/" + ui.Hash
 
+	body := fmt.Sprintf("Hi %s,\n\n you are invited join %s. To activate your account click the following link and enter a password %s", ui.DisplayName, m.AppConfig.Title, activation)
+
+	if !ui.ExpiresAt.IsZero() {
+		body += fmt.Sprintf("\n\nThe invitation expires on %s.", ui.ExpiresAt.Format("January 2, 2006 at 3:04 PM"))
+	}
+
 	ml := mail.Mail{
 		To:      ui.Email,
 		Subject: "You got an invitation",
-		Body:    fmt.Sprintf("Hi %s,\n\n you are invited join %s. To activate your account click the following link and enter a password %s", ui.DisplayName, m.AppConfig.Title, activation),
+		Body:    body,
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendInviteReminder(ui *UserInvite) {
+	activation := m.AppConfig.Domain + "/admin/activate-account/" + ui.Hash
+
+	ml := mail.Mail{
+		To:      ui.Email,
+		Subject: "Your invitation expires soon",
+		Body: fmt.Sprintf("Hi %s,\n\nyour invitation to %s expires on %s. To activate your account click the following link and enter a password %s",
+			ui.DisplayName, m.AppConfig.Title, ui.ExpiresAt.Format("January 2, 2006 at 3:04 PM"), activation),
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendDormancyWarning(u *User, deactivateAt time.Time) {
+	login := m.AppConfig.Domain + "/admin/login"
+
+	ml := mail.Mail{
+		To:      u.Email,
+		Subject: "Your account will be deactivated",
+		Body: fmt.Sprintf("Hi %s,\n\nyou did not log in to %s for a long time. Your account will be deactivated on %s. Log in to keep your account %s",
+			u.DisplayName, m.AppConfig.Title, deactivateAt.Format("January 2, 2006 at 3:04 PM"), login),
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendNewDeviceLogin(u *User, d *UserDevice, t *Token) {
+	secure := m.AppConfig.Domain + "/admin/secure-account/" + t.Hash
+
+	ml := mail.Mail{
+		To:      u.Email,
+		Subject: "New login to your account",
+		Body: fmt.Sprintf("Hi %s,\n\nyour account at %s was logged in from a new device.\n\nTime: %s\nIP address: %s\nBrowser: %s\n\n"+
+			"If this was you, you can ignore this mail. Otherwise click the following link to log out all sessions and reset your password %s",
+			u.DisplayName, m.AppConfig.Title, time.Now().Format("January 2, 2006 at 3:04 PM"), d.IP, d.UserAgent, secure),
 	}
 
 	m.Sender.SendAsync(ml)
@@ -45,3 +92,54 @@ func (m *Mailer) SendPasswordResetLink(u *User, t *Token) {
 
 	m.Sender.SendAsync(ml)
 }
+
+func (m *Mailer) SendAccountLocked(u *User, d time.Duration) {
+	ml := mail.Mail{
+		To:      u.Email,
+		Subject: "Your account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nyour account was locked for %s after too many failed logins. "+
+			"If you did not try to log in, someone else may be guessing your password. Please contact an administrator.", u.DisplayName, d),
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendEmailChangeLink(u *User, t *Token) {
+	confirmLink := m.AppConfig.Domain + "/admin/confirm-email/" + t.Hash
+
+	ml := mail.Mail{
+		To:      t.Payload,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nuse the following link to confirm your new email address for %s:\n\n%s\n\n"+
+			"Your previous email address stays active until the new one is confirmed.", u.DisplayName, m.AppConfig.Title, confirmLink),
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendEmailChanged(u *User, email string, t *Token) {
+	revertLink := m.AppConfig.Domain + "/admin/revert-email/" + t.Hash
+
+	ml := mail.Mail{
+		To:      u.Email,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nthe email address of your account was changed to %s. "+
+			"If you did not change it, use the following link to restore this email address:\n\n%s", u.DisplayName, email, revertLink),
+	}
+
+	m.Sender.SendAsync(ml)
+}
+
+func (m *Mailer) SendMagicLink(u *User, t *Token, expiry time.Duration) {
+	signInLink := m.AppConfig.Domain + "/admin/login/magic-link/" + t.Hash
+
+	ml := mail.Mail{
+		To:      u.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\nuse the following link to sign in to %s:\n\n%s\n\n"+
+			"The link is valid for %s and can only be used once in the browser where you requested it. "+
+			"If you did not request it, you can ignore this mail.", u.DisplayName, m.AppConfig.Title, signInLink, expiry),
+	}
+
+	m.Sender.SendAsync(ml)
+}
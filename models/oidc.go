@@ -0,0 +1,227 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/settings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCCallbackPath is the path the identity provider redirects to after the login
+const OIDCCallbackPath = "/admin/login/oidc/callback"
+
+// OIDCLogin contains the values of a started login, which have to be kept in the session until the callback is received
+type OIDCLogin struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// oidcClaims are the claims of the ID token used to find or create the user
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// OIDCService containing the service to sign in users with an OpenID Connect identity provider
+// using the authorization code flow with PKCE
+type OIDCService struct {
+	UserService *UserService
+	AppConfig   settings.Application
+	Config      settings.OIDC
+
+	mutex    sync.Mutex
+	provider *oidc.Provider
+}
+
+// Begin starts a new login; the returned URL is the authorization endpoint of the identity provider
+func (ocs *OIDCService) Begin(ctx context.Context) (string, *OIDCLogin, error) {
+	oc, _, err := ocs.oauth2Config(ctx)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	l := &OIDCLogin{
+		State:    hex.EncodeToString(crypt.RandomSecureKey(32)),
+		Nonce:    hex.EncodeToString(crypt.RandomSecureKey(32)),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	return oc.AuthCodeURL(l.State, oidc.Nonce(l.Nonce), oauth2.S256ChallengeOption(l.Verifier)), l, nil
+}
+
+// Finish exchanges the authorization code and returns the user matching the email claim of the ID token;
+// unknown users are created if auto provisioning is enabled
+func (ocs *OIDCService) Finish(ctx context.Context, l *OIDCLogin, state, code string) (*User, error) {
+	if l == nil || len(state) == 0 || subtle.ConstantTimeCompare([]byte(l.State), []byte(state)) != 1 {
+		return nil, httperror.New(http.StatusUnauthorized, "The login has expired. Please try again.", errors.New("the oidc state does not match"))
+	}
+
+	oc, verifier, err := ocs.oauth2Config(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oc.Exchange(ctx, code, oauth2.VerifierOption(l.Verifier))
+
+	if err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The login at the identity provider failed.", fmt.Errorf("could not exchange the oidc authorization code %v", err))
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+
+	if !ok {
+		return nil, httperror.New(http.StatusUnauthorized, "The login at the identity provider failed.", errors.New("the token response contains no id token"))
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+
+	if err != nil {
+		return nil, httperror.New(http.StatusUnauthorized, "The login at the identity provider failed.", fmt.Errorf("could not verify the id token %v", err))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(l.Nonce)) != 1 {
+		return nil, httperror.New(http.StatusUnauthorized, "The login at the identity provider failed.", errors.New("the nonce of the id token does not match"))
+	}
+
+	var claims oidcClaims
+
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// some identity providers do not send the email_verified claim; a missing claim is only accepted if configured
+	verified := claims.EmailVerified != nil && *claims.EmailVerified
+
+	if claims.EmailVerified == nil && ocs.Config.TrustUnverifiedEmail {
+		verified = true
+	}
+
+	if len(claims.Email) == 0 || !verified {
+		return nil, httperror.New(http.StatusUnauthorized,
+			"The identity provider returned no verified email address.",
+			fmt.Errorf("the id token of subject %s contains no verified email", idToken.Subject))
+	}
+
+	u, err := ocs.UserService.GetByMail(claims.Email)
+
+	if err != nil {
+		var e *httperror.Error
+
+		if !errors.As(err, &e) || e.HTTPStatus != http.StatusNotFound {
+			return nil, err
+		}
+
+		if !ocs.Config.AutoProvision {
+			return nil, httperror.New(http.StatusUnauthorized,
+				"There is no account for your email address.",
+				fmt.Errorf("no user found for the oidc email %s", claims.Email))
+		}
+
+		return ocs.provision(claims)
+	}
+
+	if !u.Active {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Your account is deactivated.",
+			fmt.Errorf("the user with id %d tried to logged in with oidc but the account is deactivated", u.ID))
+	}
+
+	u.Password = nil
+	u.Salt = nil
+
+	return u, nil
+}
+
+// provision creates an user for the claims with the default role; a random password is set, which is never shown
+func (ocs *OIDCService) provision(claims oidcClaims) (*User, error) {
+	username := claims.PreferredUsername
+
+	if len(username) == 0 {
+		username = claims.Email
+	}
+
+	displayName := claims.Name
+
+	if len(displayName) == 0 {
+		displayName = username
+	}
+
+	u := &User{
+		Username:      username,
+		Email:         claims.Email,
+		DisplayName:   displayName,
+		Active:        true,
+		Role:          Role(ocs.Config.DefaultRole),
+		PlainPassword: []byte(hex.EncodeToString(crypt.RandomSecureKey(16))),
+	}
+
+	id, err := ocs.UserService.Create(u, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Infof("the user %s with id %d was created by the oidc login", u.Username, id)
+
+	return ocs.UserService.GetByID(id)
+}
+
+// oauth2Config discovers the identity provider on first use, so that go-blog can start if the provider is not reachable
+func (ocs *OIDCService) oauth2Config(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !ocs.Config.Enabled {
+		return nil, nil, httperror.New(http.StatusNotFound, "The single sign-on is not enabled.", errors.New("oidc is disabled"))
+	}
+
+	ocs.mutex.Lock()
+	defer ocs.mutex.Unlock()
+
+	if ocs.provider == nil {
+		// the provider keeps the context for fetching the signing keys later on, so it must not be bound to the request
+		p, err := oidc.NewProvider(context.Background(), ocs.Config.Issuer)
+
+		if err != nil {
+			return nil, nil, httperror.New(http.StatusBadGateway,
+				"The identity provider is not reachable. Please try again later.",
+				fmt.Errorf("could not discover the oidc provider %s %v", ocs.Config.Issuer, err))
+		}
+
+		ocs.provider = p
+	}
+
+	redirectURL := ocs.Config.RedirectURL
+
+	if len(redirectURL) == 0 {
+		redirectURL = strings.TrimSuffix(ocs.AppConfig.Domain, "/") + OIDCCallbackPath
+	}
+
+	oc := &oauth2.Config{
+		ClientID:     ocs.Config.ClientID,
+		ClientSecret: ocs.Config.ClientSecret,
+		Endpoint:     ocs.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+
+	return oc, ocs.provider.Verifier(&oidc.Config{ClientID: ocs.Config.ClientID}), nil
+}
@@ -8,15 +8,40 @@ import (
 	"fmt"
 	"html/template"
 	"math"
+	"net/url"
 	"strings"
 )
 
-// Pagination type is used to provide a page selector
+// Pagination type is used to provide a page selector;
+// the optional query is appended to the page links, e.g. to keep filter values
 type Pagination struct {
 	Total       int
 	Limit       int
 	CurrentPage int
 	RelURL      string
+	Query       url.Values
+}
+
+// PaginationMeta contains the pagination information returned by the JSON API
+type PaginationMeta struct {
+	Total       int  `json:"total"`
+	Limit       int  `json:"limit"`
+	CurrentPage int  `json:"current_page"`
+	Pages       int  `json:"pages"`
+	HasNext     bool `json:"has_next"`
+	HasPrevious bool `json:"has_previous"`
+}
+
+// Meta returns the pagination information for JSON responses
+func (p *Pagination) Meta() *PaginationMeta {
+	return &PaginationMeta{
+		Total:       p.Total,
+		Limit:       p.Limit,
+		CurrentPage: p.CurrentPage,
+		Pages:       p.pages(),
+		HasNext:     p.hasNext(),
+		HasPrevious: p.hasPrevious(),
+	}
 }
 
 // Offset returns the offset where to start
@@ -32,6 +57,17 @@ func (p *Pagination) url() string {
 	return "/" + p.RelURL
 }
 
+// pageURL returns the escaped url of the page including the query
+func (p *Pagination) pageURL(page int) string {
+	u := fmt.Sprintf("%s/%d", p.url(), page)
+
+	if len(p.Query) > 0 {
+		u += "?" + p.Query.Encode()
+	}
+
+	return template.HTMLEscapeString(u)
+}
+
 // pages returns the amount of pages
 func (p *Pagination) pages() int {
 	return int(math.Ceil(float64(p.Total) / float64(p.Limit)))
@@ -81,21 +117,21 @@ func (p *Pagination) PaginationBar() template.HTML {
 		if !p.hasPrevious() {
 			sb.WriteString(`<a class="button button-inactive" href="#">&laquo; Backward</a>`)
 		} else {
-			sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s/%d">&laquo; Backward</a>`, p.url(), p.previousPage()))
+			sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s">&laquo; Backward</a>`, p.pageURL(p.previousPage())))
 		}
 
 		for i := 1; i <= p.pages(); i++ {
 			if p.CurrentPage == i {
 				sb.WriteString(fmt.Sprintf(`<a class="button button-inactive" href="#">%d</a>`, i))
 			} else {
-				sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s/%d">%d</a>`, p.url(), i, i))
+				sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s">%d</a>`, p.pageURL(i), i))
 			}
 		}
 
 		if !p.hasNext() {
 			sb.WriteString(`<a class="button button-inactive" href="#">Forward &raquo;</a>`)
 		} else {
-			sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s/%d">Forward &raquo;</a>`, p.url(), p.nextPage()))
+			sb.WriteString(fmt.Sprintf(`<a class="button button-active" href="%s">Forward &raquo;</a>`, p.pageURL(p.nextPage())))
 		}
 
 		sb.WriteString(`</div>`)
@@ -0,0 +1,264 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PersonalDataService exports the personal data of an user and removes accounts on request of the user
+type PersonalDataService struct {
+	UserService    *UserService
+	ArticleService *ArticleService
+	SiteService    *SiteService
+	FileService    *FileService
+}
+
+// personalProfile is the profile of the user in the export
+type personalProfile struct {
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	DisplayName      string    `json:"display_name"`
+	Role             Role      `json:"role"`
+	Active           bool      `json:"active"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	Bio              string    `json:"bio"`
+	Website          string    `json:"website"`
+	Avatar           string    `json:"avatar"`
+	SocialLinks      []string  `json:"social_links"`
+	LastModified     time.Time `json:"last_modified"`
+}
+
+// personalFile is the metadata of a file in the export
+type personalFile struct {
+	Filename     string    `json:"filename"`
+	UniqueName   string    `json:"unique_name"`
+	ContentType  string    `json:"content_type"`
+	Inline       bool      `json:"inline"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Path         string    `json:"path"`
+}
+
+// Export writes a ZIP archive with the profile, the articles and sites as markdown, the metadata of the files and
+// the files of the user
+func (pds *PersonalDataService) Export(w io.Writer, u *User) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeJSONEntry(zw, "profile.json", personalProfile{
+		Username:         u.Username,
+		Email:            u.Email,
+		DisplayName:      u.DisplayName,
+		Role:             u.Role,
+		Active:           u.Active,
+		TwoFactorEnabled: u.TOTPEnabled,
+		Bio:              u.Bio,
+		Website:          u.Website,
+		Avatar:           u.Avatar,
+		SocialLinks:      u.SocialLinks,
+		LastModified:     u.LastModified,
+	}); err != nil {
+		return err
+	}
+
+	articles, err := pds.ArticleService.ListByAuthor(u, nil, All)
+
+	if err != nil {
+		return err
+	}
+
+	for _, a := range articles {
+		name := fmt.Sprintf("articles/%d-%s.md", a.ID, path.Base(a.Slug))
+
+		if err := writeMarkdownEntry(zw, name, [][2]string{
+			{"title", fmt.Sprintf("%q", a.Headline)},
+			{"slug", fmt.Sprintf("%q", a.Slug)},
+			{"category", fmt.Sprintf("%q", a.CName.String)},
+			{"published", fmt.Sprintf("%t", a.Published)},
+			{"published_on", formatNullTime(a.PublishedOn)},
+			{"last_modified", a.LastModified.Format(time.RFC3339)},
+		}, a.Teaser+"\n\n"+a.Content); err != nil {
+			return err
+		}
+	}
+
+	sites, err := pds.SiteService.List(All, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sites {
+		if s.Author == nil || s.Author.ID != u.ID {
+			continue
+		}
+
+		name := fmt.Sprintf("sites/%d-%s.md", s.ID, sanitizeFilename(s.Link))
+
+		if err := writeMarkdownEntry(zw, name, [][2]string{
+			{"title", fmt.Sprintf("%q", s.Title)},
+			{"link", fmt.Sprintf("%q", s.Link)},
+			{"section", fmt.Sprintf("%q", s.Section)},
+			{"published", fmt.Sprintf("%t", s.Published)},
+			{"published_on", formatNullTime(s.PublishedOn)},
+			{"last_modified", s.LastModified.Format(time.RFC3339)},
+		}, s.Content); err != nil {
+			return err
+		}
+	}
+
+	files, err := pds.FileService.List(authorOnly(u), nil)
+
+	if err != nil {
+		return err
+	}
+
+	meta := []personalFile{}
+
+	for _, f := range files {
+		p := "files/" + f.UniqueName
+
+		meta = append(meta, personalFile{
+			Filename:     f.FullFilename,
+			UniqueName:   f.UniqueName,
+			ContentType:  f.ContentType,
+			Inline:       f.Inline,
+			Size:         f.Size,
+			LastModified: f.LastModified,
+			Path:         p,
+		})
+
+		if err := writeFileEntry(zw, pds.FileService.Config.Location, p, f); err != nil {
+			return err
+		}
+	}
+
+	if err := writeJSONEntry(zw, "files.json", meta); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// RemoveAccount removes the account of the user. If a successor is given the articles, sites and files are transferred
+// to the successor, otherwise the files are removed from the disk after the remaining content was removed along with the user
+func (pds *PersonalDataService) RemoveAccount(u *User, successor *User, actor *User) error {
+	var files []File
+
+	if successor == nil {
+		var err error
+
+		files, err = pds.FileService.List(authorOnly(u), nil)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := pds.UserService.RemoveAccount(u, successor, actor); err != nil {
+		return err
+	}
+
+	var failed []string
+
+	for _, f := range files {
+		pds.FileService.AuditService.Record(actor, AuditDelete, AuditFile, f.ID, f.auditSummary(), "")
+
+		if err := os.Remove(filepath.Join(pds.FileService.Config.Location, f.UniqueName)); err != nil && !os.IsNotExist(err) {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return httperror.New(http.StatusInternalServerError,
+			"Your account was removed, but not all of your files could be removed. Please contact an administrator.",
+			fmt.Errorf("the account of user %d was removed, but not all files: %s", u.ID, strings.Join(failed, ", ")))
+	}
+
+	return nil
+}
+
+// writeFileEntry writes the content of the file in the file location; missing files are skipped
+func writeFileEntry(zw *zip.Writer, location, name string, f File) error {
+	fh, err := os.Open(filepath.Join(location, f.UniqueName))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Log.Warnf("the file %s of the export is missing on the disk", f.UniqueName)
+			return nil
+		}
+		return err
+	}
+
+	defer fh.Close()
+
+	ew, err := zw.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(ew, fh)
+
+	return err
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	ew, err := zw.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(ew)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// writeMarkdownEntry writes the markdown with the fields as front matter
+func writeMarkdownEntry(zw *zip.Writer, name string, fields [][2]string, markdown string) error {
+	ew, err := zw.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+
+	b.WriteString("---\n")
+
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+	}
+
+	b.WriteString("---\n\n")
+	b.WriteString(markdown)
+	b.WriteString("\n")
+
+	_, err = io.WriteString(ew, b.String())
+
+	return err
+}
+
+func formatNullTime(nt NullTime) string {
+	if !nt.Valid {
+		return ""
+	}
+
+	return nt.Time.Format(time.RFC3339)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// PostgresRecoveryCodeDatasource providing an implementation of RecoveryCodeDatasourceService for PostgreSQL
+type PostgresRecoveryCodeDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create creates a new recovery code
+func (rdb *PostgresRecoveryCodeDatasource) Create(rc *RecoveryCode) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO recovery_code (hash, created_at, user_id) VALUES($1, $2, $3) RETURNING id",
+		rc.Hash, time.Now(), rc.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// ListByUser returns all unused recovery codes of the user
+func (rdb *PostgresRecoveryCodeDatasource) ListByUser(userID int) ([]RecoveryCode, error) {
+	rows, err := rdb.SQLConn.Query("SELECT rc.id, rc.hash, rc.created_at, rc.user_id FROM recovery_code as rc WHERE rc.user_id=$1 ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var codes []RecoveryCode
+
+	for rows.Next() {
+		var u User
+		var rc RecoveryCode
+
+		if err = rows.Scan(&rc.ID, &rc.Hash, &rc.CreatedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		rc.Author = &u
+
+		codes = append(codes, rc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Remove removes a recovery code
+func (rdb *PostgresRecoveryCodeDatasource) Remove(codeID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM recovery_code WHERE id=$1 ", codeID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveByUser removes all recovery codes of the user
+func (rdb *PostgresRecoveryCodeDatasource) RemoveByUser(userID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM recovery_code WHERE user_id=$1 ", userID); err != nil {
+		return err
+	}
+
+	return nil
+}
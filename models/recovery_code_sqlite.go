@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+)
+
+// SQLiteRecoveryCodeDatasource providing an implementation of RecoveryCodeDatasourceService for SQLite
+type SQLiteRecoveryCodeDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create creates a new recovery code
+func (rdb *SQLiteRecoveryCodeDatasource) Create(rc *RecoveryCode) (int, error) {
+	res, err := rdb.SQLConn.Exec("INSERT INTO recovery_code (hash, created_at, user_id) VALUES(?, ?, ?)",
+		rc.Hash, time.Now(), rc.Author.ID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// ListByUser returns all unused recovery codes of the user
+func (rdb *SQLiteRecoveryCodeDatasource) ListByUser(userID int) ([]RecoveryCode, error) {
+	rows, err := rdb.SQLConn.Query("SELECT rc.id, rc.hash, rc.created_at, rc.user_id FROM recovery_code as rc WHERE rc.user_id=? ", userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	var codes []RecoveryCode
+
+	for rows.Next() {
+		var u User
+		var rc RecoveryCode
+
+		if err = rows.Scan(&rc.ID, &rc.Hash, &rc.CreatedAt, &u.ID); err != nil {
+			return nil, err
+		}
+
+		rc.Author = &u
+
+		codes = append(codes, rc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Remove removes a recovery code
+func (rdb *SQLiteRecoveryCodeDatasource) Remove(codeID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM recovery_code WHERE id=? ", codeID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveByUser removes all recovery codes of the user
+func (rdb *SQLiteRecoveryCodeDatasource) RemoveByUser(userID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM recovery_code WHERE user_id=? ", userID); err != nil {
+		return err
+	}
+
+	return nil
+}
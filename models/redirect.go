@@ -0,0 +1,90 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+)
+
+// RedirectDatasourceService defines an interface for storing redirects
+type RedirectDatasourceService interface {
+	Create(r *Redirect) (int, error)
+	GetByPath(path string) (*Redirect, error)
+}
+
+// Redirect redirects the requests of an old path permanently to the target, e.g. the permalink of an imported post
+// to the article
+type Redirect struct {
+	ID           int
+	Path         string
+	Target       string
+	LastModified time.Time
+}
+
+// RedirectService containing the service to access redirects
+type RedirectService struct {
+	Datasource RedirectDatasourceService
+}
+
+// RedirectPath returns the path of the URL or path without the trailing slash; the query is not considered
+func RedirectPath(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimRight(u.Path, "/")
+}
+
+// Create creates a redirect from the path to the target
+func (rs *RedirectService) Create(r *Redirect) (int, error) {
+	r.Path = RedirectPath(r.Path)
+
+	if len(r.Path) == 0 {
+		return -1, httperror.ValueRequired("path")
+	}
+
+	if len(r.Target) == 0 {
+		return -1, httperror.ValueRequired("target")
+	}
+
+	if _, err := rs.Datasource.GetByPath(r.Path); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return -1, err
+		}
+	} else {
+		return -1, httperror.New(http.StatusUnprocessableEntity,
+			fmt.Sprintf("The path %s is already redirected.", r.Path),
+			fmt.Errorf("the path %s is already redirected", r.Path))
+	}
+
+	return rs.Datasource.Create(r)
+}
+
+// Target returns the target of the path; sql.ErrNoRows is returned if the path is not redirected
+func (rs *RedirectService) Target(path string) (string, error) {
+	path = RedirectPath(path)
+
+	if len(path) == 0 {
+		return "", sql.ErrNoRows
+	}
+
+	r, err := rs.Datasource.GetByPath(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return r.Target, nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostgresRedirectDatasource providing an implementation of RedirectDatasourceService for PostgreSQL
+type PostgresRedirectDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create creates a redirect
+func (rdb *PostgresRedirectDatasource) Create(r *Redirect) (int, error) {
+	var id int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO redirect (path, target, last_modified) VALUES($1, $2, $3) RETURNING id",
+		r.Path, r.Target, time.Now()).Scan(&id); err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// GetByPath returns the redirect of the path
+func (rdb *PostgresRedirectDatasource) GetByPath(path string) (*Redirect, error) {
+	var r Redirect
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, path, target, last_modified FROM redirect WHERE path=$1", path).
+		Scan(&r.ID, &r.Path, &r.Target, &r.LastModified); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SQLiteRedirectDatasource providing an implementation of RedirectDatasourceService for SQLite
+type SQLiteRedirectDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create creates a redirect
+func (rdb *SQLiteRedirectDatasource) Create(r *Redirect) (int, error) {
+	res, err := rdb.SQLConn.Exec("INSERT INTO redirect (path, target, last_modified) VALUES(?, ?, ?)", r.Path, r.Target, time.Now())
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// GetByPath returns the redirect of the path
+func (rdb *SQLiteRedirectDatasource) GetByPath(path string) (*Redirect, error) {
+	var r Redirect
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, path, target, last_modified FROM redirect WHERE path=?", path).
+		Scan(&r.ID, &r.Path, &r.Target, &r.LastModified); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+// Role is the role of an user, every role grants a set of permissions
+type Role string
+
+// Permission is a named permission granted by a role
+type Permission string
+
+const (
+	// RoleAdmin is granted all permissions
+	RoleAdmin Role = "admin"
+	// RoleEditor manages the content of all users
+	RoleEditor Role = "editor"
+	// RoleAuthor writes and publishes own articles
+	RoleAuthor Role = "author"
+	// RoleContributor writes own articles, but can not publish them
+	RoleContributor Role = "contributor"
+)
+
+const (
+	// PermArticlePublish allows publishing articles
+	PermArticlePublish Permission = "article.publish"
+	// PermArticleEditAny allows viewing, editing and deleting articles of all users
+	PermArticleEditAny Permission = "article.edit.any"
+	// PermCategoryManage allows editing and deleting categories of all users
+	PermCategoryManage Permission = "category.manage"
+	// PermFileEditAny allows viewing and deleting files of all users
+	PermFileEditAny Permission = "file.edit.any"
+	// PermSiteManage allows managing the sites
+	PermSiteManage Permission = "site.manage"
+	// PermUserManage allows managing users and invitations
+	PermUserManage Permission = "user.manage"
+	// PermAuditView allows viewing and exporting the audit log
+	PermAuditView Permission = "audit.view"
+)
+
+var roles = []Role{RoleAdmin, RoleEditor, RoleAuthor, RoleContributor}
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermArticlePublish,
+		PermArticleEditAny,
+		PermCategoryManage,
+		PermFileEditAny,
+		PermSiteManage,
+		PermUserManage,
+		PermAuditView,
+	},
+	RoleEditor: {
+		PermArticlePublish,
+		PermArticleEditAny,
+		PermCategoryManage,
+		PermFileEditAny,
+		PermSiteManage,
+	},
+	RoleAuthor: {
+		PermArticlePublish,
+	},
+	RoleContributor: {},
+}
+
+// Roles returns all available roles
+func Roles() []Role {
+	return roles
+}
+
+// Valid returns true if the role is known
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can returns true if the role of the user grants the permission;
+// this is the only place where permissions are resolved
+func (u *User) Can(p Permission) bool {
+	if u == nil {
+		return false
+	}
+
+	for _, rp := range rolePermissions[u.Role] {
+		if rp == p {
+			return true
+		}
+	}
+
+	return false
+}
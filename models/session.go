@@ -0,0 +1,98 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"net/http"
+
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/session"
+)
+
+// SessionService wraps the session service; if the sessions are kept by the DatabaseSessionProvider the values are
+// persisted as soon as they are set
+type SessionService struct {
+	session.Service
+}
+
+// Session is a session returned by the SessionService
+type Session struct {
+	*session.Session
+
+	provider *DatabaseSessionProvider
+}
+
+// Get returns the session of the request
+func (ss *SessionService) Get(rw http.ResponseWriter, r *http.Request) (*Session, error) {
+	s, err := ss.Service.Get(rw, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.wrap(s), nil
+}
+
+// Create creates a new session and sets the session cookie
+func (ss *SessionService) Create(rw http.ResponseWriter, r *http.Request) *Session {
+	return ss.wrap(ss.Service.Create(rw, r))
+}
+
+// Renew replaces the session of the request by a new session with the same values
+func (ss *SessionService) Renew(rw http.ResponseWriter, r *http.Request) (*Session, error) {
+	s, err := ss.Service.Renew(rw, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ns := ss.wrap(s)
+	ns.save()
+
+	return ns, nil
+}
+
+func (ss *SessionService) wrap(s *session.Session) *Session {
+	sp, _ := ss.SessionProvider.(*DatabaseSessionProvider)
+
+	return &Session{
+		Session:  s,
+		provider: sp,
+	}
+}
+
+// SetValue sets the value of the key and persists the session
+func (s *Session) SetValue(key string, value interface{}) {
+	s.Session.SetValue(key, value)
+	s.save(key)
+}
+
+// RemoveKey removes the value of the key and persists the session
+func (s *Session) RemoveKey(key string) {
+	s.Session.RemoveKey(key)
+	s.save(key)
+}
+
+// setValues sets the values and persists the session once
+func (s *Session) setValues(values map[string]interface{}) {
+	keys := make([]string, 0, len(values))
+
+	for k, v := range values {
+		s.Session.SetValue(k, v)
+		keys = append(keys, k)
+	}
+
+	s.save(keys...)
+}
+
+func (s *Session) save(keys ...string) {
+	if s.provider == nil {
+		return
+	}
+
+	if err := s.provider.save(s.Session, keys...); err != nil {
+		logger.Log.Errorf("could not persist session %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/session"
+)
+
+// transientSessionKeys are the keys of the short-lived values of a pending setup or login, e.g. the pending TOTP
+// secret and the challenges of WebAuthn and OIDC; they are kept in memory only
+var transientSessionKeys = map[string]bool{
+	"totp_secret":           true,
+	"webauthn_registration": true,
+	"webauthn_login":        true,
+	"oidc_state":            true,
+	"oidc_nonce":            true,
+	"oidc_verifier":         true,
+	"oidc_redirect":         true,
+}
+
+// DatabaseSessionProvider is a session provider which keeps the sessions in memory and persists them in the SQLite or
+// PostgreSQL database, so sessions survive a restart of the application. Only the SHA-512 hash of the session id is saved.
+// The session is written whenever a value is set with the SessionService and on every run of the garbage collection.
+// All values are persisted except the transient ones; values of types, which are not registered with gob, are skipped.
+type DatabaseSessionProvider struct {
+	SQLConn *sql.DB
+
+	// bind converts the queries written with ? placeholders to the placeholders of the database
+	bind   func(query string) string
+	mutex  sync.Mutex
+	memory *session.InMemoryProvider
+	sids   map[string]string
+	// keys are the keys of all values which were set, as the values of a session can not be listed
+	keys    map[string]bool
+	timeout time.Duration
+}
+
+// NewSQLiteSessionProvider returns a new session provider which persists the sessions in the SQLite database
+func NewSQLiteSessionProvider(db *sql.DB) *DatabaseSessionProvider {
+	return &DatabaseSessionProvider{
+		SQLConn: db,
+		bind:    func(query string) string { return query },
+		memory:  session.NewInMemoryProvider(),
+		sids:    make(map[string]string),
+		keys:    make(map[string]bool),
+	}
+}
+
+// NewPostgresSessionProvider returns a new session provider which persists the sessions in the PostgreSQL database
+func NewPostgresSessionProvider(db *sql.DB) *DatabaseSessionProvider {
+	sp := NewSQLiteSessionProvider(db)
+	sp.bind = database.Rebind
+
+	return sp
+}
+
+// Create creates a new session with the given session id
+func (sp *DatabaseSessionProvider) Create(sid string) *session.Session {
+	s := sp.memory.Create(sid)
+
+	sp.mutex.Lock()
+	sp.sids[crypt.Hash([]byte(sid))] = sid
+	sp.mutex.Unlock()
+
+	if err := sp.persist(sid, s, time.Now()); err != nil {
+		logger.Log.Errorf("could not persist session %v", err)
+	}
+
+	return s
+}
+
+// Get returns the session; if the session is not in memory, e.g. after a restart, it is restored from the database
+func (sp *DatabaseSessionProvider) Get(sid string) (*session.Session, error) {
+	if s, err := sp.memory.Get(sid); err == nil {
+		return s, nil
+	}
+
+	hash := crypt.Hash([]byte(sid))
+
+	var data []byte
+	var lastAccess time.Time
+
+	if err := sp.SQLConn.QueryRow(sp.bind("SELECT data, last_access_time FROM session WHERE id=? "), hash).Scan(&data, &lastAccess); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+
+	sp.mutex.Lock()
+	timeout := sp.timeout
+	sp.mutex.Unlock()
+
+	if timeout > 0 && time.Since(lastAccess) > timeout {
+		sp.Remove(sid)
+		return nil, errors.New("session expired")
+	}
+
+	values, err := decodeSessionValues(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := sp.memory.Create(sid)
+
+	sp.mutex.Lock()
+	for k, v := range values {
+		s.SetValue(k, v)
+		sp.keys[k] = true
+	}
+	sp.sids[hash] = sid
+	sp.mutex.Unlock()
+
+	return s, nil
+}
+
+// FindByValue returns all sessions, in memory or persisted, containing the key with the value; the values of the
+// sessions in memory take precedence over the persisted ones. Sessions which were not accessed since a restart are
+// identified by the hash of their session id
+func (sp *DatabaseSessionProvider) FindByValue(key string, value interface{}) []session.Session {
+	sp.mutex.Lock()
+	sids := make(map[string]string, len(sp.sids))
+	for hash, sid := range sp.sids {
+		sids[hash] = sid
+	}
+	sp.mutex.Unlock()
+
+	var sessions []session.Session
+
+	// the sessions in memory are checked first
+	for hash, sid := range sids {
+		s, err := sp.memory.Get(sid)
+
+		if err != nil {
+			delete(sids, hash)
+			continue
+		}
+
+		if s.GetValue(key) == value {
+			sessions = append(sessions, *s)
+		}
+	}
+
+	rows, err := sp.SQLConn.Query("SELECT id, data FROM session ")
+
+	if err != nil {
+		logger.Log.Error(err)
+		return sessions
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	scratch := session.NewInMemoryProvider()
+
+	for rows.Next() {
+		var hash string
+		var data []byte
+
+		if err := rows.Scan(&hash, &data); err != nil {
+			logger.Log.Error(err)
+			return sessions
+		}
+
+		if _, ok := sids[hash]; ok {
+			continue
+		}
+
+		values, err := decodeSessionValues(data)
+
+		if err != nil {
+			logger.Log.Error(err)
+			continue
+		}
+
+		if values[key] != value {
+			continue
+		}
+
+		s := scratch.Create(hash)
+
+		for k, v := range values {
+			s.SetValue(k, v)
+		}
+
+		sessions = append(sessions, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Log.Error(err)
+	}
+
+	return sessions
+}
+
+// Remove removes the session; sid is either the session id or the hash returned by FindByValue
+func (sp *DatabaseSessionProvider) Remove(sid string) {
+	sp.memory.Remove(sid)
+
+	hash := crypt.Hash([]byte(sid))
+
+	sp.mutex.Lock()
+	delete(sp.sids, hash)
+	delete(sp.sids, sid)
+	sp.mutex.Unlock()
+
+	if _, err := sp.SQLConn.Exec(sp.bind("DELETE FROM session WHERE id=? OR id=? "), hash, sid); err != nil {
+		logger.Log.Error(err)
+	}
+}
+
+// Clean removes the sessions which were idle longer than timeoutAfter, the remaining sessions are persisted
+func (sp *DatabaseSessionProvider) Clean(ticker *time.Ticker, timeoutAfter time.Duration) {
+	sp.mutex.Lock()
+	sp.timeout = timeoutAfter
+	sp.mutex.Unlock()
+
+	go func() {
+		for range ticker.C {
+			sp.clean(timeoutAfter)
+		}
+	}()
+}
+
+func (sp *DatabaseSessionProvider) clean(timeoutAfter time.Duration) {
+	sp.mutex.Lock()
+	sids := make([]string, 0, len(sp.sids))
+	for _, sid := range sp.sids {
+		sids = append(sids, sid)
+	}
+	sp.mutex.Unlock()
+
+	for _, sid := range sids {
+		s, err := sp.memory.Get(sid)
+
+		if err != nil || time.Since(s.LastAccessTime()) > timeoutAfter {
+			sp.Remove(sid)
+			continue
+		}
+
+		if err := sp.persist(sid, s, s.LastAccessTime()); err != nil {
+			logger.Log.Errorf("could not persist session %v", err)
+		}
+	}
+
+	if _, err := sp.SQLConn.Exec(sp.bind("DELETE FROM session WHERE last_access_time < ? "), time.Now().Add(-timeoutAfter)); err != nil {
+		logger.Log.Error(err)
+	}
+}
+
+// save persists the session after the values of the keys were set
+func (sp *DatabaseSessionProvider) save(s *session.Session, keys ...string) error {
+	sp.mutex.Lock()
+	for _, k := range keys {
+		if !transientSessionKeys[k] {
+			sp.keys[k] = true
+		}
+	}
+	sp.mutex.Unlock()
+
+	return sp.persist(s.SessionID(), s, time.Now())
+}
+
+func (sp *DatabaseSessionProvider) persist(sid string, s *session.Session, lastAccess time.Time) error {
+	sp.mutex.Lock()
+	keys := make([]string, 0, len(sp.keys))
+	for k := range sp.keys {
+		keys = append(keys, k)
+	}
+	sp.mutex.Unlock()
+
+	values := make(map[string]interface{})
+
+	for _, k := range keys {
+		v := s.GetValue(k)
+
+		if v == nil {
+			continue
+		}
+
+		// a single value which can not be encoded must not prevent persisting the session
+		if err := gob.NewEncoder(io.Discard).Encode(&v); err != nil {
+			logger.Log.Warnf("the session value %s is not persisted %v", k, err)
+			continue
+		}
+
+		values[k] = v
+	}
+
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return err
+	}
+
+	_, err := sp.SQLConn.Exec(sp.bind("INSERT INTO session (id, data, last_access_time, created_at) VALUES(?, ?, ?, ?) "+
+		"ON CONFLICT(id) DO UPDATE SET data=excluded.data, last_access_time=excluded.last_access_time "),
+		crypt.Hash([]byte(sid)), buf.Bytes(), lastAccess, time.Now())
+
+	return err
+}
+
+func decodeSessionValues(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,209 @@
+package models_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/session"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// unregistered is a type which is not registered with gob
+type unregistered struct {
+	Challenge []byte
+}
+
+func TestSQLiteSessionProvider(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	sp := models.NewSQLiteSessionProvider(db)
+	ss := newSessionService(sp)
+
+	alice := ss.Create(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	alice.SetValue("userid", 1)
+	alice.SetValue("two_factor_state", "admin/articles")
+	alice.SetValue("session_ip", "127.0.0.1")
+	alice.SetValue("totp_secret", "JBSWY3DPEHPK3PXP")
+	alice.SetValue("challenge", unregistered{Challenge: []byte("challenge")})
+
+	bob := ss.Create(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	bob.SetValue("userid", 2)
+
+	var count int
+
+	if err := db.QueryRow("SELECT count(*) FROM session WHERE id=? ", alice.SessionID()).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Error("expected that the plain session id is not saved")
+	}
+
+	// restart; the values were written when they were set
+	sp = models.NewSQLiteSessionProvider(db)
+
+	s, err := sp.Get(alice.SessionID())
+
+	if err != nil {
+		t.Fatalf("expected the session to survive a restart, but got %v", err)
+	}
+
+	if s.GetValue("userid") != 1 || s.GetValue("two_factor_state") != "admin/articles" || s.GetValue("session_ip") != "127.0.0.1" {
+		t.Errorf("expected the restored session values, but got %v, %v, %v", s.GetValue("userid"), s.GetValue("two_factor_state"), s.GetValue("session_ip"))
+	}
+
+	if s.GetValue("totp_secret") != nil {
+		t.Error("expected that the transient values are not persisted")
+	}
+
+	if s.GetValue("challenge") != nil {
+		t.Error("expected that values of types not registered with gob are not persisted")
+	}
+
+	// bob was not accessed since the restart; his session is identified by the hash
+	sessions := sp.FindByValue("userid", 2)
+
+	if len(sessions) != 1 || sessions[0].SessionID() != crypt.Hash([]byte(bob.SessionID())) {
+		t.Fatalf("expected to find the persisted session of bob, but got %v", sessions)
+	}
+
+	sp.Remove(sessions[0].SessionID())
+
+	if _, err := sp.Get(bob.SessionID()); err == nil {
+		t.Error("expected an error while getting a removed session, but error is nil")
+	}
+
+	sessions = sp.FindByValue("userid", 1)
+
+	if len(sessions) != 1 || sessions[0].SessionID() != alice.SessionID() {
+		t.Fatalf("expected to find the session of alice, but got %v", sessions)
+	}
+
+	// the values in memory take precedence and are not written by the lookup
+	s.SetValue("userid", 3)
+
+	if sessions := sp.FindByValue("userid", 1); len(sessions) != 0 {
+		t.Errorf("expected the persisted value to be superseded by the value in memory, but got %v", sessions)
+	}
+
+	if sessions := sp.FindByValue("userid", 3); len(sessions) != 1 || sessions[0].SessionID() != alice.SessionID() {
+		t.Errorf("expected to find the session of alice by the value in memory, but got %v", sessions)
+	}
+
+	if restored, err := models.NewSQLiteSessionProvider(db).Get(alice.SessionID()); err != nil || restored.GetValue("userid") != 1 {
+		t.Errorf("expected that the lookup does not persist the sessions, but got %v", err)
+	}
+
+	sp.Remove(alice.SessionID())
+
+	if _, err := models.NewSQLiteSessionProvider(db).Get(alice.SessionID()); err == nil {
+		t.Error("expected an error while restoring a removed session, but error is nil")
+	}
+}
+
+func TestSQLiteSessionProviderRenew(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	ss := newSessionService(models.NewSQLiteSessionProvider(db))
+
+	rw := httptest.NewRecorder()
+
+	s := ss.Create(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	s.SetValue("two_factor_userid", 1)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(rw.Result().Cookies()[0])
+
+	s, err := ss.Renew(httptest.NewRecorder(), r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restart
+	restored, err := models.NewSQLiteSessionProvider(db).Get(s.SessionID())
+
+	if err != nil {
+		t.Fatalf("expected the renewed session to be persisted, but got %v", err)
+	}
+
+	if restored.GetValue("two_factor_userid") != 1 {
+		t.Errorf("expected the values of the renewed session, but got %v", restored.GetValue("two_factor_userid"))
+	}
+}
+
+func TestSQLiteSessionProviderExpiry(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	sp := models.NewSQLiteSessionProvider(db)
+
+	alice := newSessionService(sp).Create(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	alice.SetValue("userid", 1)
+
+	if _, err := db.Exec("UPDATE session SET last_access_time=? ", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart
+	sp = models.NewSQLiteSessionProvider(db)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	sp.Clean(ticker, 30*time.Minute)
+
+	if _, err := sp.Get(alice.SessionID()); err == nil {
+		t.Error("expected an error while restoring an expired session, but error is nil")
+	}
+
+	var count int
+
+	if err := db.QueryRow("SELECT count(*) FROM session ").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("expected the expired session to be removed, but %d sessions left", count)
+	}
+}
+
+func newSessionService(sp *models.DatabaseSessionProvider) *models.SessionService {
+	return &models.SessionService{Service: session.Service{
+		Path:            "/",
+		Name:            "test-session",
+		HTTPOnly:        true,
+		SessionProvider: sp,
+		IdleSessionTTL:  3600,
+	}}
+}
+
+func setupSessionDB(t *testing.T) *sql.DB {
+	logger.InitLogger(ioutil.Discard, "Debug")
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	if err := database.InitTables(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
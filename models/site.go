@@ -37,16 +37,16 @@ const (
 
 // Site represents a site
 type Site struct {
-	ID           int
-	Title        string
-	Link         string
-	Section      string
-	Content      string
-	Published    bool
-	PublishedOn  NullTime
-	LastModified time.Time
-	OrderNo      int
-	Author       *User
+	ID           int       `json:"id"`
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	Section      string    `json:"section"`
+	Content      string    `json:"content"`
+	Published    bool      `json:"published"`
+	PublishedOn  NullTime  `json:"published_on"`
+	LastModified time.Time `json:"last_modified"`
+	OrderNo      int       `json:"order_no"`
+	Author       *User     `json:"author"`
 }
 
 // LinkEscape escapes a link for safe use in URLs
@@ -116,7 +116,8 @@ func (s *Site) validate(ds SiteDatasourceService, changeLink bool) error {
 
 // SiteService containing the service to access site
 type SiteService struct {
-	Datasource SiteDatasourceService
+	Datasource   SiteDatasourceService
+	AuditService *AuditService
 }
 
 // List returns all sites
@@ -125,14 +126,23 @@ func (ss *SiteService) List(pc PublishedCriteria, p *Pagination) ([]Site, error)
 }
 
 // Publish switches the publish state of the site
-func (ss *SiteService) Publish(siteID int) error {
+func (ss *SiteService) Publish(siteID int, u *User) error {
 	s, err := ss.Datasource.Get(siteID, All)
 
 	if err != nil {
 		return err
 	}
 
-	return ss.Datasource.Publish(s)
+	if err := ss.Datasource.Publish(s); err != nil {
+		return err
+	}
+
+	published := *s
+	published.Published = !s.Published
+
+	ss.AuditService.Record(u, AuditPublish, AuditSite, s.ID, s.auditSummary(), published.auditSummary())
+
+	return nil
 }
 
 // Create creates a site
@@ -151,12 +161,34 @@ func (ss *SiteService) Create(s *Site) (int, error) {
 
 	s.OrderNo = m + 1
 
-	return ss.Datasource.Create(s)
+	id, err := ss.Datasource.Create(s)
+
+	if err != nil {
+		return -1, err
+	}
+
+	ss.AuditService.Record(s.Author, AuditCreate, AuditSite, id, "", s.auditSummary())
+
+	return id, nil
 }
 
 // Order reorder the site
-func (ss *SiteService) Order(siteID int, dir Direction) error {
-	return ss.Datasource.Order(siteID, dir)
+func (ss *SiteService) Order(siteID int, dir Direction, u *User) error {
+	s, err := ss.Datasource.Get(siteID, All)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ss.Datasource.Order(siteID, dir); err != nil {
+		return err
+	}
+
+	if after, err := ss.Datasource.Get(siteID, All); err == nil {
+		ss.AuditService.Record(u, AuditOrder, AuditSite, s.ID, s.auditSummary(), after.auditSummary())
+	}
+
+	return nil
 }
 
 // Update updates a site
@@ -178,18 +210,30 @@ func (ss *SiteService) Update(s *Site) error {
 		return err
 	}
 
-	return ss.Datasource.Update(s)
+	if err := ss.Datasource.Update(s); err != nil {
+		return err
+	}
+
+	ss.AuditService.Record(s.Author, AuditUpdate, AuditSite, s.ID, oldSite.auditSummary(), s.auditSummary())
+
+	return nil
 }
 
 // Delete deletes a site
-func (ss *SiteService) Delete(siteID int) error {
+func (ss *SiteService) Delete(siteID int, u *User) error {
 	s, err := ss.GetByID(siteID, All)
 
 	if err != nil {
 		return err
 	}
 
-	return ss.Datasource.Delete(s)
+	if err := ss.Datasource.Delete(s); err != nil {
+		return err
+	}
+
+	ss.AuditService.Record(u, AuditDelete, AuditSite, s.ID, s.auditSummary(), "")
+
+	return nil
 }
 
 // GetByLink Get a site by the link.
@@ -51,10 +51,11 @@ func (rdb *SQLiteSiteDatasource) List(pc PublishedCriteria, p *Pagination) ([]Si
 	}()
 
 	var sites []Site
-	var s Site
-	var u User
 
 	for rows.Next() {
+		var s Site
+		var u User
+
 		if err = rows.Scan(&s.ID, &s.Title, &s.Link, &s.Section, &s.Content, &s.Published, &s.PublishedOn, &s.LastModified, &s.OrderNo, &u.ID, &u.DisplayName, &u.Email, &u.Username); err != nil {
 			return nil, err
 		}
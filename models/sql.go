@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"time"
 )
 
@@ -41,6 +42,29 @@ func (nt *NullTime) Scan(value interface{}) error {
 	return nil
 }
 
+// MarshalJSON implements the json.Marshaler interface; an invalid time is marshaled as null
+func (nt NullTime) MarshalJSON() ([]byte, error) {
+	if !nt.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nt.Time)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (nt *NullTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		nt.Time, nt.Valid = time.Time{}, false
+		return nil
+	}
+
+	if err := json.Unmarshal(b, &nt.Time); err != nil {
+		return err
+	}
+
+	nt.Valid = true
+	return nil
+}
+
 // Value implements the driver Valuer interface.
 func (nt NullTime) Value() (driver.Value, error) {
 	if !nt.Valid {
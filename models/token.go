@@ -26,6 +26,8 @@ type Token struct {
 	Hash        string
 	Type        TokenType
 	RequestedAt time.Time
+	// Payload contains the value the token confirms, e.g. the new email address of an email change
+	Payload string
 
 	Author *User
 }
@@ -33,9 +35,17 @@ type Token struct {
 const (
 	// PasswordReset token generated for resetting passwords
 	PasswordReset = iota
+	// EmailChange token generated for confirming a new email address
+	EmailChange
+	// EmailRevert token generated for reverting an email change from the previous email address
+	EmailRevert
+	// MagicLink token generated for logging in without a password
+	MagicLink
+	// SecureAccount token generated for securing the account after a login from an unfamiliar device
+	SecureAccount
 )
 
-var types = [...]string{"password_reset"}
+var types = [...]string{"password_reset", "email_change", "email_revert", "magic_link", "secure_account"}
 
 // TokenType specifies the type where token can be used
 type TokenType int
@@ -44,8 +54,7 @@ type TokenType int
 func (tt *TokenType) Scan(value interface{}) error {
 	for k, t := range types {
 		if t == (value.(string)) {
-			tts := TokenType(k)
-			tt = &tts
+			*tt = TokenType(k)
 			return nil
 		}
 	}
@@ -122,7 +131,29 @@ func (ts *TokenService) RateLimit(userID int, tt TokenType) error {
 	return nil
 }
 
+// ListByUser returns the tokens of the token type requested by the user, the most recent first
+func (ts *TokenService) ListByUser(userID int, tt TokenType) ([]Token, error) {
+	return ts.Datasource.ListByUser(userID, tt)
+}
+
 // Remove removes a token
 func (ts *TokenService) Remove(hash string, tt TokenType) error {
 	return ts.Datasource.Remove(hash, tt)
 }
+
+// RemoveByUser removes all tokens of the token type requested by the user
+func (ts *TokenService) RemoveByUser(userID int, tt TokenType) error {
+	tokens, err := ts.Datasource.ListByUser(userID, tt)
+
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		if err := ts.Datasource.Remove(t.Hash, tt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"time"
+)
+
+// PostgresTokenDatasource providing an implementation of TokenDatasourceService for PostgreSQL
+type PostgresTokenDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create creates a new token
+func (rdb *PostgresTokenDatasource) Create(t *Token) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO token (hash, requested_at, token_type, payload, user_id) VALUES($1, $2, $3, $4, $5) RETURNING id",
+		t.Hash, time.Now(), t.Type, t.Payload, t.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// Get gets a token based on the hash and the token type
+func (rdb *PostgresTokenDatasource) Get(hash string, tt TokenType) (*Token, error) {
+	var t Token
+	var u User
+
+	if err := rdb.SQLConn.QueryRow("SELECT t.id, t.hash, t.requested_at, t.token_type, t.payload, t.user_id FROM token as t WHERE t.hash=$1 AND t.token_type=$2 ", hash, tt.String()).
+		Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &t.Payload, &u.ID); err != nil {
+		return nil, err
+	}
+
+	t.Author = &u
+
+	return &t, nil
+}
+
+// ListByUser receives all tokens based on the user id and the token type ordered by requested
+func (rdb *PostgresTokenDatasource) ListByUser(userID int, tt TokenType) ([]Token, error) {
+	rows, err := rdb.SQLConn.Query("SELECT t.id, t.hash, t.requested_at, t.token_type, t.payload, t.user_id FROM token as t WHERE t.user_id=$1 AND t.token_type=$2 ORDER BY t.requested_at DESC ", userID, tt.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	tokens := []Token{}
+
+	for rows.Next() {
+		var u User
+		var t Token
+		if err = rows.Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &t.Payload, &u.ID); err != nil {
+			return nil, err
+		}
+
+		t.Author = &u
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens, nil
+}
+
+// Remove removes a token based on the hash
+func (rdb *PostgresTokenDatasource) Remove(hash string, tt TokenType) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM token WHERE hash=$1 AND token_type=$2 ", hash, tt.String()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -13,8 +13,8 @@ type SQLiteTokenDatasource struct {
 
 // Create creates a new token
 func (rdb *SQLiteTokenDatasource) Create(t *Token) (int, error) {
-	res, err := rdb.SQLConn.Exec("INSERT INTO token (hash, requested_at, token_type, user_id) VALUES(?, ?, ?, ?)",
-		t.Hash, time.Now(), t.Type, t.Author.ID)
+	res, err := rdb.SQLConn.Exec("INSERT INTO token (hash, requested_at, token_type, payload, user_id) VALUES(?, ?, ?, ?, ?)",
+		t.Hash, time.Now(), t.Type, t.Payload, t.Author.ID)
 
 	if err != nil {
 		return -1, err
@@ -34,8 +34,8 @@ func (rdb *SQLiteTokenDatasource) Get(hash string, tt TokenType) (*Token, error)
 	var t Token
 	var u User
 
-	if err := rdb.SQLConn.QueryRow("SELECT t.id, t.hash, t.requested_at, t.token_type, t.user_id FROM token as t WHERE t.hash=? AND t.token_type=? ", hash, tt.String()).
-		Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &u.ID); err != nil {
+	if err := rdb.SQLConn.QueryRow("SELECT t.id, t.hash, t.requested_at, t.token_type, t.payload, t.user_id FROM token as t WHERE t.hash=? AND t.token_type=? ", hash, tt.String()).
+		Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &t.Payload, &u.ID); err != nil {
 		return nil, err
 	}
 
@@ -46,7 +46,7 @@ func (rdb *SQLiteTokenDatasource) Get(hash string, tt TokenType) (*Token, error)
 
 // ListByUser receives all tokens based on the user id and the token type ordered by requested
 func (rdb *SQLiteTokenDatasource) ListByUser(userID int, tt TokenType) ([]Token, error) {
-	rows, err := rdb.SQLConn.Query("SELECT t.id, t.hash, t.requested_at, t.token_type, t.user_id FROM token as t WHERE t.user_id=? AND t.token_type=? ", userID, tt.String())
+	rows, err := rdb.SQLConn.Query("SELECT t.id, t.hash, t.requested_at, t.token_type, t.payload, t.user_id FROM token as t WHERE t.user_id=? AND t.token_type=? ORDER BY t.requested_at DESC ", userID, tt.String())
 
 	if err != nil {
 		return nil, err
@@ -63,7 +63,7 @@ func (rdb *SQLiteTokenDatasource) ListByUser(userID int, tt TokenType) ([]Token,
 	for rows.Next() {
 		var u User
 		var t Token
-		if err = rows.Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &u.ID); err != nil {
+		if err = rows.Scan(&t.ID, &t.Hash, &t.RequestedAt, &t.Type, &t.Payload, &u.ID); err != nil {
 			return nil, err
 		}
 
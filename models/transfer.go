@@ -0,0 +1,1155 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/slug"
+)
+
+// TransferFormatVersion is the version of the layout of the export archive
+const TransferFormatVersion = 1
+
+// TransferService exports the content of the blog into a portable archive and imports the content into another blog
+type TransferService struct {
+	UserService     *UserService
+	ArticleService  *ArticleService
+	SiteService     *SiteService
+	FileService     *FileService
+	CategoryService *CategoryService
+	RedirectService *RedirectService
+}
+
+// Content is the content of a blog independent of the database it is stored in; the articles, sites and files
+// reference their author by the username, the articles reference their category by the name
+type Content struct {
+	Users      []TransferUser
+	Categories []TransferCategory
+	Articles   []TransferArticle
+	Sites      []TransferSite
+	Files      []TransferFile
+}
+
+// TransferUser is an user in the export; the password and the second factors are not exported
+type TransferUser struct {
+	ID          int      `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	DisplayName string   `json:"display_name"`
+	Role        Role     `json:"role"`
+	Active      bool     `json:"active"`
+	Bio         string   `json:"bio"`
+	Website     string   `json:"website"`
+	Avatar      string   `json:"avatar"`
+	SocialLinks []string `json:"social_links"`
+}
+
+// TransferCategory is a category in the export
+type TransferCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// TransferArticle is an article in the export, it is written as markdown with front matter;
+// the permalinks are the URLs of the article in the blog the article is imported from
+type TransferArticle struct {
+	ID           int
+	Headline     string
+	Teaser       string
+	Content      string
+	Slug         string
+	Author       string
+	Category     string
+	Published    bool
+	PublishedOn  NullTime
+	LastModified time.Time
+	Permalinks   []string
+}
+
+// TransferSite is a site in the export, it is written as markdown with front matter;
+// the permalinks are the URLs of the site in the blog the site is imported from
+type TransferSite struct {
+	ID           int
+	Title        string
+	Link         string
+	Section      string
+	Content      string
+	OrderNo      int
+	Author       string
+	Published    bool
+	PublishedOn  NullTime
+	LastModified time.Time
+	Permalinks   []string
+}
+
+// TransferFile is the metadata of a file in the export; Path is the name of the entry containing the file
+type TransferFile struct {
+	ID           int       `json:"id"`
+	Filename     string    `json:"filename"`
+	UniqueName   string    `json:"unique_name"`
+	ContentType  string    `json:"content_type"`
+	Inline       bool      `json:"inline"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Author       string    `json:"author"`
+	Path         string    `json:"path"`
+	Data         []byte    `json:"-"`
+}
+
+type transferManifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ImportResult describes what happened to an entry during the import
+type ImportResult string
+
+const (
+	// ImportCreated the entry was created
+	ImportCreated ImportResult = "created"
+	// ImportRenamed the entry was created with another slug, link or filename, the original one was taken
+	ImportRenamed ImportResult = "renamed"
+	// ImportMapped an existing entry is used instead of the entry
+	ImportMapped ImportResult = "mapped"
+	// ImportSkipped the entry was not imported
+	ImportSkipped ImportResult = "skipped"
+)
+
+// ImportEntry is an imported entry of the report; Type is the type of the entry as used in the audit log,
+// SourceID the ID in the export and ID the ID in this blog
+type ImportEntry struct {
+	Type     string
+	Name     string
+	SourceID int
+	ID       int
+	Result   ImportResult
+	Note     string
+}
+
+// ImportReport reports what happened to the entries of an import
+type ImportReport struct {
+	Entries []ImportEntry
+}
+
+func (r *ImportReport) add(e ImportEntry) {
+	r.Entries = append(r.Entries, e)
+}
+
+// plan notes if the name was planned for another entry of the content; on a dry run the entries are not saved, the
+// conflicts between them are resolved on import
+func (e *ImportEntry) plan(planned map[string]bool, name string) {
+	if planned[name] {
+		e.Note += fmt.Sprintf("; %s is planned for another entry, a new name is chosen on import", name)
+	}
+
+	planned[name] = true
+}
+
+// Count returns the number of entries of the type with the result
+func (r *ImportReport) Count(typ string, result ImportResult) int {
+	n := 0
+
+	for _, e := range r.Entries {
+		if e.Type == typ && e.Result == result {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ImportOptions controls how the content is imported
+type ImportOptions struct {
+	// CreateUsers creates the users which do not exist yet as inactive users; otherwise their content is assigned to
+	// the importing user
+	CreateUsers bool
+	// DryRun plans the import without changing the blog; the report lists the planned changes. Entries of the
+	// content which would get the same name are noted, they are renamed on import
+	DryRun bool
+}
+
+// fileLinkPattern matches the links to the uploaded files in markdown and HTML
+var fileLinkPattern = regexp.MustCompile(`/file/([^\s()<>"'?#]+)`)
+
+// Export writes a ZIP archive with the users, categories, the articles and sites as markdown, the metadata of the files
+// and the files
+func (ts *TransferService) Export(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeJSONEntry(zw, "manifest.json", transferManifest{
+		FormatVersion: TransferFormatVersion,
+		CreatedAt:     time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	users, err := ts.UserService.List(nil)
+
+	if err != nil {
+		return err
+	}
+
+	tusers := []TransferUser{}
+
+	for _, u := range users {
+		// the list does not contain the profile
+		p, err := ts.UserService.GetByID(u.ID)
+
+		if err != nil {
+			return err
+		}
+
+		tusers = append(tusers, TransferUser{
+			ID:          p.ID,
+			Username:    p.Username,
+			Email:       p.Email,
+			DisplayName: p.DisplayName,
+			Role:        p.Role,
+			Active:      p.Active,
+			Bio:         p.Bio,
+			Website:     p.Website,
+			Avatar:      p.Avatar,
+			SocialLinks: p.SocialLinks,
+		})
+	}
+
+	if err := writeJSONEntry(zw, "users.json", tusers); err != nil {
+		return err
+	}
+
+	categories, err := ts.CategoryService.List(AllCategories)
+
+	if err != nil {
+		return err
+	}
+
+	tcategories := []TransferCategory{}
+
+	for _, c := range categories {
+		tcategories = append(tcategories, TransferCategory{
+			ID:   c.ID,
+			Name: c.Name,
+			Slug: c.Slug,
+		})
+	}
+
+	if err := writeJSONEntry(zw, "categories.json", tcategories); err != nil {
+		return err
+	}
+
+	articles, err := ts.ArticleService.List(nil, nil, nil, All)
+
+	if err != nil {
+		return err
+	}
+
+	for _, a := range articles {
+		name := fmt.Sprintf("articles/%d-%s.md", a.ID, path.Base(a.Slug))
+
+		if err := writeMarkdownEntry(zw, name, [][2]string{
+			{"id", strconv.Itoa(a.ID)},
+			{"title", fmt.Sprintf("%q", a.Headline)},
+			{"slug", fmt.Sprintf("%q", a.Slug)},
+			{"author", fmt.Sprintf("%q", a.Author.Username)},
+			{"category", fmt.Sprintf("%q", a.CName.String)},
+			{"published", fmt.Sprintf("%t", a.Published)},
+			{"published_on", formatNullTime(a.PublishedOn)},
+			{"last_modified", a.LastModified.Format(time.RFC3339)},
+			{"teaser", fmt.Sprintf("%q", a.Teaser)},
+		}, a.Content); err != nil {
+			return err
+		}
+	}
+
+	sites, err := ts.SiteService.List(All, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sites {
+		name := fmt.Sprintf("sites/%d-%s.md", s.ID, sanitizeFilename(s.Link))
+
+		if err := writeMarkdownEntry(zw, name, [][2]string{
+			{"id", strconv.Itoa(s.ID)},
+			{"title", fmt.Sprintf("%q", s.Title)},
+			{"link", fmt.Sprintf("%q", s.Link)},
+			{"section", fmt.Sprintf("%q", s.Section)},
+			{"order_no", strconv.Itoa(s.OrderNo)},
+			{"author", fmt.Sprintf("%q", s.Author.Username)},
+			{"published", fmt.Sprintf("%t", s.Published)},
+			{"published_on", formatNullTime(s.PublishedOn)},
+			{"last_modified", s.LastModified.Format(time.RFC3339)},
+		}, s.Content); err != nil {
+			return err
+		}
+	}
+
+	files, err := ts.FileService.List(nil, nil)
+
+	if err != nil {
+		return err
+	}
+
+	tfiles := []TransferFile{}
+
+	for _, f := range files {
+		p := "files/" + f.UniqueName
+
+		tfiles = append(tfiles, TransferFile{
+			ID:           f.ID,
+			Filename:     f.FullFilename,
+			UniqueName:   f.UniqueName,
+			ContentType:  f.ContentType,
+			Inline:       f.Inline,
+			Size:         f.Size,
+			LastModified: f.LastModified,
+			Author:       f.Author.Username,
+			Path:         p,
+		})
+
+		if err := writeFileEntry(zw, ts.FileService.Config.Location, p, f); err != nil {
+			return err
+		}
+	}
+
+	if err := writeJSONEntry(zw, "files.json", tfiles); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// Import imports the content of an archive written by Export; the actor is the user who imports the content
+func (ts *TransferService) Import(r *zip.Reader, actor *User, opts ImportOptions) (*ImportReport, error) {
+	c, err := ReadExport(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.ImportContent(c, actor, opts)
+}
+
+// ReadExport reads the content of an archive written by Export
+func ReadExport(r *zip.Reader) (*Content, error) {
+	entries := make(map[string]*zip.File)
+
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+
+	var m transferManifest
+
+	if err := readJSONEntry(entries, "manifest.json", &m); err != nil {
+		return nil, err
+	}
+
+	if m.FormatVersion < 1 || m.FormatVersion > TransferFormatVersion {
+		return nil, fmt.Errorf("the format version %d of the export is not supported", m.FormatVersion)
+	}
+
+	c := &Content{}
+
+	if err := readJSONEntry(entries, "users.json", &c.Users); err != nil {
+		return nil, err
+	}
+
+	if err := readJSONEntry(entries, "categories.json", &c.Categories); err != nil {
+		return nil, err
+	}
+
+	if err := readJSONEntry(entries, "files.json", &c.Files); err != nil {
+		return nil, err
+	}
+
+	for i, f := range c.Files {
+		e, ok := entries[f.Path]
+
+		if !ok {
+			continue
+		}
+
+		data, err := readEntry(e)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.Files[i].Data = data
+	}
+
+	for _, f := range r.File {
+		if path.Ext(f.Name) != ".md" {
+			continue
+		}
+
+		switch path.Dir(f.Name) {
+		case "articles":
+			a, err := readArticleEntry(f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			c.Articles = append(c.Articles, *a)
+		case "sites":
+			s, err := readSiteEntry(f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			c.Sites = append(c.Sites, *s)
+		}
+	}
+
+	sort.SliceStable(c.Articles, func(i, j int) bool {
+		return c.Articles[i].ID < c.Articles[j].ID
+	})
+
+	sort.SliceStable(c.Sites, func(i, j int) bool {
+		return c.Sites[i].OrderNo < c.Sites[j].OrderNo
+	})
+
+	return c, nil
+}
+
+// ImportContent imports the content into the blog. Existing users and categories are used instead of creating them
+// again. The IDs are remapped; the slugs of the articles, the links of the sites and the names of the files are kept
+// if they are not taken, otherwise new ones are built the way new entries are named. The links to renamed files are
+// rewritten in the articles and sites, the permalinks of the articles and sites are redirected to them.
+// Entries which could not be imported are skipped and listed in the report.
+func (ts *TransferService) ImportContent(c *Content, actor *User, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{}
+
+	users, err := ts.importUsers(c, actor, opts, report)
+
+	if err != nil {
+		return report, err
+	}
+
+	categories, err := ts.importCategories(c, actor, opts, report)
+
+	if err != nil {
+		return report, err
+	}
+
+	renamed, err := ts.importFiles(c, users, actor, opts, report)
+
+	if err != nil {
+		return report, err
+	}
+
+	slugs := make(map[string]bool)
+
+	for _, ta := range c.Articles {
+		a := &Article{
+			Headline:     ta.Headline,
+			Teaser:       rewriteFileLinks(ta.Teaser, renamed),
+			Content:      rewriteFileLinks(ta.Content, renamed),
+			Slug:         ta.Slug,
+			Published:    ta.Published,
+			PublishedOn:  ta.PublishedOn,
+			LastModified: ta.LastModified,
+			Author:       importAuthor(users, ta.Author, actor),
+		}
+
+		if id, ok := categories[ta.Category]; ok {
+			a.CID = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+
+		e := ImportEntry{
+			Type:     AuditArticle,
+			Name:     ta.Headline,
+			SourceID: ta.ID,
+		}
+
+		var id int
+
+		if opts.DryRun {
+			err = ts.ArticleService.prepareImport(a)
+		} else {
+			id, err = ts.ArticleService.Import(a, actor)
+		}
+
+		if err != nil {
+			e.Result = ImportSkipped
+			e.Note = err.Error()
+		} else {
+			e.ID = id
+			e.Result = ImportCreated
+			e.Note = "slug " + a.Slug
+
+			if a.Slug != ta.Slug && len(ta.Slug) > 0 {
+				e.Result = ImportRenamed
+				e.Note = fmt.Sprintf("slug %s was taken, new slug %s", ta.Slug, a.Slug)
+			}
+
+			e.plan(slugs, a.Slug)
+
+			ts.redirect(ta.Permalinks, "/article/"+a.SlugEscape(), opts, &e)
+		}
+
+		report.add(e)
+	}
+
+	links := make(map[string]bool)
+
+	for _, tsite := range c.Sites {
+		e := ImportEntry{
+			Type:     AuditSite,
+			Name:     tsite.Title,
+			SourceID: tsite.ID,
+		}
+
+		link, err := ts.siteLink(tsite.Link)
+
+		if err != nil {
+			e.Result = ImportSkipped
+			e.Note = err.Error()
+			report.add(e)
+			continue
+		}
+
+		s := &Site{
+			Title:       tsite.Title,
+			Link:        link,
+			Section:     tsite.Section,
+			Content:     rewriteFileLinks(tsite.Content, renamed),
+			Published:   tsite.Published,
+			PublishedOn: tsite.PublishedOn,
+			Author:      importAuthor(users, tsite.Author, actor),
+		}
+
+		var id int
+
+		if opts.DryRun {
+			err = s.validate(ts.SiteService.Datasource, true)
+		} else {
+			id, err = ts.SiteService.Create(s)
+		}
+
+		if err != nil {
+			e.Result = ImportSkipped
+			e.Note = err.Error()
+		} else {
+			e.ID = id
+			e.Result = ImportCreated
+			e.Note = "link " + s.Link
+
+			if s.Link != tsite.Link {
+				e.Result = ImportRenamed
+				e.Note = fmt.Sprintf("link %s was taken, new link %s", tsite.Link, s.Link)
+			}
+
+			e.plan(links, s.Link)
+
+			ts.redirect(tsite.Permalinks, s.LinkEscape(), opts, &e)
+		}
+
+		report.add(e)
+	}
+
+	return report, nil
+}
+
+// importUsers returns the users of the blog by the username in the export
+func (ts *TransferService) importUsers(c *Content, actor *User, opts ImportOptions, report *ImportReport) (map[string]*User, error) {
+	users := make(map[string]*User)
+
+	for _, tu := range c.Users {
+		e := ImportEntry{
+			Type:     AuditUser,
+			Name:     tu.Username,
+			SourceID: tu.ID,
+		}
+
+		u, err := ts.UserService.Datasource.GetByUsername(tu.Username)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		if u == nil {
+			u, err = ts.UserService.Datasource.GetByMail(tu.Email)
+
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+		}
+
+		if u != nil {
+			users[tu.Username] = u
+
+			e.ID = u.ID
+			e.Result = ImportMapped
+			e.Note = "existing user " + u.Username
+
+			report.add(e)
+			continue
+		}
+
+		if !opts.CreateUsers {
+			users[tu.Username] = actor
+
+			e.ID = actor.ID
+			e.Result = ImportMapped
+			e.Note = "the content is assigned to " + actor.Username
+
+			report.add(e)
+			continue
+		}
+
+		if opts.DryRun {
+			users[tu.Username] = &User{Username: tu.Username}
+
+			e.Result = ImportCreated
+			e.Note = "inactive, the password must be reset"
+
+			report.add(e)
+			continue
+		}
+
+		// the user has to reset the password after an administrator activated the account
+		u = &User{
+			Username:      tu.Username,
+			Email:         tu.Email,
+			DisplayName:   tu.DisplayName,
+			Role:          tu.Role,
+			Active:        false,
+			PlainPassword: []byte(crypt.RandomHash(32)),
+		}
+
+		id, err := ts.UserService.Create(u, actor)
+
+		if err != nil {
+			users[tu.Username] = actor
+
+			e.ID = actor.ID
+			e.Result = ImportSkipped
+			e.Note = fmt.Sprintf("%v; the content is assigned to %s", err, actor.Username)
+
+			report.add(e)
+			continue
+		}
+
+		users[tu.Username] = u
+
+		e.ID = id
+		e.Result = ImportCreated
+		e.Note = "inactive, the password must be reset"
+
+		u.Bio = tu.Bio
+		u.Website = tu.Website
+		u.Avatar = tu.Avatar
+		u.SocialLinks = tu.SocialLinks
+
+		if len(u.Bio) > 0 || len(u.Website) > 0 || len(u.Avatar) > 0 || len(u.SocialLinks) > 0 {
+			if err := ts.UserService.UpdateProfile(u, actor); err != nil {
+				e.Note += fmt.Sprintf("; the profile was not imported %v", err)
+			}
+		}
+
+		report.add(e)
+	}
+
+	return users, nil
+}
+
+// importCategories returns the IDs of the categories by the name; the categories of the articles which are
+// missing in the list of categories are created as well
+func (ts *TransferService) importCategories(c *Content, actor *User, opts ImportOptions, report *ImportReport) (map[string]int, error) {
+	existing, err := ts.CategoryService.List(AllCategories)
+
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make(map[string]int)
+
+	for _, ec := range existing {
+		categories[ec.Name] = ec.ID
+	}
+
+	tcategories := append([]TransferCategory{}, c.Categories...)
+
+	for _, ta := range c.Articles {
+		tcategories = append(tcategories, TransferCategory{Name: ta.Category})
+	}
+
+	imported := make(map[string]bool)
+
+	for _, tc := range tcategories {
+		name := strings.TrimSpace(tc.Name)
+
+		if len(name) == 0 || imported[name] {
+			continue
+		}
+
+		imported[name] = true
+
+		e := ImportEntry{
+			Type:     AuditCategory,
+			Name:     name,
+			SourceID: tc.ID,
+		}
+
+		if id, ok := categories[name]; ok {
+			e.ID = id
+			e.Result = ImportMapped
+			e.Note = "existing category"
+
+			report.add(e)
+			continue
+		}
+
+		if opts.DryRun {
+			e.Result = ImportCreated
+			e.Note = "new category"
+
+			report.add(e)
+			continue
+		}
+
+		cat := &Category{
+			Name:   name,
+			Author: actor,
+		}
+
+		id, err := ts.CategoryService.Create(cat)
+
+		if err != nil {
+			e.Result = ImportSkipped
+			e.Note = err.Error()
+
+			report.add(e)
+			continue
+		}
+
+		categories[name] = id
+
+		e.ID = id
+		e.Result = ImportCreated
+		e.Note = "slug " + cat.Slug
+
+		if len(tc.Slug) > 0 && tc.Slug != cat.Slug {
+			e.Result = ImportRenamed
+			e.Note = fmt.Sprintf("slug %s was taken, new slug %s", tc.Slug, cat.Slug)
+		}
+
+		report.add(e)
+	}
+
+	return categories, nil
+}
+
+// importFiles returns the new names of the renamed files by the name in the export;
+// a file is not imported again if the same file exists
+func (ts *TransferService) importFiles(c *Content, users map[string]*User, actor *User, opts ImportOptions, report *ImportReport) (map[string]string, error) {
+	renamed := make(map[string]string)
+	names := make(map[string]bool)
+
+	for _, tf := range c.Files {
+		e := ImportEntry{
+			Type:     AuditFile,
+			Name:     tf.UniqueName,
+			SourceID: tf.ID,
+		}
+
+		if tf.Data == nil {
+			e.Result = ImportSkipped
+			e.Note = "the content of the file is missing"
+
+			report.add(e)
+			continue
+		}
+
+		ef, err := ts.identicalFile(tf)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ef != nil {
+			e.ID = ef.ID
+			e.Result = ImportMapped
+			e.Note = "the same file exists"
+
+			if ef.UniqueName != tf.UniqueName {
+				renamed[tf.UniqueName] = ef.UniqueName
+				e.Note = "the same file exists as " + ef.UniqueName
+			}
+
+			report.add(e)
+			continue
+		}
+
+		f := &File{
+			FullFilename: tf.Filename,
+			UniqueName:   tf.UniqueName,
+			ContentType:  tf.ContentType,
+			Inline:       tf.Inline,
+			Data:         tf.Data,
+			Author:       importAuthor(users, tf.Author, actor),
+		}
+
+		var id int
+
+		if opts.DryRun {
+			err = ts.FileService.prepareImport(f)
+		} else {
+			id, err = ts.FileService.Import(f)
+		}
+
+		if err != nil {
+			e.Result = ImportSkipped
+			e.Note = err.Error()
+
+			report.add(e)
+			continue
+		}
+
+		e.ID = id
+		e.Result = ImportCreated
+
+		if f.UniqueName != tf.UniqueName {
+			renamed[tf.UniqueName] = f.UniqueName
+
+			e.Result = ImportRenamed
+			e.Note = fmt.Sprintf("the name was taken, new name %s", f.UniqueName)
+		}
+
+		e.plan(names, f.UniqueName)
+
+		report.add(e)
+	}
+
+	return renamed, nil
+}
+
+// redirect redirects the permalinks to the target, the redirects are noted in the entry
+func (ts *TransferService) redirect(permalinks []string, target string, opts ImportOptions, e *ImportEntry) {
+	if ts.RedirectService == nil {
+		return
+	}
+
+	for _, p := range permalinks {
+		path := RedirectPath(p)
+
+		if len(path) == 0 || path == target {
+			continue
+		}
+
+		if opts.DryRun {
+			e.Note += "; redirect from " + path
+			continue
+		}
+
+		if _, err := ts.RedirectService.Create(&Redirect{Path: path, Target: target}); err != nil {
+			e.Note += fmt.Sprintf("; %s is not redirected %v", path, err)
+			continue
+		}
+
+		e.Note += "; redirect from " + path
+	}
+}
+
+// identicalFile returns the existing file with the same content; besides the name of the file the names the file
+// gets if the name is taken are considered
+func (ts *TransferService) identicalFile(tf TransferFile) (*File, error) {
+	for i := 0; i < 10; i++ {
+		ef, err := ts.FileService.Datasource.GetByUniqueName(importName(tf.UniqueName, i), nil)
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(ts.FileService.Config.Location, ef.UniqueName))
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		if bytes.Equal(data, tf.Data) {
+			return ef, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// siteLink returns the link of the site if it is not taken, otherwise a number is appended to the link
+func (ts *TransferService) siteLink(link string) (string, error) {
+	s := &Site{Link: link}
+
+	if s.isExternal() {
+		return link, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		l := slug.CreateURLSafeSlug(link, i)
+
+		if _, err := ts.SiteService.Datasource.GetByLink(l, All); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return l, nil
+			}
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("the link %s is taken", link)
+}
+
+func importAuthor(users map[string]*User, username string, actor *User) *User {
+	if u, ok := users[username]; ok {
+		return u
+	}
+
+	return actor
+}
+
+// rewriteFileLinks replaces the links to the renamed files
+func rewriteFileLinks(s string, renamed map[string]string) string {
+	if len(renamed) == 0 {
+		return s
+	}
+
+	return fileLinkPattern.ReplaceAllStringFunc(s, func(link string) string {
+		if n, ok := renamed[strings.TrimPrefix(link, "/file/")]; ok {
+			return "/file/" + n
+		}
+		return link
+	})
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
+
+func readJSONEntry(entries map[string]*zip.File, name string, v interface{}) error {
+	f, ok := entries[name]
+
+	if !ok {
+		return fmt.Errorf("the export contains no %s", name)
+	}
+
+	b, err := readEntry(f)
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("the entry %s of the export is invalid %v", name, err)
+	}
+
+	return nil
+}
+
+func readArticleEntry(f *zip.File) (*TransferArticle, error) {
+	fm, err := readMarkdownEntry(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a := &TransferArticle{
+		Headline: fm.fields["title"],
+		Teaser:   fm.fields["teaser"],
+		Content:  fm.body,
+		Slug:     fm.fields["slug"],
+		Author:   fm.fields["author"],
+		Category: fm.fields["category"],
+	}
+
+	a.ID = fm.int("id")
+	a.Published = fm.bool("published")
+	a.PublishedOn = fm.nullTime("published_on")
+	a.LastModified = fm.nullTime("last_modified").Time
+
+	if fm.err != nil {
+		return nil, fmt.Errorf("the entry %s of the export is invalid %v", f.Name, fm.err)
+	}
+
+	return a, nil
+}
+
+func readSiteEntry(f *zip.File) (*TransferSite, error) {
+	fm, err := readMarkdownEntry(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TransferSite{
+		Title:   fm.fields["title"],
+		Link:    fm.fields["link"],
+		Section: fm.fields["section"],
+		Content: fm.body,
+		Author:  fm.fields["author"],
+	}
+
+	s.ID = fm.int("id")
+	s.OrderNo = fm.int("order_no")
+	s.Published = fm.bool("published")
+	s.PublishedOn = fm.nullTime("published_on")
+	s.LastModified = fm.nullTime("last_modified").Time
+
+	if fm.err != nil {
+		return nil, fmt.Errorf("the entry %s of the export is invalid %v", f.Name, fm.err)
+	}
+
+	return s, nil
+}
+
+// frontMatter contains the fields and the markdown of an entry written by writeMarkdownEntry;
+// the first error while converting a field is kept in err
+type frontMatter struct {
+	fields map[string]string
+	body   string
+	err    error
+}
+
+func readMarkdownEntry(f *zip.File) (*frontMatter, error) {
+	b, err := readEntry(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fm, err := parseFrontMatter(string(b))
+
+	if err != nil {
+		return nil, fmt.Errorf("the entry %s of the export is invalid %v", f.Name, err)
+	}
+
+	return fm, nil
+}
+
+// parseFrontMatter parses the fields between the lines '---'; quoted values are unquoted
+func parseFrontMatter(s string) (*frontMatter, error) {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+
+	if len(lines) == 0 || lines[0] != "---" {
+		return nil, errors.New("the front matter is missing")
+	}
+
+	fm := &frontMatter{
+		fields: make(map[string]string),
+	}
+
+	for i, line := range lines[1:] {
+		if line == "---" {
+			body := strings.Join(lines[i+2:], "\n")
+			body = strings.TrimPrefix(body, "\n")
+			fm.body = strings.TrimSuffix(body, "\n")
+			return fm, nil
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+
+		if !ok {
+			return nil, fmt.Errorf("the line '%s' of the front matter is invalid", line)
+		}
+
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			uq, err := strconv.Unquote(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("the value of %s is invalid %v", key, err)
+			}
+
+			value = uq
+		}
+
+		fm.fields[strings.TrimSpace(key)] = value
+	}
+
+	return nil, errors.New("the end of the front matter is missing")
+}
+
+func (fm *frontMatter) int(key string) int {
+	v, ok := fm.fields[key]
+
+	if !ok || fm.err != nil {
+		return 0
+	}
+
+	i, err := strconv.Atoi(v)
+
+	if err != nil {
+		fm.err = fmt.Errorf("the value of %s is invalid %v", key, err)
+	}
+
+	return i
+}
+
+func (fm *frontMatter) bool(key string) bool {
+	v, ok := fm.fields[key]
+
+	if !ok || fm.err != nil {
+		return false
+	}
+
+	b, err := strconv.ParseBool(v)
+
+	if err != nil {
+		fm.err = fmt.Errorf("the value of %s is invalid %v", key, err)
+	}
+
+	return b
+}
+
+func (fm *frontMatter) nullTime(key string) NullTime {
+	v := fm.fields[key]
+
+	if len(v) == 0 || fm.err != nil {
+		return NullTime{}
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+
+	if err != nil {
+		fm.err = fmt.Errorf("the value of %s is invalid %v", key, err)
+		return NullTime{}
+	}
+
+	return NullTime{Time: t, Valid: true}
+}
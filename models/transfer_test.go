@@ -0,0 +1,203 @@
+package models_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+func newTransferService(t *testing.T, db *sql.DB) *models.TransferService {
+	ds := models.NewSQLiteDatasources(db)
+
+	return &models.TransferService{
+		UserService:    &models.UserService{Datasource: ds.Users},
+		ArticleService: &models.ArticleService{Datasource: ds.Articles},
+		SiteService:    &models.SiteService{Datasource: ds.Sites},
+		FileService: &models.FileService{
+			Datasource: ds.Files,
+			Config: settings.File{
+				Location:              t.TempDir(),
+				AllowedFileExtensions: settings.AllowedFileExts{".png": ".png"},
+			},
+		},
+		CategoryService: &models.CategoryService{Datasource: ds.Categories},
+	}
+}
+
+func createTransferUser(t *testing.T, ts *models.TransferService, username string, role models.Role) *models.User {
+	u := &models.User{
+		Username:      username,
+		Email:         username + "@example.com",
+		DisplayName:   username,
+		PlainPassword: []byte("secret-password"),
+		Role:          role,
+		Active:        true,
+	}
+
+	id, err := ts.UserService.Create(u, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.ID = id
+
+	return u
+}
+
+func createTransferArticle(t *testing.T, ts *models.TransferService, author *models.User, content string) *models.Article {
+	a := &models.Article{
+		Headline: "Hello transfer",
+		Teaser:   "the teaser\nwith two lines",
+		Content:  content,
+		Author:   author,
+	}
+
+	id, err := ts.ArticleService.Create(a)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.ID = id
+
+	return a
+}
+
+func TestTransferExportImport(t *testing.T) {
+	srcDB := setupSessionDB(t)
+	defer srcDB.Close()
+
+	src := newTransferService(t, srcDB)
+
+	alice := createTransferUser(t, src, "alice", models.RoleAdmin)
+	bob := createTransferUser(t, src, "bob", models.RoleAuthor)
+
+	if _, err := src.CategoryService.Create(&models.Category{Name: "Go", Author: alice}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.FileService.Upload(&models.File{FullFilename: "a.png", ContentType: "image/png", Data: []byte("source"), Author: bob}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := createTransferArticle(t, src, bob, "![image](/file/a.png)")
+
+	if _, err := srcDB.Exec("UPDATE article SET category_id=(SELECT id FROM category WHERE name='Go') WHERE id=?", a.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.ArticleService.Publish(a.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.SiteService.Create(&models.Site{Title: "About", Link: "about", Section: "navigation", Content: "about", Author: alice}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dstDB := setupSessionDB(t)
+	defer dstDB.Close()
+
+	dst := newTransferService(t, dstDB)
+
+	carol := createTransferUser(t, dst, "carol", models.RoleAdmin)
+
+	// the slug, the link and the filename of the imported content are taken
+	createTransferArticle(t, dst, carol, "existing")
+
+	if _, err := dst.SiteService.Create(&models.Site{Title: "About", Link: "about", Section: "footer", Content: "about", Author: carol}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dst.FileService.Upload(&models.File{FullFilename: "a.png", ContentType: "image/png", Data: []byte("existing"), Author: carol}); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := dst.Import(zr, carol, models.ImportOptions{CreateUsers: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		typ    string
+		result models.ImportResult
+		count  int
+	}{
+		{models.AuditUser, models.ImportCreated, 2},
+		{models.AuditCategory, models.ImportCreated, 1},
+		{models.AuditFile, models.ImportRenamed, 1},
+		{models.AuditArticle, models.ImportRenamed, 1},
+		{models.AuditSite, models.ImportRenamed, 1},
+	} {
+		if n := report.Count(c.typ, c.result); n != c.count {
+			t.Errorf("expected %d %s entries with the result %s, but got %d: %v", c.count, c.typ, c.result, n, report.Entries)
+		}
+	}
+
+	importedBob, err := dst.UserService.GetByUsername("bob")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if importedBob.Active {
+		t.Error("expected the imported user to be inactive")
+	}
+
+	articles, err := dst.ArticleService.ListByAuthor(importedBob, nil, models.All)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(articles) != 1 {
+		t.Fatalf("expected one article of the imported user, but got %d", len(articles))
+	}
+
+	ia := articles[0]
+
+	if !ia.Published || !ia.PublishedOn.Valid || ia.Slug == a.Slug || ia.CName.String != "Go" {
+		t.Errorf("unexpected imported article %v", ia)
+	}
+
+	if ia.Teaser != a.Teaser {
+		t.Errorf("expected the teaser %q, but got %q", a.Teaser, ia.Teaser)
+	}
+
+	if !strings.Contains(ia.Content, "/file/a1.png") {
+		t.Errorf("expected the link to the renamed file, but got %s", ia.Content)
+	}
+
+	if _, err := dst.SiteService.GetByLink("about1", models.All); err != nil {
+		t.Errorf("expected the imported site with the link about1 %v", err)
+	}
+
+	// importing the archive again does not import the files twice
+	report, err = dst.Import(zr, carol, models.ImportOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count(models.AuditUser, models.ImportMapped) != 2 || report.Count(models.AuditFile, models.ImportMapped) != 1 {
+		t.Errorf("expected the existing users and files to be used, but got %v", report.Entries)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/settings"
+	"git.hoogi.eu/snafu/go-blog/totp"
+	"rsc.io/qr"
+)
+
+// RecoveryCodeDatasourceService defines an interface for CRUD operations of recovery codes
+type RecoveryCodeDatasourceService interface {
+	Create(rc *RecoveryCode) (int, error)
+	ListByUser(userID int) ([]RecoveryCode, error)
+	Remove(codeID int) error
+	RemoveByUser(userID int) error
+}
+
+// RecoveryCode represents a one-time recovery code, which can be used instead of a TOTP code;
+// only the hash of the code is saved
+type RecoveryCode struct {
+	ID        int
+	Hash      string
+	CreatedAt time.Time
+
+	Author *User
+}
+
+const recoveryCodeCount = 10
+
+// TwoFactorService containing the service to set up and verify the second factor
+type TwoFactorService struct {
+	Datasource  RecoveryCodeDatasourceService
+	UserService *UserService
+	AppConfig   settings.Application
+	Config      settings.User
+}
+
+// Required returns true if the user has to use two-factor authentication
+func (tfs *TwoFactorService) Required(u *User) bool {
+	return u.RequireTwoFactor || (u.Role == RoleAdmin && tfs.Config.TwoFactorRequiredForAdmins)
+}
+
+// NewSecret returns a new TOTP secret which has to be confirmed with Enable
+func (tfs *TwoFactorService) NewSecret() string {
+	return totp.GenerateSecret()
+}
+
+// ProvisioningURI returns the otpauth URI for the secret
+func (tfs *TwoFactorService) ProvisioningURI(u *User, secret string) string {
+	return totp.ProvisioningURI(tfs.AppConfig.Title, u.Username, secret)
+}
+
+// QRCode returns the provisioning URI of the secret as PNG encoded QR code
+func (tfs *TwoFactorService) QRCode(u *User, secret string) ([]byte, error) {
+	c, err := qr.Encode(tfs.ProvisioningURI(u, secret), qr.M)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PNG(), nil
+}
+
+// Enable enables two-factor authentication if the passcode matches the secret
+// The generated recovery codes are returned and will not be shown again
+func (tfs *TwoFactorService) Enable(u *User, secret, passcode string) ([]string, error) {
+	if u.TOTPEnabled {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"Two-factor authentication is already enabled.",
+			fmt.Errorf("two-factor authentication for user %d is already enabled", u.ID))
+	}
+
+	counter, ok := totp.Validate(secret, passcode, time.Now())
+
+	if !ok {
+		return nil, httperror.New(http.StatusUnprocessableEntity,
+			"The verification code is invalid. Please try again.",
+			fmt.Errorf("invalid verification code while enabling two-factor authentication for user %d", u.ID))
+	}
+
+	if err := tfs.UserService.Datasource.UpdateTOTP(u.ID, secret, true); err != nil {
+		return nil, err
+	}
+
+	// the code used for enabling can't be used for a login
+	if _, err := tfs.UserService.Datasource.UseTOTPCounter(u.ID, counter); err != nil {
+		return nil, err
+	}
+
+	u.TOTPSecret = secret
+	u.TOTPEnabled = true
+
+	return tfs.RegenerateRecoveryCodes(u)
+}
+
+// Disable disables two-factor authentication and removes the recovery codes
+// If the two-factor authentication is required for the user, only an admin is allowed to disable it
+func (tfs *TwoFactorService) Disable(u *User, cu *User) error {
+	if tfs.Required(u) && !cu.Can(PermUserManage) {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"Two-factor authentication is required for your account and can't be disabled.",
+			fmt.Errorf("user %d tried to disable the required two-factor authentication", u.ID))
+	}
+
+	if u.ID != cu.ID && !cu.Can(PermUserManage) {
+		return httperror.PermissionDenied("disable", "two-factor authentication", fmt.Errorf("user %d is not allowed to disable two-factor authentication of user %d", cu.ID, u.ID))
+	}
+
+	if err := tfs.UserService.Datasource.UpdateTOTP(u.ID, "", false); err != nil {
+		return err
+	}
+
+	u.TOTPSecret = ""
+	u.TOTPEnabled = false
+
+	return tfs.Datasource.RemoveByUser(u.ID)
+}
+
+// RegenerateRecoveryCodes invalidates all existing recovery codes of the user and returns new ones
+func (tfs *TwoFactorService) RegenerateRecoveryCodes(u *User) ([]string, error) {
+	if err := tfs.Datasource.RemoveByUser(u.ID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		c := crypt.AlphaLower.RandomSequence(10)
+		code := string(c[:5]) + "-" + string(c[5:])
+
+		rc := &RecoveryCode{
+			Hash:   crypt.Hash([]byte(code)),
+			Author: u,
+		}
+
+		if _, err := tfs.Datasource.Create(rc); err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes
+func (tfs *TwoFactorService) CountRecoveryCodes(u *User) (int, error) {
+	rcs, err := tfs.Datasource.ListByUser(u.ID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return len(rcs), nil
+}
+
+// Verify checks the passcode of the user; the passcode is either a TOTP code or a recovery code
+// A used recovery code is removed; a TOTP code is rejected if its time step or a later one was used before
+func (tfs *TwoFactorService) Verify(u *User, passcode string) error {
+	if !u.TOTPEnabled {
+		return httperror.InternalServerError(fmt.Errorf("two-factor authentication for user %d is not enabled", u.ID))
+	}
+
+	passcode = strings.ToLower(strings.TrimSpace(passcode))
+
+	if len(passcode) == 0 {
+		return httperror.ValueRequired("verification code")
+	}
+
+	if counter, ok := totp.Validate(u.TOTPSecret, passcode, time.Now()); ok {
+		used, err := tfs.UserService.Datasource.UseTOTPCounter(u.ID, counter)
+
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return httperror.New(http.StatusUnauthorized, "The verification code was already used. Please wait for the next code.",
+				fmt.Errorf("the TOTP code of the time step %d was used again by user %d", counter, u.ID))
+		}
+
+		return nil
+	}
+
+	rcs, err := tfs.Datasource.ListByUser(u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	hash := []byte(crypt.Hash([]byte(passcode)))
+
+	for _, rc := range rcs {
+		if subtle.ConstantTimeCompare(hash, []byte(rc.Hash)) == 1 {
+			return tfs.Datasource.Remove(rc.ID)
+		}
+	}
+
+	return httperror.New(http.StatusUnauthorized, "The verification code is invalid.", errors.New("invalid verification code"))
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -16,7 +17,6 @@ import (
 	"git.hoogi.eu/snafu/go-blog/httperror"
 	"git.hoogi.eu/snafu/go-blog/logger"
 	"git.hoogi.eu/snafu/go-blog/settings"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserDatasourceService defines an interface for CRUD operations for users
@@ -28,28 +28,57 @@ type UserDatasourceService interface {
 	Count(ac AdminCriteria) (int, error)
 	GetByMail(mail string) (*User, error)
 	GetByUsername(username string) (*User, error)
+	UpdateTOTP(userID int, secret string, enabled bool) error
+	UseTOTPCounter(userID int, counter uint64) (bool, error)
+	UpdatePassword(userID int, password []byte) error
+	UpdateProfile(u *User) error
+	RemoveAccount(userID, successorID int) error
+	RecordLogin(userID int, ip string) error
+	ListDormant(idleSince time.Time) ([]User, error)
+	UpdateDormantWarned(userID int, warnedAt time.Time) error
+	Deactivate(userID int) error
 	Remove(userID int) error
 }
 
 // User represents a user
 type User struct {
-	ID            int
-	Username      string
-	Email         string
-	DisplayName   string
-	Password      []byte
-	PlainPassword []byte
-	Salt          []byte
-	LastModified  time.Time
-	Active        bool
-	IsAdmin       bool
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"-"`
+	DisplayName   string    `json:"display_name"`
+	Password      []byte    `json:"-"`
+	PlainPassword []byte    `json:"-"`
+	Salt          []byte    `json:"-"`
+	LastModified  time.Time `json:"-"`
+	Active        bool      `json:"-"`
+	Role          Role      `json:"-"`
+
+	TOTPSecret       string `json:"-"`
+	TOTPEnabled      bool   `json:"-"`
+	RequireTwoFactor bool   `json:"-"`
+
+	LastLogin       NullTime `json:"-"`
+	LastLoginIP     string   `json:"-"`
+	DormantWarnedAt NullTime `json:"-"`
+
+	// the public profile shown on the author page
+	Bio         string   `json:"bio,omitempty"`
+	Website     string   `json:"website,omitempty"`
+	Avatar      string   `json:"avatar,omitempty"`
+	SocialLinks []string `json:"social_links,omitempty"`
+
+	// RemoteAddr is the IP address of the request the user is authenticated for, it is recorded in the audit log; not persisted
+	RemoteAddr string `json:"-"`
 }
 
 // UserService containing the service to access users
 type UserService struct {
-	Datasource      UserDatasourceService
-	Config          settings.User
-	UserInterceptor UserInterceptor
+	Datasource         UserDatasourceService
+	Config             settings.User
+	UserInterceptor    UserInterceptor
+	UserSessionService *UserSessionService
+	Authenticator      Authenticator
+	AuditService       *AuditService
 }
 
 // UserInterceptor will be executed before and after updating/creating users
@@ -99,6 +128,12 @@ func (u *User) validate(us *UserService, minPasswordLength int, v Validations) e
 		return httperror.ValueTooLong("username", 60)
 	}
 
+	if !u.Role.Valid() {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"Please select a valid role.",
+			fmt.Errorf("the role '%s' is not valid", u.Role))
+	}
+
 	if (v & VPassword) != 0 {
 		if len(u.PlainPassword) < minPasswordLength && len(u.PlainPassword) > 0 {
 			return httperror.New(http.StatusUnprocessableEntity,
@@ -209,9 +244,9 @@ func (us *UserService) GetByMail(mail string) (*User, error) {
 	return u, nil
 }
 
-// Create creates the user
+// Create creates the user; the actor is the user who creates the user, nil if the user is created by the system
 // If an UserInterceptor is available the action PreCreate is executed before creating and PostCreate after creating the user
-func (us *UserService) Create(u *User) (int, error) {
+func (us *UserService) Create(u *User, actor *User) (int, error) {
 	if us.UserInterceptor != nil {
 		if err := us.UserInterceptor.PreCreate(u); err != nil {
 			return -1, httperror.InternalServerError(fmt.Errorf("error while executing user interceptor 'PreCreate' error %v", err))
@@ -222,47 +257,46 @@ func (us *UserService) Create(u *User) (int, error) {
 		return -1, err
 	}
 
-	salt := crypt.GenerateSalt()
-	saltedPassword := append(u.PlainPassword[:], salt[:]...)
-	password, err := crypt.CryptPassword([]byte(saltedPassword))
-
-	if err != nil {
+	if err := us.hashPassword(u); err != nil {
 		return -1, err
 	}
 
-	u.Salt = salt
-	u.Password = password
-
 	userID, err := us.Datasource.Create(u)
 
 	if err != nil {
 		return -1, err
 	}
 
+	u.ID = userID
+
 	if us.UserInterceptor != nil {
 		errUserInterceptor := us.UserInterceptor.PostCreate(u)
 		logger.Log.Errorf("error while executing PostCreate user interceptor method %v", errUserInterceptor)
 	}
 
-	salt = nil
-	saltedPassword = nil
 	u.PlainPassword = nil
 
+	us.AuditService.Record(actor, AuditCreate, AuditUser, userID, "", u.auditSummary())
+
 	return userID, nil
 }
 
-//Update updates the user
+//Update updates the user; the actor is the user who updates the user, nil if the user is updated by the system
 //If an UserInterceptor is available the action PreUpdate is executed before updating and PostUpdate after updating the user
-func (us *UserService) Update(u *User, changePassword bool) error {
+func (us *UserService) Update(u *User, actor *User, changePassword bool) error {
 	oldUser, err := us.Datasource.Get(u.ID)
 
 	if err != nil {
 		return err
 	}
 
-	if !oldUser.IsAdmin {
-		if oldUser.ID != u.ID {
-			return httperror.PermissionDenied("update", "user", fmt.Errorf("permission denied user %d is not granted to update user %d", oldUser.ID, u.ID))
+	if actor != nil && !actor.Can(PermUserManage) {
+		if actor.ID != u.ID {
+			return httperror.PermissionDenied("update", "user", fmt.Errorf("permission denied user %d is not granted to update user %d", actor.ID, u.ID))
+		}
+
+		if u.Role != oldUser.Role {
+			return httperror.PermissionDenied("change", "role", fmt.Errorf("permission denied user %d is not granted to change the role of user %d", actor.ID, u.ID))
 		}
 	}
 
@@ -297,7 +331,7 @@ func (us *UserService) Update(u *User, changePassword bool) error {
 	}
 
 	if oneAdmin {
-		if (oldUser.IsAdmin && !u.IsAdmin) || (oldUser.IsAdmin && !u.Active) {
+		if (oldUser.Role == RoleAdmin && u.Role != RoleAdmin) || (oldUser.Role == RoleAdmin && !u.Active) {
 			return httperror.New(http.StatusUnprocessableEntity,
 				"Could not update user, because no administrator would remain",
 				fmt.Errorf("could not update user %s action, because no administrator would remain", oldUser.Username))
@@ -305,22 +339,27 @@ func (us *UserService) Update(u *User, changePassword bool) error {
 	}
 
 	if changePassword {
-		salt := crypt.GenerateSalt()
-		saltedPassword := append(u.PlainPassword[:], salt[:]...)
-		password, err := crypt.CryptPassword([]byte(saltedPassword))
-
-		if err != nil {
+		if err := us.hashPassword(u); err != nil {
 			return err
 		}
-
-		u.Password = password
-		u.Salt = salt
 	}
 
 	if err = us.Datasource.Update(u, changePassword); err != nil {
 		return err
 	}
 
+	if changePassword || (oldUser.Active && !u.Active) {
+		us.revokeSessions(u)
+	}
+
+	after := u.auditSummary()
+
+	if changePassword {
+		after += " password=changed"
+	}
+
+	us.AuditService.Record(actor, AuditUpdate, AuditUser, u.ID, oldUser.auditSummary(), after)
+
 	u.Password = nil
 
 	if us.UserInterceptor != nil {
@@ -334,74 +373,325 @@ func (us *UserService) Update(u *User, changePassword bool) error {
 	return nil
 }
 
-// Authenticate authenticates the user by the given login method (email or username)
-// if the user was found but the password is wrong the found user and an error will be returned
-func (us *UserService) Authenticate(u *User, loginMethod settings.LoginMethod) (*User, error) {
-	var err error
+const (
+	maxBioLength   = 2000
+	maxSocialLinks = 10
+)
+
+// validateProfile validates the public profile; the website and the social links must be absolute http(s) URLs
+func (u *User) validateProfile() error {
+	u.Bio = strings.TrimSpace(u.Bio)
+	u.Website = strings.TrimSpace(u.Website)
+
+	if len([]rune(u.Bio)) > maxBioLength {
+		return httperror.ValueTooLong("bio", maxBioLength)
+	}
+
+	if len(u.Website) > 0 {
+		if err := validateProfileURL("website", u.Website); err != nil {
+			return err
+		}
+	}
+
+	var links []string
+
+	for _, l := range u.SocialLinks {
+		l = strings.TrimSpace(l)
+
+		if len(l) == 0 {
+			continue
+		}
+
+		if err := validateProfileURL("social link", l); err != nil {
+			return err
+		}
+
+		links = append(links, l)
+	}
+
+	if len(links) > maxSocialLinks {
+		return httperror.New(http.StatusUnprocessableEntity,
+			fmt.Sprintf("Please enter at most %d social links.", maxSocialLinks),
+			fmt.Errorf("too many social links %d", len(links)))
+	}
+
+	u.SocialLinks = links
+
+	return nil
+}
+
+func validateProfileURL(field, v string) error {
+	if len(v) > 191 {
+		return httperror.ValueTooLong(field, 191)
+	}
+
+	pu, err := url.Parse(v)
+
+	if err != nil || (pu.Scheme != "http" && pu.Scheme != "https") || len(pu.Host) == 0 {
+		return httperror.New(http.StatusUnprocessableEntity,
+			fmt.Sprintf("The %s '%s' is not a valid http or https URL.", field, v),
+			fmt.Errorf("the %s %s is not a valid url %v", field, v, err))
+	}
+
+	return nil
+}
 
-	if len(u.Username) == 0 || len(u.PlainPassword) == 0 {
-		return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", errors.New("no username or password were given"))
+// UpdateProfile updates the public profile of the user: bio, website, avatar and social links; the actor is the
+// user who updates the profile
+func (us *UserService) UpdateProfile(u *User, actor *User) error {
+	oldUser, err := us.Datasource.Get(u.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if actor != nil && actor.ID != u.ID && !actor.Can(PermUserManage) {
+		return httperror.PermissionDenied("update", "user", fmt.Errorf("permission denied user %d is not granted to update the profile of user %d", actor.ID, u.ID))
 	}
 
-	var password = u.PlainPassword
+	if err := u.validateProfile(); err != nil {
+		return err
+	}
 
-	if loginMethod == settings.EMail {
-		u, err = us.Datasource.GetByMail(u.Email)
-	} else {
-		u, err = us.Datasource.GetByUsername(u.Username)
+	if err := us.Datasource.UpdateProfile(u); err != nil {
+		return err
 	}
 
+	us.AuditService.Record(actor, AuditUpdate, AuditUser, u.ID, oldUser.profileSummary(), u.profileSummary())
+
+	return nil
+}
+
+// GetAuthor returns the active user with the username for the public author page
+func (us *UserService) GetAuthor(username string) (*User, error) {
+	u, err := us.Datasource.GetByUsername(username)
+
 	if err != nil {
-		//Do some extra work
-		bcrypt.CompareHashAndPassword([]byte("$2a$12$bQlRnXTNZMp6kCyoAlnf3uZW5vtmSj9CHP7pYplRUVK2n0C5xBHBa"), password)
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", err)
+			return nil, httperror.NotFound("author", fmt.Errorf("the author %s was not found", username))
 		}
 		return nil, err
 	}
 
-	u.PlainPassword = password
-
-	if err := u.comparePassword(); err != nil {
-		return u, httperror.New(http.StatusUnauthorized, "Your username or password is invalid.", err)
-	}
-
 	if !u.Active {
-		return nil, httperror.New(http.StatusUnprocessableEntity,
-			"Your account is deactivated.",
-			fmt.Errorf("the user with id %d tried to logged in but the account is deactivated", u.ID))
+		return nil, httperror.NotFound("author", fmt.Errorf("the author %s is deactivated", username))
 	}
 
-	u.PlainPassword = nil
 	u.Password = nil
 	u.Salt = nil
+	u.TOTPSecret = ""
 
 	return u, nil
 }
 
-// Remove removes the user returns an error if no administrator would remain
-func (us *UserService) Remove(u *User) error {
-	if us.UserInterceptor != nil {
-		if err := us.UserInterceptor.PreRemove(u); err != nil {
-			return httperror.InternalServerError(fmt.Errorf("error while executing user interceptor 'PreRemove' error %v", err))
-		}
+// CheckEmail returns an error if the email address is not valid or already used by another user
+func (us *UserService) CheckEmail(email string) error {
+	email = strings.TrimSpace(email)
+
+	if len(email) == 0 {
+		return httperror.ValueRequired("email")
 	}
 
-	oneAdmin, err := us.OneAdmin()
+	if len(email) > 191 {
+		return httperror.ValueTooLong("email", 191)
+	}
+
+	return us.duplicateMail(email)
+}
+
+// ChangeEmail sets the confirmed email address of the user; the actor is the user who confirmed the address
+func (us *UserService) ChangeEmail(userID int, email string, actor *User) (*User, error) {
+	u, err := us.Datasource.Get(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.Email = email
+
+	if err := us.Update(u, actor, false); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// RevertEmail restores the previous email address of the user and revokes all sessions, because the account may be
+// taken over by the one who changed the email address
+func (us *UserService) RevertEmail(userID int, email string, actor *User) (*User, error) {
+	u, err := us.ChangeEmail(userID, email, actor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	us.revokeSessions(u)
+
+	return u, nil
+}
+
+// Authenticate authenticates the user by the given login method (email or username) with the configured authenticator;
+// if no authenticator is set the password is checked against the hash in the database
+func (us *UserService) Authenticate(u *User, loginMethod settings.LoginMethod) (*User, error) {
+	if us.Authenticator == nil {
+		return SQLiteAuthenticator{Datasource: us.Datasource, Params: us.PasswordParams()}.Authenticate(u, loginMethod)
+	}
+
+	return us.Authenticator.Authenticate(u, loginMethod)
+}
+
+// RecordLogin records the time and the IP address of a successful login; a pending dormancy warning is reset
+func (us *UserService) RecordLogin(u *User) error {
+	return us.Datasource.RecordLogin(u.ID, u.RemoteAddr)
+}
+
+// lastActivity returns the last login or the last modification of the user, whichever is later; so a reactivation
+// by an administrator starts a new dormancy period
+func (u *User) lastActivity() time.Time {
+	if u.LastLogin.Valid && u.LastLogin.Time.After(u.LastModified) {
+		return u.LastLogin.Time
+	}
+
+	return u.LastModified
+}
+
+// Maintain warns the non-admin users without a login for the configured dormancy period minus the warning period
+// by mail and deactivates them if they did not log in for the dormancy period and were warned long enough ago
+// since their last activity
+func (us *UserService) Maintain(mailer *Mailer) error {
+	if us.Config.DormantAfter <= 0 {
+		return nil
+	}
+
+	warnAfter := us.Config.DormantAfter - us.Config.DormantWarning
+
+	if warnAfter < 0 {
+		warnAfter = 0
+	}
+
+	now := time.Now()
+
+	users, err := us.Datasource.ListDormant(now.Add(-warnAfter))
 
 	if err != nil {
 		return err
 	}
 
-	if oneAdmin {
-		if u.IsAdmin {
-			return httperror.New(http.StatusUnprocessableEntity,
-				"Could not remove administrator. No Administrator would remain.",
-				fmt.Errorf("could not remove administrator %s no administrator would remain", u.Username))
+	for _, u := range users {
+		u := u
+
+		deactivateAt := u.lastActivity().Add(us.Config.DormantAfter)
+
+		if us.Config.DormantWarning > 0 {
+			// a warning before the last activity e.g. an update of the user or the reactivation is outdated
+			if !u.DormantWarnedAt.Valid || u.DormantWarnedAt.Time.Before(u.lastActivity()) {
+				if err := us.Datasource.UpdateDormantWarned(u.ID, now); err != nil {
+					return err
+				}
+
+				if earliest := now.Add(us.Config.DormantWarning); deactivateAt.Before(earliest) {
+					deactivateAt = earliest
+				}
+
+				mailer.SendDormancyWarning(&u, deactivateAt)
+
+				continue
+			}
+
+			if now.Before(u.DormantWarnedAt.Time.Add(us.Config.DormantWarning)) {
+				continue
+			}
+		}
+
+		if now.Before(deactivateAt) {
+			continue
 		}
+
+		if err := us.Datasource.Deactivate(u.ID); err != nil {
+			return err
+		}
+
+		logger.Log.Infof("deactivated the dormant user %s", u.Username)
+
+		us.revokeSessions(&u)
+
+		u.Active = false
+
+		us.AuditService.Record(nil, AuditUpdate, AuditUser, u.ID, u.auditSummary()+" dormant=true", "")
 	}
 
-	err = us.Datasource.Remove(u.ID)
+	return nil
+}
+
+// InitMaintenance runs the maintenance of the dormant users on every tick
+func (us *UserService) InitMaintenance(ticker *time.Ticker, mailer *Mailer) {
+	go func() {
+		for range ticker.C {
+			if err := us.Maintain(mailer); err != nil {
+				logger.Log.Errorf("error while maintaining the dormant users %v", err)
+			}
+		}
+	}()
+}
+
+// Remove removes the user returns an error if no administrator would remain; the actor is the user who removes the user
+func (us *UserService) Remove(u *User, actor *User) error {
+	return us.remove(u, actor, func() error {
+		return us.Datasource.Remove(u.ID)
+	})
+}
+
+// RemoveAccount removes the user in one transaction; the articles, sites, files and categories are transferred to the
+// successor before, without a successor the files are removed along with the user. The files are not removed from the
+// disk. The actor is the user who removes the account
+func (us *UserService) RemoveAccount(u *User, successor *User, actor *User) error {
+	if successor == nil {
+		return us.remove(u, actor, func() error {
+			return us.Datasource.RemoveAccount(u.ID, 0)
+		})
+	}
+
+	if u.ID == successor.ID {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"Please choose another user to take over the content.",
+			fmt.Errorf("the content of user %d can not be reassigned to the same user", u.ID))
+	}
+
+	if !successor.Active {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"The user to take over the content is deactivated.",
+			fmt.Errorf("the content of user %d can not be reassigned to the deactivated user %d", u.ID, successor.ID))
+	}
+
+	return us.remove(u, actor, func() error {
+		if err := us.Datasource.RemoveAccount(u.ID, successor.ID); err != nil {
+			return err
+		}
+
+		us.AuditService.Record(actor, AuditUpdate, AuditUser, u.ID, u.auditSummary(), fmt.Sprintf("content reassigned to username=%q", successor.Username))
+
+		return nil
+	})
+}
+
+// remove runs the interceptors and the checks around the removal of the user
+func (us *UserService) remove(u *User, actor *User, remove func() error) error {
+	if us.UserInterceptor != nil {
+		if err := us.UserInterceptor.PreRemove(u); err != nil {
+			return httperror.InternalServerError(fmt.Errorf("error while executing user interceptor 'PreRemove' error %v", err))
+		}
+	}
+
+	if err := us.CheckRemovable(u); err != nil {
+		return err
+	}
+
+	err := remove()
+
+	if err == nil {
+		us.revokeSessions(u)
+		us.AuditService.Record(actor, AuditDelete, AuditUser, u.ID, u.auditSummary(), "")
+	}
 
 	if us.UserInterceptor != nil {
 		if err := us.UserInterceptor.PostRemove(u); err != nil {
@@ -412,6 +702,30 @@ func (us *UserService) Remove(u *User) error {
 	return err
 }
 
+// CheckRemovable returns an error if the user is the last administrator
+func (us *UserService) CheckRemovable(u *User) error {
+	oneAdmin, err := us.OneAdmin()
+
+	if err != nil {
+		return err
+	}
+
+	if oneAdmin && u.Role == RoleAdmin {
+		return httperror.New(http.StatusUnprocessableEntity,
+			"Could not remove administrator. No Administrator would remain.",
+			fmt.Errorf("could not remove administrator %s no administrator would remain", u.Username))
+	}
+
+	return nil
+}
+
+// revokeSessions signs out all sessions of the user
+func (us *UserService) revokeSessions(u *User) {
+	if us.UserSessionService != nil {
+		us.UserSessionService.RevokeAll(u, "")
+	}
+}
+
 // OneAdmin returns true if there is only one admin
 func (us *UserService) OneAdmin() (bool, error) {
 	c, err := us.Datasource.Count(OnlyAdmins)
@@ -427,6 +741,31 @@ func (us *UserService) OneAdmin() (bool, error) {
 	return false, nil
 }
 
-func (u *User) comparePassword() error {
-	return bcrypt.CompareHashAndPassword(u.Password, append(u.PlainPassword[:], u.Salt[:]...))
+// PasswordParams returns the configured argon2id parameters for new password hashes;
+// the defaults are used if no parameters are configured
+func (us *UserService) PasswordParams() crypt.PasswordParams {
+	if us.Config.PasswordHashMemory == 0 || us.Config.PasswordHashIterations == 0 || us.Config.PasswordHashParallelism == 0 {
+		return crypt.DefaultPasswordParams
+	}
+
+	return crypt.PasswordParams{
+		Memory:      uint32(us.Config.PasswordHashMemory),
+		Iterations:  uint32(us.Config.PasswordHashIterations),
+		Parallelism: uint8(us.Config.PasswordHashParallelism),
+	}
+}
+
+// hashPassword hashes the plain password with argon2id; the salt is part of the encoded hash,
+// the salt column is only used by legacy bcrypt hashes
+func (us *UserService) hashPassword(u *User) error {
+	password, err := crypt.HashPassword(u.PlainPassword, us.PasswordParams())
+
+	if err != nil {
+		return err
+	}
+
+	u.Password = password
+	u.Salt = []byte{}
+
+	return nil
 }
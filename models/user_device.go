@@ -0,0 +1,103 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+)
+
+// UserDeviceDatasourceService defines an interface for CRUD operations for the known devices of users
+type UserDeviceDatasourceService interface {
+	Create(d *UserDevice) (int, error)
+	Get(userID int, fingerprint string) (*UserDevice, error)
+	Count(userID int) (int, error)
+	Update(d *UserDevice) error
+	Remove(userID int, fingerprint string) error
+}
+
+// UserDevice represents a browser the user logged in with; the fingerprint is the hash of the device cookie and the user agent
+type UserDevice struct {
+	ID          int
+	Fingerprint string
+	UserAgent   string
+	IP          string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+
+	Author *User
+}
+
+// UserDeviceService containing the service to recognize the devices of users
+type UserDeviceService struct {
+	Datasource UserDeviceDatasourceService
+}
+
+// Fingerprint returns the fingerprint of the device by the value of the device cookie and the user agent
+func Fingerprint(cookie, userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return crypt.Hash([]byte(cookie + "\n" + userAgent))
+}
+
+// Recognize remembers the device the user logged in with; it returns the device and true if the device is unfamiliar,
+// the first device of an user is not considered as unfamiliar
+func (uds *UserDeviceService) Recognize(u *User, cookie, userAgent string) (*UserDevice, bool, error) {
+	fingerprint := Fingerprint(cookie, userAgent)
+
+	d, err := uds.Datasource.Get(u.ID, fingerprint)
+
+	if err == nil {
+		d.IP = u.RemoteAddr
+
+		if err := uds.Datasource.Update(d); err != nil {
+			return nil, false, err
+		}
+
+		return d, false, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	c, err := uds.Datasource.Count(u.ID)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	d = &UserDevice{
+		Fingerprint: fingerprint,
+		UserAgent:   userAgent,
+		IP:          u.RemoteAddr,
+		Author:      u,
+	}
+
+	if d.ID, err = uds.Datasource.Create(d); err != nil {
+		return nil, false, err
+	}
+
+	return d, c > 0, nil
+}
+
+// Get returns the known device of the user by its fingerprint
+func (uds *UserDeviceService) Get(u *User, fingerprint string) (*UserDevice, error) {
+	return uds.Datasource.Get(u.ID, fingerprint)
+}
+
+// Forget removes the device from the known devices of the user, so a login with it is unfamiliar again
+func (uds *UserDeviceService) Forget(u *User, fingerprint string) error {
+	return uds.Datasource.Remove(u.ID, fingerprint)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostgresUserDeviceDatasource providing an implementation of UserDeviceDatasourceService for PostgreSQL
+type PostgresUserDeviceDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new known device of the user
+func (rdb *PostgresUserDeviceDatasource) Create(d *UserDevice) (int, error) {
+	now := time.Now()
+
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO user_device (fingerprint, user_agent, ip, first_seen, last_seen, user_id) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+		d.Fingerprint, d.UserAgent, d.IP, now, now, d.Author.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// Get returns the known device of the user by its fingerprint
+func (rdb *PostgresUserDeviceDatasource) Get(userID int, fingerprint string) (*UserDevice, error) {
+	var d UserDevice
+	var u User
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, fingerprint, user_agent, ip, first_seen, last_seen, user_id FROM user_device "+
+		"WHERE user_id=$1 AND fingerprint=$2 ", userID, fingerprint).
+		Scan(&d.ID, &d.Fingerprint, &d.UserAgent, &d.IP, &d.FirstSeen, &d.LastSeen, &u.ID); err != nil {
+		return nil, err
+	}
+
+	d.Author = &u
+
+	return &d, nil
+}
+
+// Count returns the number of known devices of the user
+func (rdb *PostgresUserDeviceDatasource) Count(userID int) (int, error) {
+	var total int
+
+	if err := rdb.SQLConn.QueryRow("SELECT count(id) FROM user_device WHERE user_id=$1 ", userID).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Update sets the time the device was last seen and its last IP address
+func (rdb *PostgresUserDeviceDatasource) Update(d *UserDevice) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user_device SET ip=$1, last_seen=$2 WHERE id=$3 ", d.IP, time.Now(), d.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes the known device of the user
+func (rdb *PostgresUserDeviceDatasource) Remove(userID int, fingerprint string) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM user_device WHERE user_id=$1 AND fingerprint=$2 ", userID, fingerprint); err != nil {
+		return err
+	}
+
+	return nil
+}
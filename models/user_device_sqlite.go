@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SQLiteUserDeviceDatasource providing an implementation of UserDeviceDatasourceService for SQLite
+type SQLiteUserDeviceDatasource struct {
+	SQLConn *sql.DB
+}
+
+// Create saves a new known device of the user
+func (rdb *SQLiteUserDeviceDatasource) Create(d *UserDevice) (int, error) {
+	now := time.Now()
+
+	res, err := rdb.SQLConn.Exec("INSERT INTO user_device (fingerprint, user_agent, ip, first_seen, last_seen, user_id) VALUES(?, ?, ?, ?, ?, ?)",
+		d.Fingerprint, d.UserAgent, d.IP, now, now, d.Author.ID)
+
+	if err != nil {
+		return -1, err
+	}
+
+	i, err := res.LastInsertId()
+
+	if err != nil {
+		return -1, err
+	}
+
+	return int(i), nil
+}
+
+// Get returns the known device of the user by its fingerprint
+func (rdb *SQLiteUserDeviceDatasource) Get(userID int, fingerprint string) (*UserDevice, error) {
+	var d UserDevice
+	var u User
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, fingerprint, user_agent, ip, first_seen, last_seen, user_id FROM user_device "+
+		"WHERE user_id=? AND fingerprint=? ", userID, fingerprint).
+		Scan(&d.ID, &d.Fingerprint, &d.UserAgent, &d.IP, &d.FirstSeen, &d.LastSeen, &u.ID); err != nil {
+		return nil, err
+	}
+
+	d.Author = &u
+
+	return &d, nil
+}
+
+// Count returns the number of known devices of the user
+func (rdb *SQLiteUserDeviceDatasource) Count(userID int) (int, error) {
+	var total int
+
+	if err := rdb.SQLConn.QueryRow("SELECT count(id) FROM user_device WHERE user_id=? ", userID).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Update sets the time the device was last seen and its last IP address
+func (rdb *SQLiteUserDeviceDatasource) Update(d *UserDevice) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user_device SET ip=?, last_seen=? WHERE id=? ", d.IP, time.Now(), d.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove removes the known device of the user
+func (rdb *SQLiteUserDeviceDatasource) Remove(userID int, fingerprint string) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM user_device WHERE user_id=? AND fingerprint=? ", userID, fingerprint); err != nil {
+		return err
+	}
+
+	return nil
+}
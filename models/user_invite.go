@@ -4,7 +4,9 @@ import (
 	"time"
 
 	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/logger"
 	"git.hoogi.eu/snafu/go-blog/mail"
+	"git.hoogi.eu/snafu/go-blog/settings"
 )
 
 // TODO: refactor
@@ -16,17 +18,26 @@ type UserInvite struct {
 	Email       string
 	DisplayName string
 	CreatedAt   time.Time
-	IsAdmin     bool
+	Role        Role
+	Reminded    bool
+
+	// ExpiresAt is zero if invitations do not expire
+	ExpiresAt time.Time
 
 	CreatedBy *User
 }
 
+// Expired returns true if the invitation can not be accepted anymore
+func (ui UserInvite) Expired() bool {
+	return !ui.ExpiresAt.IsZero() && time.Now().After(ui.ExpiresAt)
+}
+
 func (ui UserInvite) Copy() *User {
 	return &User{
 		Username:    ui.Username,
 		Email:       ui.Email,
 		DisplayName: ui.DisplayName,
-		IsAdmin:     ui.IsAdmin,
+		Role:        ui.Role,
 	}
 }
 
@@ -42,9 +53,11 @@ type UserInviteDatasourceService interface {
 
 // UserInviteService
 type UserInviteService struct {
-	Datasource  UserInviteDatasourceService
-	UserService *UserService
-	MailService *mail.Service
+	Datasource   UserInviteDatasourceService
+	UserService  *UserService
+	MailService  *mail.Service
+	AuditService *AuditService
+	Config       settings.User
 }
 
 // validate A user invitation must conform the user validations except the password checks
@@ -54,8 +67,19 @@ func (ui UserInvite) validate(uis *UserInviteService) error {
 	return user.validate(uis.UserService, -1, VDupEmail|VDupUsername)
 }
 
+// List returns all invitations including the expired ones, which are not removed yet
 func (uis *UserInviteService) List() ([]UserInvite, error) {
-	return uis.Datasource.List()
+	invites, err := uis.Datasource.List()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range invites {
+		uis.setExpiry(&invites[i])
+	}
+
+	return invites, nil
 }
 
 func (uis *UserInviteService) Update(ui *UserInvite) error {
@@ -65,7 +89,22 @@ func (uis *UserInviteService) Update(ui *UserInvite) error {
 		return err
 	}
 
-	return uis.Datasource.Update(ui)
+	oldInvite, err := uis.Datasource.Get(ui.ID)
+
+	if err != nil {
+		return err
+	}
+
+	ui.CreatedAt = oldInvite.CreatedAt
+	ui.Reminded = oldInvite.Reminded
+
+	if err := uis.Datasource.Update(ui); err != nil {
+		return err
+	}
+
+	uis.AuditService.Record(ui.CreatedBy, AuditUpdate, AuditUserInvite, ui.ID, oldInvite.auditSummary(), ui.auditSummary())
+
+	return nil
 }
 
 func (uis *UserInviteService) Create(ui *UserInvite) (int, error) {
@@ -75,17 +114,147 @@ func (uis *UserInviteService) Create(ui *UserInvite) (int, error) {
 		return -1, err
 	}
 
-	return uis.Datasource.Create(ui)
+	id, err := uis.Datasource.Create(ui)
+
+	if err != nil {
+		return -1, err
+	}
+
+	ui.CreatedAt = time.Now()
+	uis.setExpiry(ui)
+
+	uis.AuditService.Record(ui.CreatedBy, AuditCreate, AuditUserInvite, id, "", ui.auditSummary())
+
+	return id, nil
 }
 
 func (uis *UserInviteService) Get(inviteID int) (*UserInvite, error) {
-	return uis.Datasource.Get(inviteID)
+	ui, err := uis.Datasource.Get(inviteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	uis.setExpiry(ui)
+
+	return ui, nil
 }
 
+// GetByHash returns the invitation of the hash; the caller has to check if the invitation is expired
 func (uis *UserInviteService) GetByHash(hash string) (*UserInvite, error) {
-	return uis.Datasource.GetByHash(hash)
+	ui, err := uis.Datasource.GetByHash(hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	uis.setExpiry(ui)
+
+	return ui, nil
+}
+
+// Renew generates a new hash for the invitation and restarts its lifetime; the actor is the user who renews the invitation
+func (uis *UserInviteService) Renew(inviteID int, actor *User) (*UserInvite, error) {
+	ui, err := uis.Datasource.Get(inviteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	before := ui.auditSummary()
+
+	ui.Hash = crypt.RandomHash(32)
+	ui.CreatedAt = time.Now()
+	ui.Reminded = false
+
+	if err := uis.Datasource.Update(ui); err != nil {
+		return nil, err
+	}
+
+	uis.setExpiry(ui)
+
+	uis.AuditService.Record(actor, AuditUpdate, AuditUserInvite, ui.ID, before, ui.auditSummary()+" renewed=true")
+
+	return ui, nil
 }
 
-func (uis *UserInviteService) Remove(inviteID int) error {
-	return uis.Datasource.Remove(inviteID)
+// Maintain removes invitations expired for longer than the invitation lifetime and sends a reminder to invited users whose invitation expires soon
+func (uis *UserInviteService) Maintain(mailer *Mailer) error {
+	invites, err := uis.List()
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, ui := range invites {
+		ui := ui
+
+		if ui.Expired() {
+			// the expired invitation is kept for another lifetime, so the invited user is told to ask for a new one
+			if now.Before(ui.ExpiresAt.Add(uis.Config.InviteLifetime)) {
+				continue
+			}
+
+			if err := uis.Datasource.Remove(ui.ID); err != nil {
+				return err
+			}
+
+			logger.Log.Infof("removed the expired invitation of %s", ui.Username)
+
+			uis.AuditService.Record(nil, AuditDelete, AuditUserInvite, ui.ID, ui.auditSummary()+" expired=true", "")
+
+			continue
+		}
+
+		if ui.ExpiresAt.IsZero() || ui.Reminded || uis.Config.InviteReminder <= 0 || now.Before(ui.ExpiresAt.Add(-uis.Config.InviteReminder)) {
+			continue
+		}
+
+		ui.Reminded = true
+
+		if err := uis.Datasource.Update(&ui); err != nil {
+			return err
+		}
+
+		mailer.SendInviteReminder(&ui)
+	}
+
+	return nil
+}
+
+// InitMaintenance runs the maintenance of the invitations on every tick
+func (uis *UserInviteService) InitMaintenance(ticker *time.Ticker, mailer *Mailer) {
+	go func() {
+		for range ticker.C {
+			if err := uis.Maintain(mailer); err != nil {
+				logger.Log.Errorf("error while maintaining the invitations %v", err)
+			}
+		}
+	}()
+}
+
+// setExpiry sets the expiry of the invitation by the configured lifetime
+func (uis *UserInviteService) setExpiry(ui *UserInvite) {
+	if uis.Config.InviteLifetime > 0 {
+		ui.ExpiresAt = ui.CreatedAt.Add(uis.Config.InviteLifetime)
+	}
+}
+
+// Remove removes the invitation; the actor is the user who removes the invitation or the invited user accepting it
+func (uis *UserInviteService) Remove(inviteID int, actor *User) error {
+	ui, err := uis.Datasource.Get(inviteID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := uis.Datasource.Remove(inviteID); err != nil {
+		return err
+	}
+
+	uis.AuditService.Record(actor, AuditDelete, AuditUserInvite, ui.ID, ui.auditSummary(), "")
+
+	return nil
 }
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"time"
+)
+
+// PostgresUserInviteDatasource providing an implementation of UserInviteDatasourceService for PostgreSQL
+type PostgresUserInviteDatasource struct {
+	SQLConn *sql.DB
+}
+
+func (rdb *PostgresUserInviteDatasource) List() ([]UserInvite, error) {
+	var invites []UserInvite
+	var ui UserInvite
+	var u User
+
+	rows, err := rdb.SQLConn.Query("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded," +
+		" u.id, u.username, u.email, u.display_name FROM user_invite as ui INNER JOIN users as u ON u.id = ui.created_by ORDER BY ui.username ASC")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		if err = rows.Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+			return nil, err
+		}
+		cb := u
+		ui.CreatedBy = &cb
+		invites = append(invites, ui)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+func (rdb *PostgresUserInviteDatasource) Get(inviteID int) (*UserInvite, error) {
+	var u User
+	var ui UserInvite
+
+	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded, "+
+		"u.id, u.username, u.email, u.display_name "+
+		"FROM user_invite as ui "+
+		"INNER JOIN users as u "+
+		"ON u.id = ui.created_by "+
+		"WHERE ui.id=$1 ", inviteID).
+		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		return nil, err
+	}
+
+	ui.CreatedBy = &u
+
+	return &ui, nil
+}
+
+func (rdb *PostgresUserInviteDatasource) GetByHash(hash string) (*UserInvite, error) {
+	var ui UserInvite
+	var u User
+
+	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded, "+
+		"u.id, u.username, u.email, u.display_name "+
+		"FROM user_invite as ui "+
+		"INNER JOIN users as u "+
+		"ON u.id = ui.created_by "+
+		"WHERE ui.hash=$1 ", hash).
+		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		return nil, err
+	}
+
+	ui.CreatedBy = &u
+
+	return &ui, nil
+}
+
+func (rdb *PostgresUserInviteDatasource) Update(ui *UserInvite) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user_invite SET hash=$1, username=$2, email=$3, display_name=$4, role=$5, created_at=$6, created_by=$7, reminded=$8 "+
+		"WHERE id=$9 ", ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.Role, ui.CreatedAt, ui.CreatedBy.ID, ui.Reminded, ui.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Create creates an new user invitation
+func (rdb *PostgresUserInviteDatasource) Create(ui *UserInvite) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO user_invite (hash, username, email, display_name, role, created_at, created_by) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.Role, time.Now(), ui.CreatedBy.ID).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// Count retuns the amount of users invitations
+func (rdb *PostgresUserInviteDatasource) Count() (int, error) {
+	var total int
+
+	if err := rdb.SQLConn.QueryRow("SELECT count(id) FROM user_invite").Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Remove removes an user invitation
+func (rdb *PostgresUserInviteDatasource) Remove(inviteID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM user_invite WHERE id=$1", inviteID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -16,7 +16,7 @@ func (rdb *SQLiteUserInviteDatasource) List() ([]UserInvite, error) {
 	var ui UserInvite
 	var u User
 
-	rows, err := rdb.SQLConn.Query("SELECT ui.id, ui.username, ui.email, ui.display_name, ui.created_at, ui.is_admin," +
+	rows, err := rdb.SQLConn.Query("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded," +
 		" u.id, u.username, u.email, u.display_name FROM user_invite as ui INNER JOIN user as u ON u.id = ui.created_by ORDER BY ui.username ASC")
 
 	if err != nil {
@@ -30,10 +30,11 @@ func (rdb *SQLiteUserInviteDatasource) List() ([]UserInvite, error) {
 	}()
 
 	for rows.Next() {
-		if err = rows.Scan(&ui.ID, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.IsAdmin, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		if err = rows.Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
 			return nil, err
 		}
-		ui.CreatedBy = &u
+		cb := u
+		ui.CreatedBy = &cb
 		invites = append(invites, ui)
 	}
 
@@ -48,13 +49,13 @@ func (rdb *SQLiteUserInviteDatasource) Get(inviteID int) (*UserInvite, error) {
 	var u User
 	var ui UserInvite
 
-	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.is_admin, "+
+	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded, "+
 		"u.id, u.username, u.email, u.display_name "+
 		"FROM user_invite as ui "+
 		"INNER JOIN user as u "+
 		"ON u.id = ui.created_by "+
 		"WHERE ui.id=? ", inviteID).
-		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.IsAdmin, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
 		return nil, err
 	}
 
@@ -67,13 +68,13 @@ func (rdb *SQLiteUserInviteDatasource) GetByHash(hash string) (*UserInvite, erro
 	var ui UserInvite
 	var u User
 
-	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.is_admin, "+
+	if err := rdb.SQLConn.QueryRow("SELECT ui.id, ui.hash, ui.username, ui.email, ui.display_name, ui.created_at, ui.role, ui.reminded, "+
 		"u.id, u.username, u.email, u.display_name "+
 		"FROM user_invite as ui "+
 		"INNER JOIN user as u "+
 		"ON u.id = ui.created_by "+
 		"WHERE ui.hash=? ", hash).
-		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.IsAdmin, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		Scan(&ui.ID, &ui.Hash, &ui.Username, &ui.Email, &ui.DisplayName, &ui.CreatedAt, &ui.Role, &ui.Reminded, &u.ID, &u.Username, &u.Email, &u.DisplayName); err != nil {
 		return nil, err
 	}
 
@@ -83,8 +84,8 @@ func (rdb *SQLiteUserInviteDatasource) GetByHash(hash string) (*UserInvite, erro
 }
 
 func (rdb *SQLiteUserInviteDatasource) Update(ui *UserInvite) error {
-	if _, err := rdb.SQLConn.Exec("UPDATE user_invite SET hash=?, username=?, email=?, display_name=?, is_admin=?, created_at=?, created_by=? "+
-		"WHERE id=? ", ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.IsAdmin, ui.CreatedBy.ID, ui.ID); err != nil {
+	if _, err := rdb.SQLConn.Exec("UPDATE user_invite SET hash=?, username=?, email=?, display_name=?, role=?, created_at=?, created_by=?, reminded=? "+
+		"WHERE id=? ", ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.Role, ui.CreatedAt, ui.CreatedBy.ID, ui.Reminded, ui.ID); err != nil {
 		return err
 	}
 
@@ -93,8 +94,8 @@ func (rdb *SQLiteUserInviteDatasource) Update(ui *UserInvite) error {
 
 // Create creates an new user invitation
 func (rdb *SQLiteUserInviteDatasource) Create(ui *UserInvite) (int, error) {
-	res, err := rdb.SQLConn.Exec("INSERT INTO user_invite (hash, username, email, display_name, is_admin, created_at, created_by) VALUES(?, ?, ?, ?, ?, ?, ?);",
-		ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.IsAdmin, time.Now(), ui.CreatedBy.ID)
+	res, err := rdb.SQLConn.Exec("INSERT INTO user_invite (hash, username, email, display_name, role, created_at, created_by) VALUES(?, ?, ?, ?, ?, ?, ?);",
+		ui.Hash, ui.Username, ui.Email, ui.DisplayName, ui.Role, time.Now(), ui.CreatedBy.ID)
 
 	if err != nil {
 		return -1, err
@@ -0,0 +1,318 @@
+package models
+
+import (
+	"database/sql"
+	"git.hoogi.eu/snafu/go-blog/database"
+	"git.hoogi.eu/snafu/go-blog/logger"
+	"strings"
+	"time"
+)
+
+// PostgresUserDatasource providing an implementation of UserDatasourceService for PostgreSQL
+type PostgresUserDatasource struct {
+	SQLConn *sql.DB
+}
+
+// List returns a list of users
+func (rdb *PostgresUserDatasource) List(p *Pagination) ([]User, error) {
+	var stmt strings.Builder
+	var args []interface{}
+	var users []User
+	var u User
+
+	stmt.WriteString("SELECT id, username, email, display_name, last_modified, active, role, totp_enabled, require_two_factor, last_login, last_login_ip " +
+		"FROM users ORDER BY username ASC ")
+
+	if p != nil {
+		stmt.WriteString("LIMIT ? OFFSET ? ")
+		args = append(args, p.Limit, p.Offset())
+	}
+
+	rows, err := rdb.SQLConn.Query(database.Rebind(stmt.String()), args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.TOTPEnabled, &u.RequireTwoFactor,
+			&u.LastLogin, &u.LastLoginIP); err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Get gets a user by his userID
+func (rdb *PostgresUserDatasource) Get(userID int) (*User, error) {
+	var u User
+
+	var socialLinks string
+
+	if err := rdb.SQLConn.QueryRow("SELECT u.id, u.username, u.email, u.display_name, u.last_modified, u.active, u.role,  u.salt, "+
+		"u.totp_secret, u.totp_enabled, u.require_two_factor, u.bio, u.website, u.avatar, u.social_links "+
+		"FROM users as u "+
+		"WHERE u.id=$1 ", userID).
+		Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.Salt,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor, &u.Bio, &u.Website, &u.Avatar, &socialLinks); err != nil {
+		return nil, err
+	}
+
+	u.SocialLinks = splitLines(socialLinks)
+
+	return &u, nil
+}
+
+// GetByMail gets a user by his mail, includes the password and salt
+func (rdb *PostgresUserDatasource) GetByMail(mail string) (*User, error) {
+	var u User
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, role, active, display_name, username, email, salt, password, "+
+		"totp_secret, totp_enabled, require_two_factor FROM users WHERE email=$1 ", mail).
+		Scan(&u.ID, &u.Role, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetByUsername gets a user by his username, includes the password and salt
+func (rdb *PostgresUserDatasource) GetByUsername(username string) (*User, error) {
+	var u User
+
+	var socialLinks string
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, role, active, display_name, username, email, salt, password, "+
+		"totp_secret, totp_enabled, require_two_factor, bio, website, avatar, social_links FROM users WHERE username=$1 ", username).
+		Scan(&u.ID, &u.Role, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor, &u.Bio, &u.Website, &u.Avatar, &socialLinks); err != nil {
+		return nil, err
+	}
+
+	u.SocialLinks = splitLines(socialLinks)
+
+	return &u, nil
+}
+
+// Create creates a new user
+func (rdb *PostgresUserDatasource) Create(u *User) (int, error) {
+	var i int
+
+	if err := rdb.SQLConn.QueryRow("INSERT INTO users (salt, password, username, email, display_name, last_modified, active, role, require_two_factor) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		u.Salt, u.Password, u.Username, u.Email, u.DisplayName, time.Now(), u.Active, u.Role, u.RequireTwoFactor).Scan(&i); err != nil {
+		return -1, err
+	}
+
+	return i, nil
+}
+
+// Update updates an user
+func (rdb *PostgresUserDatasource) Update(u *User, changePassword bool) error {
+	var stmt strings.Builder
+	var args []interface{}
+
+	stmt.WriteString("UPDATE users SET display_name=?, username=?, email=?, last_modified=?, active=?, role=?, require_two_factor=? ")
+	args = append(args, u.DisplayName, u.Username, u.Email, time.Now(), u.Active, u.Role, u.RequireTwoFactor)
+
+	if changePassword {
+		stmt.WriteString(", salt=?, password=? ")
+		args = append(args, u.Salt, u.Password)
+	}
+
+	stmt.WriteString("WHERE id=?;")
+
+	args = append(args, u.ID)
+
+	if _, err := rdb.SQLConn.Exec(database.Rebind(stmt.String()), args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateProfile updates the public profile of the user
+func (rdb *PostgresUserDatasource) UpdateProfile(u *User) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET bio=$1, website=$2, avatar=$3, social_links=$4, last_modified=$5 WHERE id=$6;",
+		u.Bio, u.Website, u.Avatar, strings.Join(u.SocialLinks, "\n"), time.Now(), u.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAccount removes the user in one transaction; if successorID is greater than zero the articles, sites, files
+// and categories are transferred to the successor before, otherwise the files of the user are removed
+func (rdb *PostgresUserDatasource) RemoveAccount(userID, successorID int) error {
+	tx, err := rdb.SQLConn.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var stmts []string
+	var args [][]interface{}
+
+	if successorID > 0 {
+		for _, table := range []string{"article", "site", "file", "category"} {
+			stmts = append(stmts, "UPDATE "+table+" SET user_id=$1 WHERE user_id=$2;")
+			args = append(args, []interface{}{successorID, userID})
+		}
+	} else {
+		stmts = append(stmts, "DELETE FROM file WHERE user_id=$1;")
+		args = append(args, []interface{}{userID})
+	}
+
+	stmts = append(stmts, "DELETE FROM users WHERE id=$1;")
+	args = append(args, []interface{}{userID})
+
+	for i, stmt := range stmts {
+		if _, err := tx.Exec(stmt, args[i]...); err != nil {
+			if err := tx.Rollback(); err != nil {
+				logger.Log.Error(err)
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordLogin sets the time and IP address of the last login and resets the dormancy warning
+func (rdb *PostgresUserDatasource) RecordLogin(userID int, ip string) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET last_login=$1, last_login_ip=$2, dormant_warned_at=NULL WHERE id=$3;", time.Now(), ip, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListDormant returns the active non-admin users neither logged in nor modified since idleSince
+func (rdb *PostgresUserDatasource) ListDormant(idleSince time.Time) ([]User, error) {
+	var users []User
+
+	rows, err := rdb.SQLConn.Query("SELECT id, username, email, display_name, last_modified, active, role, last_login, last_login_ip, dormant_warned_at "+
+		"FROM users WHERE active=$1 AND role<>$2 AND last_modified < $3 "+
+		"AND (last_login IS NULL OR last_login < $4) ORDER BY id ASC", true, RoleAdmin, idleSince, idleSince)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		var u User
+
+		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.LastLogin, &u.LastLoginIP, &u.DormantWarnedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateDormantWarned sets the time the user was warned about the upcoming deactivation
+func (rdb *PostgresUserDatasource) UpdateDormantWarned(userID int, warnedAt time.Time) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET dormant_warned_at=$1 WHERE id=$2;", warnedAt, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deactivate deactivates the user
+func (rdb *PostgresUserDatasource) Deactivate(userID int) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET active=$1, dormant_warned_at=NULL, last_modified=$2 WHERE id=$3;", false, time.Now(), userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateTOTP updates the TOTP secret and whether two-factor authentication is enabled
+func (rdb *PostgresUserDatasource) UpdateTOTP(userID int, secret string, enabled bool) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET totp_secret=$1, totp_enabled=$2, totp_last_counter=0, last_modified=$3 WHERE id=$4", secret, enabled, time.Now(), userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records the time step of a used TOTP code; false is returned if the time step or a later one
+// was used before
+func (rdb *PostgresUserDatasource) UseTOTPCounter(userID int, counter uint64) (bool, error) {
+	res, err := rdb.SQLConn.Exec("UPDATE users SET totp_last_counter=$1 WHERE id=$2 AND totp_last_counter < $1", int64(counter), userID)
+
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UpdatePassword replaces the password hash; the salt is cleared as it is part of the encoded hash
+func (rdb *PostgresUserDatasource) UpdatePassword(userID int, password []byte) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE users SET password=$1, salt='' WHERE id=$2", password, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Count returns the amount of users matches the AdminCriteria
+func (rdb *PostgresUserDatasource) Count(ac AdminCriteria) (int, error) {
+	var stmt strings.Builder
+	stmt.WriteString("SELECT count(id) FROM users ")
+
+	if ac == OnlyAdmins {
+		stmt.WriteString("WHERE role = 'admin'")
+	} else if ac == NoAdmins {
+		stmt.WriteString("WHERE role <> 'admin'")
+	}
+
+	var total int
+
+	if err := rdb.SQLConn.QueryRow(stmt.String()).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Remove removes an user
+func (rdb *PostgresUserDatasource) Remove(userID int) error {
+	if _, err := rdb.SQLConn.Exec("DELETE FROM users WHERE id=$1", userID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/session"
+)
+
+const maxUserAgentLength = 255
+
+// UserSession represents a session of a logged-in user
+// the ID is the hash of the session id, so it can be shown safely
+type UserSession struct {
+	ID           string
+	CreatedAt    time.Time
+	LastActivity time.Time
+	IP           string
+	UserAgent    string
+	Current      bool
+}
+
+// UserSessionService containing the service to list and revoke the sessions of users
+type UserSessionService struct {
+	SessionProvider session.SessionProvider
+}
+
+// Track saves the creation time, the time of the last activity, the ip address and the user agent in the session
+func (uss *UserSessionService) Track(s *Session, ip, userAgent string) {
+	now := time.Now().Unix()
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	values := map[string]interface{}{
+		"session_last_activity": now,
+		"session_ip":            ip,
+		"session_user_agent":    userAgent,
+	}
+
+	if _, ok := s.GetValue("session_created").(int64); !ok {
+		values["session_created"] = now
+	}
+
+	s.setValues(values)
+}
+
+// List returns all sessions of the user, the most recently used session first;
+// currentSID is the session id of the requesting session
+func (uss *UserSessionService) List(u *User, currentSID string) []UserSession {
+	sessions := uss.SessionProvider.FindByValue("userid", u.ID)
+
+	uses := make([]UserSession, 0, len(sessions))
+
+	for _, s := range sessions {
+		us := UserSession{
+			ID:           crypt.Hash([]byte(s.SessionID())),
+			CreatedAt:    s.LastAccessTime(),
+			LastActivity: s.LastAccessTime(),
+			Current:      s.SessionID() == currentSID,
+		}
+
+		if created, ok := s.GetValue("session_created").(int64); ok {
+			us.CreatedAt = time.Unix(created, 0)
+		}
+
+		if lastActivity, ok := s.GetValue("session_last_activity").(int64); ok {
+			us.LastActivity = time.Unix(lastActivity, 0)
+		}
+
+		us.IP, _ = s.GetValue("session_ip").(string)
+		us.UserAgent, _ = s.GetValue("session_user_agent").(string)
+
+		uses = append(uses, us)
+	}
+
+	sort.Slice(uses, func(i, j int) bool {
+		return uses[i].LastActivity.After(uses[j].LastActivity)
+	})
+
+	return uses
+}
+
+// Revoke signs out the session of the user; id is the hash of the session id
+func (uss *UserSessionService) Revoke(u *User, id string) error {
+	for _, s := range uss.SessionProvider.FindByValue("userid", u.ID) {
+		if crypt.Hash([]byte(s.SessionID())) == id {
+			uss.SessionProvider.Remove(s.SessionID())
+			return nil
+		}
+	}
+
+	return httperror.NotFound("session", fmt.Errorf("the session %s of user %d was not found", id, u.ID))
+}
+
+// RevokeAll signs out all sessions of the user except the session with the session id exceptSID
+func (uss *UserSessionService) RevokeAll(u *User, exceptSID string) {
+	for _, s := range uss.SessionProvider.FindByValue("userid", u.ID) {
+		if s.SessionID() != exceptSID {
+			uss.SessionProvider.Remove(s.SessionID())
+		}
+	}
+}
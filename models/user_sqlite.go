@@ -19,7 +19,8 @@ func (rdb *SQLiteUserDatasource) List(p *Pagination) ([]User, error) {
 	var users []User
 	var u User
 
-	stmt.WriteString("SELECT id, username, email, display_name, last_modified, active, is_admin FROM user ORDER BY username ASC ")
+	stmt.WriteString("SELECT id, username, email, display_name, last_modified, active, role, totp_enabled, require_two_factor, last_login, last_login_ip " +
+		"FROM user ORDER BY username ASC ")
 
 	if p != nil {
 		stmt.WriteString("LIMIT ? OFFSET ? ")
@@ -39,7 +40,8 @@ func (rdb *SQLiteUserDatasource) List(p *Pagination) ([]User, error) {
 	}()
 
 	for rows.Next() {
-		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.IsAdmin); err != nil {
+		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.TOTPEnabled, &u.RequireTwoFactor,
+			&u.LastLogin, &u.LastLoginIP); err != nil {
 			return nil, err
 		}
 
@@ -57,13 +59,19 @@ func (rdb *SQLiteUserDatasource) List(p *Pagination) ([]User, error) {
 func (rdb *SQLiteUserDatasource) Get(userID int) (*User, error) {
 	var u User
 
-	if err := rdb.SQLConn.QueryRow("SELECT u.id, u.username, u.email, u.display_name, u.last_modified, u.active, u.is_admin,  u.salt "+
+	var socialLinks string
+
+	if err := rdb.SQLConn.QueryRow("SELECT u.id, u.username, u.email, u.display_name, u.last_modified, u.active, u.role,  u.salt, "+
+		"u.totp_secret, u.totp_enabled, u.require_two_factor, u.bio, u.website, u.avatar, u.social_links "+
 		"FROM user as u "+
 		"WHERE u.id=? ", userID).
-		Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.IsAdmin, &u.Salt); err != nil {
+		Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.Salt,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor, &u.Bio, &u.Website, &u.Avatar, &socialLinks); err != nil {
 		return nil, err
 	}
 
+	u.SocialLinks = splitLines(socialLinks)
+
 	return &u, nil
 }
 
@@ -71,8 +79,10 @@ func (rdb *SQLiteUserDatasource) Get(userID int) (*User, error) {
 func (rdb *SQLiteUserDatasource) GetByMail(mail string) (*User, error) {
 	var u User
 
-	if err := rdb.SQLConn.QueryRow("SELECT id, is_admin, active, display_name, username, email, salt, password FROM user WHERE email=? ", mail).
-		Scan(&u.ID, &u.IsAdmin, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password); err != nil {
+	if err := rdb.SQLConn.QueryRow("SELECT id, role, active, display_name, username, email, salt, password, "+
+		"totp_secret, totp_enabled, require_two_factor FROM user WHERE email=? ", mail).
+		Scan(&u.ID, &u.Role, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor); err != nil {
 		return nil, err
 	}
 	return &u, nil
@@ -82,17 +92,24 @@ func (rdb *SQLiteUserDatasource) GetByMail(mail string) (*User, error) {
 func (rdb *SQLiteUserDatasource) GetByUsername(username string) (*User, error) {
 	var u User
 
-	if err := rdb.SQLConn.QueryRow("SELECT id, is_admin, active, display_name, username, email, salt, password FROM user WHERE username=? ", username).
-		Scan(&u.ID, &u.IsAdmin, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password); err != nil {
+	var socialLinks string
+
+	if err := rdb.SQLConn.QueryRow("SELECT id, role, active, display_name, username, email, salt, password, "+
+		"totp_secret, totp_enabled, require_two_factor, bio, website, avatar, social_links FROM user WHERE username=? ", username).
+		Scan(&u.ID, &u.Role, &u.Active, &u.DisplayName, &u.Username, &u.Email, &u.Salt, &u.Password,
+			&u.TOTPSecret, &u.TOTPEnabled, &u.RequireTwoFactor, &u.Bio, &u.Website, &u.Avatar, &socialLinks); err != nil {
 		return nil, err
 	}
+
+	u.SocialLinks = splitLines(socialLinks)
+
 	return &u, nil
 }
 
 // Create creates a new user
 func (rdb *SQLiteUserDatasource) Create(u *User) (int, error) {
-	res, err := rdb.SQLConn.Exec("INSERT INTO user (salt, password, username, email, display_name, last_modified, active, is_admin) VALUES(?, ?, ?, ?, ?, ?, ?, ?);",
-		u.Salt, u.Password, u.Username, u.Email, u.DisplayName, time.Now(), u.Active, u.IsAdmin)
+	res, err := rdb.SQLConn.Exec("INSERT INTO user (salt, password, username, email, display_name, last_modified, active, role, require_two_factor) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);",
+		u.Salt, u.Password, u.Username, u.Email, u.DisplayName, time.Now(), u.Active, u.Role, u.RequireTwoFactor)
 
 	if err != nil {
 		return -1, err
@@ -112,8 +129,8 @@ func (rdb *SQLiteUserDatasource) Update(u *User, changePassword bool) error {
 	var stmt strings.Builder
 	var args []interface{}
 
-	stmt.WriteString("UPDATE user SET display_name=?, username=?, email=?, last_modified=?, active=?, is_admin=? ")
-	args = append(args, u.DisplayName, u.Username, u.Email, time.Now(), u.Active, u.IsAdmin)
+	stmt.WriteString("UPDATE user SET display_name=?, username=?, email=?, last_modified=?, active=?, role=?, require_two_factor=? ")
+	args = append(args, u.DisplayName, u.Username, u.Email, time.Now(), u.Active, u.Role, u.RequireTwoFactor)
 
 	if changePassword {
 		stmt.WriteString(", salt=?, password=? ")
@@ -131,15 +148,168 @@ func (rdb *SQLiteUserDatasource) Update(u *User, changePassword bool) error {
 	return nil
 }
 
+// UpdateProfile updates the public profile of the user
+func (rdb *SQLiteUserDatasource) UpdateProfile(u *User) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET bio=?, website=?, avatar=?, social_links=?, last_modified=? WHERE id=?;",
+		u.Bio, u.Website, u.Avatar, strings.Join(u.SocialLinks, "\n"), time.Now(), u.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveAccount removes the user in one transaction; if successorID is greater than zero the articles, sites, files
+// and categories are transferred to the successor before, otherwise the files of the user are removed
+func (rdb *SQLiteUserDatasource) RemoveAccount(userID, successorID int) error {
+	tx, err := rdb.SQLConn.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var stmts []string
+	var args [][]interface{}
+
+	if successorID > 0 {
+		for _, table := range []string{"article", "site", "file", "category"} {
+			stmts = append(stmts, "UPDATE "+table+" SET user_id=? WHERE user_id=?;")
+			args = append(args, []interface{}{successorID, userID})
+		}
+	} else {
+		stmts = append(stmts, "DELETE FROM file WHERE user_id=?;")
+		args = append(args, []interface{}{userID})
+	}
+
+	stmts = append(stmts, "DELETE FROM user WHERE id=?;")
+	args = append(args, []interface{}{userID})
+
+	for i, stmt := range stmts {
+		if _, err := tx.Exec(stmt, args[i]...); err != nil {
+			if err := tx.Rollback(); err != nil {
+				logger.Log.Error(err)
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordLogin sets the time and IP address of the last login and resets the dormancy warning
+func (rdb *SQLiteUserDatasource) RecordLogin(userID int, ip string) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET last_login=?, last_login_ip=?, dormant_warned_at=NULL WHERE id=?;", time.Now(), ip, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListDormant returns the active non-admin users neither logged in nor modified since idleSince
+func (rdb *SQLiteUserDatasource) ListDormant(idleSince time.Time) ([]User, error) {
+	var users []User
+
+	rows, err := rdb.SQLConn.Query("SELECT id, username, email, display_name, last_modified, active, role, last_login, last_login_ip, dormant_warned_at "+
+		"FROM user WHERE active=? AND role<>? AND last_modified < ? "+
+		"AND (last_login IS NULL OR last_login < ?) ORDER BY id ASC", true, RoleAdmin, idleSince, idleSince)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Log.Error(err)
+		}
+	}()
+
+	for rows.Next() {
+		var u User
+
+		if err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.LastModified, &u.Active, &u.Role, &u.LastLogin, &u.LastLoginIP, &u.DormantWarnedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateDormantWarned sets the time the user was warned about the upcoming deactivation
+func (rdb *SQLiteUserDatasource) UpdateDormantWarned(userID int, warnedAt time.Time) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET dormant_warned_at=? WHERE id=?;", warnedAt, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deactivate deactivates the user
+func (rdb *SQLiteUserDatasource) Deactivate(userID int) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET active=?, dormant_warned_at=NULL, last_modified=? WHERE id=?;", false, time.Now(), userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// UpdateTOTP updates the TOTP secret and whether two-factor authentication is enabled
+func (rdb *SQLiteUserDatasource) UpdateTOTP(userID int, secret string, enabled bool) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET totp_secret=?, totp_enabled=?, totp_last_counter=0, last_modified=? WHERE id=?", secret, enabled, time.Now(), userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records the time step of a used TOTP code; false is returned if the time step or a later one
+// was used before
+func (rdb *SQLiteUserDatasource) UseTOTPCounter(userID int, counter uint64) (bool, error) {
+	res, err := rdb.SQLConn.Exec("UPDATE user SET totp_last_counter=? WHERE id=? AND totp_last_counter < ?", int64(counter), userID, int64(counter))
+
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UpdatePassword replaces the password hash; the salt is cleared as it is part of the encoded hash
+func (rdb *SQLiteUserDatasource) UpdatePassword(userID int, password []byte) error {
+	if _, err := rdb.SQLConn.Exec("UPDATE user SET password=?, salt='' WHERE id=?", password, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Count returns the amount of users matches the AdminCriteria
 func (rdb *SQLiteUserDatasource) Count(ac AdminCriteria) (int, error) {
 	var stmt strings.Builder
 	stmt.WriteString("SELECT count(id) FROM user ")
 
 	if ac == OnlyAdmins {
-		stmt.WriteString("WHERE is_admin = '1'")
+		stmt.WriteString("WHERE role = 'admin'")
 	} else if ac == NoAdmins {
-		stmt.WriteString("WHERE is_admin = '0'")
+		stmt.WriteString("WHERE role <> 'admin'")
 	}
 
 	var total int
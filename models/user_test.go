@@ -0,0 +1,170 @@
+package models_test
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"git.hoogi.eu/snafu/go-blog/httperror"
+	"git.hoogi.eu/snafu/go-blog/models"
+	"git.hoogi.eu/snafu/go-blog/settings"
+)
+
+func newUserService(db *sql.DB) *models.UserService {
+	return &models.UserService{
+		Datasource: &models.SQLiteUserDatasource{
+			SQLConn: db,
+		},
+		Config: settings.User{
+			MinPasswordLength: 12,
+		},
+	}
+}
+
+func createRoleUser(t *testing.T, us *models.UserService, username string, role models.Role) *models.User {
+	id, err := us.Create(&models.User{
+		Username:      username,
+		Email:         username + "@example.org",
+		DisplayName:   username,
+		Active:        true,
+		Role:          role,
+		PlainPassword: []byte("secret-password"),
+	}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := us.GetByID(id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestUserServiceUpdatePermission(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	us := newUserService(db)
+
+	alice := createRoleUser(t, us, "alice", models.RoleAdmin)
+	bob := createRoleUser(t, us, "bob", models.RoleAuthor)
+	carol := createRoleUser(t, us, "carol", models.RoleEditor)
+
+	isDenied := func(err error) bool {
+		var e *httperror.Error
+		return errors.As(err, &e) && e.HTTPStatus == http.StatusForbidden
+	}
+
+	// an editor or author can not update other users
+	for _, actor := range []*models.User{bob, carol} {
+		u, _ := us.GetByID(alice.ID)
+		u.DisplayName = "Mallory"
+
+		if err := us.Update(u, actor, false); !isDenied(err) {
+			t.Errorf("expected the %s %s to be denied updating another user, but got %v", actor.Role, actor.Username, err)
+		}
+	}
+
+	// nor change their own role
+	for _, actor := range []*models.User{bob, carol} {
+		u, _ := us.GetByID(actor.ID)
+		u.Role = models.RoleAdmin
+
+		if err := us.Update(u, actor, false); !isDenied(err) {
+			t.Errorf("expected the %s %s to be denied changing the own role, but got %v", actor.Role, actor.Username, err)
+		}
+	}
+
+	u, _ := us.GetByID(bob.ID)
+	u.DisplayName = "Bobby"
+
+	if err := us.Update(u, bob, false); err != nil {
+		t.Errorf("expected that an author can update the own user, but got %v", err)
+	}
+
+	u, _ = us.GetByID(bob.ID)
+	u.Role = models.RoleEditor
+
+	if err := us.Update(u, alice, false); err != nil {
+		t.Errorf("expected that an admin can change the role of another user, but got %v", err)
+	}
+
+	u, _ = us.GetByID(bob.ID)
+
+	if u.Role != models.RoleEditor || u.DisplayName != "Bobby" {
+		t.Errorf("expected the updated user, but got the role %s and the display name %s", u.Role, u.DisplayName)
+	}
+
+	u, _ = us.GetByID(alice.ID)
+
+	if u.DisplayName != "alice" || u.Role != models.RoleAdmin {
+		t.Errorf("expected the admin to be unchanged, but got the role %s and the display name %s", u.Role, u.DisplayName)
+	}
+}
+
+func TestUserServiceRemoveAccountRollback(t *testing.T) {
+	db := setupSessionDB(t)
+	defer db.Close()
+
+	us := newUserService(db)
+
+	alice := createRoleUser(t, us, "alice", models.RoleAdmin)
+	bob := createRoleUser(t, us, "bob", models.RoleAuthor)
+
+	as := &models.ArticleService{Datasource: &models.SQLiteArticleDatasource{SQLConn: db}}
+
+	articleID, err := as.Create(&models.Article{
+		Headline: "Hello",
+		Teaser:   "the teaser",
+		Content:  "the content",
+		Author:   bob,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the removal of the user fails after the content was transferred
+	if _, err := db.Exec("CREATE TRIGGER user_locked BEFORE DELETE ON user BEGIN SELECT RAISE(ABORT, 'locked'); END"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := us.RemoveAccount(bob, alice, bob); err == nil {
+		t.Fatal("expected an error while removing the locked user, but error is nil")
+	}
+
+	var userID int
+
+	if err := db.QueryRow("SELECT user_id FROM article WHERE id=?", articleID).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != bob.ID {
+		t.Errorf("expected the transfer of the content to be rolled back, but the article belongs to user %d", userID)
+	}
+
+	if _, err := db.Exec("DROP TRIGGER user_locked"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := us.RemoveAccount(bob, alice, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.QueryRow("SELECT user_id FROM article WHERE id=?", articleID).Scan(&userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != alice.ID {
+		t.Errorf("expected the article to belong to the successor, but got the user %d", userID)
+	}
+
+	if _, err := us.GetByID(bob.ID); err == nil {
+		t.Error("expected the removed user to be gone, but error is nil")
+	}
+}
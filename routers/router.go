@@ -10,6 +10,7 @@ import (
 
 	"git.hoogi.eu/snafu/go-blog/handler"
 	m "git.hoogi.eu/snafu/go-blog/middleware"
+	"git.hoogi.eu/snafu/go-blog/models"
 	"git.hoogi.eu/snafu/go-blog/settings"
 
 	"github.com/gorilla/csrf"
@@ -45,11 +46,18 @@ func InitRoutes(ctx *m.AppContext, cfg *settings.Settings) *mux.Router {
 
 	ar := router.PathPrefix("/admin").Subrouter()
 
-	restrictedChain := chain.Append(rf).Append(ctx.AuthHandler)
+	restrictedChain := chain.Append(ctx.TokenAuthHandler).Append(rf).Append(ctx.AuthHandler)
 
 	restrictedRoutes(ctx, ar, restrictedChain)
 
-	router.NotFoundHandler = chain.Then(useTemplateHandler(ctx, m.NotFound))
+	api := router.PathPrefix("/api/v1").Subrouter()
+
+	apiRoutes(ctx, api, chain.Append(ctx.APIAuthHandler))
+
+	router.NotFoundHandler = chain.Then(handler.RedirectHandler{
+		Context:  ctx,
+		NotFound: useTemplateHandler(ctx, m.NotFound),
+	})
 
 	router.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, cfg.Application.Favicon)
@@ -103,36 +111,66 @@ func restrictedRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain)
 	router.Handle("/article/{articleID}", chain.Then(useTemplateHandler(ctx, handler.AdminPreviewArticleByIDHandler))).Methods("GET")
 
 	// user
+	pdh := handler.PersonalDataHandler{
+		Context: ctx,
+	}
+
 	router.Handle("/user/profile", chain.Then(useTemplateHandler(ctx, handler.AdminProfileHandler))).Methods("GET")
 	router.Handle("/user/profile", chain.Then(useTemplateHandler(ctx, handler.AdminProfilePostHandler))).Methods("POST")
-	router.Handle("/users", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUsersHandler))).Methods("GET")
-	router.Handle("/users/page/{page}", chain.Then(useTemplateHandler(ctx, handler.AdminUsersHandler))).Methods("GET")
-	router.Handle("/user/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserNewHandler))).Methods("GET")
-	router.Handle("/user/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserNewPostHandler))).Methods("POST")
-	router.Handle("/user/edit/{userID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserEditHandler))).Methods("GET")
-	router.Handle("/user/edit/{userID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserEditPostHandler))).Methods("POST")
-	router.Handle("/user/delete/{userID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserDeleteHandler))).Methods("GET")
-	router.Handle("/user/delete/{userID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserDeletePostHandler))).Methods("POST")
+	router.Handle("/user/profile/public", chain.Then(useTemplateHandler(ctx, handler.AdminPublicProfilePostHandler))).Methods("POST")
+	router.Handle("/user/profile/export", chain.ThenFunc(pdh.ExportHandler)).Methods("GET")
+	router.Handle("/user/profile/delete", chain.Then(useTemplateHandler(ctx, handler.AdminAccountDeleteHandler))).Methods("GET")
+	router.Handle("/user/profile/delete", chain.Then(useTemplateHandler(ctx, handler.AdminAccountDeletePostHandler))).Methods("POST")
+	router.Handle("/users", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUsersHandler))).Methods("GET")
+	router.Handle("/users/page/{page}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUsersHandler))).Methods("GET")
+	router.Handle("/user/new", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserNewHandler))).Methods("GET")
+	router.Handle("/user/new", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserNewPostHandler))).Methods("POST")
+	router.Handle("/user/edit/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserEditHandler))).Methods("GET")
+	router.Handle("/user/edit/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserEditPostHandler))).Methods("POST")
+	router.Handle("/user/delete/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserDeleteHandler))).Methods("GET")
+	router.Handle("/user/delete/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserDeletePostHandler))).Methods("POST")
+
+	// two-factor authentication
+	router.Handle("/user/two-factor", chain.Then(useTemplateHandler(ctx, handler.AdminTwoFactorHandler))).Methods("GET")
+	router.Handle("/user/two-factor/enable", chain.Then(useTemplateHandler(ctx, handler.AdminTwoFactorEnablePostHandler))).Methods("POST")
+	router.Handle("/user/two-factor/disable", chain.Then(useTemplateHandler(ctx, handler.AdminTwoFactorDisablePostHandler))).Methods("POST")
+	router.Handle("/user/two-factor/recovery-codes", chain.Then(useTemplateHandler(ctx, handler.AdminTwoFactorRecoveryCodesPostHandler))).Methods("POST")
+	router.Handle("/user/unlock", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserUnlockPostHandler))).Methods("POST")
+	router.Handle("/user/two-factor/reset/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserTwoFactorResetHandler))).Methods("GET")
+	router.Handle("/user/two-factor/reset/{userID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserTwoFactorResetPostHandler))).Methods("POST")
+
+	// sessions
+	router.Handle("/user/session/revoke/{sessionID}", chain.Then(useTemplateHandler(ctx, handler.AdminSessionRevokePostHandler))).Methods("POST")
+	router.Handle("/user/session/revoke-others", chain.Then(useTemplateHandler(ctx, handler.AdminSessionRevokeOthersPostHandler))).Methods("POST")
+
+	// passkeys
+	router.Handle("/user/passkey/delete/{credentialID}", chain.Then(useTemplateHandler(ctx, handler.AdminPasskeyDeleteHandler))).Methods("GET")
+	router.Handle("/user/passkey/delete/{credentialID}", chain.Then(useTemplateHandler(ctx, handler.AdminPasskeyDeletePostHandler))).Methods("POST")
+
+	// personal access tokens
+	router.Handle("/user/access-token/new", chain.Then(useTemplateHandler(ctx, handler.AdminAccessTokenNewPostHandler))).Methods("POST")
+	router.Handle("/user/access-token/delete/{tokenID}", chain.Then(useTemplateHandler(ctx, handler.AdminAccessTokenDeleteHandler))).Methods("GET")
+	router.Handle("/user/access-token/delete/{tokenID}", chain.Then(useTemplateHandler(ctx, handler.AdminAccessTokenDeletePostHandler))).Methods("POST")
 
 	// user invites
-	router.Handle("/user-invite/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserInviteNewHandler))).Methods("GET")
-	router.Handle("/user-invite/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserInviteNewPostHandler))).Methods("POST")
-	router.Handle("/user-invite/resend/{inviteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserInviteResendPostHandler))).Methods("POST")
-	router.Handle("/user-invite/delete/{inviteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserInviteDeleteHandler))).Methods("GET")
-	router.Handle("/user-invite/delete/{inviteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminUserInviteDeletePostHandler))).Methods("POST")
+	router.Handle("/user-invite/new", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserInviteNewHandler))).Methods("GET")
+	router.Handle("/user-invite/new", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserInviteNewPostHandler))).Methods("POST")
+	router.Handle("/user-invite/resend/{inviteID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserInviteResendPostHandler))).Methods("POST")
+	router.Handle("/user-invite/delete/{inviteID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserInviteDeleteHandler))).Methods("GET")
+	router.Handle("/user-invite/delete/{inviteID}", chain.Append(ctx.RequirePermission(models.PermUserManage)).Then(useTemplateHandler(ctx, handler.AdminUserInviteDeletePostHandler))).Methods("POST")
 
 	// site
-	router.Handle("/sites", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSitesHandler))).Methods("GET")
-	router.Handle("/site/page/{page}", chain.Then(useTemplateHandler(ctx, handler.AdminSitesHandler))).Methods("GET")
-	router.Handle("/site/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteNewHandler))).Methods("GET")
-	router.Handle("/site/new", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteNewPostHandler))).Methods("POST")
-	router.Handle("/site/publish/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSitePublishHandler))).Methods("GET")
-	router.Handle("/site/publish/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSitePublishPostHandler))).Methods("POST")
-	router.Handle("/site/edit/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteEditHandler))).Methods("GET")
-	router.Handle("/site/edit/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteEditPostHandler))).Methods("POST")
-	router.Handle("/site/delete/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteDeleteHandler))).Methods("GET")
-	router.Handle("/site/delete/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteDeletePostHandler))).Methods("POST")
-	router.Handle("/site/order/{siteID}", chain.Append(ctx.RequireAdmin).Then(useTemplateHandler(ctx, handler.AdminSiteOrderHandler))).Methods("POST")
+	router.Handle("/sites", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSitesHandler))).Methods("GET")
+	router.Handle("/site/page/{page}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSitesHandler))).Methods("GET")
+	router.Handle("/site/new", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteNewHandler))).Methods("GET")
+	router.Handle("/site/new", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteNewPostHandler))).Methods("POST")
+	router.Handle("/site/publish/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSitePublishHandler))).Methods("GET")
+	router.Handle("/site/publish/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSitePublishPostHandler))).Methods("POST")
+	router.Handle("/site/edit/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteEditHandler))).Methods("GET")
+	router.Handle("/site/edit/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteEditPostHandler))).Methods("POST")
+	router.Handle("/site/delete/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteDeleteHandler))).Methods("GET")
+	router.Handle("/site/delete/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteDeletePostHandler))).Methods("POST")
+	router.Handle("/site/order/{siteID}", chain.Append(ctx.RequirePermission(models.PermSiteManage)).Then(useTemplateHandler(ctx, handler.AdminSiteOrderHandler))).Methods("POST")
 	router.Handle("/site/{siteID:[0-9]+}}", chain.Then(useTemplateHandler(ctx, handler.AdminGetSiteHandler))).Methods("GET")
 
 	// article
@@ -154,10 +192,53 @@ func restrictedRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain)
 	router.Handle("/file/delete/{fileID}", chain.Then(useTemplateHandler(ctx, handler.AdminUploadDeleteHandler))).Methods("GET")
 	router.Handle("/file/delete/{fileID}", chain.Then(useTemplateHandler(ctx, handler.AdminUploadDeletePostHandler))).Methods("POST")
 
+	// audit
+	ah := handler.AuditHandler{
+		Context: ctx,
+	}
+
+	router.Handle("/audit", chain.Append(ctx.RequirePermission(models.PermAuditView)).Then(useTemplateHandler(ctx, handler.AdminAuditHandler))).Methods("GET")
+	router.Handle("/audit/page/{page}", chain.Append(ctx.RequirePermission(models.PermAuditView)).Then(useTemplateHandler(ctx, handler.AdminAuditHandler))).Methods("GET")
+	router.Handle("/audit/export", chain.Append(ctx.RequirePermission(models.PermAuditView)).ThenFunc(ah.ExportHandler)).Methods("GET")
+
 	router.Handle("/logout", chain.Then(useTemplateHandler(ctx, handler.LogoutHandler))).Methods("GET")
 
 	router.Handle("/json/session/keep-alive", chain.Then(useJSONHandler(ctx, handler.KeepAliveSessionHandler))).Methods("POST")
 	router.Handle("/json/file/upload", chain.Then(useJSONHandler(ctx, handler.AdminUploadJSONFilePostHandler))).Methods("POST")
+	router.Handle("/json/passkey/register/begin", chain.Then(useJSONHandler(ctx, handler.AdminPasskeyRegisterBeginHandler))).Methods("POST")
+	router.Handle("/json/passkey/register/finish", chain.Then(useJSONHandler(ctx, handler.AdminPasskeyRegisterFinishHandler))).Methods("POST")
+}
+
+func apiRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
+	// article
+	router.Handle("/articles", chain.Then(useJSONHandler(ctx, handler.APIListArticlesHandler))).Methods("GET")
+	router.Handle("/articles", chain.Then(useJSONHandler(ctx, handler.APIArticleNewHandler))).Methods("POST")
+	router.Handle("/articles/{articleID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIGetArticleHandler))).Methods("GET")
+	router.Handle("/articles/{articleID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIArticleEditHandler))).Methods("PUT")
+	router.Handle("/articles/{articleID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIArticleDeleteHandler))).Methods("DELETE")
+	router.Handle("/articles/{articleID:[0-9]+}/publish", chain.Then(useJSONHandler(ctx, handler.APIArticlePublishHandler))).Methods("POST")
+
+	// category
+	router.Handle("/categories", chain.Then(useJSONHandler(ctx, handler.APIListCategoriesHandler))).Methods("GET")
+	router.Handle("/categories", chain.Then(useJSONHandler(ctx, handler.APICategoryNewHandler))).Methods("POST")
+	router.Handle("/categories/{categoryID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIGetCategoryHandler))).Methods("GET")
+	router.Handle("/categories/{categoryID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APICategoryEditHandler))).Methods("PUT")
+	router.Handle("/categories/{categoryID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APICategoryDeleteHandler))).Methods("DELETE")
+
+	// site
+	router.Handle("/sites", chain.Then(useJSONHandler(ctx, handler.APIListSitesHandler))).Methods("GET")
+	router.Handle("/sites", chain.Then(useJSONHandler(ctx, handler.APISiteNewHandler))).Methods("POST")
+	router.Handle("/sites/{siteID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIGetSiteHandler))).Methods("GET")
+	router.Handle("/sites/{siteID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APISiteEditHandler))).Methods("PUT")
+	router.Handle("/sites/{siteID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APISiteDeleteHandler))).Methods("DELETE")
+	router.Handle("/sites/{siteID:[0-9]+}/publish", chain.Then(useJSONHandler(ctx, handler.APISitePublishHandler))).Methods("POST")
+
+	// file
+	router.Handle("/files", chain.Then(useJSONHandler(ctx, handler.APIListFilesHandler))).Methods("GET")
+	router.Handle("/files", chain.Then(useJSONHandler(ctx, handler.APIFileNewHandler))).Methods("POST")
+	router.Handle("/files/{fileID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIGetFileHandler))).Methods("GET")
+	router.Handle("/files/{fileID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIFileEditHandler))).Methods("PUT")
+	router.Handle("/files/{fileID:[0-9]+}", chain.Then(useJSONHandler(ctx, handler.APIFileDeleteHandler))).Methods("DELETE")
 }
 
 func publicRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
@@ -165,6 +246,10 @@ func publicRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
 		Context: ctx,
 	}
 
+	oh := handler.OIDCHandler{
+		Context: ctx,
+	}
+
 	router.Handle("/", chain.Then(useTemplateHandler(ctx, handler.ListArticlesHandler))).Methods("GET")
 	router.Handle("/articles/category/{categorySlug}", chain.Then(useTemplateHandler(ctx, handler.ListArticlesCategoryHandler))).Methods("GET")
 	router.Handle("/articles/category/{categorySlug}/{page}", chain.Then(useTemplateHandler(ctx, handler.ListArticlesCategoryHandler))).Methods("GET")
@@ -175,6 +260,9 @@ func publicRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
 	router.Handle("/article/{year}/{month}/{slug}", chain.Then(useTemplateHandler(ctx, handler.GetArticleHandler))).Methods("GET")
 	router.Handle("/article/by-id/{articleID}", chain.Then(useTemplateHandler(ctx, handler.GetArticleByIDHandler))).Methods("GET")
 
+	router.Handle("/author/{username}", chain.Then(useTemplateHandler(ctx, handler.AuthorHandler))).Methods("GET")
+	router.Handle("/author/{username}/page/{page}", chain.Then(useTemplateHandler(ctx, handler.AuthorHandler))).Methods("GET")
+
 	router.Handle("/rss.xml", chain.Then(useXMLHandler(ctx, handler.RSSFeed))).Methods("GET")
 
 	router.Handle("/site/{site}", chain.Then(useTemplateHandler(ctx, handler.GetSiteHandler))).Methods("GET")
@@ -183,6 +271,14 @@ func publicRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
 
 	router.Handle("/admin", chain.Then(useTemplateHandler(ctx, handler.LoginHandler))).Methods("GET")
 	router.Handle("/admin", chain.Then(useTemplateHandler(ctx, handler.LoginPostHandler))).Methods("POST")
+	router.Handle("/admin/login/two-factor", chain.Then(useTemplateHandler(ctx, handler.TwoFactorLoginHandler))).Methods("GET")
+	router.Handle("/admin/login/two-factor", chain.Then(useTemplateHandler(ctx, handler.TwoFactorLoginPostHandler))).Methods("POST")
+	router.Handle("/admin/login/oidc", chain.ThenFunc(oh.LoginHandler)).Methods("GET")
+	router.Handle("/admin/login/magic-link", chain.Then(useTemplateHandler(ctx, handler.MagicLinkPostHandler))).Methods("POST")
+	router.Handle("/admin/login/magic-link/{hash}", chain.Then(useTemplateHandler(ctx, handler.MagicLinkHandler))).Methods("GET")
+	router.Handle(models.OIDCCallbackPath, chain.Then(useTemplateHandler(ctx, handler.OIDCCallbackHandler))).Methods("GET")
+	router.Handle("/admin/json/passkey/login/begin", chain.Then(useJSONHandler(ctx, handler.PasskeyLoginBeginHandler))).Methods("POST")
+	router.Handle("/admin/json/passkey/login/finish", chain.Then(useJSONHandler(ctx, handler.PasskeyLoginFinishHandler))).Methods("POST")
 
 	router.Handle("/admin/forgot-password", chain.Then(useTemplateHandler(ctx, handler.ForgotPasswordHandler))).Methods("GET")
 	router.Handle("/admin/forgot-password", chain.Then(useTemplateHandler(ctx, handler.ForgotPasswordPostHandler))).Methods("POST")
@@ -192,6 +288,15 @@ func publicRoutes(ctx *m.AppContext, router *mux.Router, chain alice.Chain) {
 
 	router.Handle("/admin/activate-account/{hash}", chain.Then(useTemplateHandler(ctx, handler.ActivateAccountHandler))).Methods("GET")
 	router.Handle("/admin/activate-account/{hash}", chain.Then(useTemplateHandler(ctx, handler.ActivateAccountPostHandler))).Methods("POST")
+
+	router.Handle("/admin/confirm-email/{hash}", chain.Then(useTemplateHandler(ctx, handler.ConfirmEmailHandler))).Methods("GET")
+	router.Handle("/admin/confirm-email/{hash}", chain.Then(useTemplateHandler(ctx, handler.ConfirmEmailPostHandler))).Methods("POST")
+
+	router.Handle("/admin/revert-email/{hash}", chain.Then(useTemplateHandler(ctx, handler.RevertEmailHandler))).Methods("GET")
+	router.Handle("/admin/revert-email/{hash}", chain.Then(useTemplateHandler(ctx, handler.RevertEmailPostHandler))).Methods("POST")
+
+	router.Handle("/admin/secure-account/{hash}", chain.Then(useTemplateHandler(ctx, handler.SecureAccountHandler))).Methods("GET")
+	router.Handle("/admin/secure-account/{hash}", chain.Then(useTemplateHandler(ctx, handler.SecureAccountPostHandler))).Methods("POST")
 }
 
 func useTemplateHandler(ctx *m.AppContext, handler m.Handler) m.TemplateHandler {
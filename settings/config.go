@@ -37,6 +37,24 @@ func (lm *LoginMethod) Unmarshal(value string) error {
 	return fmt.Errorf("unexpected config value for login method %s", value)
 }
 
+type SessionProvider int
+
+const (
+	InMemory = iota
+	InDatabase
+)
+
+func (sp *SessionProvider) Unmarshal(value string) error {
+	if strings.ToLower(value) == "memory" {
+		*sp = SessionProvider(InMemory)
+		return nil
+	} else if strings.ToLower(value) == "sqlite" || strings.ToLower(value) == "database" {
+		*sp = SessionProvider(InDatabase)
+		return nil
+	}
+	return fmt.Errorf("unexpected config value for session provider %s", value)
+}
+
 type AllowedFileExts map[string]string
 
 func (afe *AllowedFileExts) Unmarshal(value string) error {
@@ -72,10 +90,13 @@ type Settings struct {
 	File
 	Server
 	Database
+	Backup
 	Mail
 	Session
 	CSRF
 	Log
+	OIDC
+	LDAP
 }
 
 type Server struct {
@@ -99,7 +120,15 @@ type Application struct {
 }
 
 type Database struct {
-	File string `cfg:"sqlite_file" default:"data/goblog.sqlite"`
+	Driver      string `cfg:"database_driver" default:"sqlite"`
+	File        string `cfg:"sqlite_file" default:"data/goblog.sqlite"`
+	PostgresDSN string `cfg:"postgres_dsn"`
+}
+
+type Backup struct {
+	Directory string        `cfg:"backup_directory" default:"data/backups"`
+	Interval  time.Duration `cfg:"backup_interval" default:"0"`
+	Keep      int           `cfg:"backup_keep" default:"7"`
 }
 
 type File struct {
@@ -114,9 +143,21 @@ type Blog struct {
 }
 
 type User struct {
-	MinPasswordLength int         `cfg:"user_min_password_length" default:"12"`
-	InterceptorPlugin string      `cfg:"user_interceptor_plugin"`
-	LoginMethod       LoginMethod `cfg:"user_login_method" default:"username"`
+	MinPasswordLength          int           `cfg:"user_min_password_length" default:"12"`
+	InterceptorPlugin          string        `cfg:"user_interceptor_plugin"`
+	LoginMethod                LoginMethod   `cfg:"user_login_method" default:"username"`
+	TwoFactorRequiredForAdmins bool          `cfg:"user_two_factor_required_for_admins" default:"false"`
+	PasswordHashMemory         int           `cfg:"user_password_hash_memory" default:"65536"`
+	PasswordHashIterations     int           `cfg:"user_password_hash_iterations" default:"3"`
+	PasswordHashParallelism    int           `cfg:"user_password_hash_parallelism" default:"2"`
+	LoginMaxFailures           int           `cfg:"user_login_max_failures" default:"10"`
+	LoginLockoutDuration       time.Duration `cfg:"user_login_lockout_duration" default:"30m"`
+	LoginBackoffMax            time.Duration `cfg:"user_login_backoff_max" default:"5m"`
+	InviteLifetime             time.Duration `cfg:"user_invite_lifetime" default:"168h"`
+	InviteReminder             time.Duration `cfg:"user_invite_reminder" default:"24h"`
+	MagicLinkLogin             bool          `cfg:"user_magic_link_login" default:"false"`
+	DormantAfter               time.Duration `cfg:"user_dormant_after" default:"0"`
+	DormantWarning             time.Duration `cfg:"user_dormant_warning" default:"336h"`
 }
 
 type Mail struct {
@@ -130,11 +171,12 @@ type Mail struct {
 }
 
 type Session struct {
-	TTL               time.Duration `cfg:"session_time_to_live" default:"2h"`
-	GarbageCollection time.Duration `cfg:"session_garbage_collection" default:"5m"`
-	CookieName        string        `cfg:"session_cookie_name" default:"goblog"`
-	CookieSecure      bool          `cfg:"session_cookie_secure" default:"true"`
-	CookiePath        string        `cfg:"session_cookie_path" default:"/admin"`
+	Provider          SessionProvider `cfg:"session_provider" default:"memory"`
+	TTL               time.Duration   `cfg:"session_time_to_live" default:"2h"`
+	GarbageCollection time.Duration   `cfg:"session_garbage_collection" default:"5m"`
+	CookieName        string          `cfg:"session_cookie_name" default:"goblog"`
+	CookieSecure      bool            `cfg:"session_cookie_secure" default:"true"`
+	CookiePath        string          `cfg:"session_cookie_path" default:"/admin"`
 }
 
 type CSRF struct {
@@ -144,6 +186,37 @@ type CSRF struct {
 	RandomKey    string `cfg:"csrf_random_key"`
 }
 
+type OIDC struct {
+	Enabled              bool   `cfg:"oidc_enabled" default:"false"`
+	ProviderName         string `cfg:"oidc_provider_name" default:"single sign-on"`
+	Issuer               string `cfg:"oidc_issuer"`
+	ClientID             string `cfg:"oidc_client_id"`
+	ClientSecret         string `cfg:"oidc_client_secret"`
+	RedirectURL          string `cfg:"oidc_redirect_url"`
+	AutoProvision        bool   `cfg:"oidc_auto_provision" default:"false"`
+	DefaultRole          string `cfg:"oidc_default_role" default:"author"`
+	DisablePasswordLogin bool   `cfg:"oidc_disable_password_login" default:"false"`
+	TrustUnverifiedEmail bool   `cfg:"oidc_trust_unverified_email" default:"false"`
+}
+
+// PasswordLoginEnabled returns false if the login with local passwords is disabled in favor of the single sign-on
+func (o OIDC) PasswordLoginEnabled() bool {
+	return !(o.Enabled && o.DisablePasswordLogin)
+}
+
+type LDAP struct {
+	Enabled              bool   `cfg:"ldap_enabled" default:"false"`
+	URL                  string `cfg:"ldap_url"`
+	BindDN               string `cfg:"ldap_bind_dn"`
+	StartTLS             bool   `cfg:"ldap_start_tls" default:"false"`
+	CAFile               string `cfg:"ldap_ca_file"`
+	MailAttribute        string `cfg:"ldap_mail_attribute" default:"mail"`
+	DisplayNameAttribute string `cfg:"ldap_display_name_attribute" default:"displayName"`
+	GroupAttribute       string `cfg:"ldap_group_attribute" default:"memberOf"`
+	AdminGroup           string `cfg:"ldap_admin_group"`
+	DefaultRole          string `cfg:"ldap_default_role" default:"author"`
+}
+
 type Log struct {
 	Level      string `cfg:"log_level" default:"info"`
 	File       string `cfg:"log_file" default:"/var/log/goblog/error.log"`
@@ -193,6 +266,24 @@ func (cfg *Settings) CheckConfig() error {
 		}
 	}
 
+	switch cfg.Database.Driver {
+	case "sqlite":
+	case "postgres":
+		if len(cfg.Database.PostgresDSN) == 0 {
+			return errors.New("config 'postgres_dsn': please specify the connection string of the postgres database")
+		}
+	default:
+		return fmt.Errorf("config 'database_driver': unexpected database driver '%s', possible values are 'sqlite' and 'postgres'", cfg.Database.Driver)
+	}
+
+	if cfg.Backup.Interval > 0 && cfg.Database.Driver != "sqlite" {
+		return errors.New("config 'backup_interval': the scheduled backup is only supported for the sqlite database")
+	}
+
+	if cfg.Backup.Keep < 0 {
+		return errors.New("config 'backup_keep': the number of backups to keep must not be negative")
+	}
+
 	if len(cfg.Application.Domain) == 0 {
 		return errors.New("config 'application_domain': please specify a domain name")
 	}
@@ -276,6 +367,68 @@ func (cfg *Settings) CheckConfig() error {
 		return fmt.Errorf("config: could not open file path %s error %v", cfg.File.Location, err)
 	}
 
+	if cfg.User.PasswordHashMemory < 8*cfg.User.PasswordHashParallelism {
+		return fmt.Errorf("config 'user_password_hash_memory': the memory must be at least 8 KiB per thread, but got %d", cfg.User.PasswordHashMemory)
+	}
+
+	if cfg.User.PasswordHashIterations < 1 {
+		return fmt.Errorf("config 'user_password_hash_iterations': invalid number of iterations %d", cfg.User.PasswordHashIterations)
+	}
+
+	if cfg.User.PasswordHashParallelism < 1 || cfg.User.PasswordHashParallelism > 255 {
+		return fmt.Errorf("config 'user_password_hash_parallelism': the parallelism must be between 1 and 255, but got %d", cfg.User.PasswordHashParallelism)
+	}
+
+	if cfg.User.InviteLifetime < 0 || cfg.User.InviteReminder < 0 {
+		return fmt.Errorf("config 'user_invite_lifetime', 'user_invite_reminder': invalid durations %s, %s", cfg.User.InviteLifetime, cfg.User.InviteReminder)
+	}
+
+	if cfg.User.DormantAfter < 0 || cfg.User.DormantWarning < 0 {
+		return fmt.Errorf("config 'user_dormant_after', 'user_dormant_warning': invalid durations %s, %s", cfg.User.DormantAfter, cfg.User.DormantWarning)
+	}
+
+	if cfg.User.LoginMaxFailures < 0 {
+		return fmt.Errorf("config 'user_login_max_failures': invalid number of failures %d", cfg.User.LoginMaxFailures)
+	}
+
+	if cfg.User.LoginMaxFailures > 0 && cfg.User.LoginLockoutDuration <= 0 {
+		return fmt.Errorf("config 'user_login_lockout_duration': invalid duration %s", cfg.User.LoginLockoutDuration)
+	}
+
+	if cfg.OIDC.Enabled {
+		if _, err := url.ParseRequestURI(cfg.OIDC.Issuer); err != nil {
+			return fmt.Errorf("config 'oidc_issuer': invalid url setting for key 'oidc_issuer' value '%s'", cfg.OIDC.Issuer)
+		}
+
+		if len(cfg.OIDC.ClientID) == 0 {
+			return errors.New("config 'oidc_client_id': please specify the client id registered at the identity provider")
+		}
+	} else if cfg.OIDC.DisablePasswordLogin {
+		return errors.New("config 'oidc_disable_password_login': the password login can only be disabled if 'oidc_enabled' is set")
+	}
+
+	if cfg.LDAP.Enabled {
+		u, err := url.Parse(cfg.LDAP.URL)
+
+		if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || len(u.Host) == 0 {
+			return fmt.Errorf("config 'ldap_url': invalid url setting for key 'ldap_url' value '%s'", cfg.LDAP.URL)
+		}
+
+		if cfg.LDAP.StartTLS && u.Scheme == "ldaps" {
+			return errors.New("config 'ldap_start_tls': StartTLS can only be used with the scheme ldap://")
+		}
+
+		if !strings.Contains(cfg.LDAP.BindDN, "{username}") {
+			return fmt.Errorf("config 'ldap_bind_dn': the bind dn '%s' must contain the placeholder {username}", cfg.LDAP.BindDN)
+		}
+
+		if len(cfg.LDAP.CAFile) > 0 {
+			if _, err := os.Open(cfg.LDAP.CAFile); err != nil {
+				return fmt.Errorf("config: could not open the ldap ca file %s error %v", cfg.LDAP.CAFile, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,113 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package totp implements time-based one-time passwords as specified in RFC 6238
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/crypt"
+)
+
+const (
+	// Digits the number of digits of a generated code
+	Digits = 6
+	// Period the time step in seconds a code is valid
+	Period = 30
+	// Skew the number of time steps before and after the current one which are accepted
+	Skew = 1
+
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded secret
+func GenerateSecret() string {
+	return encoding.EncodeToString(crypt.RandomSecureKey(secretSize))
+}
+
+// Code returns the code for the secret at the given time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, uint64(t.Unix())/Period), nil
+}
+
+// Validate returns the time step of the code and true if the code matches the secret at the given time;
+// codes of the adjacent time steps defined by Skew are accepted as well. The time step has to be remembered
+// by the caller to reject the code if it is used again
+func Validate(secret, passcode string, t time.Time) (uint64, bool) {
+	passcode = strings.Replace(strings.TrimSpace(passcode), " ", "", -1)
+
+	if len(passcode) != Digits {
+		return 0, false
+	}
+
+	key, err := decodeSecret(secret)
+
+	if err != nil {
+		return 0, false
+	}
+
+	counter := uint64(t.Unix()) / Period
+
+	for i := -Skew; i <= Skew; i++ {
+		step := uint64(int64(counter) + int64(i))
+
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(passcode)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth URI which is understood by authenticator apps
+func ProvisioningURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(Period))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+
+	return encoding.DecodeString(secret)
+}
+
+func code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, bin%1000000)
+}
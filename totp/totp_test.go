@@ -0,0 +1,74 @@
+// Copyright 2018 Lars Hoogestraat
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package totp_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"git.hoogi.eu/snafu/go-blog/totp"
+)
+
+// rfcSecret is the SHA1 secret used in the test vectors of RFC 6238
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	testcases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range testcases {
+		actual, err := totp.Code(rfcSecret, time.Unix(tc.unix, 0))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != tc.code {
+			t.Errorf("Got: '%s'; want '%s' at %d", actual, tc.code, tc.unix)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := totp.GenerateSecret()
+	now := time.Now()
+
+	c, err := totp.Code(secret, now)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, ok := totp.Validate(secret, c, now)
+
+	if !ok {
+		t.Error("expected a valid code for the current time step")
+	}
+
+	if counter != uint64(now.Unix())/totp.Period {
+		t.Errorf("expected the current time step %d, but got %d", uint64(now.Unix())/totp.Period, counter)
+	}
+
+	if next, ok := totp.Validate(secret, c, now.Add(totp.Period*time.Second)); !ok || next != counter {
+		t.Error("expected a valid code of the same time step for the next time step")
+	}
+
+	if _, ok := totp.Validate(secret, c, now.Add(3*totp.Period*time.Second)); ok {
+		t.Error("expected an invalid code three time steps later")
+	}
+
+	if _, ok := totp.Validate(secret, "12345", now); ok {
+		t.Error("expected an invalid code for a code with the wrong length")
+	}
+}